@@ -2,9 +2,12 @@ package api
 
 import (
 	ctx "context"
+	"crypto/rand"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/kataras/iris"
 	"github.com/microcosm-cc/bluemonday"
@@ -16,14 +19,63 @@ import (
 type API struct {
 	db  db.BoilingDB
 	app *iris.Application
+	cfg Config
+
+	// stopping is cancelled when the API is stopped, which cancels the
+	// database queries of all in-flight requests.
+	stopping ctx.Context
+	stop     ctx.CancelFunc
 
 	c Cache
+
+	// cursorKey signs the cursors handed out for paginating lists.
+	cursorKey []byte
+}
+
+// Config holds the configuration of the API.
+type Config struct {
+	// AnnounceBase is the base URL of the tracker, for example
+	// https://tracker.boiling.rip:34000.
+	// Announce URLs are constructed as <AnnounceBase>/<passkey>/announce.
+	AnnounceBase string
+
+	// TrackerSecret is the secret shared with the tracker to authenticate
+	// requests to the internal tracker API.
+	// If it is empty, the internal tracker API is disabled.
+	TrackerSecret string
+
+	// QueryTimeout limits the time a single request may spend on database
+	// queries.
+	// If it is zero, queries are only cancelled if the client disconnects or
+	// the API is stopped.
+	QueryTimeout time.Duration
+
+	// InviteOnly requires users to sign up with an invite.
+	InviteOnly bool
+
+	// CursorSecret is used to sign pagination cursors.
+	// If it is empty, a random secret is generated at startup, which
+	// invalidates all cursors handed out before a restart.
+	CursorSecret string
 }
 
-func New(db db.BoilingDB) (*API, error) {
-	a := &API{db: db}
+func New(db db.BoilingDB, cfg Config) (*API, error) {
+	a := &API{db: db, cfg: cfg}
+	a.stopping, a.stop = ctx.WithCancel(ctx.Background())
+
+	if len(cfg.CursorSecret) != 0 {
+		a.cursorKey = []byte(cfg.CursorSecret)
+	} else {
+		log.Warnln("no cursor secret configured, cursors will be invalid after a restart")
+		a.cursorKey = make([]byte, 32)
+		_, err := rand.Read(a.cursorKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	log.Infoln("Building cache...")
-	c, err := NewCache(db)
+	c, err := NewCache(a.stopping, db)
 	if err != nil {
 		return nil, err
 	}
@@ -36,6 +88,8 @@ func New(db db.BoilingDB) (*API, error) {
 }
 
 func (a *API) makeRoutes() {
+	a.app.Use(handler(a.withDBContext))
+
 	a.app.Post("/login", handler(a.withFields([]field{
 		{
 			name:     "username",
@@ -65,9 +119,33 @@ func (a *API) makeRoutes() {
 				required: true,
 				dType:    dTypeRawString, // postSignup checks if this contains spaces before or after, hence it has to be a raw (non-trimmed) string
 			},
+			{
+				name:  "invite",
+				dType: dTypeString,
+			},
 		})),
 		handler(a.postSignup))
 
+	if len(a.cfg.TrackerSecret) != 0 {
+		internal := a.app.Party("/internal/tracker", handler(a.withTrackerSecret))
+		internal.Get("/authorize", handler(a.authorizeAnnounce))
+		internal.Post("/announces", handler(a.postAnnounces))
+	} else {
+		log.Warnln("no tracker secret configured, internal tracker API disabled")
+	}
+
+	// the fields of the revert endpoints, which are the same for all entities
+	revertFields := []field{
+		{
+			name:     "revision",
+			required: true,
+			dType:    dTypeInt,
+			validator: func(_ *context, v interface{}) bool {
+				return v.(int) > 0
+			},
+		},
+	}
+
 	withAuth := a.app.Party("/", handler(a.withLogin))
 	withAuth.Get("/blogs", handler(a.withPrivilege("get_blogs")), handler(a.getBlogs))
 	withAuth.Post("/blogs", handler(a.withPrivilege("post_blog")),
@@ -140,12 +218,385 @@ func (a *API) makeRoutes() {
 
 	withAuth.Get("/users", handler(a.getUserSelf))
 	withAuth.Get("/users/{id}", handler(a.getUser))
+	withAuth.Get("/users/{id}/stats/history", handler(a.getUserStatHistory))
+	withAuth.Get("/users/{id}/stats/torrents", handler(a.getUserTorrentStats))
+	withAuth.Post("/users/{id}/class", handler(a.withPrivilege("set_user_class")),
+		handler(a.withFields([]field{
+			{
+				name:     "class",
+				required: true,
+				dType:    dTypeUnsafeString,
+				validator: func(_ *context, v interface{}) bool {
+					return a.c.userClasses.Has(v.(string))
+				},
+			},
+			{
+				name:  "reason",
+				dType: dTypeString,
+			},
+		})),
+		handler(a.setUserClass))
+	withAuth.Get("/users/{id}/class_history", handler(a.getUserClassHistory))
+	withAuth.Post("/users/{id}/invites", handler(a.withPrivilege("set_user_invites")),
+		handler(a.withFields([]field{
+			{
+				name:     "invites",
+				required: true,
+				dType:    dTypeInt,
+				validator: func(_ *context, v interface{}) bool {
+					return v.(int) >= 0
+				},
+			},
+		})),
+		handler(a.setUserInvites))
+	withAuth.Get("/users/{id}/invite_tree", handler(a.withPrivilege("get_invite_tree")), handler(a.getInviteTree))
+	withAuth.Post("/users/{id}/invite_tree/disable", handler(a.withPrivilege("disable_invite_tree")), handler(a.disableInviteTree))
+	withAuth.Get("/users/{id}/privileges", handler(a.withPrivilege("get_user_privileges")), handler(a.getUserPrivileges))
+	withAuth.Post("/users/{id}/privileges", handler(a.withPrivilege("set_user_privileges")),
+		handler(a.withFields([]field{
+			{
+				name:     "privilege",
+				required: true,
+				dType:    dTypeUnsafeString,
+				validator: func(_ *context, v interface{}) bool {
+					return a.c.privileges.Has(v.(string))
+				},
+			},
+		})),
+		handler(a.grantUserPrivilege))
+	withAuth.Delete("/users/{id}/privileges/{privilege}", handler(a.withPrivilege("set_user_privileges")), handler(a.revokeUserPrivilege))
+
+	withAuth.Get("/invites", handler(a.withPrivilege("invite")), handler(a.getInvites))
+	withAuth.Post("/invites", handler(a.withPrivilege("invite")), handler(a.postInvite))
 
 	withAuth.Get("/artists/{id}", handler(a.withPrivilege("get_artist")), handler(a.getArtist))
 	withAuth.Get("/artists/autocomplete/{s}", handler(a.withPrivilege("get_artist")), handler(a.autocompleteArtist))
 	withAuth.Get("/artists/autocomplete_tags/{s}", handler(a.withPrivilege("get_artist")), handler(a.autocompleteArtistTags))
-
+	withAuth.Post("/artists", handler(a.withPrivilege("create_artist")),
+		handler(a.withFields([]field{
+			{
+				name:     "name",
+				required: true,
+				dType:    dTypeString,
+			},
+			{
+				name:  "bio",
+				dType: dTypeUnsafeString, // markdown, sanitized when rendered
+			},
+			{
+				name:  "tags",
+				dType: dTypeTags,
+			},
+		})),
+		handler(a.postArtist))
+	withAuth.Post("/artists/{id}", handler(a.withPrivilege("update_artist")),
+		handler(a.withFields([]field{
+			{
+				name:  "name",
+				dType: dTypeString,
+				validator: func(_ *context, v interface{}) bool {
+					return len(v.(string)) > 0
+				},
+			},
+			{
+				name:  "bio",
+				dType: dTypeUnsafeString,
+			},
+		})),
+		handler(a.updateArtist))
+	withAuth.Post("/artists/{id}/aliases", handler(a.withPrivilege("add_artist_alias")),
+		handler(a.withFields([]field{
+			{
+				name:     "alias",
+				required: true,
+				dType:    dTypeString,
+			},
+		})),
+		handler(a.addArtistAlias))
+	withAuth.Delete("/artists/{id}/aliases/{alias:path}", handler(a.withPrivilege("remove_artist_alias")), handler(a.removeArtistAlias))
+	withAuth.Post("/artists/{id}/tags", handler(a.withPrivilege("add_artist_tag")),
+		handler(a.withFields([]field{
+			{
+				name:     "tags",
+				required: true,
+				dType:    dTypeTags,
+			},
+		})),
+		handler(a.addArtistTags))
+	withAuth.Delete("/artists/{id}/tags/{tag}", handler(a.withPrivilege("remove_artist_tag")), handler(a.removeArtistTag))
+	withAuth.Post("/artists/{id}/merge", handler(a.withPrivilege("merge_artists")),
+		handler(a.withFields([]field{
+			{
+				name:     "from",
+				required: true,
+				dType:    dTypeInt,
+			},
+		})),
+		handler(a.mergeArtists))
+	withAuth.Get("/artists/{id}/history", handler(a.withPrivilege("get_artist")), handler(a.getHistory(db.RevisionArtist)))
+	withAuth.Post("/artists/{id}/revert", handler(a.withPrivilege("revert_revision")),
+		handler(a.withFields(revertFields)),
+		handler(a.revert(db.RevisionArtist)))
+
+	withAuth.Get("/release_groups/search", handler(a.withPrivilege("get_release_group")), handler(a.searchReleaseGroups))
+	withAuth.Get("/release_groups/browse", handler(a.withPrivilege("get_release_group")), handler(a.browseReleaseGroups))
 	withAuth.Get("/release_groups/{id}", handler(a.withPrivilege("get_release_group")), handler(a.getReleaseGroup))
+	withAuth.Post("/release_groups", handler(a.withPrivilege("create_release_group")),
+		handler(a.withFields([]field{
+			{
+				name:     "name",
+				required: true,
+				dType:    dTypeString,
+			},
+			{
+				name:     "type",
+				required: true,
+				dType:    dTypeUnsafeString,
+				validator: func(_ *context, v interface{}) bool {
+					return a.c.releaseGroupTypes.Has(v.(string))
+				},
+			},
+			{
+				name:     "release_date",
+				required: true,
+				dType:    dTypeDate,
+			},
+			{
+				name:     "artists",
+				required: true,
+				dType:    dTypeStrings,
+			},
+			{
+				name:  "tags",
+				dType: dTypeTags,
+			},
+		})),
+		handler(a.postReleaseGroup))
+	withAuth.Post("/release_groups/{id}", handler(a.withPrivilege("update_release_group")),
+		handler(a.withFields([]field{
+			{
+				name:  "name",
+				dType: dTypeString,
+				validator: func(_ *context, v interface{}) bool {
+					return len(v.(string)) > 0
+				},
+			},
+			{
+				name:  "type",
+				dType: dTypeUnsafeString,
+				validator: func(_ *context, v interface{}) bool {
+					return a.c.releaseGroupTypes.Has(v.(string))
+				},
+			},
+			{
+				name:  "release_date",
+				dType: dTypeDate,
+			},
+			{
+				name:  "artists",
+				dType: dTypeStrings,
+			},
+			{
+				name:  "tags",
+				dType: dTypeTags,
+			},
+		})),
+		handler(a.updateReleaseGroup))
+	withAuth.Delete("/release_groups/{id}", handler(a.withPrivilege("delete_release_group")), handler(a.deleteReleaseGroup))
+	withAuth.Get("/release_groups/{id}/history", handler(a.withPrivilege("get_release_group")), handler(a.getHistory(db.RevisionReleaseGroup)))
+	withAuth.Post("/release_groups/{id}/revert", handler(a.withPrivilege("revert_revision")),
+		handler(a.withFields(revertFields)),
+		handler(a.revert(db.RevisionReleaseGroup)))
+	withAuth.Post("/release_groups/{id}/releases", handler(a.withPrivilege("create_release")),
+		handler(a.withFields([]field{
+			{
+				name:  "edition",
+				dType: dTypeString,
+			},
+			{
+				name:     "medium",
+				required: true,
+				dType:    dTypeUnsafeString,
+				validator: func(_ *context, v interface{}) bool {
+					return a.c.media.Has(v.(string))
+				},
+			},
+			{
+				name:     "release_date",
+				required: true,
+				dType:    dTypeDate,
+			},
+			{
+				name:  "catalogue_number",
+				dType: dTypeString,
+			},
+			{
+				name:     "record_label",
+				required: true,
+				dType:    dTypeInt,
+				validator: func(_ *context, v interface{}) bool {
+					return v.(int) >= 0
+				},
+			},
+			{
+				name:      "original",
+				dType:     dTypeUnsafeString,
+				validator: validBool,
+			},
+			{
+				name:  "tags",
+				dType: dTypeTags,
+			},
+		})),
+		handler(a.postRelease))
+
+	withAuth.Get("/releases/{id}", handler(a.withPrivilege("get_release_group")), handler(a.getRelease))
+	withAuth.Post("/releases/{id}", handler(a.withPrivilege("update_release")),
+		handler(a.withFields([]field{
+			{
+				name:  "edition",
+				dType: dTypeString,
+			},
+			{
+				name:  "medium",
+				dType: dTypeUnsafeString,
+				validator: func(_ *context, v interface{}) bool {
+					return a.c.media.Has(v.(string))
+				},
+			},
+			{
+				name:  "release_date",
+				dType: dTypeDate,
+			},
+			{
+				name:  "catalogue_number",
+				dType: dTypeString,
+			},
+			{
+				name:  "record_label",
+				dType: dTypeInt,
+				validator: func(_ *context, v interface{}) bool {
+					return v.(int) >= 0
+				},
+			},
+			{
+				name:      "original",
+				dType:     dTypeUnsafeString,
+				validator: validBool,
+			},
+			{
+				name:  "tags",
+				dType: dTypeTags,
+			},
+		})),
+		handler(a.updateRelease))
+	withAuth.Delete("/releases/{id}", handler(a.withPrivilege("delete_release")), handler(a.deleteRelease))
+	withAuth.Get("/releases/{id}/history", handler(a.withPrivilege("get_release_group")), handler(a.getHistory(db.RevisionRelease)))
+	withAuth.Post("/releases/{id}/revert", handler(a.withPrivilege("revert_revision")),
+		handler(a.withFields(revertFields)),
+		handler(a.revert(db.RevisionRelease)))
+	withAuth.Post("/releases/{id}/properties", handler(a.withPrivilege("set_release_property")),
+		handler(a.withFields([]field{
+			{
+				name:     "property",
+				required: true,
+				dType:    dTypeUnsafeString,
+				validator: func(_ *context, v interface{}) bool {
+					return a.c.releaseProperties.Has(v.(string))
+				},
+			},
+			{
+				name:  "value",
+				dType: dTypeString,
+			},
+		})),
+		handler(a.setReleaseProperty))
+	withAuth.Post("/releases/{id}/torrents", handler(a.withPrivilege("upload_torrent")),
+		handler(a.withFields([]field{
+			{
+				name:     "torrent",
+				required: true,
+				dType:    dTypeFile,
+			},
+			{
+				name:     "format",
+				required: true,
+				dType:    dTypeUnsafeString,
+				validator: func(_ *context, v interface{}) bool {
+					return a.c.formats.Has(v.(string))
+				},
+			},
+			{
+				name:  "description",
+				dType: dTypeString,
+			},
+		})),
+		handler(a.postTorrent))
+	withAuth.Get("/torrents/{id}/download", handler(a.withPrivilege("download_torrent")), handler(a.downloadTorrent))
+
+	withAuth.Get("/labels/{id}", handler(a.withPrivilege("get_record_label")), handler(a.getRecordLabel))
+	withAuth.Get("/labels/{id}/releases", handler(a.withPrivilege("get_record_label")), handler(a.getRecordLabelReleases))
+	withAuth.Get("/labels/autocomplete/{s}", handler(a.withPrivilege("get_record_label")), handler(a.autocompleteRecordLabel))
+	withAuth.Post("/labels", handler(a.withPrivilege("create_record_label")),
+		handler(a.withFields([]field{
+			{
+				name:     "name",
+				required: true,
+				dType:    dTypeString,
+			},
+			{
+				name:  "description",
+				dType: dTypeString,
+			},
+			{
+				name:  "founded",
+				dType: dTypeDate,
+			},
+			{
+				name:  "parent",
+				dType: dTypeInt,
+				validator: func(_ *context, v interface{}) bool {
+					return v.(int) >= 0
+				},
+			},
+		})),
+		handler(a.postRecordLabel))
+	withAuth.Post("/labels/{id}", handler(a.withPrivilege("update_record_label")),
+		handler(a.withFields([]field{
+			{
+				name:  "name",
+				dType: dTypeString,
+				validator: func(_ *context, v interface{}) bool {
+					return len(v.(string)) > 0
+				},
+			},
+			{
+				name:  "description",
+				dType: dTypeString,
+			},
+			{
+				name:  "founded",
+				dType: dTypeDate,
+			},
+			{
+				name:  "parent",
+				dType: dTypeUnsafeString, // empty to remove the parent
+				validator: func(_ *context, v interface{}) bool {
+					if v.(string) == "" {
+						return true
+					}
+					id, err := strconv.Atoi(v.(string))
+					return err == nil && id >= 0
+				},
+			},
+		})),
+		handler(a.updateRecordLabel))
+	withAuth.Get("/labels/{id}/history", handler(a.withPrivilege("get_record_label")), handler(a.getHistory(db.RevisionRecordLabel)))
+	withAuth.Post("/labels/{id}/revert", handler(a.withPrivilege("revert_revision")),
+		handler(a.withFields(revertFields)),
+		handler(a.revert(db.RevisionRecordLabel)))
+
+	withAuth.Get("/search", handler(a.search))
 
 	withAuth.Get("/formats", handler(a.getFormats))
 	withAuth.Get("/leech_types", handler(a.getLeechTypes))
@@ -154,6 +605,19 @@ func (a *API) makeRoutes() {
 	withAuth.Get("/release_properties", handler(a.getReleaseProperties))
 	withAuth.Get("/release_roles", handler(a.getReleaseRoles))
 	withAuth.Get("/privileges", handler(a.getPrivileges))
+	withAuth.Post("/privileges", handler(a.withPrivilege("create_privilege")),
+		handler(a.withFields([]field{
+			{
+				name:     "privilege",
+				required: true,
+				dType:    dTypeUnsafeString,
+				validator: func(_ *context, v interface{}) bool {
+					return validPrivilegeName(v.(string))
+				},
+			},
+		})),
+		handler(a.createPrivilege))
+	withAuth.Get("/user_classes", handler(a.getUserClasses))
 }
 
 func (a *API) Run(runner iris.Runner) error {
@@ -166,6 +630,7 @@ func (a *API) Run(runner iris.Runner) error {
 }
 
 func (a *API) Stop() error {
+	a.stop()
 	return a.app.Shutdown(ctx.Background())
 }
 
@@ -214,6 +679,10 @@ type context struct {
 	iris.Context
 	user db.User
 
+	// dbCtx must be passed to all database calls made while handling the
+	// request.
+	dbCtx ctx.Context
+
 	fields
 }
 
@@ -283,10 +752,12 @@ func acquire(original iris.Context) *context {
 
 	c := contextPool.Get().(*context)
 	c.Context = original
+	c.dbCtx = original.Request().Context()
 	c.fields.fields = make(map[string]interface{})
 	return c
 }
 
 func release(ctx *context) {
+	ctx.dbCtx = nil
 	contextPool.Put(ctx)
 }
@@ -1,18 +1,20 @@
 package api
 
 import (
+	ctx "context"
 	"database/sql"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/kataras/iris"
+	"github.com/lib/pq"
 
 	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/db/memdb"
 )
 
 func TestMain(m *testing.M) {
@@ -32,41 +34,107 @@ func TestMain(m *testing.M) {
 	os.Exit(ret)
 }
 
+// testDBEnv selects the database the tests run against.
+// If it is set to "memory", an in-memory database is used instead of
+// postgres.
+const testDBEnv = "BOILING_TEST_DB"
+
 func cleanDB() (db.BoilingDB, error) {
-	d, err := db.New("boilingtest", "boilingtest", "boilingtest")
+	if os.Getenv(testDBEnv) == "memory" {
+		return cleanMemDB()
+	}
+
+	d, err := db.New(testDBConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	inner, err := sql.Open("postgres", fmt.Sprintf("user=%s password=%s dbname=%s", "boilingtest", "boilingtest", "boilingtest"))
+	inner, err := db.Open(testDBConfig)
 	if err != nil {
 		return nil, err
 	}
 	defer inner.Close()
 
-	file, err := ioutil.ReadFile("../db/create.sql")
+	err = db.ResetSchema(inner)
 	if err != nil {
 		return nil, err
 	}
 
-	requests := strings.Split(string(file), ";")
+	file, err := ioutil.ReadFile("../db/testdata.sql")
+	if err != nil {
+		return nil, err
+	}
 
-	for _, request := range requests {
-		_, err := inner.Exec(request)
-		if err != nil {
-			return nil, err
-		}
+	_, err = inner.Exec(string(file))
+	if err != nil {
+		return nil, err
 	}
 
 	return d, nil
 }
 
+// cleanMemDB returns an in-memory database with the fixtures of
+// db/testdata.sql loaded.
+func cleanMemDB() (db.BoilingDB, error) {
+	d := memdb.New()
+
+	u := db.User{
+		ID:           1,
+		Username:     "test",
+		Email:        "test@boiling.rip",
+		PasswordHash: "$2a$14$2v2YkEAjBx9ZEYZdYQgDR.H4r.CmdOTI.10cmqnKvQ7Ucq60prUGm", // password is test
+		Bio:          sql.NullString{Valid: true},
+		Enabled:      true,
+		CanLogin:     true,
+		JoinedAt:     time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		LastLogin:    pq.NullTime{Time: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+		LastAccess:   pq.NullTime{Time: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+		Class:        4, // SysOp, has all privileges
+	}
+
+	err := d.InsertUser(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+var testDBConfig = db.Config{
+	Database: "boilingtest",
+	User:     "boilingtest",
+	Password: "boilingtest",
+}
+
+var testConfig = Config{
+	AnnounceBase:  "http://tracker.boiling.rip:34000",
+	TrackerSecret: "trackersecret",
+}
+
 var defaultAPI *struct {
 	api *API
 	wg  *sync.WaitGroup
 }
 
 func givePrivileges(a *API, uid int, privileges ...string) error {
+	dbCtx := ctx.Background()
+
+	var ps []int
+	for _, s := range privileges {
+		p, err := a.c.privileges.LookUp(s)
+		if err != nil {
+			return err
+		}
+
+		ps = append(ps, p)
+	}
+	err := a.db.UpdateUserAddPrivileges(dbCtx, uid, ps)
+	return err
+}
+
+func denyPrivileges(a *API, uid int, privileges ...string) error {
+	dbCtx := ctx.Background()
+
 	var ps []int
 	for _, s := range privileges {
 		p, err := a.c.privileges.LookUp(s)
@@ -76,7 +144,7 @@ func givePrivileges(a *API, uid int, privileges ...string) error {
 
 		ps = append(ps, p)
 	}
-	err := a.db.UpdateUserAddPrivileges(uid, ps)
+	err := a.db.UpdateUserDenyPrivileges(dbCtx, uid, ps)
 	return err
 }
 
@@ -89,7 +157,7 @@ func getDefaultAPIWithDB(d db.BoilingDB) (*API, error) {
 		api *API
 		wg  *sync.WaitGroup
 	}{}
-	a, err := New(d)
+	a, err := New(d, testConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -128,22 +196,24 @@ type dbWithLogin struct {
 }
 
 func cleanDBWithLogin() (*dbWithLogin, error) {
+	dbCtx := ctx.Background()
+
 	d, err := cleanDB()
 	if err != nil {
 		return nil, err
 	}
 
-	err = d.SignUpUser("sometestuser", "sometestpw12345", "some@ex.am.ple.com")
+	err = d.SignUpUser(dbCtx, "sometestuser", "sometestpw12345", "some@ex.am.ple.com")
 	if err != nil {
 		return nil, err
 	}
 
-	u, err := d.LoginAndGetUser("sometestuser", "sometestpw12345")
+	u, err := d.LoginAndGetUser(dbCtx, "sometestuser", "sometestpw12345")
 	if err != nil {
 		return nil, err
 	}
 
-	tok, err := d.InsertTokenForUser(*u)
+	tok, err := d.InsertTokenForUser(dbCtx, *u)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"database/sql"
 	"errors"
 	"time"
 
@@ -16,7 +17,8 @@ type Artist struct {
 	ReleaseGroups []RoledReleaseGroup `json:"release_groups,omitempty"`
 	Added         time.Time           `json:"added"`
 	AddedBy       BaseUser            `json:"added_by"`
-	Bio           *string             `json:"bio,omitempty"`
+	Bio           *string             `json:"bio,omitempty"`      // markdown
+	BioHTML       *string             `json:"bio_html,omitempty"` // rendered and sanitized
 	Tags          []string            `json:"tags,omitempty"`
 }
 
@@ -29,7 +31,10 @@ func (a *API) artistFromDBArtist(dbA *db.Artist) Artist {
 		Tags:    dbA.Tags,
 	}
 	if dbA.Bio.Valid {
-		artist.Bio = &dbA.Bio.String
+		bio := dbA.Bio.String
+		html := string(compileMarkdown([]byte(bio)))
+		artist.Bio = &bio
+		artist.BioHTML = &html
 	}
 	for _, dbAlias := range dbA.Aliases {
 		alias := artistAliasFromDBArtistAlias(dbAlias)
@@ -91,6 +96,30 @@ type ArtistsResponse struct {
 	Artists []Artist `json:"artists"`
 }
 
+// ArtistRedirect points from an artist that was merged into another one to
+// that artist.
+type ArtistRedirect struct {
+	Artist   int       `json:"artist"`
+	Target   int       `json:"target"`
+	Merged   time.Time `json:"merged"`
+	MergedBy BaseUser  `json:"merged_by"`
+}
+
+func artistRedirectFromDBArtistRedirect(dbR *db.ArtistRedirect) ArtistRedirect {
+	return ArtistRedirect{
+		Artist:   dbR.Artist,
+		Target:   dbR.Target,
+		Merged:   dbR.Merged,
+		MergedBy: baseUserFromDBUser(dbR.MergedBy),
+	}
+}
+
+// ArtistRedirectResponse is returned instead of an ArtistResponse for artists
+// that were merged into another one.
+type ArtistRedirectResponse struct {
+	Redirect ArtistRedirect `json:"redirect"`
+}
+
 type TagsResponse struct {
 	Tags []string `json:"tags"`
 }
@@ -106,13 +135,20 @@ func (a *API) getArtist(ctx *context) {
 		return
 	}
 
-	artist, err := a.db.GetArtist(id)
+	artist, err := a.db.GetArtist(ctx.dbCtx, id)
+	if err == sql.ErrNoRows {
+		redirect, rErr := a.db.GetArtistRedirect(ctx.dbCtx, id)
+		if rErr == nil {
+			ctx.Success(ArtistRedirectResponse{Redirect: artistRedirectFromDBArtistRedirect(redirect)})
+			return
+		}
+	}
 	if err != nil {
 		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
 		return
 	}
 
-	err = a.db.PopulateReleaseGroups(artist)
+	err = a.db.PopulateReleaseGroups(ctx.dbCtx, artist)
 	if err != nil {
 		ctx.Error(err, iris.StatusInternalServerError)
 		return
@@ -128,7 +164,7 @@ func (a *API) autocompleteArtist(ctx *context) {
 		return
 	}
 
-	artists, err := a.db.AutocompleteArtists(s)
+	artists, err := a.db.AutocompleteArtists(ctx.dbCtx, s)
 	if err != nil {
 		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
 		return
@@ -149,7 +185,7 @@ func (a *API) autocompleteArtistTags(ctx *context) {
 		return
 	}
 
-	tags, err := a.db.AutocompleteArtistTags(s)
+	tags, err := a.db.AutocompleteArtistTags(ctx.dbCtx, s)
 	if err != nil {
 		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
 		return
@@ -157,3 +193,169 @@ func (a *API) autocompleteArtistTags(ctx *context) {
 
 	ctx.Success(TagsResponse{Tags: tags})
 }
+
+// respondWithArtist responds with the artist with the given ID, as returned
+// by getArtist.
+func (a *API) respondWithArtist(ctx *context, id int) {
+	artist, err := a.db.GetArtist(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	err = a.db.PopulateReleaseGroups(ctx.dbCtx, artist)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	ctx.Success(ArtistResponse{Artist: a.artistFromDBArtist(artist)})
+}
+
+func (a *API) postArtist(ctx *context) {
+	name := ctx.fields.mustGetString("name")
+	bio, _ := ctx.fields.getString("bio")
+	tags, _ := ctx.fields.getTags("tags")
+
+	artist := db.Artist{
+		Name:    name,
+		Bio:     sql.NullString{String: bio, Valid: bio != ""},
+		Tags:    tags,
+		Added:   time.Now(),
+		AddedBy: ctx.user,
+	}
+
+	err := a.db.InsertArtist(ctx.dbCtx, &artist)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to create artist"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithArtist(ctx, artist.ID)
+}
+
+func (a *API) updateArtist(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	name, nameSet := ctx.fields.getString("name")
+	bio, bioSet := ctx.fields.getString("bio")
+
+	original, err := a.db.GetArtist(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	if nameSet {
+		original.Name = name
+	}
+	if bioSet {
+		// an empty bio removes it
+		original.Bio = sql.NullString{String: bio, Valid: bio != ""}
+	}
+
+	err = a.db.UpdateArtist(ctx.dbCtx, *original, newEdit(ctx))
+	if err != nil {
+		ctx.Fail(userError(err, "unable to update artist"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithArtist(ctx, id)
+}
+
+func (a *API) addArtistAlias(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	alias := db.ArtistAlias{
+		Alias:   ctx.fields.mustGetString("alias"),
+		Added:   time.Now(),
+		AddedBy: ctx.user,
+	}
+
+	err := a.db.AddArtistAlias(ctx.dbCtx, id, alias)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to add alias"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithArtist(ctx, id)
+}
+
+func (a *API) removeArtistAlias(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	err := a.db.RemoveArtistAlias(ctx.dbCtx, id, ctx.Params().Get("alias"), newEdit(ctx))
+	if err != nil {
+		ctx.Fail(userError(err, "unable to remove alias"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithArtist(ctx, id)
+}
+
+func (a *API) addArtistTags(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	err := a.db.AddArtistTags(ctx.dbCtx, id, ctx.fields.mustGetTags("tags"), newEdit(ctx))
+	if err != nil {
+		ctx.Fail(userError(err, "unable to add tags"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithArtist(ctx, id)
+}
+
+func (a *API) removeArtistTag(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	tags, err := prepareTags([]string{ctx.Params().Get("tag")})
+	if err != nil || len(tags) != 1 {
+		ctx.Fail(userError(err, "invalid tag"), iris.StatusBadRequest)
+		return
+	}
+
+	err = a.db.RemoveArtistTag(ctx.dbCtx, id, tags[0], newEdit(ctx))
+	if err != nil {
+		ctx.Fail(userError(err, "unable to remove tag"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithArtist(ctx, id)
+}
+
+func (a *API) mergeArtists(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	redirect := db.ArtistRedirect{
+		Artist:   ctx.fields.mustGetInt("from"),
+		Target:   id,
+		Merged:   time.Now(),
+		MergedBy: ctx.user,
+	}
+
+	err := a.db.MergeArtists(ctx.dbCtx, redirect)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to merge artists"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithArtist(ctx, id)
+}
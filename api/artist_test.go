@@ -1,6 +1,7 @@
 package api
 
 import (
+	ctx "context"
 	"database/sql"
 	"testing"
 	"time"
@@ -12,6 +13,8 @@ import (
 )
 
 func TestGetArtist(t *testing.T) {
+	dbCtx := ctx.Background()
+
 	tc, err := cleanDBWithLogin()
 	require.Nil(t, err)
 	a, err := getDefaultAPIWithDB(tc.db)
@@ -31,7 +34,7 @@ func TestGetArtist(t *testing.T) {
 		AddedBy: db.User{ID: 1},
 	}
 
-	err = tc.db.InsertArtist(&a1)
+	err = tc.db.InsertArtist(dbCtx, &a1)
 	require.Nil(t, err)
 
 	g := db.ReleaseGroup{
@@ -49,7 +52,7 @@ func TestGetArtist(t *testing.T) {
 		Tags:        []string{"electronic", "canadian"},
 	}
 
-	err = tc.db.InsertReleaseGroup(&g)
+	err = tc.db.InsertReleaseGroup(dbCtx, &g)
 	require.Nil(t, err)
 
 	e := httpexpect.New(t, "http://localhost:8080")
@@ -64,11 +67,12 @@ func TestGetArtist(t *testing.T) {
 	obj.Value("data").Object().Keys().ContainsOnly("artist")
 	artist := obj.Value("data").Object().Value("artist").Object()
 
-	artist.Keys().ContainsOnly("id", "name", "bio", "aliases", "tags", "added", "added_by", "release_groups")
+	artist.Keys().ContainsOnly("id", "name", "bio", "bio_html", "aliases", "tags", "added", "added_by", "release_groups")
 	artist.ValueEqual("id", a1.ID)
 	artist.ValueEqual("name", a1.Name)
 	artist.Value("aliases").Array().Length().Equal(1)
 	artist.ValueEqual("bio", a1.Bio.String)
+	artist.ValueEqual("bio_html", "<p>Some bio</p>\n")
 	artist.ValueEqual("tags", a1.Tags)
 	artist.ValueEqual("added", a1.Added)
 	artist.Value("added_by").Object().ValueEqual("id", a1.AddedBy.ID)
@@ -95,6 +99,8 @@ func TestGetArtist(t *testing.T) {
 }
 
 func TestAutocompleteArtist(t *testing.T) {
+	dbCtx := ctx.Background()
+
 	tc, err := cleanDBWithLogin()
 	require.Nil(t, err)
 	a, err := getDefaultAPIWithDB(tc.db)
@@ -126,9 +132,9 @@ func TestAutocompleteArtist(t *testing.T) {
 		AddedBy: db.User{ID: 1},
 	}
 
-	err = tc.db.InsertArtist(&a1)
+	err = tc.db.InsertArtist(dbCtx, &a1)
 	require.Nil(t, err)
-	err = tc.db.InsertArtist(&a2)
+	err = tc.db.InsertArtist(dbCtx, &a2)
 	require.Nil(t, err)
 
 	e := httpexpect.New(t, "http://localhost:8080")
@@ -170,6 +176,8 @@ func TestAutocompleteArtist(t *testing.T) {
 }
 
 func TestAutocompleteArtistTags(t *testing.T) {
+	dbCtx := ctx.Background()
+
 	tc, err := cleanDBWithLogin()
 	require.Nil(t, err)
 	a, err := getDefaultAPIWithDB(tc.db)
@@ -201,9 +209,9 @@ func TestAutocompleteArtistTags(t *testing.T) {
 		AddedBy: db.User{ID: 1},
 	}
 
-	err = tc.db.InsertArtist(&a1)
+	err = tc.db.InsertArtist(dbCtx, &a1)
 	require.Nil(t, err)
-	err = tc.db.InsertArtist(&a2)
+	err = tc.db.InsertArtist(dbCtx, &a2)
 	require.Nil(t, err)
 
 	e := httpexpect.New(t, "http://localhost:8080")
@@ -233,3 +241,190 @@ func TestAutocompleteArtistTags(t *testing.T) {
 	tags.ContainsOnly("non.special.tag", "special.tag")
 
 }
+
+func TestInsertUpdateArtist(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_artist", "create_artist", "update_artist",
+		"add_artist_alias", "remove_artist_alias", "add_artist_tag", "remove_artist_tag")
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	artistOf := func(resp *httpexpect.Response) *httpexpect.Object {
+		obj := resp.JSON().Object()
+		obj.ValueEqual("status", "success")
+		return obj.Value("data").Object().Value("artist").Object()
+	}
+
+	// Create
+	artist := artistOf(e.POST("/artists").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Justice").
+		WithFormField("bio", "*French* duo <script>alert(1)</script>").
+		WithFormField("tags", "Electronic").
+		WithFormField("tags", "french").
+		Expect().Status(200))
+	artist.ValueEqual("name", "Justice")
+	artist.ValueEqual("bio", "*French* duo <script>alert(1)</script>")
+	artist.ValueEqual("bio_html", "<p><em>French</em> duo </p>\n")
+	artist.ValueEqual("tags", []string{"electronic", "french"})
+	artist.Value("added_by").Object().ValueEqual("id", tc.user.ID)
+	id := int(artist.Value("id").Number().Raw())
+
+	e.POST("/artists").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("bio", "no name").
+		Expect().Status(400)
+
+	// Update, fields that are not given are kept
+	artist = artistOf(e.POST("/artists/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Justice!").
+		Expect().Status(200))
+	artist.ValueEqual("name", "Justice!")
+	artist.ValueEqual("bio", "*French* duo <script>alert(1)</script>")
+
+	artist = artistOf(e.POST("/artists/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("bio", "").
+		Expect().Status(200))
+	artist.ValueEqual("name", "Justice!")
+	artist.NotContainsKey("bio")
+	artist.NotContainsKey("bio_html")
+
+	e.POST("/artists/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", " ").
+		Expect().Status(400)
+
+	e.POST("/artists/{id}", id+100).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Nobody").
+		Expect().Status(404)
+
+	// Aliases
+	artist = artistOf(e.POST("/artists/{id}/aliases", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("alias", "Gaspard/Xavier").
+		Expect().Status(200))
+	artist.Value("aliases").Array().Length().Equal(1)
+	alias := artist.Value("aliases").Array().Element(0).Object()
+	alias.ValueEqual("alias", "Gaspard/Xavier")
+	alias.Value("added_by").Object().ValueEqual("id", tc.user.ID)
+
+	e.POST("/artists/{id}/aliases", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("alias", "Gaspard/Xavier").
+		Expect().Status(400)
+
+	artist = artistOf(e.DELETE("/artists/{id}/aliases/Gaspard/Xavier", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200))
+	artist.NotContainsKey("aliases")
+
+	e.DELETE("/artists/{id}/aliases/Gaspard/Xavier", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(400)
+
+	// Tags
+	artist = artistOf(e.POST("/artists/{id}/tags", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("tags", "house").
+		WithFormField("tags", "french").
+		Expect().Status(200))
+	artist.Value("tags").Array().ContainsOnly("electronic", "french", "house")
+
+	artist = artistOf(e.DELETE("/artists/{id}/tags/{tag}", id, "French").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200))
+	artist.Value("tags").Array().ContainsOnly("electronic", "house")
+
+	e.DELETE("/artists/{id}/tags/{tag}", id, "french").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(400)
+}
+
+func TestInsertArtistPrivileges(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_artist")
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	e.POST("/artists").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Justice").
+		Expect().Status(403).
+		JSON().Object().ValueEqual("status", "fail")
+}
+
+func TestMergeArtists(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_artist", "create_artist", "merge_artists")
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	create := func(name string, tags ...string) int {
+		req := e.POST("/artists").
+			WithHeader("X-User-Token", tc.token).
+			WithFormField("name", name)
+		for _, tag := range tags {
+			req = req.WithFormField("tags", tag)
+		}
+		obj := req.Expect().Status(200).JSON().Object()
+		return int(obj.Value("data").Object().Value("artist").Object().Value("id").Number().Raw())
+	}
+	target := create("Daft Punk", "electronic")
+	dupe := create("Daft Punk (FR)", "house")
+
+	artist := e.POST("/artists/{id}/merge", target).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("from", dupe).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("artist").Object()
+	artist.ValueEqual("id", target)
+	artist.Value("tags").Array().ContainsOnly("electronic", "house")
+	artist.Value("aliases").Array().Length().Equal(1)
+	artist.Value("aliases").Array().Element(0).Object().ValueEqual("alias", "Daft Punk (FR)")
+
+	obj := e.GET("/artists/{id}", dupe).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object()
+	obj.ValueEqual("status", "success")
+	obj.Value("data").Object().Keys().ContainsOnly("redirect")
+	redirect := obj.Value("data").Object().Value("redirect").Object()
+	redirect.Keys().ContainsOnly("artist", "target", "merged", "merged_by")
+	redirect.ValueEqual("artist", dupe)
+	redirect.ValueEqual("target", target)
+	redirect.Value("merged_by").Object().ValueEqual("id", tc.user.ID)
+
+	// the merged artist is gone
+	e.POST("/artists/{id}/merge", target).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("from", dupe).
+		Expect().Status(400)
+
+	e.POST("/artists/{id}/merge", target).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("from", target).
+		Expect().Status(400)
+
+	e.POST("/artists/{id}/merge", target).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(400)
+
+	e.GET("/artists/{id}", dupe+100).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(404)
+}
@@ -41,41 +41,72 @@ func dbBlogEntryFromBlogEntry(e BlogEntry) db.BlogEntry {
 }
 
 type BlogEntriesResponse struct {
-	Entries []BlogEntry `json:"entries"`
+	Entries    []BlogEntry `json:"entries"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
 }
 
 type BlogEntryResponse struct {
 	Entry BlogEntry `json:"entry"`
 }
 
+const maxBlogEntriesLimit = 50
+
 func (a *API) getBlogs(ctx *context) {
-	offset, err := ctx.URLParamInt("offset")
-	if err != nil || offset < 0 {
-		ctx.Fail(userError(err, "invalid offset"), iris.StatusBadRequest)
+	p, ok := a.parsePage(ctx, "blogs", maxBlogEntriesLimit, maxBlogEntriesLimit)
+	if !ok {
 		return
 	}
 
-	limit, err := ctx.URLParamInt("limit")
-	if err != nil || limit < 0 {
-		ctx.Fail(userError(err, "invalid limit"), iris.StatusBadRequest)
-		return
-	}
-	if limit > 50 {
-		limit = 50
+	var c db.BlogCursor
+	if p.cursor != nil {
+		postedAt, err := time.Parse(time.RFC3339Nano, p.cursor.Key)
+		if err != nil {
+			ctx.Fail(errInvalidCursor, iris.StatusBadRequest)
+			return
+		}
+		c = db.BlogCursor{PostedAt: postedAt, ID: p.cursor.ID}
+	}
+
+	// One more entry than requested is fetched to find out whether there is
+	// another page.
+	var (
+		posts []db.BlogEntry
+		err   error
+	)
+	switch {
+	case p.cursor == nil:
+		posts, err = a.db.GetBlogEntries(ctx.dbCtx, p.limit+1, p.offset)
+	case p.backward():
+		posts, err = a.db.GetBlogEntriesBefore(ctx.dbCtx, c, p.limit+1)
+	default:
+		posts, err = a.db.GetBlogEntriesAfter(ctx.dbCtx, c, p.limit+1)
 	}
-
-	posts, err := a.db.GetBlogEntries(limit, offset)
 	if err != nil {
 		ctx.Error(err, iris.StatusInternalServerError)
 		return
 	}
 
+	more := len(posts) > p.limit
+	if more {
+		if p.backward() {
+			posts = posts[1:]
+		} else {
+			posts = posts[:p.limit]
+		}
+	}
+
 	entries := make([]BlogEntry, 0, len(posts))
 	for i := range posts {
 		entries = append(entries, blogEntryFromDBBlogEntry(posts[i]))
 	}
 
-	ctx.Success(BlogEntriesResponse{Entries: entries})
+	resp := BlogEntriesResponse{Entries: entries}
+	resp.NextCursor, resp.PrevCursor = a.cursors(p, len(posts), more, func(i int) (string, int) {
+		return timeKey(posts[i].PostedAt), posts[i].ID
+	})
+
+	ctx.Success(resp)
 }
 
 func (a *API) postBlog(ctx *context) {
@@ -100,7 +131,7 @@ func (a *API) postBlog(ctx *context) {
 		entry.PostedAt = postedAt
 	}
 
-	err := a.db.InsertBlogEntry(&entry)
+	err := a.db.InsertBlogEntry(ctx.dbCtx, &entry)
 	if err != nil {
 		ctx.Fail(userError(err, "unable to post blog"), iris.StatusBadRequest)
 		return
@@ -132,7 +163,7 @@ func (a *API) updateBlog(ctx *context) {
 		return
 	}
 
-	original, err := a.db.GetBlogEntry(id)
+	original, err := a.db.GetBlogEntry(ctx.dbCtx, id)
 	if err != nil {
 		ctx.Fail(userError(err, "not found"), iris.StatusBadRequest)
 		return
@@ -154,7 +185,7 @@ func (a *API) updateBlog(ctx *context) {
 		original.PostedAt = postedAt
 	}
 
-	err = a.db.UpdateBlogEntry(*original)
+	err = a.db.UpdateBlogEntry(ctx.dbCtx, *original)
 	if err != nil {
 		ctx.Fail(userError(err, "unable to update blog"), iris.StatusBadRequest)
 		return
@@ -181,7 +212,7 @@ func (a *API) deleteBlog(ctx *context) {
 	}
 
 	if !canDeleteForeignPost {
-		original, err := a.db.GetBlogEntry(id)
+		original, err := a.db.GetBlogEntry(ctx.dbCtx, id)
 		if err != nil {
 			ctx.Fail(userError(err, "not found"), iris.StatusBadRequest)
 			return
@@ -193,7 +224,7 @@ func (a *API) deleteBlog(ctx *context) {
 		}
 	}
 
-	err = a.db.DeleteBlogEntry(id)
+	err = a.db.DeleteBlogEntry(ctx.dbCtx, id)
 	if err != nil {
 		ctx.Fail(userError(err, "unable to delete blog"), iris.StatusBadRequest)
 		return
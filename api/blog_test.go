@@ -1,6 +1,7 @@
 package api
 
 import (
+	ctx "context"
 	"testing"
 	"time"
 
@@ -11,6 +12,8 @@ import (
 )
 
 func TestGetBlogs(t *testing.T) {
+	dbCtx := ctx.Background()
+
 	tc, err := cleanDBWithLogin()
 	require.Nil(t, err)
 	a, err := getDefaultAPIWithDB(tc.db)
@@ -26,7 +29,7 @@ func TestGetBlogs(t *testing.T) {
 		PostedAt: time.Date(2001, 01, 01, 0, 0, 0, 0, time.FixedZone("", 0)),
 	}
 
-	err = tc.db.InsertBlogEntry(&entry)
+	err = tc.db.InsertBlogEntry(dbCtx, &entry)
 	require.Nil(t, err)
 
 	e := httpexpect.New(t, "http://localhost:8080")
@@ -52,6 +55,101 @@ func TestGetBlogs(t *testing.T) {
 	post.Value("author").Object().ValueEqual("id", entry.Author.ID)
 }
 
+func TestGetBlogsCursor(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_blogs")
+	require.Nil(t, err)
+
+	var entries []db.BlogEntry
+	for i := 0; i < 5; i++ {
+		entry := db.BlogEntry{
+			Title:    "test title",
+			Content:  "test content",
+			Author:   db.User{ID: 1},
+			PostedAt: time.Date(2001, 01, 05-i, 0, 0, 0, 0, time.FixedZone("", 0)),
+		}
+		err = tc.db.InsertBlogEntry(dbCtx, &entry)
+		require.Nil(t, err)
+		entries = append(entries, entry)
+	}
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	get := func(query map[string]interface{}) *httpexpect.Object {
+		req := e.GET("/blogs").
+			WithHeader("X-User-Token", tc.token)
+		for k, v := range query {
+			req = req.WithQuery(k, v)
+		}
+
+		obj := req.Expect().Status(200).JSON().Object()
+		obj.ValueEqual("status", "success")
+		return obj.Value("data").Object()
+	}
+
+	ids := func(data *httpexpect.Object) []interface{} {
+		var res []interface{}
+		for _, v := range data.Value("entries").Array().Iter() {
+			res = append(res, v.Object().Value("id").Raw())
+		}
+		return res
+	}
+
+	data := get(map[string]interface{}{"limit": 2})
+	require.Equal(t, []interface{}{float64(entries[0].ID), float64(entries[1].ID)}, ids(data))
+	data.NotContainsKey("prev_cursor")
+	next := data.Value("next_cursor").String().Raw()
+
+	// a new entry does not shift the following pages
+	err = tc.db.InsertBlogEntry(dbCtx, &db.BlogEntry{
+		Title:    "newer title",
+		Content:  "newer content",
+		Author:   db.User{ID: 1},
+		PostedAt: time.Date(2001, 01, 06, 0, 0, 0, 0, time.FixedZone("", 0)),
+	})
+	require.Nil(t, err)
+
+	data = get(map[string]interface{}{"limit": 2, "cursor": next})
+	require.Equal(t, []interface{}{float64(entries[2].ID), float64(entries[3].ID)}, ids(data))
+	prev := data.Value("prev_cursor").String().Raw()
+	next = data.Value("next_cursor").String().Raw()
+
+	data = get(map[string]interface{}{"limit": 2, "cursor": next})
+	require.Equal(t, []interface{}{float64(entries[4].ID)}, ids(data))
+	data.NotContainsKey("next_cursor")
+	data.ContainsKey("prev_cursor")
+
+	data = get(map[string]interface{}{"limit": 2, "cursor": prev})
+	require.Equal(t, []interface{}{float64(entries[0].ID), float64(entries[1].ID)}, ids(data))
+	data.ContainsKey("next_cursor")
+	prev = data.Value("prev_cursor").String().Raw()
+
+	data = get(map[string]interface{}{"limit": 2, "cursor": prev})
+	data.Value("entries").Array().Length().Equal(1)
+	data.NotContainsKey("prev_cursor")
+
+	for _, query := range []map[string]interface{}{
+		{"cursor": "garbage"},
+		{"cursor": next + "a"},
+		{"cursor": next, "offset": 1},
+		{"offset": -1},
+		{"limit": 0},
+	} {
+		req := e.GET("/blogs").
+			WithHeader("X-User-Token", tc.token)
+		for k, v := range query {
+			req = req.WithQuery(k, v)
+		}
+		req.Expect().Status(400).
+			JSON().Object().ValueEqual("status", "fail")
+	}
+}
+
 func TestInsertUpdateDeleteBlog(t *testing.T) {
 	tc, err := cleanDBWithLogin()
 	require.Nil(t, err)
@@ -1,6 +1,7 @@
 package api
 
 import (
+	ctx "context"
 	"fmt"
 	"sync"
 
@@ -71,9 +72,10 @@ type Cache struct {
 	releaseProperties *SyncedLookupTable
 	releaseRoles      *SyncedLookupTable
 	privileges        *SyncedLookupTable
+	userClasses       *SyncedLookupTable
 }
 
-func NewCache(db db.BoilingDB) (Cache, error) {
+func NewCache(dbCtx ctx.Context, db db.BoilingDB) (Cache, error) {
 	c := Cache{
 		formats:           new(SyncedLookupTable),
 		leechTypes:        new(SyncedLookupTable),
@@ -82,39 +84,45 @@ func NewCache(db db.BoilingDB) (Cache, error) {
 		releaseProperties: new(SyncedLookupTable),
 		releaseRoles:      new(SyncedLookupTable),
 		privileges:        new(SyncedLookupTable),
+		userClasses:       new(SyncedLookupTable),
 	}
 
-	err := c.RefreshFormats(db)
+	err := c.RefreshFormats(dbCtx, db)
 	if err != nil {
 		return Cache{}, err
 	}
 
-	err = c.RefreshLeechTypes(db)
+	err = c.RefreshLeechTypes(dbCtx, db)
 	if err != nil {
 		return Cache{}, err
 	}
 
-	err = c.RefreshMedia(db)
+	err = c.RefreshMedia(dbCtx, db)
 	if err != nil {
 		return Cache{}, err
 	}
 
-	err = c.RefreshReleaseGroupTypes(db)
+	err = c.RefreshReleaseGroupTypes(dbCtx, db)
 	if err != nil {
 		return Cache{}, err
 	}
 
-	err = c.RefreshReleaseProperties(db)
+	err = c.RefreshReleaseProperties(dbCtx, db)
 	if err != nil {
 		return Cache{}, err
 	}
 
-	err = c.RefreshReleaseRoles(db)
+	err = c.RefreshReleaseRoles(dbCtx, db)
 	if err != nil {
 		return Cache{}, err
 	}
 
-	err = c.RefreshPrivileges(db)
+	err = c.RefreshPrivileges(dbCtx, db)
+	if err != nil {
+		return Cache{}, err
+	}
+
+	err = c.RefreshUserClasses(dbCtx, db)
 	if err != nil {
 		return Cache{}, err
 	}
@@ -122,11 +130,11 @@ func NewCache(db db.BoilingDB) (Cache, error) {
 	return c, nil
 }
 
-func (c Cache) RefreshFormats(db db.BoilingDB) error {
+func (c Cache) RefreshFormats(dbCtx ctx.Context, db db.BoilingDB) error {
 	c.formats.Lock()
 	defer c.formats.Unlock()
 
-	formats, err := db.GetAllFormats()
+	formats, err := db.GetAllFormats(dbCtx)
 	if err != nil {
 		return err
 	}
@@ -142,11 +150,11 @@ func (c Cache) RefreshFormats(db db.BoilingDB) error {
 	return nil
 }
 
-func (c Cache) RefreshLeechTypes(db db.BoilingDB) error {
+func (c Cache) RefreshLeechTypes(dbCtx ctx.Context, db db.BoilingDB) error {
 	c.leechTypes.Lock()
 	defer c.leechTypes.Unlock()
 
-	leechTypes, err := db.GetAllLeechTypes()
+	leechTypes, err := db.GetAllLeechTypes(dbCtx)
 	if err != nil {
 		return err
 	}
@@ -157,11 +165,11 @@ func (c Cache) RefreshLeechTypes(db db.BoilingDB) error {
 	return nil
 }
 
-func (c Cache) RefreshMedia(db db.BoilingDB) error {
+func (c Cache) RefreshMedia(dbCtx ctx.Context, db db.BoilingDB) error {
 	c.media.Lock()
 	defer c.media.Unlock()
 
-	media, err := db.GetAllMedia()
+	media, err := db.GetAllMedia(dbCtx)
 	if err != nil {
 		return err
 	}
@@ -172,11 +180,11 @@ func (c Cache) RefreshMedia(db db.BoilingDB) error {
 	return nil
 }
 
-func (c Cache) RefreshReleaseGroupTypes(db db.BoilingDB) error {
+func (c Cache) RefreshReleaseGroupTypes(dbCtx ctx.Context, db db.BoilingDB) error {
 	c.releaseGroupTypes.Lock()
 	defer c.releaseGroupTypes.Unlock()
 
-	releaseGroupTypes, err := db.GetAllReleaseGroupTypes()
+	releaseGroupTypes, err := db.GetAllReleaseGroupTypes(dbCtx)
 	if err != nil {
 		return err
 	}
@@ -187,11 +195,11 @@ func (c Cache) RefreshReleaseGroupTypes(db db.BoilingDB) error {
 	return nil
 }
 
-func (c Cache) RefreshReleaseProperties(db db.BoilingDB) error {
+func (c Cache) RefreshReleaseProperties(dbCtx ctx.Context, db db.BoilingDB) error {
 	c.releaseProperties.Lock()
 	defer c.releaseProperties.Unlock()
 
-	releaseProperties, err := db.GetAllReleaseProperties()
+	releaseProperties, err := db.GetAllReleaseProperties(dbCtx)
 	if err != nil {
 		return err
 	}
@@ -202,11 +210,11 @@ func (c Cache) RefreshReleaseProperties(db db.BoilingDB) error {
 	return nil
 }
 
-func (c Cache) RefreshReleaseRoles(db db.BoilingDB) error {
+func (c Cache) RefreshReleaseRoles(dbCtx ctx.Context, db db.BoilingDB) error {
 	c.releaseRoles.Lock()
 	defer c.releaseRoles.Unlock()
 
-	releaseRoles, err := db.GetAllReleaseGroupRoles()
+	releaseRoles, err := db.GetAllReleaseGroupRoles(dbCtx)
 	if err != nil {
 		return err
 	}
@@ -217,11 +225,11 @@ func (c Cache) RefreshReleaseRoles(db db.BoilingDB) error {
 	return nil
 }
 
-func (c Cache) RefreshPrivileges(db db.BoilingDB) error {
+func (c Cache) RefreshPrivileges(dbCtx ctx.Context, db db.BoilingDB) error {
 	c.privileges.Lock()
 	defer c.privileges.Unlock()
 
-	privileges, err := db.GetAllPrivileges()
+	privileges, err := db.GetAllPrivileges(dbCtx)
 	if err != nil {
 		return err
 	}
@@ -231,3 +239,18 @@ func (c Cache) RefreshPrivileges(db db.BoilingDB) error {
 
 	return nil
 }
+
+func (c Cache) RefreshUserClasses(dbCtx ctx.Context, db db.BoilingDB) error {
+	c.userClasses.Lock()
+	defer c.userClasses.Unlock()
+
+	userClasses, err := db.GetAllUserClasses(dbCtx)
+	if err != nil {
+		return err
+	}
+
+	t := BuildLookupTable(userClasses)
+	c.userClasses.l = t
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	ctx "context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -10,7 +11,7 @@ func TestCache(t *testing.T) {
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	c, err := NewCache(db)
+	c, err := NewCache(ctx.Background(), db)
 	require.Nil(t, err)
 	require.NotNil(t, c)
 
@@ -28,7 +29,7 @@ func BenchmarkCacheLookUp(b *testing.B) {
 	db, err := cleanDB()
 	require.Nil(b, err)
 
-	c, err := NewCache(db)
+	c, err := NewCache(ctx.Background(), db)
 	require.Nil(b, err)
 	b.ResetTimer()
 
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris"
+)
+
+// A cursor is a position in a list, between the row it names and the rows
+// following it, or preceding it if Backward is set.
+// Cursors are handed out to clients as next_cursor and prev_cursor.
+// They are opaque and signed, so clients can neither read nor forge them.
+type cursor struct {
+	// List names the list and its order, a cursor is only valid for the list
+	// it was issued for.
+	List string `json:"l"`
+
+	// Key is the sort key of the row, ID its ID.
+	Key string `json:"k"`
+	ID  int    `json:"i"`
+
+	Backward bool `json:"b,omitempty"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func (a *API) cursorSignature(payload string) []byte {
+	mac := hmac.New(sha256.New, a.cursorKey)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func (a *API) encodeCursor(c cursor) string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		// can not happen for strings, ints and bools
+		panic(err)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(a.cursorSignature(payload))
+}
+
+// decodeCursor decodes and verifies a cursor issued for list.
+func (a *API) decodeCursor(s, list string) (*cursor, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return nil, errInvalidCursor
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	if !hmac.Equal(sig, a.cursorSignature(parts[0])) {
+		return nil, errInvalidCursor
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var c cursor
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	if c.List != list {
+		return nil, errors.New("cursor belongs to a different list or order")
+	}
+
+	return &c, nil
+}
+
+func timeKey(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTimeKey(s string) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func intKey(i int) string {
+	return strconv.Itoa(i)
+}
+
+func parseIntKey(s string) (interface{}, error) {
+	return strconv.Atoi(s)
+}
+
+// page holds the pagination parameters of a request for a list.
+// Either offset or cursor is used, limit applies to both.
+type page struct {
+	list   string
+	offset int
+	limit  int
+	cursor *cursor
+}
+
+func (p page) backward() bool {
+	return p.cursor != nil && p.cursor.Backward
+}
+
+// parsePage parses the offset, limit and cursor URL parameters.
+// If it returns false, a response was already sent.
+func (a *API) parsePage(ctx *context, list string, defaultLimit, maxLimit int) (page, bool) {
+	p := page{list: list, limit: defaultLimit}
+
+	if ctx.URLParamExists("offset") {
+		var err error
+		p.offset, err = ctx.URLParamInt("offset")
+		if err != nil || p.offset < 0 {
+			ctx.Fail(userError(err, "invalid offset"), iris.StatusBadRequest)
+			return page{}, false
+		}
+	}
+
+	if ctx.URLParamExists("limit") {
+		var err error
+		p.limit, err = ctx.URLParamInt("limit")
+		if err != nil || p.limit < 1 {
+			ctx.Fail(userError(err, "invalid limit"), iris.StatusBadRequest)
+			return page{}, false
+		}
+	}
+	if p.limit > maxLimit {
+		p.limit = maxLimit
+	}
+
+	if s := ctx.URLParam("cursor"); s != "" {
+		if p.offset != 0 {
+			ctx.Fail(errors.New("offset and cursor are mutually exclusive"), iris.StatusBadRequest)
+			return page{}, false
+		}
+
+		var err error
+		p.cursor, err = a.decodeCursor(s, list)
+		if err != nil {
+			ctx.Fail(err, iris.StatusBadRequest)
+			return page{}, false
+		}
+	}
+
+	return p, true
+}
+
+// cursors returns the cursors to the pages around a page of n rows.
+// more reports whether the list continues beyond the page in the direction
+// of paging.
+// key returns the sort key and ID of the i-th row of the page.
+func (a *API) cursors(p page, n int, more bool, key func(i int) (string, int)) (next, prev string) {
+	if n == 0 {
+		return "", ""
+	}
+
+	first, firstID := key(0)
+	last, lastID := key(n - 1)
+	hasNext := more
+	hasPrev := p.cursor != nil || p.offset > 0
+	if p.backward() {
+		hasNext, hasPrev = true, more
+	}
+
+	if hasNext {
+		next = a.encodeCursor(cursor{List: p.list, Key: last, ID: lastID})
+	}
+	if hasPrev {
+		prev = a.encodeCursor(cursor{List: p.list, Key: first, ID: firstID, Backward: true})
+	}
+
+	return next, prev
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	a := &API{cursorKey: []byte("some secret")}
+
+	c := cursor{List: "blogs", Key: "2017-10-02T12:00:00Z", ID: 3, Backward: true}
+	s := a.encodeCursor(c)
+
+	decoded, err := a.decodeCursor(s, "blogs")
+	require.Nil(t, err)
+	require.Equal(t, c, *decoded)
+
+	_, err = a.decodeCursor(s, "release_groups/added/desc")
+	require.NotNil(t, err)
+
+	other := &API{cursorKey: []byte("other secret")}
+	_, err = other.decodeCursor(s, "blogs")
+	require.NotNil(t, err)
+
+	// forged payload with the original signature
+	parts := strings.Split(s, ".")
+	forged := a.encodeCursor(cursor{List: "blogs", Key: "2017-10-02T12:00:00Z", ID: 4})
+	_, err = a.decodeCursor(strings.Split(forged, ".")[0]+"."+parts[1], "blogs")
+	require.NotNil(t, err)
+
+	for _, s := range []string{"", ".", "garbage", "a.b.c", s + "a"} {
+		_, err = a.decodeCursor(s, "blogs")
+		require.NotNil(t, err, s)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"mime/multipart"
 	"strconv"
 	"strings"
 	"time"
@@ -18,6 +19,8 @@ const (
 	dTypeRawString
 	dTypeDate
 	dTypeTags
+	dTypeFile
+	dTypeStrings // all values of a field, sanitized like dTypeString, empty ones are dropped
 )
 
 type field struct {
@@ -55,6 +58,15 @@ func (f fields) getString(key string) (string, bool) {
 	return s, true
 }
 
+func (f fields) mustGetInt(key string) int {
+	i, ok := f.getInt(key)
+	if !ok {
+		panic(fmt.Sprintf("mustGetInt: key %s not found", key))
+	}
+
+	return i
+}
+
 func (f fields) getInt(key string) (int, bool) {
 	val, ok := f.fields[key]
 	if !ok {
@@ -69,6 +81,15 @@ func (f fields) getInt(key string) (int, bool) {
 	return i, true
 }
 
+func (f fields) mustGetDate(key string) time.Time {
+	d, ok := f.getDate(key)
+	if !ok {
+		panic(fmt.Sprintf("mustGetDate: key %s not found", key))
+	}
+
+	return d
+}
+
 func (f fields) getDate(key string) (time.Time, bool) {
 	val, ok := f.fields[key]
 	if !ok {
@@ -106,6 +127,52 @@ func (f fields) getTags(key string) ([]string, bool) {
 	return t, true
 }
 
+func (f fields) mustGetStrings(key string) []string {
+	s, ok := f.getStrings(key)
+	if !ok {
+		panic(fmt.Sprintf("mustGetStrings: key %s not found", key))
+	}
+
+	return s
+}
+
+func (f fields) getStrings(key string) ([]string, bool) {
+	val, ok := f.fields[key]
+	if !ok {
+		return nil, false
+	}
+
+	s, ok := val.([]string)
+	if !ok {
+		panic(fmt.Sprintf("field %s is %T but was requested as strings", key, val))
+	}
+
+	return s, true
+}
+
+func (f fields) mustGetFile(key string) *multipart.FileHeader {
+	h, ok := f.getFile(key)
+	if !ok {
+		panic(fmt.Sprintf("mustGetFile: key %s not found", key))
+	}
+
+	return h
+}
+
+func (f fields) getFile(key string) (*multipart.FileHeader, bool) {
+	val, ok := f.fields[key]
+	if !ok {
+		return nil, false
+	}
+
+	h, ok := val.(*multipart.FileHeader)
+	if !ok {
+		panic(fmt.Sprintf("field %s is %T but was requested as file", key, val))
+	}
+
+	return h, true
+}
+
 func (a *API) withFields(fields []field) func(*context) {
 	a.c.privileges.RLock()
 	defer a.c.privileges.RUnlock()
@@ -131,7 +198,13 @@ func (a *API) withFields(fields []field) func(*context) {
 		}
 
 		for _, f := range fields {
-			_, ok := ctx.Request().PostForm[f.name]
+			var ok bool
+			if f.dType == dTypeFile {
+				form := ctx.Request().MultipartForm
+				ok = form != nil && len(form.File[f.name]) > 0
+			} else {
+				_, ok = ctx.Request().PostForm[f.name]
+			}
 			if !ok {
 				if f.required {
 					ctx.Fail(fmt.Errorf("missing required field %s", f.name), iris.StatusBadRequest)
@@ -153,7 +226,9 @@ func (a *API) withFields(fields []field) func(*context) {
 			}
 
 			var parsed interface{}
-			if f.dType == dTypeTags {
+			if f.dType == dTypeFile {
+				parsed = ctx.Request().MultipartForm.File[f.name][0]
+			} else if f.dType == dTypeTags {
 				tags, err := prepareTags(ctx.PostValues(f.name))
 				if err != nil {
 					ctx.Fail(userError(err, fmt.Sprintf("invalid tags for field %s", f.name)), iris.StatusBadRequest)
@@ -167,6 +242,21 @@ func (a *API) withFields(fields []field) func(*context) {
 					continue
 				}
 				parsed = tags
+			} else if f.dType == dTypeStrings {
+				var values []string
+				for _, v := range ctx.PostValues(f.name) {
+					if sanitized := sanitizeString(v); len(sanitized) > 0 {
+						values = append(values, sanitized)
+					}
+				}
+				if len(values) == 0 {
+					if f.required {
+						ctx.Fail(fmt.Errorf("missing required field %s", f.name), iris.StatusBadRequest)
+						return
+					}
+					continue
+				}
+				parsed = values
 			} else {
 				raw := ctx.PostValue(f.name)
 				trimmed := strings.TrimSpace(raw)
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"time"
+
+	"github.com/kataras/iris"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type Invite struct {
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// UsedBy and UsedAt are not set for unused invites.
+	UsedBy *BaseUser  `json:"used_by,omitempty"`
+	UsedAt *time.Time `json:"used_at,omitempty"`
+}
+
+func inviteFromDBInvite(dbInv db.Invite) Invite {
+	inv := Invite{
+		Code:      dbInv.Code,
+		CreatedAt: dbInv.CreatedAt,
+	}
+	if dbInv.UsedBy != nil {
+		u := baseUserFromDBUser(*dbInv.UsedBy)
+		inv.UsedBy = &u
+	}
+	if dbInv.UsedAt.Valid {
+		inv.UsedAt = &dbInv.UsedAt.Time
+	}
+	return inv
+}
+
+type InvitesResponse struct {
+	Invites []Invite `json:"invites"`
+
+	// Remaining is the number of invites the user can still issue.
+	Remaining int `json:"remaining"`
+}
+
+type InviteResponse struct {
+	Invite Invite `json:"invite"`
+}
+
+type InviteTreeUser struct {
+	ID       int              `json:"id"`
+	Username string           `json:"username"`
+	Enabled  bool             `json:"enabled"`
+	JoinedAt time.Time        `json:"joined_at"`
+	Class    string           `json:"class"`
+	Invitees []InviteTreeUser `json:"invitees"`
+}
+
+type InviteTreeResponse struct {
+	User InviteTreeUser `json:"user"`
+
+	// Size is the number of users invited by the user, directly or
+	// indirectly.
+	Size int `json:"size"`
+}
+
+type DisableInviteTreeResponse struct {
+	InviteTreeResponse
+
+	// Disabled is the number of users that were disabled.
+	Disabled int `json:"disabled"`
+}
+
+// getInvites responds with the invites issued by the user making the
+// request, newest first.
+func (a *API) getInvites(ctx *context) {
+	u, err := a.db.GetUser(ctx.dbCtx, ctx.user.ID)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	invites, err := a.db.GetInvitesByUser(ctx.dbCtx, ctx.user.ID)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	resp := InvitesResponse{
+		Invites:   make([]Invite, 0, len(invites)),
+		Remaining: u.Invites,
+	}
+	for _, inv := range invites {
+		resp.Invites = append(resp.Invites, inviteFromDBInvite(inv))
+	}
+
+	ctx.Success(resp)
+}
+
+// postInvite issues an invite, which uses up one of the invites of the user
+// making the request.
+func (a *API) postInvite(ctx *context) {
+	inv := db.Invite{
+		CreatedBy: ctx.user,
+		CreatedAt: time.Now(),
+	}
+	err := a.db.InsertInvite(ctx.dbCtx, &inv)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to issue invite"), iris.StatusBadRequest)
+		return
+	}
+
+	ctx.Success(InviteResponse{inviteFromDBInvite(inv)})
+}
+
+type UserInvitesResponse struct {
+	Invites int `json:"invites"`
+}
+
+// setUserInvites sets the number of invites a user can still issue.
+// Staff can only set the invites of users below their own class.
+func (a *API) setUserInvites(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	if _, ok = a.userBelow(ctx, id); !ok {
+		return
+	}
+
+	invites := ctx.fields.mustGetInt("invites")
+	err := a.db.UpdateUserSetInvites(ctx.dbCtx, id, invites)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to set invites"), iris.StatusBadRequest)
+		return
+	}
+
+	ctx.Success(UserInvitesResponse{invites})
+}
+
+// inviteTree builds the invite tree of a user.
+func (a *API) inviteTree(ctx *context, u *db.User) (*InviteTreeResponse, error) {
+	nodes, err := a.db.GetInviteTree(ctx.dbCtx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitees := make(map[int][]db.User)
+	for _, n := range nodes {
+		invitees[n.InvitedBy] = append(invitees[n.InvitedBy], n.User)
+	}
+
+	var build func(u db.User) InviteTreeUser
+	build = func(u db.User) InviteTreeUser {
+		tu := InviteTreeUser{
+			ID:       u.ID,
+			Username: u.Username,
+			Enabled:  u.Enabled,
+			JoinedAt: u.JoinedAt,
+			Class:    a.c.userClasses.MustReverseLookUp(u.Class),
+			Invitees: make([]InviteTreeUser, 0, len(invitees[u.ID])),
+		}
+		for _, invitee := range invitees[u.ID] {
+			tu.Invitees = append(tu.Invitees, build(invitee))
+		}
+		return tu
+	}
+
+	return &InviteTreeResponse{
+		User: build(*u),
+		Size: len(nodes),
+	}, nil
+}
+
+// getInviteTree responds with a user and all users they invited, directly or
+// indirectly.
+func (a *API) getInviteTree(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	u, err := a.db.GetUser(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	resp, err := a.inviteTree(ctx, u)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	ctx.Success(resp)
+}
+
+// disableInviteTree disables a user and all users they invited, directly or
+// indirectly.
+// Staff can only disable branches rooted at users below their own class,
+// users in the branch who are not below their class are left alone.
+func (a *API) disableInviteTree(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	u, ok := a.userBelow(ctx, id)
+	if !ok {
+		return
+	}
+
+	nodes, err := a.db.GetInviteTree(ctx.dbCtx, u.ID)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	var ids []int
+	if u.Enabled {
+		ids = append(ids, u.ID)
+	}
+	for _, n := range nodes {
+		if n.User.Enabled && n.User.Class < ctx.user.Class {
+			ids = append(ids, n.User.ID)
+		}
+	}
+
+	err = a.db.UpdateUsersDisable(ctx.dbCtx, ids)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	u.Enabled = false
+	resp, err := a.inviteTree(ctx, u)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	ctx.Success(DisableInviteTreeResponse{
+		InviteTreeResponse: *resp,
+		Disabled:           len(ids),
+	})
+}
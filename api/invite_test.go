@@ -0,0 +1,190 @@
+package api
+
+import (
+	ctx "context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gavv/httpexpect.v1"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+func TestInvites(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+
+	a.cfg.InviteOnly = true
+	defer func() { a.cfg.InviteOnly = false }()
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	// Users can't invite
+	e.POST("/invites").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+
+	err = tc.db.SetUserClass(ctx.Background(), &db.UserClassChange{User: tc.user.ID, To: a.c.userClasses.MustLookUp("Power User"), Changed: time.Now()})
+	require.Nil(t, err)
+
+	// No invites left
+	e.POST("/invites").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(400)
+
+	err = tc.db.UpdateUserSetInvites(ctx.Background(), tc.user.ID, 1)
+	require.Nil(t, err)
+
+	invite := e.POST("/invites").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("invite").Object()
+	invite.Keys().ContainsOnly("code", "created_at")
+	code := invite.Value("code").String().Raw()
+
+	// Signup requires an invite
+	e.POST("/signup").
+		WithFormField("username", "invitee").
+		WithFormField("password", "pass123pass123").
+		WithFormField("email", "invitee@some.example.org").
+		Expect().Status(400)
+
+	e.POST("/signup").
+		WithFormField("username", "invitee").
+		WithFormField("password", "pass123pass123").
+		WithFormField("email", "invitee@some.example.org").
+		WithFormField("invite", "garbage").
+		Expect().Status(400)
+
+	e.POST("/signup").
+		WithFormField("username", "invitee").
+		WithFormField("password", "pass123pass123").
+		WithFormField("email", "invitee@some.example.org").
+		WithFormField("invite", code).
+		Expect().Status(200)
+
+	// Invites can only be used once
+	e.POST("/signup").
+		WithFormField("username", "invitee2").
+		WithFormField("password", "pass123pass123").
+		WithFormField("email", "invitee2@some.example.org").
+		WithFormField("invite", code).
+		Expect().Status(400)
+
+	data := e.GET("/invites").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.ValueEqual("remaining", 0)
+	invites := data.Value("invites").Array()
+	invites.Length().Equal(1)
+	invites.Element(0).Object().ValueEqual("code", code)
+	invites.Element(0).Object().Value("used_by").Object().ValueEqual("username", "invitee")
+
+	e.GET("/users").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("user").Object().ValueEqual("invites", 0)
+}
+
+func TestInviteTree(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	// tc.user -> inviter -> invitee
+	err = tc.db.UpdateUserSetInvites(dbCtx, tc.user.ID, 1)
+	require.Nil(t, err)
+	inv := db.Invite{CreatedBy: tc.user, CreatedAt: time.Now()}
+	err = tc.db.InsertInvite(dbCtx, &inv)
+	require.Nil(t, err)
+	err = tc.db.SignUpUserWithInvite(dbCtx, "inviter", "inviterpw12345", "inviter@ex.am.ple.com", inv.Code)
+	require.Nil(t, err)
+	inviter, err := tc.db.LoginAndGetUser(dbCtx, "inviter", "inviterpw12345")
+	require.Nil(t, err)
+	inviterToken, err := tc.db.InsertTokenForUser(dbCtx, *inviter)
+	require.Nil(t, err)
+
+	err = tc.db.UpdateUserSetInvites(dbCtx, inviter.ID, 1)
+	require.Nil(t, err)
+	inv = db.Invite{CreatedBy: *inviter, CreatedAt: time.Now()}
+	err = tc.db.InsertInvite(dbCtx, &inv)
+	require.Nil(t, err)
+	err = tc.db.SignUpUserWithInvite(dbCtx, "invitee", "inviteepw12345", "invitee@ex.am.ple.com", inv.Code)
+	require.Nil(t, err)
+
+	// Missing privilege
+	e.GET("/users/{id}/invite_tree", inviter.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+
+	err = tc.db.SetUserClass(dbCtx, &db.UserClassChange{User: tc.user.ID, To: a.c.userClasses.MustLookUp("Moderator"), Changed: time.Now()})
+	require.Nil(t, err)
+
+	data := e.GET("/users/{id}/invite_tree", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.ValueEqual("size", 2)
+	root := data.Value("user").Object()
+	root.ValueEqual("id", tc.user.ID)
+	child := root.Value("invitees").Array().Element(0).Object()
+	child.ValueEqual("username", "inviter")
+	child.Value("invitees").Array().Element(0).Object().ValueEqual("username", "invitee")
+
+	// Staff can set invites of users below them
+	e.POST("/users/{id}/invites", inviter.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("invites", 3).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().ValueEqual("invites", 3)
+
+	e.POST("/users/{id}/invites", inviter.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("invites", -1).
+		Expect().Status(400)
+
+	// Disable the branch below tc.user
+	data = e.POST("/users/{id}/invite_tree/disable", inviter.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.ValueEqual("disabled", 2)
+	data.ValueEqual("size", 1)
+	data.Value("user").Object().ValueEqual("enabled", false)
+	data.Value("user").Object().Value("invitees").Array().Element(0).Object().ValueEqual("enabled", false)
+
+	u, err := tc.db.GetUser(dbCtx, inviter.ID)
+	require.Nil(t, err)
+	require.False(t, u.Enabled)
+
+	// Disabled users lose API access
+	e.GET("/users").
+		WithHeader("X-User-Token", inviterToken.Token).
+		Expect().Status(401)
+
+	// Even with a token issued after they were disabled
+	tok, err := tc.db.InsertTokenForUser(dbCtx, *u)
+	require.Nil(t, err)
+	e.GET("/users").
+		WithHeader("X-User-Token", tok.Token).
+		Expect().Status(401).
+		JSON().Object().ValueEqual("message", "user disabled")
+
+	// The user is not below the caller
+	e.POST("/users/{id}/invite_tree/disable", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+
+	e.GET("/users/{id}/invite_tree", 1000).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(404)
+}
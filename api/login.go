@@ -15,27 +15,27 @@ func (a *API) postLogin(ctx *context) {
 	username := ctx.fields.mustGetString("username")
 	password := ctx.fields.mustGetString("password")
 
-	u, err := a.db.LoginAndGetUser(username, password)
+	u, err := a.db.LoginAndGetUser(ctx.dbCtx, username, password)
 	if err != nil {
 		ctx.Fail(userError(err, "unable to log in"), iris.StatusBadRequest)
 		return
 	}
 	u.PasswordHash = "" // just to be sure
 
-	err = a.db.UpdateUserSetLastLogin(u.ID, time.Now())
+	err = a.db.UpdateUserSetLastLogin(ctx.dbCtx, u.ID, time.Now())
 	if err != nil {
 		ctx.Error(err, iris.StatusInternalServerError)
 		return
 	}
 
-	tok, err := a.db.InsertTokenForUser(*u)
+	tok, err := a.db.InsertTokenForUser(ctx.dbCtx, *u)
 	if err != nil {
 		ctx.Error(err, iris.StatusInternalServerError)
 		return
 	}
 
 	ctx.Success(LoginResponse{
-		User:  userFromDBUser(*u),
+		User:  a.userFromDBUser(*u),
 		Token: tok.Token,
 	})
 }
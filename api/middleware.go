@@ -1,14 +1,41 @@
 package api
 
 import (
+	goctx "context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"sort"
 	"time"
 
 	"github.com/kataras/iris"
+
+	"github.com/boilingrip/boiling-api/tracker"
 )
 
+// withDBContext derives the context for the database queries of the request.
+// It is cancelled when the client disconnects, the query timeout expires or
+// the API is stopped.
+func (a *API) withDBContext(ctx *context) {
+	dbCtx, cancel := goctx.WithCancel(ctx.Request().Context())
+	defer cancel()
+	if a.cfg.QueryTimeout > 0 {
+		dbCtx, cancel = goctx.WithTimeout(dbCtx, a.cfg.QueryTimeout)
+		defer cancel()
+	}
+
+	go func() {
+		select {
+		case <-a.stopping.Done():
+			cancel()
+		case <-dbCtx.Done():
+		}
+	}()
+
+	ctx.dbCtx = dbCtx
+	ctx.Next()
+}
+
 func (a *API) withLogin(ctx *context) {
 	tokenString := ctx.GetHeader("X-User-Token")
 	if tokenString == "" {
@@ -16,20 +43,24 @@ func (a *API) withLogin(ctx *context) {
 		return
 	}
 
-	token, err := a.db.GetToken(tokenString)
+	token, err := a.db.GetToken(ctx.dbCtx, tokenString)
 	if err != nil {
 		// TODO distinguish errors
 		ctx.Fail(errors.New("invalid token"), iris.StatusUnauthorized)
 		return
 	}
+	if !token.User.Enabled {
+		ctx.Fail(errors.New("user disabled"), iris.StatusUnauthorized)
+		return
+	}
 
-	err = a.db.UpdateUserSetLastAccess(token.User.ID, time.Now())
+	err = a.db.UpdateUserSetLastAccess(ctx.dbCtx, token.User.ID, time.Now())
 	if err != nil {
 		ctx.Error(err, iris.StatusInternalServerError)
 		return
 	}
 
-	err = a.db.PopulateUserPrivileges(&token.User)
+	err = a.db.PopulateUserPrivileges(ctx.dbCtx, &token.User)
 	if err != nil {
 		ctx.Error(err, iris.StatusInternalServerError)
 		return
@@ -40,6 +71,23 @@ func (a *API) withLogin(ctx *context) {
 	ctx.Next()
 }
 
+// withTrackerSecret protects the internal tracker API by checking the shared
+// secret sent by the tracker.
+func (a *API) withTrackerSecret(ctx *context) {
+	secret := ctx.GetHeader(tracker.SecretHeader)
+	if len(secret) == 0 {
+		ctx.Fail(errors.New("missing secret"), iris.StatusUnauthorized)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(a.cfg.TrackerSecret)) != 1 {
+		ctx.Fail(errors.New("invalid secret"), iris.StatusUnauthorized)
+		return
+	}
+
+	ctx.Next()
+}
+
 func (a *API) containsPrivilege(userPrivileges []int, privilege string) (bool, error) {
 	a.c.privileges.RLock()
 	p, err := a.c.privileges.l.LookUp(privilege)
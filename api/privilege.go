@@ -0,0 +1,186 @@
+package api
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/kataras/iris"
+)
+
+type UserPrivilegesResponse struct {
+	// Privileges are the effective privileges of the user.
+	Privileges []string `json:"privileges"`
+
+	// Granted and Denied are the privileges granted or denied to the user
+	// individually, on top of their class.
+	Granted []string `json:"granted"`
+	Denied  []string `json:"denied"`
+}
+
+// validPrivilegeName checks that a privilege name is a lower case
+// identifier, like the existing privileges.
+func validPrivilegeName(s string) bool {
+	if len(s) == 0 || len(s) > 128 {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case i > 0 && (r == '_' || (r >= '0' && r <= '9')):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (a *API) privilegeNames(privileges []int) []string {
+	names := make([]string, 0, len(privileges))
+	for _, p := range privileges {
+		names = append(names, a.c.privileges.MustReverseLookUp(p))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// respondWithUserPrivileges responds with the privileges of the user with the
+// given ID.
+func (a *API) respondWithUserPrivileges(ctx *context, id int) {
+	u, err := a.db.GetUser(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	err = a.db.PopulateUserPrivileges(ctx.dbCtx, u)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	grants, err := a.db.GetUserPrivilegeGrants(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	var granted, denied []int
+	for p, g := range grants {
+		if g {
+			granted = append(granted, p)
+		} else {
+			denied = append(denied, p)
+		}
+	}
+
+	ctx.Success(UserPrivilegesResponse{
+		Privileges: a.privilegeNames(u.Privileges),
+		Granted:    a.privilegeNames(granted),
+		Denied:     a.privilegeNames(denied),
+	})
+}
+
+func (a *API) getUserPrivileges(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	a.respondWithUserPrivileges(ctx, id)
+}
+
+// grantUserPrivilege grants a privilege to a user, lifting a denial of it.
+// Staff can only grant privileges they have themselves.
+func (a *API) grantUserPrivilege(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	privilege := ctx.fields.mustGetString("privilege")
+	has, err := a.containsPrivilege(ctx.user.Privileges, privilege)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+	if !has {
+		ctx.Fail(errors.New("can not grant a privilege you do not have"), iris.StatusForbidden)
+		return
+	}
+
+	if _, ok = a.userBelow(ctx, id); !ok {
+		return
+	}
+
+	err = a.db.UpdateUserAddPrivileges(ctx.dbCtx, id, []int{a.c.privileges.MustLookUp(privilege)})
+	if err != nil {
+		ctx.Fail(userError(err, "unable to grant privilege"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithUserPrivileges(ctx, id)
+}
+
+// revokeUserPrivilege takes a privilege away from a user.
+// Privileges granted to the user individually are removed, privileges the
+// user has through their class are denied.
+func (a *API) revokeUserPrivilege(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	p, err := a.c.privileges.LookUp(ctx.Params().Get("privilege"))
+	if err != nil {
+		ctx.Fail(userError(err, "unknown privilege"), iris.StatusNotFound)
+		return
+	}
+
+	u, ok := a.userBelow(ctx, id)
+	if !ok {
+		return
+	}
+
+	classPrivileges, err := a.db.GetUserClassPrivileges(ctx.dbCtx, u.Class)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	i := sort.SearchInts(classPrivileges, p)
+	if i < len(classPrivileges) && classPrivileges[i] == p {
+		err = a.db.UpdateUserDenyPrivileges(ctx.dbCtx, id, []int{p})
+	} else {
+		err = a.db.UpdateUserRemovePrivileges(ctx.dbCtx, id, []int{p})
+	}
+	if err != nil {
+		ctx.Fail(userError(err, "unable to revoke privilege"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithUserPrivileges(ctx, id)
+}
+
+// createPrivilege creates a privilege and refreshes the privileges cache.
+// New privileges are given to the highest user class only.
+func (a *API) createPrivilege(ctx *context) {
+	privilege := ctx.fields.mustGetString("privilege")
+	if a.c.privileges.Has(privilege) {
+		ctx.Fail(errors.New("privilege already exists"), iris.StatusBadRequest)
+		return
+	}
+
+	_, err := a.db.InsertPrivilege(ctx.dbCtx, privilege)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to create privilege"), iris.StatusBadRequest)
+		return
+	}
+
+	err = a.c.RefreshPrivileges(ctx.dbCtx, a.db)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	a.getPrivileges(ctx)
+}
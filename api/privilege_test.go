@@ -0,0 +1,132 @@
+package api
+
+import (
+	ctx "context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gavv/httpexpect.v1"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+func TestUserPrivileges(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	err = tc.db.SignUpUser(ctx.Background(), "someotheruser", "someotherpw12345", "other@ex.am.ple.com")
+	require.Nil(t, err)
+	other, err := tc.db.LoginAndGetUser(ctx.Background(), "someotheruser", "someotherpw12345")
+	require.Nil(t, err)
+
+	// Missing privilege
+	e.GET("/users/{id}/privileges", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+
+	err = tc.db.SetUserClass(ctx.Background(), &db.UserClassChange{User: tc.user.ID, To: a.c.userClasses.MustLookUp("Moderator"), Changed: time.Now()})
+	require.Nil(t, err)
+
+	obj := e.GET("/users/{id}/privileges", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object()
+	obj.ValueEqual("status", "success")
+	data := obj.Value("data").Object()
+	data.Keys().ContainsOnly("privileges", "granted", "denied")
+	data.Value("privileges").Array().Contains("get_artist", "download_torrent")
+	data.Value("privileges").Array().NotContains("post_blog")
+	data.Value("granted").Array().Empty()
+	data.Value("denied").Array().Empty()
+
+	// Grant a privilege
+	data = e.POST("/users/{id}/privileges", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("privilege", "post_blog").
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.Value("privileges").Array().Contains("post_blog")
+	data.Value("granted").Array().Equal([]string{"post_blog"})
+
+	// Moderators do not have create_privilege, so they can't grant it
+	e.POST("/users/{id}/privileges", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("privilege", "create_privilege").
+		Expect().Status(403)
+
+	e.POST("/users/{id}/privileges", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("privilege", "no_such_privilege").
+		Expect().Status(400)
+
+	// Revoking a granted privilege removes the grant
+	data = e.DELETE("/users/{id}/privileges/{privilege}", other.ID, "post_blog").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.Value("privileges").Array().NotContains("post_blog")
+	data.Value("granted").Array().Empty()
+	data.Value("denied").Array().Empty()
+
+	// Revoking a privilege of the class denies it
+	data = e.DELETE("/users/{id}/privileges/{privilege}", other.ID, "get_artist").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.Value("privileges").Array().NotContains("get_artist")
+	data.Value("denied").Array().Equal([]string{"get_artist"})
+
+	e.DELETE("/users/{id}/privileges/{privilege}", other.ID, "no_such_privilege").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(404)
+
+	// The user is not below the caller
+	e.DELETE("/users/{id}/privileges/{privilege}", 1, "get_artist").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+}
+
+func TestCreatePrivilege(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	e.POST("/privileges").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("privilege", "some_privilege").
+		Expect().Status(403)
+
+	err = givePrivileges(a, tc.user.ID, "create_privilege")
+	require.Nil(t, err)
+
+	e.POST("/privileges").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("privilege", "Some Privilege").
+		Expect().Status(400)
+
+	e.POST("/privileges").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("privilege", "some_privilege").
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("privileges").Array().Contains("some_privilege")
+
+	// The cache knows the new privilege
+	require.True(t, a.c.privileges.Has("some_privilege"))
+	e.GET("/privileges").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("privileges").Array().Contains("some_privilege")
+
+	e.POST("/privileges").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("privilege", "some_privilege").
+		Expect().Status(400)
+}
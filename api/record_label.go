@@ -0,0 +1,296 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris"
+	"github.com/lib/pq"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type BaseRecordLabel struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func baseRecordLabelFromDBRecordLabel(dbL *db.RecordLabel) BaseRecordLabel {
+	return BaseRecordLabel{
+		ID:   dbL.ID,
+		Name: dbL.Name,
+	}
+}
+
+type RecordLabel struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Description *string           `json:"description,omitempty"`
+	Founded     *time.Time        `json:"founded,omitempty"`
+	Added       time.Time         `json:"added"`
+	AddedBy     BaseUser          `json:"added_by"`
+	Parent      *BaseRecordLabel  `json:"parent,omitempty"`
+	SubLabels   []BaseRecordLabel `json:"sub_labels,omitempty"`
+}
+
+func recordLabelFromDBRecordLabel(dbL *db.RecordLabel) RecordLabel {
+	l := RecordLabel{
+		ID:      dbL.ID,
+		Name:    dbL.Name,
+		Added:   dbL.Added,
+		AddedBy: baseUserFromDBUser(dbL.AddedBy),
+	}
+	if dbL.Description.Valid {
+		l.Description = &dbL.Description.String
+	}
+	if dbL.Founded.Valid {
+		l.Founded = &dbL.Founded.Time
+	}
+	if dbL.Parent != nil {
+		parent := baseRecordLabelFromDBRecordLabel(dbL.Parent)
+		l.Parent = &parent
+	}
+
+	return l
+}
+
+type RecordLabelResponse struct {
+	RecordLabel RecordLabel `json:"record_label"`
+}
+
+type RecordLabelsResponse struct {
+	RecordLabels []RecordLabel `json:"record_labels"`
+}
+
+type RecordLabelReleasesResponse struct {
+	Releases   []Release `json:"releases"`
+	Total      int       `json:"total"`
+	Offset     int       `json:"offset"`
+	Limit      int       `json:"limit"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	PrevCursor string    `json:"prev_cursor,omitempty"`
+}
+
+const (
+	defaultRecordLabelReleasesLimit = 50
+	maxRecordLabelReleasesLimit     = 50
+)
+
+// respondWithRecordLabel responds with the record label and its sub-labels.
+// It responds with 404 if the label does not exist.
+func (a *API) respondWithRecordLabel(ctx *context, id int) {
+	label, err := a.db.GetRecordLabel(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	subs, err := a.db.GetSubLabels(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	l := recordLabelFromDBRecordLabel(label)
+	for _, sub := range subs {
+		l.SubLabels = append(l.SubLabels, baseRecordLabelFromDBRecordLabel(&sub))
+	}
+
+	ctx.Success(RecordLabelResponse{RecordLabel: l})
+}
+
+func (a *API) getRecordLabel(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	a.respondWithRecordLabel(ctx, id)
+}
+
+func (a *API) autocompleteRecordLabel(ctx *context) {
+	s := ctx.Params().Get("s")
+	if len(s) == 0 {
+		ctx.Fail(errors.New("missing fragment"), iris.StatusBadRequest)
+		return
+	}
+
+	labels, err := a.db.AutocompleteRecordLabels(ctx.dbCtx, s)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	toReturn := make([]RecordLabel, 0, len(labels))
+	for _, label := range labels {
+		toReturn = append(toReturn, recordLabelFromDBRecordLabel(&label))
+	}
+
+	ctx.Success(RecordLabelsResponse{RecordLabels: toReturn})
+}
+
+func (a *API) postRecordLabel(ctx *context) {
+	description, _ := ctx.fields.getString("description")
+	founded, foundedSet := ctx.fields.getDate("founded")
+
+	label := db.RecordLabel{
+		Name:        ctx.fields.mustGetString("name"),
+		Description: sql.NullString{String: description, Valid: description != ""},
+		Founded:     pq.NullTime{Time: founded, Valid: foundedSet},
+		AddedBy:     ctx.user,
+	}
+	if parent, ok := ctx.fields.getInt("parent"); ok {
+		label.Parent = &db.RecordLabel{ID: parent}
+	}
+
+	err := a.db.InsertRecordLabel(ctx.dbCtx, &label)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to create record label"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithRecordLabel(ctx, label.ID)
+}
+
+func (a *API) updateRecordLabel(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	label, err := a.db.GetRecordLabel(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	if name, ok := ctx.fields.getString("name"); ok {
+		label.Name = name
+	}
+	// an empty description or parent removes it
+	if description, ok := ctx.fields.getString("description"); ok {
+		label.Description = sql.NullString{String: description, Valid: description != ""}
+	}
+	if founded, ok := ctx.fields.getDate("founded"); ok {
+		label.Founded = pq.NullTime{Time: founded, Valid: true}
+	}
+	if parent, ok := ctx.fields.getString("parent"); ok {
+		label.Parent = nil
+		if parent != "" {
+			parentID, _ := strconv.Atoi(parent)
+			label.Parent = &db.RecordLabel{ID: parentID}
+		}
+	}
+
+	err = a.db.UpdateRecordLabel(ctx.dbCtx, *label, newEdit(ctx))
+	if err != nil {
+		ctx.Fail(userError(err, "unable to update record label"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithRecordLabel(ctx, id)
+}
+
+// catalogKey returns the sort key of a release in a label catalog for
+// cursors, the catalogue number follows the release date, if there is one.
+func catalogKey(r *db.Release) string {
+	key := timeKey(r.ReleaseDate)
+	if r.CatalogueNumber.Valid {
+		key += "|" + r.CatalogueNumber.String
+	}
+	return key
+}
+
+func parseCatalogKey(s string, id int) (db.RecordLabelReleaseCursor, error) {
+	parts := strings.SplitN(s, "|", 2)
+	releaseDate, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return db.RecordLabelReleaseCursor{}, err
+	}
+
+	c := db.RecordLabelReleaseCursor{ReleaseDate: releaseDate, ID: id}
+	if len(parts) == 2 {
+		c.CatalogueNumber = sql.NullString{String: parts[1], Valid: true}
+	}
+	return c, nil
+}
+
+func (a *API) getRecordLabelReleases(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	p, ok := a.parsePage(ctx, "labels/"+strconv.Itoa(id)+"/releases", defaultRecordLabelReleasesLimit, maxRecordLabelReleasesLimit)
+	if !ok {
+		return
+	}
+
+	var c db.RecordLabelReleaseCursor
+	if p.cursor != nil {
+		var err error
+		c, err = parseCatalogKey(p.cursor.Key, p.cursor.ID)
+		if err != nil {
+			ctx.Fail(errInvalidCursor, iris.StatusBadRequest)
+			return
+		}
+	}
+
+	_, err := a.db.GetRecordLabel(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	total, err := a.db.CountRecordLabelReleases(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	// One more release than requested is fetched to find out whether there
+	// is another page.
+	var releases []db.Release
+	switch {
+	case p.cursor == nil:
+		releases, err = a.db.GetRecordLabelReleases(ctx.dbCtx, id, p.offset, p.limit+1)
+	case p.backward():
+		releases, err = a.db.GetRecordLabelReleasesBefore(ctx.dbCtx, id, c, p.limit+1)
+	default:
+		releases, err = a.db.GetRecordLabelReleasesAfter(ctx.dbCtx, id, c, p.limit+1)
+	}
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	more := len(releases) > p.limit
+	if more {
+		if p.backward() {
+			releases = releases[1:]
+		} else {
+			releases = releases[:p.limit]
+		}
+	}
+
+	resp := RecordLabelReleasesResponse{
+		Releases: make([]Release, 0, len(releases)),
+		Total:    total,
+		Offset:   p.offset,
+		Limit:    p.limit,
+	}
+	for _, dbR := range releases {
+		r := a.releaseFromDBRelease(&dbR)
+		group := a.baseReleaseGroupFromDBReleaseGroup(&dbR.ReleaseGroup)
+		r.ReleaseGroup = &group
+		resp.Releases = append(resp.Releases, r)
+	}
+	resp.NextCursor, resp.PrevCursor = a.cursors(p, len(releases), more, func(i int) (string, int) {
+		return catalogKey(&releases[i]), releases[i].ID
+	})
+
+	ctx.Success(resp)
+}
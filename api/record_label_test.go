@@ -0,0 +1,221 @@
+package api
+
+import (
+	ctx "context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gavv/httpexpect.v1"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+func TestRecordLabels(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_record_label", "create_record_label", "update_record_label")
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	labelOf := func(resp *httpexpect.Response) *httpexpect.Object {
+		obj := resp.JSON().Object()
+		obj.ValueEqual("status", "success")
+		return obj.Value("data").Object().Value("record_label").Object()
+	}
+
+	// Create
+	label := labelOf(e.POST("/labels").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Warner").
+		WithFormField("description", "A major label").
+		WithFormField("founded", "1958-03-19T00:00:00Z").
+		Expect().Status(200))
+	label.Keys().ContainsOnly("id", "name", "description", "founded", "added", "added_by")
+	label.ValueEqual("name", "Warner")
+	label.ValueEqual("description", "A major label")
+	label.Value("added_by").Object().ValueEqual("id", tc.user.ID)
+	warner := int(label.Value("id").Number().Raw())
+
+	label = labelOf(e.POST("/labels").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Parlophone").
+		WithFormField("parent", warner).
+		Expect().Status(200))
+	label.Value("parent").Object().ValueEqual("id", warner)
+	label.Value("parent").Object().ValueEqual("name", "Warner")
+	parlophone := int(label.Value("id").Number().Raw())
+
+	e.POST("/labels").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Nobody").
+		WithFormField("parent", parlophone+100).
+		Expect().Status(400)
+
+	e.POST("/labels").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("description", "no name").
+		Expect().Status(400)
+
+	// Get
+	label = labelOf(e.GET("/labels/{id}", warner).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200))
+	label.Value("sub_labels").Array().Length().Equal(1)
+	label.Value("sub_labels").Array().Element(0).Object().ValueEqual("id", parlophone)
+
+	e.GET("/labels/{id}", parlophone+100).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(404)
+
+	e.GET("/labels/autocomplete/{s}", "parlo").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("record_labels").Array().Length().Equal(1)
+
+	// Update
+	label = labelOf(e.POST("/labels/{id}", warner).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Warner Music Group").
+		Expect().Status(200))
+	label.ValueEqual("name", "Warner Music Group")
+	label.ValueEqual("description", "A major label")
+
+	e.POST("/labels/{id}", warner).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("parent", parlophone).
+		Expect().Status(400)
+
+	label = labelOf(e.POST("/labels/{id}", parlophone).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("parent", "").
+		Expect().Status(200))
+	label.NotContainsKey("parent")
+
+	e.POST("/labels/{id}", parlophone).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("parent", "warner").
+		Expect().Status(400)
+}
+
+func TestRecordLabelReleases(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_record_label")
+	require.Nil(t, err)
+
+	g := db.ReleaseGroup{
+		Name:        "Discovery",
+		ReleaseDate: time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC),
+		Added:       time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC),
+		AddedBy:     db.User{ID: tc.user.ID},
+		Type:        0,
+	}
+	err = tc.db.InsertReleaseGroup(dbCtx, &g)
+	require.Nil(t, err)
+
+	l := db.RecordLabel{
+		Name:    "Virgin",
+		AddedBy: db.User{ID: tc.user.ID},
+	}
+	err = tc.db.InsertRecordLabel(dbCtx, &l)
+	require.Nil(t, err)
+
+	var ids []int
+	for _, catalogueNumber := range []string{"VJCP-2", "", "VJCP-1"} {
+		r := db.Release{
+			ReleaseGroup:    db.ReleaseGroup{ID: g.ID},
+			Medium:          0,
+			ReleaseDate:     time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC),
+			CatalogueNumber: sql.NullString{String: catalogueNumber},
+			RecordLabel:     db.RecordLabel{ID: l.ID},
+			Added:           time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC),
+			AddedBy:         db.User{ID: tc.user.ID},
+		}
+		err = tc.db.InsertRelease(dbCtx, &r)
+		require.Nil(t, err)
+		ids = append(ids, r.ID)
+	}
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	obj := e.GET("/labels/{id}/releases", l.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 2).
+		Expect().Status(200).
+		JSON().Object()
+	obj.ValueEqual("status", "success")
+	data := obj.Value("data").Object()
+	data.Keys().ContainsOnly("releases", "total", "offset", "limit", "next_cursor")
+	data.ValueEqual("total", 3)
+	data.ValueEqual("limit", 2)
+	releases := data.Value("releases").Array()
+	releases.Length().Equal(2)
+	releases.Element(0).Object().ValueEqual("id", ids[2])
+	releases.Element(0).Object().ValueEqual("catalogue_number", "VJCP-1")
+	releases.Element(0).Object().Value("release_group").Object().ValueEqual("name", "Discovery")
+	releases.Element(1).Object().ValueEqual("id", ids[0])
+
+	next := data.Value("next_cursor").String().Raw()
+
+	releases = e.GET("/labels/{id}/releases", l.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("offset", 2).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("releases").Array()
+	releases.Length().Equal(1)
+	releases.Element(0).Object().ValueEqual("id", ids[1])
+
+	// a release added to the start of the catalog does not shift the
+	// following pages
+	r := db.Release{
+		ReleaseGroup:    db.ReleaseGroup{ID: g.ID},
+		Medium:          0,
+		ReleaseDate:     time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC),
+		CatalogueNumber: sql.NullString{String: "VJCP-0"},
+		RecordLabel:     db.RecordLabel{ID: l.ID},
+		Added:           time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC),
+		AddedBy:         db.User{ID: tc.user.ID},
+	}
+	err = tc.db.InsertRelease(dbCtx, &r)
+	require.Nil(t, err)
+
+	data = e.GET("/labels/{id}/releases", l.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 2).
+		WithQuery("cursor", next).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	releases = data.Value("releases").Array()
+	releases.Length().Equal(1)
+	releases.Element(0).Object().ValueEqual("id", ids[1])
+	data.NotContainsKey("next_cursor")
+	prev := data.Value("prev_cursor").String().Raw()
+
+	releases = e.GET("/labels/{id}/releases", l.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 2).
+		WithQuery("cursor", prev).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("releases").Array()
+	releases.Length().Equal(2)
+	releases.Element(0).Object().ValueEqual("id", ids[2])
+	releases.Element(1).Object().ValueEqual("id", ids[0])
+
+	e.GET("/labels/{id}/releases", l.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("cursor", "garbage").
+		Expect().Status(400)
+
+	e.GET("/labels/{id}/releases", l.ID+100).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(404)
+}
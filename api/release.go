@@ -1,24 +1,28 @@
 package api
 
 import (
+	"database/sql"
+	"strconv"
 	"time"
 
+	"github.com/kataras/iris"
+
 	"github.com/boilingrip/boiling-api/db"
 )
 
 type Release struct {
-	ID              int          `json:"id"`
-	ReleaseGroup    ReleaseGroup `json:"release_group"`
-	Edition         *string      `json:"edition,omitempty"`
-	Medium          string       `json:"medium"`
-	ReleaseDate     time.Time    `json:"release_date"`
-	CatalogueNumber *string      `json:"catalogue_number,omitempty"`
-	//RecordLabel     RecordLabel
-	//Torrents        []Torrent
-	Added    time.Time `json:"added"`
-	AddedBy  BaseUser  `json:"added_by"`
-	Original bool      `json:"original"`
-	Tags     []string  `json:"tags,omitempty"`
+	ID              int               `json:"id"`
+	ReleaseGroup    *BaseReleaseGroup `json:"release_group,omitempty"` // not set for the releases of a release group
+	Edition         *string           `json:"edition,omitempty"`
+	Medium          string            `json:"medium"`
+	ReleaseDate     time.Time         `json:"release_date"`
+	CatalogueNumber *string           `json:"catalogue_number,omitempty"`
+	RecordLabel     BaseRecordLabel   `json:"record_label"`
+	Torrents        []Torrent         `json:"torrents,omitempty"`
+	Added           time.Time         `json:"added"`
+	AddedBy         BaseUser          `json:"added_by"`
+	Original        bool              `json:"original"`
+	Tags            []string          `json:"tags,omitempty"`
 
 	// Properties lists "official" properties of releases, for example
 	// "LossyMasterApproved", to be set by trusted users or staff.
@@ -30,6 +34,7 @@ func (a *API) releaseFromDBRelease(dbR *db.Release) Release {
 		ID:          dbR.ID,
 		Medium:      a.c.media.MustReverseLookUp(dbR.Medium),
 		ReleaseDate: dbR.ReleaseDate,
+		RecordLabel: baseRecordLabelFromDBRecordLabel(&dbR.RecordLabel),
 		Added:       dbR.Added,
 		AddedBy:     baseUserFromDBUser(dbR.AddedBy),
 		Original:    dbR.Original,
@@ -42,6 +47,10 @@ func (a *API) releaseFromDBRelease(dbR *db.Release) Release {
 	if dbR.CatalogueNumber.Valid {
 		r.CatalogueNumber = &dbR.CatalogueNumber.String
 	}
+	for _, dbT := range dbR.Torrents {
+		t := a.torrentFromDBTorrent(&dbT)
+		r.Torrents = append(r.Torrents, t)
+	}
 
 	return r
 }
@@ -49,3 +58,177 @@ func (a *API) releaseFromDBRelease(dbR *db.Release) Release {
 type ReleaseResponse struct {
 	Release Release `json:"release"`
 }
+
+// releaseResponse populates the torrents and release group of a release.
+func (a *API) releaseResponse(ctx *context, dbR *db.Release) (*ReleaseResponse, error) {
+	err := a.db.PopulateTorrents(ctx.dbCtx, dbR)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := a.db.GetReleaseGroup(ctx.dbCtx, dbR.ReleaseGroup.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := a.releaseFromDBRelease(dbR)
+	base := a.baseReleaseGroupFromDBReleaseGroup(group)
+	r.ReleaseGroup = &base
+
+	return &ReleaseResponse{Release: r}, nil
+}
+
+func (a *API) getRelease(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	release, err := a.db.GetRelease(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	resp, err := a.releaseResponse(ctx, release)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	ctx.Success(resp)
+}
+
+// respondWithRelease responds with the release with the given ID, as
+// returned by getRelease.
+func (a *API) respondWithRelease(ctx *context, id int) {
+	release, err := a.db.GetRelease(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	resp, err := a.releaseResponse(ctx, release)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	ctx.Success(resp)
+}
+
+func validBool(_ *context, v interface{}) bool {
+	_, err := strconv.ParseBool(v.(string))
+	return err == nil
+}
+
+func (a *API) postRelease(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	edition, _ := ctx.fields.getString("edition")
+	catalogueNumber, _ := ctx.fields.getString("catalogue_number")
+	original := false
+	if s, ok := ctx.fields.getString("original"); ok {
+		original, _ = strconv.ParseBool(s)
+	}
+	tags, _ := ctx.fields.getTags("tags")
+
+	release := db.Release{
+		ReleaseGroup:    db.ReleaseGroup{ID: id},
+		Edition:         sql.NullString{String: edition, Valid: edition != ""},
+		Medium:          a.c.media.MustLookUp(ctx.fields.mustGetString("medium")),
+		ReleaseDate:     ctx.fields.mustGetDate("release_date"),
+		CatalogueNumber: sql.NullString{String: catalogueNumber, Valid: catalogueNumber != ""},
+		RecordLabel:     db.RecordLabel{ID: ctx.fields.mustGetInt("record_label")},
+		Added:           time.Now(),
+		AddedBy:         ctx.user,
+		Original:        original,
+		Tags:            tags,
+	}
+
+	err := a.db.InsertRelease(ctx.dbCtx, &release)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to create release"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithRelease(ctx, release.ID)
+}
+
+func (a *API) updateRelease(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	release, err := a.db.GetRelease(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	// empty editions and catalogue numbers remove them
+	if edition, ok := ctx.fields.getString("edition"); ok {
+		release.Edition = sql.NullString{String: edition, Valid: edition != ""}
+	}
+	if catalogueNumber, ok := ctx.fields.getString("catalogue_number"); ok {
+		release.CatalogueNumber = sql.NullString{String: catalogueNumber, Valid: catalogueNumber != ""}
+	}
+	if medium, ok := ctx.fields.getString("medium"); ok {
+		release.Medium = a.c.media.MustLookUp(medium)
+	}
+	if releaseDate, ok := ctx.fields.getDate("release_date"); ok {
+		release.ReleaseDate = releaseDate
+	}
+	if label, ok := ctx.fields.getInt("record_label"); ok {
+		release.RecordLabel = db.RecordLabel{ID: label}
+	}
+	if s, ok := ctx.fields.getString("original"); ok {
+		release.Original, _ = strconv.ParseBool(s)
+	}
+	if tags, ok := ctx.fields.getTags("tags"); ok {
+		release.Tags = tags
+	}
+
+	err = a.db.UpdateRelease(ctx.dbCtx, *release, newEdit(ctx))
+	if err != nil {
+		ctx.Fail(userError(err, "unable to update release"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithRelease(ctx, id)
+}
+
+func (a *API) deleteRelease(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	err := a.db.DeleteRelease(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to delete release"), iris.StatusBadRequest)
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+func (a *API) setReleaseProperty(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	value, _ := ctx.fields.getString("value")
+	err := a.db.SetReleaseProperty(ctx.dbCtx, id, ctx.fields.mustGetString("property"), value, newEdit(ctx))
+	if err != nil {
+		ctx.Fail(userError(err, "unable to set property"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithRelease(ctx, id)
+}
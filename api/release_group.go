@@ -2,6 +2,10 @@ package api
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kataras/iris"
@@ -88,13 +92,13 @@ func (a *API) getReleaseGroup(ctx *context) {
 		return
 	}
 
-	group, err := a.db.GetReleaseGroup(id)
+	group, err := a.db.GetReleaseGroup(ctx.dbCtx, id)
 	if err != nil {
 		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
 		return
 	}
 
-	err = a.db.PopulateReleases(group)
+	err = a.populateReleases(ctx, group)
 	if err != nil {
 		ctx.Error(err, iris.StatusInternalServerError)
 		return
@@ -102,3 +106,519 @@ func (a *API) getReleaseGroup(ctx *context) {
 
 	ctx.Success(ReleaseGroupResponse{ReleaseGroup: a.releaseGroupFromDBReleaseGroup(group)})
 }
+
+// populateReleases populates the releases of a release group and their
+// torrents.
+func (a *API) populateReleases(ctx *context, group *db.ReleaseGroup) error {
+	err := a.db.PopulateReleases(ctx.dbCtx, group)
+	if err != nil {
+		return err
+	}
+
+	for i := range group.Releases {
+		err = a.db.PopulateTorrents(ctx.dbCtx, &group.Releases[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// respondWithReleaseGroup responds with the release group with the given ID,
+// as returned by getReleaseGroup.
+func (a *API) respondWithReleaseGroup(ctx *context, id int) {
+	group, err := a.db.GetReleaseGroup(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	err = a.populateReleases(ctx, group)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	ctx.Success(ReleaseGroupResponse{ReleaseGroup: a.releaseGroupFromDBReleaseGroup(group)})
+}
+
+// parseRoledArtists parses artist credits of the form <artist ID>:<role>.
+func (a *API) parseRoledArtists(values []string) ([]db.RoledArtist, error) {
+	var artists []db.RoledArtist
+	for _, v := range values {
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid artist %q, expected <artist ID>:<role>", v)
+		}
+
+		id, err := strconv.Atoi(parts[0])
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("invalid artist ID %q", parts[0])
+		}
+		role, err := a.c.releaseRoles.LookUp(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("unknown role %q", parts[1])
+		}
+
+		artists = append(artists, db.RoledArtist{Role: role, Artist: db.Artist{ID: id}})
+	}
+
+	return artists, nil
+}
+
+func (a *API) postReleaseGroup(ctx *context) {
+	artists, err := a.parseRoledArtists(ctx.fields.mustGetStrings("artists"))
+	if err != nil {
+		ctx.Fail(err, iris.StatusBadRequest)
+		return
+	}
+	tags, _ := ctx.fields.getTags("tags")
+
+	group := db.ReleaseGroup{
+		Name:        ctx.fields.mustGetString("name"),
+		Artists:     artists,
+		ReleaseDate: ctx.fields.mustGetDate("release_date"),
+		Added:       time.Now(),
+		AddedBy:     ctx.user,
+		Type:        a.c.releaseGroupTypes.MustLookUp(ctx.fields.mustGetString("type")),
+		Tags:        tags,
+	}
+
+	err = a.db.InsertReleaseGroup(ctx.dbCtx, &group)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to create release group"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithReleaseGroup(ctx, group.ID)
+}
+
+func (a *API) updateReleaseGroup(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	group, err := a.db.GetReleaseGroup(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	if name, ok := ctx.fields.getString("name"); ok {
+		group.Name = name
+	}
+	if typ, ok := ctx.fields.getString("type"); ok {
+		group.Type = a.c.releaseGroupTypes.MustLookUp(typ)
+	}
+	if releaseDate, ok := ctx.fields.getDate("release_date"); ok {
+		group.ReleaseDate = releaseDate
+	}
+	if tags, ok := ctx.fields.getTags("tags"); ok {
+		group.Tags = tags
+	}
+	if values, ok := ctx.fields.getStrings("artists"); ok {
+		group.Artists, err = a.parseRoledArtists(values)
+		if err != nil {
+			ctx.Fail(err, iris.StatusBadRequest)
+			return
+		}
+	}
+
+	err = a.db.UpdateReleaseGroup(ctx.dbCtx, *group, newEdit(ctx))
+	if err != nil {
+		ctx.Fail(userError(err, "unable to update release group"), iris.StatusBadRequest)
+		return
+	}
+
+	a.respondWithReleaseGroup(ctx, id)
+}
+
+func (a *API) deleteReleaseGroup(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	err := a.db.DeleteReleaseGroup(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to delete release group"), iris.StatusBadRequest)
+		return
+	}
+
+	ctx.Success(nil)
+}
+
+type ReleaseGroupsResponse struct {
+	ReleaseGroups []ReleaseGroup `json:"release_groups"`
+	Total         int            `json:"total"`
+	Offset        int            `json:"offset"`
+	Limit         int            `json:"limit"`
+	NextCursor    string         `json:"next_cursor,omitempty"`
+	PrevCursor    string         `json:"prev_cursor,omitempty"`
+}
+
+const (
+	defaultReleaseGroupSearchLimit = 50
+	maxReleaseGroupSearchLimit     = 50
+)
+
+type releaseGroupSort struct {
+	selector func() db.ColumnSelector
+
+	// key returns the sort key of a release group for cursors, parseKey
+	// parses it back.
+	key      func(g *db.ReleaseGroup) string
+	parseKey func(s string) (interface{}, error)
+}
+
+var releaseGroupSorts = map[string]releaseGroupSort{
+	"added": {
+		selector: db.ReleaseGroupAddedSelector,
+		key:      func(g *db.ReleaseGroup) string { return timeKey(g.Added) },
+		parseKey: parseTimeKey,
+	},
+	"release_date": {
+		selector: db.ReleaseGroupReleaseDateSelector,
+		key:      func(g *db.ReleaseGroup) string { return timeKey(g.ReleaseDate) },
+		parseKey: parseTimeKey,
+	},
+	"type": {
+		selector: db.ReleaseGroupTypeSelector,
+		key:      func(g *db.ReleaseGroup) string { return intKey(g.Type) },
+		parseKey: parseIntKey,
+	},
+}
+
+// allOf combines the conditions with AND, ignoring nil conditions.
+// It returns nil if there are none.
+func allOf(conds []db.Boolean) db.Boolean {
+	var res db.Boolean
+	for _, c := range conds {
+		if c == nil {
+			continue
+		}
+		if res == nil {
+			res = c
+			continue
+		}
+		res = db.And(res, c)
+	}
+	return res
+}
+
+// lookUpParam looks up the value of the URL parameter k in t.
+// It returns false if the parameter is not set.
+func lookUpParam(ctx *context, t *SyncedLookupTable, k string) (int, bool, error) {
+	s := ctx.URLParam(k)
+	if s == "" {
+		return 0, false, nil
+	}
+	i, err := t.LookUp(s)
+	if err != nil {
+		return 0, false, userError(err, "invalid "+k)
+	}
+	return i, true, nil
+}
+
+// releaseGroupSearchFilter builds a condition from the filters of a release
+// group search.
+// Filters are combined with AND. Values only ever end up in placeholders.
+// The release and torrent filters must be satisfied by the same release, so
+// medium=Vinyl&format=FLAC$Lossless means FLAC torrents of vinyl releases.
+func (a *API) releaseGroupSearchFilter(ctx *context) (db.Boolean, error) {
+	var filters []db.Boolean
+
+	typ, ok, err := lookUpParam(ctx, a.c.releaseGroupTypes, "type")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		filters = append(filters, db.Eq(db.ReleaseGroupTypeSelector(), typ))
+	}
+
+	if s := ctx.URLParam("release_date_from"); s != "" {
+		from, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, userError(err, "invalid release_date_from")
+		}
+		filters = append(filters, db.Gte(db.ReleaseGroupReleaseDateSelector(), from))
+	}
+
+	if s := ctx.URLParam("release_date_to"); s != "" {
+		to, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, userError(err, "invalid release_date_to")
+		}
+		filters = append(filters, db.Lte(db.ReleaseGroupReleaseDateSelector(), to))
+	}
+
+	if s := ctx.URLParam("decade"); s != "" {
+		decade, err := strconv.Atoi(s)
+		if err != nil || decade < 0 || decade%10 != 0 {
+			return nil, userError(err, "invalid decade")
+		}
+		from := time.Date(decade, 1, 1, 0, 0, 0, 0, time.UTC)
+		filters = append(filters, db.And(
+			db.Gte(db.ReleaseGroupReleaseDateSelector(), from),
+			db.Lt(db.ReleaseGroupReleaseDateSelector(), from.AddDate(10, 0, 0)),
+		))
+	}
+
+	tags, err := prepareTags(ctx.Request().URL.Query()["tag"])
+	if err != nil {
+		return nil, userError(err, "invalid tag")
+	}
+	for _, t := range tags {
+		filters = append(filters, db.ReleaseGroupHasTag(t))
+	}
+
+	if s := ctx.URLParam("artist"); s != "" {
+		artist, err := strconv.Atoi(s)
+		if err != nil || artist < 0 {
+			return nil, userError(err, "invalid artist")
+		}
+		filters = append(filters, db.ReleaseGroupHasArtist(artist))
+	}
+
+	var torrentFilters []db.Boolean
+	format, ok, err := lookUpParam(ctx, a.c.formats, "format")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		torrentFilters = append(torrentFilters, db.Eq(db.TorrentFormatSelector(), format))
+	}
+
+	leechType, ok, err := lookUpParam(ctx, a.c.leechTypes, "leech_type")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		torrentFilters = append(torrentFilters, db.Eq(db.TorrentLeechTypeSelector(), leechType))
+	}
+
+	var releaseFilters []db.Boolean
+	medium, ok, err := lookUpParam(ctx, a.c.media, "medium")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		releaseFilters = append(releaseFilters, db.Eq(db.ReleaseMediumSelector(), medium))
+	}
+	if len(torrentFilters) > 0 {
+		releaseFilters = append(releaseFilters, db.ReleaseTorrents(allOf(torrentFilters)))
+	}
+	if len(releaseFilters) > 0 {
+		filters = append(filters, db.ReleaseGroupReleases(allOf(releaseFilters)))
+	}
+
+	return allOf(filters), nil
+}
+
+// releaseGroupSearchSort parses the sort and order of a release group search.
+func releaseGroupSearchSort(ctx *context) (sortBy string, desc bool, err error) {
+	sortBy = "added"
+	if s := ctx.URLParam("sort"); s != "" {
+		sortBy = s
+	}
+	if _, ok := releaseGroupSorts[sortBy]; !ok {
+		return "", false, errors.New("invalid sort")
+	}
+
+	switch ctx.URLParam("order") {
+	case "", "desc":
+		desc = true
+	case "asc":
+	default:
+		return "", false, errors.New("invalid order")
+	}
+
+	return sortBy, desc, nil
+}
+
+func sortDirection(column db.ColumnSelector, desc bool) db.Sorter {
+	if desc {
+		return db.SortDescending(column)
+	}
+	return db.SortAscending(column)
+}
+
+// findReleaseGroups handles the parameters common to searching and browsing
+// release groups.
+// The returned query matches all release groups that match the filters,
+// regardless of pagination.
+// If it returns false, a response was already sent.
+func (a *API) findReleaseGroups(ctx *context) (*db.Query, ReleaseGroupsResponse, bool) {
+	filter, err := a.releaseGroupSearchFilter(ctx)
+	if err != nil {
+		ctx.Fail(err, iris.StatusBadRequest)
+		return nil, ReleaseGroupsResponse{}, false
+	}
+
+	sortBy, desc, err := releaseGroupSearchSort(ctx)
+	if err != nil {
+		ctx.Fail(err, iris.StatusBadRequest)
+		return nil, ReleaseGroupsResponse{}, false
+	}
+	srt := releaseGroupSorts[sortBy]
+
+	list := "release_groups/" + sortBy
+	if desc {
+		list += "/desc"
+	}
+	p, ok := a.parsePage(ctx, list, defaultReleaseGroupSearchLimit, maxReleaseGroupSearchLimit)
+	if !ok {
+		return nil, ReleaseGroupsResponse{}, false
+	}
+
+	q := db.NewQuery(filter)
+	q.SetSorter(sortDirection(srt.selector(), desc))
+
+	total, err := a.db.CountReleaseGroups(ctx.dbCtx, q)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return nil, ReleaseGroupsResponse{}, false
+	}
+
+	// One more release group than requested is fetched to find out whether
+	// there is another page.
+	pageQ := q
+	if p.cursor != nil {
+		key, err := srt.parseKey(p.cursor.Key)
+		if err != nil {
+			ctx.Fail(errInvalidCursor, iris.StatusBadRequest)
+			return nil, ReleaseGroupsResponse{}, false
+		}
+
+		if p.backward() {
+			// Walk the list in reverse, starting at the cursor.
+			pageQ = db.NewQuery(allOf([]db.Boolean{filter, db.Before(srt.selector(), desc, key, db.ReleaseGroupIDSelector(), p.cursor.ID)}))
+			pageQ.SetSorter(db.SortBy(sortDirection(srt.selector(), !desc), db.SortDescending(db.ReleaseGroupIDSelector())))
+		} else {
+			pageQ = db.NewQuery(allOf([]db.Boolean{filter, db.After(srt.selector(), desc, key, db.ReleaseGroupIDSelector(), p.cursor.ID)}))
+			pageQ.SetSorter(sortDirection(srt.selector(), desc))
+		}
+	}
+
+	groups, err := a.db.SearchReleaseGroups(ctx.dbCtx, pageQ, p.offset, p.limit+1)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return nil, ReleaseGroupsResponse{}, false
+	}
+
+	more := len(groups) > p.limit
+	if more {
+		groups = groups[:p.limit]
+	}
+	if p.backward() {
+		for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+			groups[i], groups[j] = groups[j], groups[i]
+		}
+	}
+
+	resp := ReleaseGroupsResponse{
+		ReleaseGroups: make([]ReleaseGroup, 0, len(groups)),
+		Total:         total,
+		Offset:        p.offset,
+		Limit:         p.limit,
+	}
+	for i := range groups {
+		resp.ReleaseGroups = append(resp.ReleaseGroups, a.releaseGroupFromDBReleaseGroup(&groups[i]))
+	}
+	resp.NextCursor, resp.PrevCursor = a.cursors(p, len(groups), more, func(i int) (string, int) {
+		return srt.key(&groups[i]), groups[i].ID
+	})
+
+	return q, resp, true
+}
+
+func (a *API) searchReleaseGroups(ctx *context) {
+	_, resp, ok := a.findReleaseGroups(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.Success(resp)
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// ReleaseGroupFacets contains the number of matching release groups for every
+// option of a filter.
+type ReleaseGroupFacets struct {
+	Types      map[string]int `json:"types"`
+	Decades    map[int]int    `json:"decades"`
+	Media      map[string]int `json:"media"`
+	Formats    map[string]int `json:"formats"`
+	LeechTypes map[string]int `json:"leech_types"`
+	Tags       []TagCount     `json:"tags"` // most common first
+}
+
+const maxTagFacets = 50
+
+// lookupFacet returns the counts of all options of t, including those without
+// matches.
+func lookupFacet(t *SyncedLookupTable, counts map[int]int) map[string]int {
+	facet := make(map[string]int)
+	for _, k := range t.Keys() {
+		facet[k] = 0
+	}
+	for id, count := range counts {
+		facet[t.MustReverseLookUp(id)] = count
+	}
+	return facet
+}
+
+func (a *API) releaseGroupFacetsFromDBReleaseGroupFacets(dbF *db.ReleaseGroupFacets) ReleaseGroupFacets {
+	f := ReleaseGroupFacets{
+		Types:      lookupFacet(a.c.releaseGroupTypes, dbF.Types),
+		Decades:    dbF.Decades,
+		Media:      lookupFacet(a.c.media, dbF.Media),
+		Formats:    lookupFacet(a.c.formats, dbF.Formats),
+		LeechTypes: lookupFacet(a.c.leechTypes, dbF.LeechTypes),
+		Tags:       make([]TagCount, 0, len(dbF.Tags)),
+	}
+
+	for tag, count := range dbF.Tags {
+		f.Tags = append(f.Tags, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(f.Tags, func(i, j int) bool {
+		if f.Tags[i].Count != f.Tags[j].Count {
+			return f.Tags[i].Count > f.Tags[j].Count
+		}
+		return f.Tags[i].Tag < f.Tags[j].Tag
+	})
+	if len(f.Tags) > maxTagFacets {
+		f.Tags = f.Tags[:maxTagFacets]
+	}
+
+	return f
+}
+
+type BrowseReleaseGroupsResponse struct {
+	ReleaseGroupsResponse
+	Facets ReleaseGroupFacets `json:"facets"`
+}
+
+func (a *API) browseReleaseGroups(ctx *context) {
+	q, resp, ok := a.findReleaseGroups(ctx)
+	if !ok {
+		return
+	}
+
+	facets, err := a.db.GetReleaseGroupFacets(ctx.dbCtx, q)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	ctx.Success(BrowseReleaseGroupsResponse{
+		ReleaseGroupsResponse: resp,
+		Facets:                a.releaseGroupFacetsFromDBReleaseGroupFacets(facets),
+	})
+}
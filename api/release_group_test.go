@@ -1,7 +1,9 @@
 package api
 
 import (
+	ctx "context"
 	"database/sql"
+	"strconv"
 	"testing"
 	"time"
 
@@ -11,6 +13,8 @@ import (
 )
 
 func TestGetReleaseGroup(t *testing.T) {
+	dbCtx := ctx.Background()
+
 	tc, err := cleanDBWithLogin()
 	require.Nil(t, err)
 	a, err := getDefaultAPIWithDB(tc.db)
@@ -30,7 +34,7 @@ func TestGetReleaseGroup(t *testing.T) {
 		AddedBy: db.User{ID: 1},
 	}
 
-	err = tc.db.InsertArtist(&a1)
+	err = tc.db.InsertArtist(dbCtx, &a1)
 	require.Nil(t, err)
 
 	g := db.ReleaseGroup{
@@ -48,7 +52,7 @@ func TestGetReleaseGroup(t *testing.T) {
 		Tags:        []string{"electronic", "canadian"},
 	}
 
-	err = tc.db.InsertReleaseGroup(&g)
+	err = tc.db.InsertReleaseGroup(dbCtx, &g)
 	require.Nil(t, err)
 
 	l := db.RecordLabel{
@@ -56,7 +60,7 @@ func TestGetReleaseGroup(t *testing.T) {
 		AddedBy: db.User{ID: 1},
 	}
 
-	err = tc.db.InsertRecordLabel(&l)
+	err = tc.db.InsertRecordLabel(dbCtx, &l)
 	require.Nil(t, err)
 
 	r := db.Release{
@@ -72,7 +76,22 @@ func TestGetReleaseGroup(t *testing.T) {
 		Properties:      map[string]string{"LossyWebApproved": "", "LossyMasterApproved": "true"},
 	}
 
-	err = tc.db.InsertRelease(&r)
+	err = tc.db.InsertRelease(dbCtx, &r)
+	require.Nil(t, err)
+
+	tor := db.Torrent{
+		Release:    db.Release{ID: r.ID},
+		Uploaded:   time.Date(2012, 3, 4, 0, 0, 0, 0, time.FixedZone("", 0)),
+		UploadedBy: db.User{ID: 1},
+		InfoHash:   [20]byte{1, 2, 3},
+		Info:       []byte("d4:name1:ae"),
+		Format:     0,
+		Size:       1000,
+		LeechType:  0,
+		FileList:   []db.TorrentFile{{Path: "01 - Some Chords.flac", Size: 1000}},
+	}
+
+	err = tc.db.InsertTorrent(dbCtx, &tor)
 	require.Nil(t, err)
 
 	e := httpexpect.New(t, "http://localhost:8080")
@@ -107,5 +126,408 @@ func TestGetReleaseGroup(t *testing.T) {
 	release := group.Value("releases").Array().Element(0).Object()
 	//release.Keys().ContainsOnly("id")
 	release.ValueEqual("id", r.ID)
+	release.Value("torrents").Array().Length().Equal(1)
+
+	torrent := release.Value("torrents").Array().Element(0).Object()
+	torrent.ValueEqual("id", tor.ID)
+	torrent.ValueEqual("format", "FLAC$Lossless")
+	torrent.ValueEqual("leech_type", "Normal")
+	torrent.ValueEqual("size", tor.Size)
+	torrent.Value("file_list").Array().Length().Equal(1)
+
+}
+
+func TestSearchReleaseGroups(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_release_group")
+	require.Nil(t, err)
+
+	a1 := db.Artist{
+		Name:    "deadmau5",
+		Added:   time.Date(2010, 03, 02, 12, 34, 0, 0, time.FixedZone("", 0)),
+		AddedBy: db.User{ID: 1},
+	}
+	err = tc.db.InsertArtist(dbCtx, &a1)
+	require.Nil(t, err)
+
+	groups := []db.ReleaseGroup{
+		{
+			Name:        "4x4=12",
+			ReleaseDate: time.Date(2010, 12, 3, 0, 0, 0, 0, time.FixedZone("", 0)),
+			Type:        0,
+			Tags:        []string{"electronic", "techno"},
+		},
+		{
+			Name:        "while(1<2)",
+			ReleaseDate: time.Date(2014, 6, 17, 0, 0, 0, 0, time.FixedZone("", 0)),
+			Type:        0,
+			Tags:        []string{"electronic"},
+		},
+		{
+			Name:        "Strobe",
+			ReleaseDate: time.Date(2009, 9, 22, 0, 0, 0, 0, time.FixedZone("", 0)),
+			Type:        2,
+			Tags:        []string{"electronic", "progressive.house"},
+		},
+	}
+	for i := range groups {
+		groups[i].Artists = []db.RoledArtist{{Role: 0, Artist: db.Artist{ID: a1.ID}}}
+		groups[i].Added = time.Date(2012, 2, 2, 2, 2, i, 0, time.FixedZone("", 0))
+		groups[i].AddedBy = db.User{ID: 1}
+		err = tc.db.InsertReleaseGroup(dbCtx, &groups[i])
+		require.Nil(t, err)
+	}
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	search := func(query map[string][]string) *httpexpect.Object {
+		req := e.GET("/release_groups/search").
+			WithHeader("X-User-Token", tc.token)
+		for k, vs := range query {
+			for _, v := range vs {
+				req = req.WithQuery(k, v)
+			}
+		}
+
+		obj := req.Expect().Status(200).JSON().Object()
+		obj.ValueEqual("status", "success")
+		data := obj.Value("data").Object()
+		data.Keys().Contains("release_groups", "total", "offset", "limit")
+		return data
+	}
+
+	ids := func(data *httpexpect.Object) []interface{} {
+		var res []interface{}
+		for _, v := range data.Value("release_groups").Array().Iter() {
+			res = append(res, v.Object().Value("id").Raw())
+		}
+		return res
+	}
+
+	// default: everything, most recently added first
+	data := search(nil)
+	data.Keys().ContainsOnly("release_groups", "total", "offset", "limit")
+	data.ValueEqual("total", 3)
+	data.ValueEqual("offset", 0)
+	data.ValueEqual("limit", 50)
+	require.Equal(t, []interface{}{float64(groups[2].ID), float64(groups[1].ID), float64(groups[0].ID)}, ids(data))
+	group := data.Value("release_groups").Array().Element(0).Object()
+	group.ValueEqual("name", groups[2].Name)
+	group.ValueEqual("type", "Single")
+	group.Value("artists").Array().Length().Equal(1)
+
+	data = search(map[string][]string{"type": {"Album"}, "sort": {"release_date"}, "order": {"asc"}})
+	data.ValueEqual("total", 2)
+	require.Equal(t, []interface{}{float64(groups[0].ID), float64(groups[1].ID)}, ids(data))
+
+	data = search(map[string][]string{
+		"release_date_from": {"2009-09-22T00:00:00Z"},
+		"release_date_to":   {"2010-12-31T00:00:00Z"},
+		"sort":              {"release_date"},
+	})
+	data.ValueEqual("total", 2)
+	require.Equal(t, []interface{}{float64(groups[0].ID), float64(groups[2].ID)}, ids(data))
+
+	data = search(map[string][]string{"tag": {"Electronic", "techno"}})
+	data.ValueEqual("total", 1)
+	require.Equal(t, []interface{}{float64(groups[0].ID)}, ids(data))
+
+	data = search(map[string][]string{"artist": {strconv.Itoa(a1.ID)}, "offset": {"1"}, "limit": {"1"}})
+	data.ValueEqual("total", 3)
+	data.ValueEqual("offset", 1)
+	data.ValueEqual("limit", 1)
+	require.Equal(t, []interface{}{float64(groups[1].ID)}, ids(data))
+
+	data = search(map[string][]string{"artist": {strconv.Itoa(a1.ID + 1)}})
+	data.ValueEqual("total", 0)
+	data.Value("release_groups").Array().Empty()
+
+	// cursors, oldest release first
+	data = search(map[string][]string{"sort": {"release_date"}, "order": {"asc"}, "limit": {"1"}})
+	require.Equal(t, []interface{}{float64(groups[2].ID)}, ids(data))
+	data.NotContainsKey("prev_cursor")
+	next := data.Value("next_cursor").String().Raw()
+
+	data = search(map[string][]string{"sort": {"release_date"}, "order": {"asc"}, "limit": {"1"}, "cursor": {next}})
+	data.ValueEqual("total", 3)
+	require.Equal(t, []interface{}{float64(groups[0].ID)}, ids(data))
+	prev := data.Value("prev_cursor").String().Raw()
+	next = data.Value("next_cursor").String().Raw()
+
+	data = search(map[string][]string{"sort": {"release_date"}, "order": {"asc"}, "limit": {"1"}, "cursor": {next}})
+	require.Equal(t, []interface{}{float64(groups[1].ID)}, ids(data))
+	data.NotContainsKey("next_cursor")
+
+	data = search(map[string][]string{"sort": {"release_date"}, "order": {"asc"}, "limit": {"1"}, "cursor": {prev}})
+	require.Equal(t, []interface{}{float64(groups[2].ID)}, ids(data))
+	data.NotContainsKey("prev_cursor")
+	data.ContainsKey("next_cursor")
+
+	// cursors are only valid for the sort and order they were issued for
+	e.GET("/release_groups/search").
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("sort", "release_date").
+		WithQuery("cursor", next).
+		Expect().Status(400).
+		JSON().Object().ValueEqual("status", "fail")
+
+	for _, query := range []map[string]string{
+		{"type": "Unknown type"},
+		{"release_date_from": "2010"},
+		{"release_date_to": "garbage"},
+		{"tag": "some tag"},
+		{"artist": "garbage"},
+		{"sort": "name"},
+		{"order": "up"},
+		{"offset": "-1"},
+		{"limit": "0"},
+		{"cursor": "garbage"},
+	} {
+		req := e.GET("/release_groups/search").
+			WithHeader("X-User-Token", tc.token)
+		for k, v := range query {
+			req = req.WithQuery(k, v)
+		}
+		req.Expect().Status(400).
+			JSON().Object().ValueEqual("status", "fail")
+	}
+}
+
+func TestBrowseReleaseGroups(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_release_group")
+	require.Nil(t, err)
+
+	groups := []db.ReleaseGroup{
+		{
+			Name:        "4x4=12",
+			ReleaseDate: time.Date(2010, 12, 3, 0, 0, 0, 0, time.FixedZone("", 0)),
+			Type:        0,
+			Tags:        []string{"electronic", "techno"},
+		},
+		{
+			Name:        "Strobe",
+			ReleaseDate: time.Date(2009, 9, 22, 0, 0, 0, 0, time.FixedZone("", 0)),
+			Type:        2,
+			Tags:        []string{"electronic"},
+		},
+	}
+	for i := range groups {
+		groups[i].Added = time.Date(2012, 2, 2, 2, 2, i, 0, time.FixedZone("", 0))
+		groups[i].AddedBy = db.User{ID: 1}
+		err = tc.db.InsertReleaseGroup(dbCtx, &groups[i])
+		require.Nil(t, err)
+	}
+
+	l := db.RecordLabel{
+		Name:    "mau5trap",
+		AddedBy: db.User{ID: 1},
+	}
+	err = tc.db.InsertRecordLabel(dbCtx, &l)
+	require.Nil(t, err)
+
+	r := db.Release{
+		ReleaseGroup: db.ReleaseGroup{ID: groups[0].ID},
+		Medium:       2,
+		ReleaseDate:  time.Date(2011, 3, 2, 0, 0, 0, 0, time.FixedZone("", 0)),
+		RecordLabel:  db.RecordLabel{ID: l.ID},
+		Added:        time.Date(2012, 3, 3, 0, 0, 2, 0, time.FixedZone("", 0)),
+		AddedBy:      db.User{ID: 1},
+		Original:     true,
+	}
+	err = tc.db.InsertRelease(dbCtx, &r)
+	require.Nil(t, err)
+
+	tor := db.Torrent{
+		Release:    db.Release{ID: r.ID},
+		Uploaded:   time.Date(2012, 3, 4, 0, 0, 0, 0, time.FixedZone("", 0)),
+		UploadedBy: db.User{ID: 1},
+		InfoHash:   [20]byte{1, 2, 3},
+		Info:       []byte("d4:name1:ae"),
+		Format:     0,
+		Size:       1000,
+		LeechType:  1,
+		FileList:   []db.TorrentFile{{Path: "01 - Some Chords.flac", Size: 1000}},
+	}
+	err = tc.db.InsertTorrent(dbCtx, &tor)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	browse := func(query map[string]string) *httpexpect.Object {
+		req := e.GET("/release_groups/browse").
+			WithHeader("X-User-Token", tc.token)
+		for k, v := range query {
+			req = req.WithQuery(k, v)
+		}
+
+		obj := req.Expect().Status(200).JSON().Object()
+		obj.ValueEqual("status", "success")
+		data := obj.Value("data").Object()
+		data.Keys().ContainsOnly("release_groups", "total", "offset", "limit", "facets")
+		return data
+	}
+
+	data := browse(nil)
+	data.ValueEqual("total", 2)
+	facets := data.Value("facets").Object()
+	facets.Keys().ContainsOnly("types", "decades", "media", "formats", "leech_types", "tags")
+	types := facets.Value("types").Object()
+	types.ValueEqual("Album", 1)
+	types.ValueEqual("Single", 1)
+	types.ValueEqual("EP", 0)
+	facets.Value("decades").Object().ValueEqual("2000", 1)
+	facets.Value("decades").Object().ValueEqual("2010", 1)
+	facets.Value("media").Object().ValueEqual("Vinyl", 1)
+	facets.Value("media").Object().ValueEqual("CD", 0)
+	facets.Value("formats").Object().ValueEqual("FLAC$Lossless", 1)
+	facets.Value("leech_types").Object().ValueEqual("Freeleech", 1)
+	facets.Value("leech_types").Object().ValueEqual("Normal", 0)
+	facets.Value("tags").Array().Equal([]interface{}{
+		map[string]interface{}{"tag": "electronic", "count": 2},
+		map[string]interface{}{"tag": "techno", "count": 1},
+	})
+
+	// facets follow the filters
+	data = browse(map[string]string{"decade": "2000"})
+	data.ValueEqual("total", 1)
+	data.Value("release_groups").Array().Element(0).Object().ValueEqual("id", groups[1].ID)
+	facets = data.Value("facets").Object()
+	facets.Value("types").Object().ValueEqual("Album", 0)
+	facets.Value("media").Object().ValueEqual("Vinyl", 0)
+	facets.Value("tags").Array().Length().Equal(1)
+
+	data = browse(map[string]string{"medium": "Vinyl", "format": "FLAC$Lossless", "leech_type": "Freeleech"})
+	data.ValueEqual("total", 1)
+	data.Value("release_groups").Array().Element(0).Object().ValueEqual("id", groups[0].ID)
+
+	// the torrent filters apply to torrents of the filtered releases
+	data = browse(map[string]string{"medium": "CD", "format": "FLAC$Lossless"})
+	data.ValueEqual("total", 0)
+
+	for _, query := range []map[string]string{
+		{"medium": "Tape"},
+		{"format": "FLAC"},
+		{"leech_type": "Free"},
+		{"decade": "2005"},
+		{"decade": "garbage"},
+	} {
+		req := e.GET("/release_groups/browse").
+			WithHeader("X-User-Token", tc.token)
+		for k, v := range query {
+			req = req.WithQuery(k, v)
+		}
+		req.Expect().Status(400).
+			JSON().Object().ValueEqual("status", "fail")
+	}
+}
+
+func TestInsertUpdateReleaseGroup(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_release_group", "create_release_group", "update_release_group", "delete_release_group")
+	require.Nil(t, err)
+
+	artist := db.Artist{
+		Name:    "Daft Punk",
+		Added:   time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC),
+		AddedBy: db.User{ID: tc.user.ID},
+	}
+	err = tc.db.InsertArtist(dbCtx, &artist)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	groupOf := func(resp *httpexpect.Response) *httpexpect.Object {
+		obj := resp.JSON().Object()
+		obj.ValueEqual("status", "success")
+		return obj.Value("data").Object().Value("release_group").Object()
+	}
+	credit := func(role string) string {
+		return strconv.Itoa(artist.ID) + ":" + role
+	}
+
+	// Create
+	group := groupOf(e.POST("/release_groups").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Homework").
+		WithFormField("type", "Album").
+		WithFormField("release_date", "1997-01-20T00:00:00Z").
+		WithFormField("artists", credit("Main")).
+		WithFormField("tags", "House").
+		Expect().Status(200))
+	group.ValueEqual("name", "Homework")
+	group.ValueEqual("type", "Album")
+	group.ValueEqual("tags", []string{"house"})
+	group.Value("artists").Array().Length().Equal(1)
+	group.Value("artists").Array().Element(0).Object().ValueEqual("role", "Main")
+	group.Value("added_by").Object().ValueEqual("id", tc.user.ID)
+	id := int(group.Value("id").Number().Raw())
+
+	for _, form := range []map[string]string{
+		{"name": "Homework", "type": "Album", "release_date": "1997-01-20T00:00:00Z"},
+		{"name": "Homework", "type": "Record", "release_date": "1997-01-20T00:00:00Z", "artists": credit("Main")},
+		{"name": "Homework", "type": "Album", "release_date": "1997", "artists": credit("Main")},
+		{"name": "Homework", "type": "Album", "release_date": "1997-01-20T00:00:00Z", "artists": credit("Singer")},
+		{"name": "Homework", "type": "Album", "release_date": "1997-01-20T00:00:00Z", "artists": strconv.Itoa(artist.ID)},
+		{"name": "Homework", "type": "Album", "release_date": "1997-01-20T00:00:00Z", "artists": strconv.Itoa(artist.ID+100) + ":Main"},
+	} {
+		req := e.POST("/release_groups").
+			WithHeader("X-User-Token", tc.token)
+		for k, v := range form {
+			req = req.WithFormField(k, v)
+		}
+		req.Expect().Status(400).
+			JSON().Object().ValueEqual("status", "fail")
+	}
+
+	// Update, fields that are not given are kept
+	group = groupOf(e.POST("/release_groups/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("type", "Compilation").
+		WithFormField("artists", credit("Main")).
+		WithFormField("artists", credit("Producer")).
+		Expect().Status(200))
+	group.ValueEqual("name", "Homework")
+	group.ValueEqual("type", "Compilation")
+	group.ValueEqual("tags", []string{"house"})
+	group.Value("artists").Array().Length().Equal(2)
+
+	e.POST("/release_groups/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("type", "Record").
+		Expect().Status(400)
 
+	e.POST("/release_groups/{id}", id+100).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Discovery").
+		Expect().Status(404)
+
+	// Delete
+	e.DELETE("/release_groups/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().ValueEqual("status", "success")
+
+	e.GET("/release_groups/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(404)
+
+	e.DELETE("/release_groups/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(400)
 }
@@ -0,0 +1,140 @@
+package api
+
+import (
+	ctx "context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gavv/httpexpect.v1"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+func TestInsertUpdateRelease(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_release_group", "create_release", "update_release", "delete_release", "set_release_property")
+	require.Nil(t, err)
+
+	g := db.ReleaseGroup{
+		Name:        "Discovery",
+		ReleaseDate: time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC),
+		Added:       time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC),
+		AddedBy:     db.User{ID: tc.user.ID},
+		Type:        0,
+	}
+	err = tc.db.InsertReleaseGroup(dbCtx, &g)
+	require.Nil(t, err)
+
+	l := db.RecordLabel{
+		Name:    "Virgin",
+		AddedBy: db.User{ID: tc.user.ID},
+	}
+	err = tc.db.InsertRecordLabel(dbCtx, &l)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	releaseOf := func(resp *httpexpect.Response) *httpexpect.Object {
+		obj := resp.JSON().Object()
+		obj.ValueEqual("status", "success")
+		return obj.Value("data").Object().Value("release").Object()
+	}
+
+	// Create
+	release := releaseOf(e.POST("/release_groups/{id}/releases", g.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("medium", "CD").
+		WithFormField("release_date", "2001-03-12T00:00:00Z").
+		WithFormField("record_label", l.ID).
+		WithFormField("edition", "Japan").
+		WithFormField("original", "true").
+		WithFormField("tags", "remaster").
+		Expect().Status(200))
+	release.Keys().ContainsOnly("id", "release_group", "edition", "medium", "release_date", "record_label", "added", "added_by", "original", "tags", "properties")
+	release.ValueEqual("medium", "CD")
+	release.ValueEqual("edition", "Japan")
+	release.ValueEqual("original", true)
+	release.ValueEqual("tags", []string{"remaster"})
+	release.Value("release_group").Object().ValueEqual("id", g.ID)
+	release.Value("release_group").Object().ValueEqual("type", "Album")
+	release.Value("record_label").Object().ValueEqual("name", "Virgin")
+	id := int(release.Value("id").Number().Raw())
+
+	release = releaseOf(e.GET("/releases/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200))
+	release.ValueEqual("id", id)
+
+	e.GET("/releases/{id}", id+100).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(404)
+
+	for _, form := range []map[string]interface{}{
+		{"medium": "Tape", "release_date": "2001-03-12T00:00:00Z", "record_label": l.ID},
+		{"medium": "CD", "release_date": "2001-03-12T00:00:00Z", "record_label": l.ID + 100},
+		{"medium": "CD", "release_date": "2001-03-12T00:00:00Z", "record_label": l.ID, "original": "maybe"},
+		{"medium": "CD", "release_date": "2001-03-12T00:00:00Z"},
+	} {
+		req := e.POST("/release_groups/{id}/releases", g.ID).
+			WithHeader("X-User-Token", tc.token)
+		for k, v := range form {
+			req = req.WithFormField(k, v)
+		}
+		req.Expect().Status(400).
+			JSON().Object().ValueEqual("status", "fail")
+	}
+
+	e.POST("/release_groups/{id}/releases", g.ID+100).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("medium", "CD").
+		WithFormField("release_date", "2001-03-12T00:00:00Z").
+		WithFormField("record_label", l.ID).
+		Expect().Status(400)
+
+	// Update, fields that are not given are kept, empty ones are removed
+	release = releaseOf(e.POST("/releases/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("medium", "Vinyl").
+		WithFormField("edition", "").
+		WithFormField("catalogue_number", "VJCP-68300").
+		Expect().Status(200))
+	release.ValueEqual("medium", "Vinyl")
+	release.NotContainsKey("edition")
+	release.ValueEqual("catalogue_number", "VJCP-68300")
+	release.ValueEqual("original", true)
+	release.ValueEqual("tags", []string{"remaster"})
+
+	e.POST("/releases/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("medium", "Tape").
+		Expect().Status(400)
+
+	// Properties
+	release = releaseOf(e.POST("/releases/{id}/properties", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("property", "LossyWebApproved").
+		WithFormField("value", "yes").
+		Expect().Status(200))
+	release.Value("properties").Object().ValueEqual("LossyWebApproved", "yes")
+
+	e.POST("/releases/{id}/properties", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("property", "Approved").
+		Expect().Status(400)
+
+	// Delete
+	e.DELETE("/releases/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().ValueEqual("status", "success")
+
+	e.DELETE("/releases/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(400)
+}
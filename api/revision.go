@@ -0,0 +1,148 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/kataras/iris"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+// newEdit attributes an edit to the calling user.
+func newEdit(ctx *context) db.Edit {
+	return db.Edit{
+		Edited:   time.Now(),
+		EditedBy: ctx.user,
+	}
+}
+
+type Revision struct {
+	Revision int             `json:"revision"`
+	Edited   time.Time       `json:"edited"`
+	EditedBy BaseUser        `json:"edited_by"`
+	Snapshot json.RawMessage `json:"snapshot"`
+	Diff     json.RawMessage `json:"diff"`
+}
+
+func revisionFromDBRevision(dbR *db.Revision) Revision {
+	return Revision{
+		Revision: dbR.Revision,
+		Edited:   dbR.Edited,
+		EditedBy: baseUserFromDBUser(dbR.EditedBy),
+		Snapshot: dbR.Snapshot,
+		Diff:     dbR.Diff,
+	}
+}
+
+type HistoryResponse struct {
+	Revisions  []Revision `json:"revisions"`
+	Total      int        `json:"total"`
+	Offset     int        `json:"offset"`
+	Limit      int        `json:"limit"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	PrevCursor string     `json:"prev_cursor,omitempty"`
+}
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 50
+)
+
+// getHistory returns a handler that responds with the revisions of an
+// entity, newest first.
+// The history of deleted entities stays available.
+func (a *API) getHistory(entityType string) func(*context) {
+	return func(ctx *context) {
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		p, ok := a.parsePage(ctx, "history/"+entityType+"/"+strconv.Itoa(id), defaultHistoryLimit, maxHistoryLimit)
+		if !ok {
+			return
+		}
+		total, err := a.db.CountRevisions(ctx.dbCtx, entityType, id)
+		if err != nil {
+			ctx.Error(err, iris.StatusInternalServerError)
+			return
+		}
+
+		// One more revision than requested is fetched to find out whether
+		// there is another page.
+		// Revision numbers are unique per entity, cursors only need them.
+		var revisions []db.Revision
+		switch {
+		case p.cursor == nil:
+			revisions, err = a.db.GetRevisions(ctx.dbCtx, entityType, id, p.offset, p.limit+1)
+		case p.backward():
+			revisions, err = a.db.GetRevisionsBefore(ctx.dbCtx, entityType, id, p.cursor.ID, p.limit+1)
+		default:
+			revisions, err = a.db.GetRevisionsAfter(ctx.dbCtx, entityType, id, p.cursor.ID, p.limit+1)
+		}
+		if err != nil {
+			ctx.Error(err, iris.StatusInternalServerError)
+			return
+		}
+
+		more := len(revisions) > p.limit
+		if more {
+			if p.backward() {
+				revisions = revisions[1:]
+			} else {
+				revisions = revisions[:p.limit]
+			}
+		}
+
+		resp := HistoryResponse{
+			Revisions: make([]Revision, 0, len(revisions)),
+			Total:     total,
+			Offset:    p.offset,
+			Limit:     p.limit,
+		}
+		for _, dbR := range revisions {
+			resp.Revisions = append(resp.Revisions, revisionFromDBRevision(&dbR))
+		}
+		resp.NextCursor, resp.PrevCursor = a.cursors(p, len(revisions), more, func(i int) (string, int) {
+			return "", revisions[i].Revision
+		})
+
+		ctx.Success(resp)
+	}
+}
+
+// revert returns a handler that restores an entity to the state of one of
+// its revisions.
+// The revert is recorded as a new revision.
+func (a *API) revert(entityType string) func(*context) {
+	return func(ctx *context) {
+		id, ok := idParam(ctx)
+		if !ok {
+			return
+		}
+
+		err := a.db.RevertRevision(ctx.dbCtx, entityType, id, ctx.fields.mustGetInt("revision"), newEdit(ctx))
+		if err == sql.ErrNoRows {
+			ctx.Fail(userError(err, "revision not found"), iris.StatusNotFound)
+			return
+		}
+		if err != nil {
+			ctx.Fail(userError(err, "unable to revert"), iris.StatusBadRequest)
+			return
+		}
+
+		switch entityType {
+		case db.RevisionArtist:
+			a.respondWithArtist(ctx, id)
+		case db.RevisionReleaseGroup:
+			a.respondWithReleaseGroup(ctx, id)
+		case db.RevisionRelease:
+			a.respondWithRelease(ctx, id)
+		case db.RevisionRecordLabel:
+			a.respondWithRecordLabel(ctx, id)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gavv/httpexpect.v1"
+)
+
+func TestArtistHistory(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_artist", "create_artist", "update_artist",
+		"add_artist_alias", "add_artist_tag", "revert_revision")
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	id := int(e.POST("/artists").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Justice").
+		WithFormField("bio", "French duo").
+		WithFormField("tags", "electronic").
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("artist").Object().Value("id").Number().Raw())
+
+	e.POST("/artists/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Justice!").
+		WithFormField("bio", "").
+		Expect().Status(200)
+	e.POST("/artists/{id}/aliases", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("alias", "Gaspard and Xavier").
+		Expect().Status(200)
+	e.POST("/artists/{id}/tags", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("tags", "french").
+		Expect().Status(200)
+
+	obj := e.GET("/artists/{id}/history", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object()
+	obj.ValueEqual("status", "success")
+	data := obj.Value("data").Object()
+	data.ValueEqual("total", 4)
+	revisions := data.Value("revisions").Array()
+	revisions.Length().Equal(4)
+
+	// newest first
+	latest := revisions.Element(0).Object()
+	latest.Keys().ContainsOnly("revision", "edited", "edited_by", "snapshot", "diff")
+	latest.ValueEqual("revision", 4)
+	latest.Value("edited_by").Object().ValueEqual("id", tc.user.ID)
+	latest.Value("snapshot").Object().ValueEqual("aliases", []string{"Gaspard and Xavier"})
+	latest.Value("diff").Object().Keys().ContainsOnly("tags")
+	latest.Value("diff").Object().Value("tags").Object().ValueEqual("old", []string{"electronic"})
+	latest.Value("diff").Object().Value("tags").Object().ValueEqual("new", []string{"electronic", "french"})
+
+	update := revisions.Element(2).Object()
+	update.ValueEqual("revision", 2)
+	update.Value("diff").Object().Keys().ContainsOnly("name", "bio")
+	update.Value("diff").Object().Value("bio").Object().NotContainsKey("new")
+
+	first := revisions.Element(3).Object()
+	first.ValueEqual("revision", 1)
+	first.Value("diff").Object().Value("name").Object().NotContainsKey("old")
+	first.Value("diff").Object().Value("name").Object().ValueEqual("new", "Justice")
+
+	e.GET("/artists/{id}/history", id).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("offset", 3).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("revisions").Array().Length().Equal(1)
+
+	data = e.GET("/artists/{id}/history", id).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 2).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.NotContainsKey("prev_cursor")
+	next := data.Value("next_cursor").String().Raw()
+
+	// Revert to the first revision
+	artist := e.POST("/artists/{id}/revert", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("revision", 1).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("artist").Object()
+	artist.ValueEqual("name", "Justice")
+	artist.ValueEqual("bio", "French duo")
+	artist.ValueEqual("tags", []string{"electronic"})
+	artist.NotContainsKey("aliases")
+
+	latest = e.GET("/artists/{id}/history", id).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 1).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("revisions").Array().Element(0).Object()
+	latest.ValueEqual("revision", 5)
+	latest.Value("diff").Object().Keys().ContainsOnly("name", "bio", "aliases", "tags")
+
+	// the revert does not shift the following pages
+	data = e.GET("/artists/{id}/history", id).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 2).
+		WithQuery("cursor", next).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	revisions = data.Value("revisions").Array()
+	revisions.Length().Equal(2)
+	revisions.Element(0).Object().ValueEqual("revision", 2)
+	revisions.Element(1).Object().ValueEqual("revision", 1)
+	data.NotContainsKey("next_cursor")
+
+	revisions = e.GET("/artists/{id}/history", id).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 2).
+		WithQuery("cursor", data.Value("prev_cursor").String().Raw()).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("revisions").Array()
+	revisions.Length().Equal(2)
+	revisions.Element(0).Object().ValueEqual("revision", 4)
+	revisions.Element(1).Object().ValueEqual("revision", 3)
+
+	// cursors are only valid for the history they were issued for
+	e.GET("/labels/{id}/history", id).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("cursor", next).
+		Expect().Status(400)
+
+	e.POST("/artists/{id}/revert", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("revision", 6).
+		Expect().Status(404)
+
+	e.POST("/artists/{id}/revert", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("revision", 0).
+		Expect().Status(400)
+}
+
+func TestRecordLabelHistory(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_record_label", "create_record_label", "update_record_label")
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	id := int(e.POST("/labels").
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("name", "Virgin").
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("record_label").Object().Value("id").Number().Raw())
+
+	e.POST("/labels/{id}", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("description", "A label").
+		Expect().Status(200)
+
+	revisions := e.GET("/labels/{id}/history", id).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("revisions").Array()
+	revisions.Length().Equal(2)
+	revisions.Element(0).Object().Value("diff").Object().Keys().ContainsOnly("description")
+
+	// reverting is staff-only
+	e.POST("/labels/{id}/revert", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("revision", 1).
+		Expect().Status(403)
+
+	err = givePrivileges(a, tc.user.ID, "revert_revision")
+	require.Nil(t, err)
+
+	label := e.POST("/labels/{id}/revert", id).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("revision", 1).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("record_label").Object()
+	label.ValueEqual("name", "Virgin")
+	label.NotContainsKey("description")
+
+	// entities without revisions have an empty history
+	e.GET("/labels/{id}/history", id+100).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().ValueEqual("total", 0)
+}
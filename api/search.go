@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/kataras/iris"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type SearchResult struct {
+	Type      string `json:"type"`
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Highlight string `json:"highlight"`
+}
+
+func searchResultFromDBSearchResult(dbR db.SearchResult) SearchResult {
+	return SearchResult{
+		Type:      dbR.Type,
+		ID:        dbR.ID,
+		Name:      dbR.Name,
+		Highlight: dbR.Highlight,
+	}
+}
+
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// searchResultPrivileges are the privileges required to see results of each
+// type.
+var searchResultPrivileges = map[string]string{
+	db.SearchResultArtist:       "get_artist",
+	db.SearchResultReleaseGroup: "get_release_group",
+	db.SearchResultRecordLabel:  "get_record_label",
+}
+
+func (a *API) search(ctx *context) {
+	q := ctx.URLParam("q")
+	if len(db.SearchTerms(q)) == 0 {
+		ctx.Fail(errors.New("missing q"), iris.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if ctx.URLParamExists("limit") {
+		var err error
+		limit, err = ctx.URLParamInt("limit")
+		if err != nil || limit < 1 {
+			ctx.Fail(userError(err, "invalid limit"), iris.StatusBadRequest)
+			return
+		}
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	// Types the user is not allowed to see are skipped, unless they were
+	// asked for explicitly.
+	requested := ctx.Request().URL.Query()["type"]
+	explicit := len(requested) > 0
+	if !explicit {
+		requested = db.SearchResultTypes
+	}
+
+	var types []string
+	for _, t := range requested {
+		privilege, ok := searchResultPrivileges[t]
+		if !ok {
+			ctx.Fail(errors.New("invalid type"), iris.StatusBadRequest)
+			return
+		}
+
+		allowed, err := a.containsPrivilege(ctx.user.Privileges, privilege)
+		if err != nil {
+			ctx.Error(err, iris.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			if explicit {
+				ctx.Fail(errors.New("missing privileges"), iris.StatusForbidden)
+				return
+			}
+			continue
+		}
+
+		types = append(types, t)
+	}
+	if len(types) == 0 {
+		// db.Search would search all types.
+		ctx.Success(SearchResponse{Results: []SearchResult{}})
+		return
+	}
+
+	results, err := a.db.Search(ctx.dbCtx, q, types, limit)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	resp := SearchResponse{Results: make([]SearchResult, 0, len(results))}
+	for _, r := range results {
+		resp.Results = append(resp.Results, searchResultFromDBSearchResult(r))
+	}
+
+	ctx.Success(resp)
+}
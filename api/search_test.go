@@ -0,0 +1,124 @@
+package api
+
+import (
+	ctx "context"
+	"testing"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gavv/httpexpect.v1"
+)
+
+func TestSearch(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "get_release_group")
+	require.Nil(t, err)
+	// the class of new users has get_artist
+	err = denyPrivileges(a, tc.user.ID, "get_artist")
+	require.Nil(t, err)
+
+	artist := db.Artist{
+		Name:    "Motörhead",
+		Added:   time.Date(2010, 03, 02, 12, 34, 0, 0, time.FixedZone("", 0)),
+		AddedBy: db.User{ID: 1},
+	}
+	err = tc.db.InsertArtist(dbCtx, &artist)
+	require.Nil(t, err)
+
+	g := db.ReleaseGroup{
+		Name:        "Ace of Spades",
+		Artists:     []db.RoledArtist{{Role: 0, Artist: db.Artist{ID: artist.ID}}},
+		ReleaseDate: time.Date(1980, 11, 8, 0, 0, 0, 0, time.FixedZone("", 0)),
+		Added:       time.Date(2012, 2, 2, 2, 2, 2, 0, time.FixedZone("", 0)),
+		AddedBy:     db.User{ID: 1},
+		Type:        0,
+		Tags:        []string{"motorhead"},
+	}
+	err = tc.db.InsertReleaseGroup(dbCtx, &g)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	// artists require get_artist
+	obj := e.GET("/search").
+		WithQuery("q", "motorhead").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).JSON().Object()
+	obj.ValueEqual("status", "success")
+	obj.Value("data").Object().Keys().ContainsOnly("results")
+	results := obj.Value("data").Object().Value("results").Array()
+	results.Length().Equal(1)
+	results.Element(0).Object().ValueEqual("type", "release_group")
+	results.Element(0).Object().ValueEqual("id", g.ID)
+	results.Element(0).Object().ValueEqual("highlight", "Ace of Spades")
+
+	e.GET("/search").
+		WithQuery("q", "motorhead").
+		WithQuery("type", "artist").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+
+	err = givePrivileges(a, tc.user.ID, "get_artist")
+	require.Nil(t, err)
+
+	obj = e.GET("/search").
+		WithQuery("q", "Motorhead").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).JSON().Object()
+	results = obj.Value("data").Object().Value("results").Array()
+	results.Length().Equal(2)
+	result := results.Element(0).Object()
+	result.Keys().ContainsOnly("type", "id", "name", "highlight")
+	result.ValueEqual("type", "artist")
+	result.ValueEqual("id", artist.ID)
+	result.ValueEqual("name", "Motörhead")
+	result.ValueEqual("highlight", "<b>Motörhead</b>")
+	results.Element(1).Object().ValueEqual("type", "release_group")
+
+	obj = e.GET("/search").
+		WithQuery("q", "ace spa").
+		WithQuery("type", "release_group").
+		WithQuery("type", "record_label").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).JSON().Object()
+	results = obj.Value("data").Object().Value("results").Array()
+	results.Length().Equal(1)
+	results.Element(0).Object().ValueEqual("highlight", "<b>Ace</b> of <b>Spades</b>")
+
+	// record labels require get_record_label
+	err = denyPrivileges(a, tc.user.ID, "get_record_label")
+	require.Nil(t, err)
+
+	e.GET("/search").
+		WithQuery("q", "ace spa").
+		WithQuery("type", "record_label").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+
+	obj = e.GET("/search").
+		WithQuery("q", "motorhead").
+		WithQuery("limit", 1).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).JSON().Object()
+	obj.Value("data").Object().Value("results").Array().Length().Equal(1)
+
+	for _, query := range []map[string]string{
+		{},
+		{"q": "!!"},
+		{"q": "motorhead", "type": "user"},
+		{"q": "motorhead", "limit": "0"},
+	} {
+		req := e.GET("/search").
+			WithHeader("X-User-Token", tc.token)
+		for k, v := range query {
+			req = req.WithQuery(k, v)
+		}
+		req.Expect().Status(400)
+	}
+}
@@ -25,7 +25,18 @@ func (a *API) postSignup(ctx *context) {
 		return
 	}
 
-	err := a.db.SignUpUser(username, password, email)
+	invite, ok := ctx.fields.getString("invite")
+	if !ok && a.cfg.InviteOnly {
+		ctx.Fail(errors.New("missing invite"), iris.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if ok {
+		err = a.db.SignUpUserWithInvite(ctx.dbCtx, username, password, email, invite)
+	} else {
+		err = a.db.SignUpUser(ctx.dbCtx, username, password, email)
+	}
 	if err != nil {
 		ctx.Fail(userError(err, "unable to sign up"), iris.StatusBadRequest)
 		return
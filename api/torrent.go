@@ -0,0 +1,205 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris"
+
+	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/metainfo"
+	"github.com/boilingrip/boiling-api/ratiowatch"
+)
+
+type Torrent struct {
+	ID              int           `json:"id"`
+	Uploaded        time.Time     `json:"uploaded"`
+	UploadedBy      BaseUser      `json:"uploaded_by"`
+	InfoHash        string        `json:"info_hash"`
+	Format          string        `json:"format"`
+	Size            int64         `json:"size"`
+	Description     *string       `json:"description,omitempty"`
+	LeechType       string        `json:"leech_type"`
+	Leechers        int           `json:"leechers"`
+	Seeders         int           `json:"seeders"`
+	Snatches        int           `json:"snatches"`
+	TotalUploaded   int64         `json:"total_uploaded"`
+	TotalDownloaded int64         `json:"total_downloaded"`
+	FileList        []TorrentFile `json:"file_list,omitempty"`
+}
+
+type TorrentFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+func (a *API) torrentFromDBTorrent(dbT *db.Torrent) Torrent {
+	t := Torrent{
+		ID:              dbT.ID,
+		Uploaded:        dbT.Uploaded,
+		UploadedBy:      baseUserFromDBUser(dbT.UploadedBy),
+		InfoHash:        hex.EncodeToString(dbT.InfoHash[:]),
+		Format:          a.c.formats.MustReverseLookUp(dbT.Format),
+		Size:            dbT.Size,
+		LeechType:       a.c.leechTypes.MustReverseLookUp(dbT.LeechType),
+		Leechers:        dbT.Leechers,
+		Seeders:         dbT.Seeders,
+		Snatches:        dbT.Snatches,
+		TotalUploaded:   dbT.TotalUploaded,
+		TotalDownloaded: dbT.TotalDownloaded,
+	}
+	if dbT.Description.Valid {
+		t.Description = &dbT.Description.String
+	}
+	for _, f := range dbT.FileList {
+		t.FileList = append(t.FileList, TorrentFile{
+			Path: f.Path,
+			Size: f.Size,
+		})
+	}
+
+	return t
+}
+
+type TorrentResponse struct {
+	Torrent Torrent `json:"torrent"`
+}
+
+// maxTorrentFileSize is the maximum size of an uploaded .torrent file, in
+// bytes.
+const maxTorrentFileSize = 5 * 1024 * 1024
+
+func (a *API) postTorrent(ctx *context) {
+	id, err := ctx.Params().GetInt("id")
+	if err != nil {
+		ctx.Fail(userError(err, "invalid ID"), iris.StatusBadRequest)
+		return
+	}
+	if id < 0 {
+		ctx.Fail(errors.New("invalid ID"), iris.StatusBadRequest)
+		return
+	}
+
+	file := ctx.fields.mustGetFile("torrent")
+	format := ctx.fields.mustGetString("format")
+	description, _ := ctx.fields.getString("description")
+
+	if file.Size > maxTorrentFileSize {
+		ctx.Fail(errors.New("torrent file too large"), iris.StatusBadRequest)
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(f, maxTorrentFileSize+1))
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+	if len(raw) > maxTorrentFileSize {
+		ctx.Fail(errors.New("torrent file too large"), iris.StatusBadRequest)
+		return
+	}
+
+	m, err := metainfo.Parse(raw)
+	if err != nil {
+		ctx.Fail(userError(err, "invalid torrent file"), iris.StatusBadRequest)
+		return
+	}
+
+	release, err := a.db.GetRelease(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	t := db.Torrent{
+		Release:     *release,
+		Uploaded:    time.Now(),
+		UploadedBy:  ctx.user,
+		InfoHash:    m.InfoHash,
+		Info:        m.Info,
+		Format:      a.c.formats.MustLookUp(format),
+		Size:        m.TotalSize(),
+		LeechType:   a.c.leechTypes.MustLookUp("Normal"),
+		Description: sql.NullString{String: description, Valid: description != ""},
+	}
+	for _, mf := range m.Files {
+		t.FileList = append(t.FileList, db.TorrentFile{
+			Path: mf.Path,
+			Size: mf.Length,
+		})
+	}
+
+	err = a.db.InsertTorrent(ctx.dbCtx, &t)
+	if err != nil {
+		ctx.Fail(userError(err, "unable to upload torrent"), iris.StatusBadRequest)
+		return
+	}
+
+	ctx.Success(TorrentResponse{Torrent: a.torrentFromDBTorrent(&t)})
+}
+
+func (a *API) announceURL(passkey string) string {
+	return fmt.Sprintf("%s/%s/announce", strings.TrimSuffix(a.cfg.AnnounceBase, "/"), passkey)
+}
+
+func (a *API) downloadTorrent(ctx *context) {
+	id, err := ctx.Params().GetInt("id")
+	if err != nil {
+		ctx.Fail(userError(err, "invalid ID"), iris.StatusBadRequest)
+		return
+	}
+	if id < 0 {
+		ctx.Fail(errors.New("invalid ID"), iris.StatusBadRequest)
+		return
+	}
+
+	if !ctx.user.Enabled {
+		ctx.Fail(errors.New("user disabled"), iris.StatusForbidden)
+		return
+	}
+
+	if ratiowatch.DownloadsDisabled(&ctx.user, time.Now()) {
+		ctx.Fail(errors.New("downloads disabled by ratio watch"), iris.StatusForbidden)
+		return
+	}
+
+	passkey, err := a.db.GetPasskeyForUser(ctx.dbCtx, ctx.user.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.Fail(userError(err, "no valid passkey"), iris.StatusForbidden)
+			return
+		}
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	info, err := a.db.GetTorrentInfo(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return
+	}
+
+	torrentFile, err := metainfo.Build(info, a.announceURL(passkey.Passkey))
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	ctx.ContentType("application/x-bittorrent")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%d.torrent\"", id))
+	ctx.StatusCode(iris.StatusOK)
+	ctx.Write(torrentFile)
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	ctx "context"
+	"crypto/sha1"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gavv/httpexpect.v1"
+
+	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/metainfo"
+)
+
+func insertTestRelease(t *testing.T, d db.BoilingDB) db.Release {
+	dbCtx := ctx.Background()
+
+	a := db.Artist{
+		Name:    "deadmau5",
+		Added:   time.Date(2010, 03, 02, 12, 34, 0, 0, time.FixedZone("", 0)),
+		AddedBy: db.User{ID: 1},
+	}
+
+	err := d.InsertArtist(dbCtx, &a)
+	require.Nil(t, err)
+
+	g := db.ReleaseGroup{
+		Name: "4x4=12",
+		Artists: []db.RoledArtist{
+			{
+				Role:   0,
+				Artist: db.Artist{ID: a.ID},
+			},
+		},
+		ReleaseDate: time.Date(2010, 12, 13, 13, 14, 15, 0, time.FixedZone("", 0)),
+		Added:       time.Date(2012, 2, 2, 2, 2, 2, 0, time.FixedZone("", 0)),
+		AddedBy:     db.User{ID: 1},
+		Type:        0,
+	}
+
+	err = d.InsertReleaseGroup(dbCtx, &g)
+	require.Nil(t, err)
+
+	l := db.RecordLabel{
+		Name:    "mau5trap",
+		AddedBy: db.User{ID: 1},
+	}
+
+	err = d.InsertRecordLabel(dbCtx, &l)
+	require.Nil(t, err)
+
+	r := db.Release{
+		ReleaseGroup: db.ReleaseGroup{ID: g.ID},
+		Medium:       0,
+		ReleaseDate:  time.Date(2012, 3, 2, 0, 0, 0, 0, time.FixedZone("", 0)),
+		RecordLabel:  db.RecordLabel{ID: l.ID},
+		Added:        time.Date(2012, 3, 3, 0, 0, 2, 0, time.FixedZone("", 0)),
+		AddedBy:      db.User{ID: 1},
+		Original:     true,
+	}
+
+	err = d.InsertRelease(dbCtx, &r)
+	require.Nil(t, err)
+
+	return r
+}
+
+func testTorrentFile(t *testing.T) []byte {
+	b, err := metainfo.Encode(map[string]interface{}{
+		"announce": "http://some.other.tracker/announce",
+		"info": map[string]interface{}{
+			"name":         "deadmau5 - 4x4=12",
+			"piece length": int64(16384),
+			"pieces":       strings.Repeat("a", sha1.Size),
+			"files": []interface{}{
+				map[string]interface{}{
+					"length": int64(1000),
+					"path":   []interface{}{"01 - Some Chords.flac"},
+				},
+				map[string]interface{}{
+					"length": int64(2000),
+					"path":   []interface{}{"02 - Sofi Needs a Ladder.flac"},
+				},
+			},
+		},
+	})
+	require.Nil(t, err)
+	return b
+}
+
+func TestPostTorrent(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "upload_torrent")
+	require.Nil(t, err)
+
+	r := insertTestRelease(t, tc.db)
+	torrentFile := testTorrentFile(t)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	resp := e.POST("/releases/{id}/torrents", r.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithMultipart().
+		WithFileBytes("torrent", "test.torrent", torrentFile).
+		WithFormField("format", "FLAC$Lossless").
+		WithFormField("description", "some description").
+		Expect().Status(200)
+
+	obj := resp.JSON().Object()
+	obj.Keys().ContainsOnly("status", "data")
+	obj.ValueEqual("status", "success")
+	torrent := obj.Value("data").Object().Value("torrent").Object()
+	torrent.ValueEqual("format", "FLAC$Lossless")
+	torrent.ValueEqual("leech_type", "Normal")
+	torrent.ValueEqual("size", 3000)
+	torrent.ValueEqual("description", "some description")
+	torrent.Value("uploaded_by").Object().ValueEqual("id", tc.user.ID)
+	torrent.Value("file_list").Array().Length().Equal(2)
+
+	m, err := metainfo.Parse(torrentFile)
+	require.Nil(t, err)
+
+	got, err := tc.db.GetTorrent(dbCtx, int(torrent.Value("id").Number().Raw()))
+	require.Nil(t, err)
+	require.Equal(t, m.InfoHash, got.InfoHash)
+	require.Equal(t, r.ID, got.Release.ID)
+
+	// an empty description is no description
+	err = tc.db.DeleteTorrent(dbCtx, got.ID)
+	require.Nil(t, err)
+	e.POST("/releases/{id}/torrents", r.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithMultipart().
+		WithFileBytes("torrent", "test.torrent", torrentFile).
+		WithFormField("format", "FLAC$Lossless").
+		WithFormField("description", "").
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("torrent").Object().
+		NotContainsKey("description")
+
+	// duplicate
+	e.POST("/releases/{id}/torrents", r.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithMultipart().
+		WithFileBytes("torrent", "test.torrent", torrentFile).
+		WithFormField("format", "FLAC$Lossless").
+		Expect().Status(400)
+
+	// garbage
+	e.POST("/releases/{id}/torrents", r.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithMultipart().
+		WithFileBytes("torrent", "test.torrent", []byte("garbage")).
+		WithFormField("format", "FLAC$Lossless").
+		Expect().Status(400)
+
+	// unknown format
+	e.POST("/releases/{id}/torrents", r.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithMultipart().
+		WithFileBytes("torrent", "test.torrent", torrentFile).
+		WithFormField("format", "WAV").
+		Expect().Status(400)
+}
+
+func TestDownloadTorrent(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+	err = givePrivileges(a, tc.user.ID, "download_torrent")
+	require.Nil(t, err)
+
+	r := insertTestRelease(t, tc.db)
+	m, err := metainfo.Parse(testTorrentFile(t))
+	require.Nil(t, err)
+
+	tor := db.Torrent{
+		Release:    db.Release{ID: r.ID},
+		Uploaded:   time.Now(),
+		UploadedBy: db.User{ID: 1},
+		InfoHash:   m.InfoHash,
+		Info:       m.Info,
+		Size:       m.TotalSize(),
+	}
+	err = tc.db.InsertTorrent(dbCtx, &tor)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	// no passkey yet
+	e.GET("/torrents/{id}/download", tor.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+
+	passkey, err := tc.db.GenerateNewPasskeyForUser(dbCtx, tc.user.ID)
+	require.Nil(t, err)
+
+	resp := e.GET("/torrents/{id}/download", tor.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200)
+	resp.ContentType("application/x-bittorrent")
+
+	v, err := metainfo.Decode([]byte(resp.Body().Raw()))
+	require.Nil(t, err)
+	root := v.(map[string]interface{})
+	require.Equal(t, testConfig.AnnounceBase+"/"+passkey+"/announce", root["announce"])
+
+	got, err := metainfo.Parse([]byte(resp.Body().Raw()))
+	require.Nil(t, err)
+	require.Equal(t, m.InfoHash, got.InfoHash)
+
+	e.GET("/torrents/{id}/download", tor.ID+1).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(404)
+
+	// under the required ratio, but within the grace period
+	err = tc.db.UpdateUserDeltaUpDown(dbCtx, tc.user.ID, 0, 10<<30)
+	require.Nil(t, err)
+	err = tc.db.UpdateUserSetRatioWatch(dbCtx, tc.user.ID, pq.NullTime{Time: time.Now().Add(time.Hour), Valid: true})
+	require.Nil(t, err)
+	e.GET("/torrents/{id}/download", tor.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200)
+
+	// beyond the grace period
+	err = tc.db.UpdateUserSetRatioWatch(dbCtx, tc.user.ID, pq.NullTime{Time: time.Now().Add(-time.Hour), Valid: true})
+	require.Nil(t, err)
+	e.GET("/torrents/{id}/download", tor.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kataras/iris"
+
+	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/ratiowatch"
+	"github.com/boilingrip/boiling-api/tracker"
+)
+
+func parseInfoHash(s string) ([20]byte, error) {
+	var infoHash [20]byte
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return infoHash, err
+	}
+	if len(b) != len(infoHash) {
+		return infoHash, errors.New("invalid info hash length")
+	}
+
+	copy(infoHash[:], b)
+	return infoHash, nil
+}
+
+func (a *API) authorizeAnnounce(ctx *context) {
+	passkey := ctx.URLParam("passkey")
+	if len(passkey) == 0 {
+		ctx.Fail(errors.New("missing passkey"), iris.StatusBadRequest)
+		return
+	}
+
+	infoHash, err := parseInfoHash(ctx.URLParam("info_hash"))
+	if err != nil {
+		ctx.Fail(userError(err, "invalid info hash"), iris.StatusBadRequest)
+		return
+	}
+
+	// Announces that don't tell how much is left count as downloads.
+	left := int64(-1)
+	if l := ctx.URLParam("left"); len(l) > 0 {
+		left, err = strconv.ParseInt(l, 10, 64)
+		if err != nil {
+			ctx.Fail(userError(err, "invalid left"), iris.StatusBadRequest)
+			return
+		}
+		if left < 0 {
+			ctx.Fail(errors.New("negative left"), iris.StatusBadRequest)
+			return
+		}
+	}
+
+	var resp tracker.AuthorizationResponse
+
+	u, err := a.db.GetUserByPasskey(ctx.dbCtx, passkey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			resp.Reason = "unknown passkey"
+			ctx.Success(resp)
+			return
+		}
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+	resp.UserID = u.ID
+
+	t, err := a.db.GetTorrentByInfoHash(ctx.dbCtx, infoHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			resp.Reason = "unregistered torrent"
+			ctx.Success(resp)
+			return
+		}
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+	resp.LeechType = a.c.leechTypes.MustReverseLookUp(t.LeechType)
+
+	if !u.Enabled {
+		resp.Reason = "user disabled"
+		ctx.Success(resp)
+		return
+	}
+
+	// Seeding is still allowed, so that the ratio can recover.
+	if left != 0 && ratiowatch.DownloadsDisabled(u, time.Now()) {
+		resp.Reason = "downloads disabled by ratio watch"
+		ctx.Success(resp)
+		return
+	}
+
+	resp.Allowed = true
+	ctx.Success(resp)
+}
+
+func (a *API) postAnnounces(ctx *context) {
+	var req tracker.ReportRequest
+	err := ctx.ReadJSON(&req)
+	if err != nil {
+		ctx.Fail(userError(err, "invalid request"), iris.StatusBadRequest)
+		return
+	}
+	if len(req.Announces) > tracker.MaxAnnouncesPerReport {
+		ctx.Fail(errors.New("too many announces"), iris.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	announces := make([]db.Announce, 0, len(req.Announces))
+	for i, ann := range req.Announces {
+		infoHash, err := parseInfoHash(ann.InfoHash)
+		if err != nil {
+			ctx.Fail(userError(err, fmt.Sprintf("invalid info hash for announce %d", i)), iris.StatusBadRequest)
+			return
+		}
+		if ann.Uploaded < 0 || ann.Downloaded < 0 || ann.Left < 0 {
+			ctx.Fail(fmt.Errorf("negative amounts for announce %d", i), iris.StatusBadRequest)
+			return
+		}
+		event := db.AnnounceEvent(ann.Event)
+		if !event.Valid() {
+			ctx.Fail(fmt.Errorf("invalid event for announce %d", i), iris.StatusBadRequest)
+			return
+		}
+		if len(ann.Passkey) == 0 {
+			ctx.Fail(fmt.Errorf("missing passkey for announce %d", i), iris.StatusBadRequest)
+			return
+		}
+
+		reportedAt := ann.ReportedAt
+		if reportedAt.IsZero() {
+			reportedAt = now
+		}
+
+		announces = append(announces, db.Announce{
+			Passkey:    ann.Passkey,
+			InfoHash:   infoHash,
+			Uploaded:   ann.Uploaded,
+			Downloaded: ann.Downloaded,
+			Left:       ann.Left,
+			Event:      event,
+			ReportedAt: reportedAt,
+		})
+	}
+
+	recorded, err := a.db.RecordAnnounces(ctx.dbCtx, announces)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	ctx.Success(tracker.ReportResponse{Recorded: recorded})
+}
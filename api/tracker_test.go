@@ -0,0 +1,129 @@
+package api
+
+import (
+	ctx "context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/tracker"
+)
+
+func TestAuthorizeAnnounce(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	_, err = getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+
+	r := insertTestRelease(t, tc.db)
+	tor := db.Torrent{
+		Release:    db.Release{ID: r.ID},
+		Uploaded:   time.Now(),
+		UploadedBy: db.User{ID: 1},
+		InfoHash:   [20]byte{1, 2, 3},
+		Info:       []byte("d4:name1:ae"),
+		LeechType:  1,
+	}
+	err = tc.db.InsertTorrent(dbCtx, &tor)
+	require.Nil(t, err)
+
+	passkey, err := tc.db.GenerateNewPasskeyForUser(dbCtx, tc.user.ID)
+	require.Nil(t, err)
+
+	c := tracker.NewClient("http://localhost:8080", testConfig.TrackerSecret, nil)
+
+	resp, err := c.Authorize(ctx.Background(), passkey, tor.InfoHash, 10)
+	require.Nil(t, err)
+	require.True(t, resp.Allowed)
+	require.Equal(t, tc.user.ID, resp.UserID)
+	require.Equal(t, "Freeleech", resp.LeechType)
+
+	resp, err = c.Authorize(ctx.Background(), "garbage", tor.InfoHash, 10)
+	require.Nil(t, err)
+	require.False(t, resp.Allowed)
+	require.Equal(t, "unknown passkey", resp.Reason)
+
+	resp, err = c.Authorize(ctx.Background(), passkey, [20]byte{}, 10)
+	require.Nil(t, err)
+	require.False(t, resp.Allowed)
+	require.Equal(t, "unregistered torrent", resp.Reason)
+
+	err = tc.db.UpdateUserDeltaUpDown(dbCtx, tc.user.ID, 0, 10<<30)
+	require.Nil(t, err)
+	err = tc.db.UpdateUserSetRatioWatch(dbCtx, tc.user.ID, pq.NullTime{Time: time.Now().Add(-time.Hour), Valid: true})
+	require.Nil(t, err)
+	resp, err = c.Authorize(ctx.Background(), passkey, tor.InfoHash, 10)
+	require.Nil(t, err)
+	require.False(t, resp.Allowed)
+	require.Equal(t, "downloads disabled by ratio watch", resp.Reason)
+
+	// seeding lets the ratio recover
+	resp, err = c.Authorize(ctx.Background(), passkey, tor.InfoHash, 0)
+	require.Nil(t, err)
+	require.True(t, resp.Allowed)
+	require.Equal(t, tc.user.ID, resp.UserID)
+
+	c = tracker.NewClient("http://localhost:8080", "wrong", nil)
+	_, err = c.Authorize(ctx.Background(), passkey, tor.InfoHash, 10)
+	require.NotNil(t, err)
+}
+
+func TestReportAnnounces(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	_, err = getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+
+	r := insertTestRelease(t, tc.db)
+	tor := db.Torrent{
+		Release:    db.Release{ID: r.ID},
+		Uploaded:   time.Now(),
+		UploadedBy: db.User{ID: 1},
+		InfoHash:   [20]byte{1, 2, 3},
+		Info:       []byte("d4:name1:ae"),
+		LeechType:  3, // DoubleUp
+	}
+	err = tc.db.InsertTorrent(dbCtx, &tor)
+	require.Nil(t, err)
+
+	passkey, err := tc.db.GenerateNewPasskeyForUser(dbCtx, tc.user.ID)
+	require.Nil(t, err)
+
+	c := tracker.NewClient("http://localhost:8080", testConfig.TrackerSecret, nil)
+
+	resp, err := c.Report(ctx.Background(), []tracker.Announce{
+		{
+			Passkey:  passkey,
+			InfoHash: hex.EncodeToString(tor.InfoHash[:]),
+			Uploaded: 100,
+			Event:    "started",
+		},
+		{
+			Passkey:  "garbage",
+			InfoHash: hex.EncodeToString(tor.InfoHash[:]),
+			Uploaded: 100,
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 1, resp.Recorded)
+
+	u, err := tc.db.GetUser(dbCtx, tc.user.ID)
+	require.Nil(t, err)
+	require.Equal(t, int64(200), u.Uploaded)
+
+	_, err = c.Report(ctx.Background(), []tracker.Announce{
+		{
+			Passkey:  passkey,
+			InfoHash: "garbage",
+		},
+	})
+	require.NotNil(t, err)
+}
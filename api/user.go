@@ -6,6 +6,7 @@ import (
 	"github.com/kataras/iris"
 
 	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/ratiowatch"
 )
 
 type BaseUser struct {
@@ -40,9 +41,32 @@ type User struct {
 	LastAccess   *time.Time `json:"last_access,omitempty"`
 	Uploaded     int64      `json:"uploaded"`
 	Downloaded   int64      `json:"downloaded"`
+	Class        string     `json:"class"`
+
+	// RatioWatch and Invites are only set for the user themselves.
+	RatioWatch *RatioWatch `json:"ratio_watch,omitempty"`
+	Invites    *int        `json:"invites,omitempty"`
+}
+
+// RatioWatch is the ratio watch status of a user.
+type RatioWatch struct {
+	RequiredRatio float64    `json:"required_ratio"`
+	Status        string     `json:"status"`
+	Deadline      *time.Time `json:"deadline,omitempty"`
+}
+
+func ratioWatchFromDBUser(dbU db.User) *RatioWatch {
+	rw := &RatioWatch{
+		RequiredRatio: ratiowatch.Required(dbU.Downloaded),
+		Status:        string(ratiowatch.StatusOf(&dbU, time.Now())),
+	}
+	if dbU.RatioWatchUntil.Valid {
+		rw.Deadline = &dbU.RatioWatchUntil.Time
+	}
+	return rw
 }
 
-func userFromDBUser(dbU db.User) User {
+func (a *API) userFromDBUser(dbU db.User) User {
 	u := User{
 		ID:           dbU.ID,
 		Username:     dbU.Username,
@@ -53,6 +77,7 @@ func userFromDBUser(dbU db.User) User {
 		JoinedAt:     dbU.JoinedAt,
 		Uploaded:     dbU.Uploaded,
 		Downloaded:   dbU.Downloaded,
+		Class:        a.c.userClasses.MustReverseLookUp(dbU.Class),
 	}
 	if dbU.LastLogin.Valid {
 		u.LastLogin = &dbU.LastLogin.Time
@@ -66,7 +91,7 @@ func userFromDBUser(dbU db.User) User {
 	return u
 }
 
-func dbUserFromUser(u User) db.User {
+func (a *API) dbUserFromUser(u User) db.User {
 	dbU := db.User{
 		ID:           u.ID,
 		Username:     u.Username,
@@ -77,6 +102,7 @@ func dbUserFromUser(u User) db.User {
 		JoinedAt:     u.JoinedAt,
 		Uploaded:     u.Uploaded,
 		Downloaded:   u.Downloaded,
+		Class:        a.c.userClasses.MustLookUp(u.Class),
 	}
 	if u.LastLogin != nil {
 		dbU.LastLogin.Valid = true
@@ -98,14 +124,18 @@ type UserResponse struct {
 }
 
 func (a *API) getUserSelf(ctx *context) {
-	u, err := a.db.GetUser(ctx.user.ID)
+	u, err := a.db.GetUser(ctx.dbCtx, ctx.user.ID)
 	if err != nil {
 		ctx.Error(err, iris.StatusInternalServerError)
 		return
 	}
 	u.PasswordHash = ""
 
-	ctx.Success(UserResponse{userFromDBUser(*u)})
+	resp := UserResponse{a.userFromDBUser(*u)}
+	resp.User.RatioWatch = ratioWatchFromDBUser(*u)
+	resp.User.Invites = &u.Invites
+
+	ctx.Success(resp)
 }
 
 func (a *API) getUser(ctx *context) {
@@ -120,7 +150,7 @@ func (a *API) getUser(ctx *context) {
 		return
 	}
 
-	u, err := a.db.GetUser(id)
+	u, err := a.db.GetUser(ctx.dbCtx, id)
 	if err != nil {
 		ctx.Fail(userError(err, "not found"), iris.StatusBadRequest)
 		return
@@ -132,5 +162,5 @@ func (a *API) getUser(ctx *context) {
 	u.LastLogin.Valid = false
 	u.CanLogin = false
 
-	ctx.Success(UserResponse{userFromDBUser(*u)})
+	ctx.Success(UserResponse{a.userFromDBUser(*u)})
 }
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kataras/iris"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type UserClass struct {
+	Class      string   `json:"class"`
+	Privileges []string `json:"privileges"`
+}
+
+type UserClassesResponse struct {
+	UserClasses []UserClass `json:"user_classes"`
+}
+
+type UserClassChange struct {
+	Changed time.Time `json:"changed"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Reason  string    `json:"reason"`
+
+	// ChangedBy is not set for automatic changes.
+	ChangedBy *BaseUser `json:"changed_by,omitempty"`
+}
+
+func (a *API) userClassChangeFromDBUserClassChange(dbC *db.UserClassChange) UserClassChange {
+	c := UserClassChange{
+		Changed: dbC.Changed,
+		From:    a.c.userClasses.MustReverseLookUp(dbC.From),
+		To:      a.c.userClasses.MustReverseLookUp(dbC.To),
+		Reason:  dbC.Reason,
+	}
+	if dbC.ChangedBy != nil {
+		u := baseUserFromDBUser(*dbC.ChangedBy)
+		c.ChangedBy = &u
+	}
+	return c
+}
+
+type UserClassHistoryResponse struct {
+	Changes    []UserClassChange `json:"changes"`
+	Total      int               `json:"total"`
+	Offset     int               `json:"offset"`
+	Limit      int               `json:"limit"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	PrevCursor string            `json:"prev_cursor,omitempty"`
+}
+
+// getUserClasses responds with the user classes and their privileges, from
+// the lowest to the highest class.
+func (a *API) getUserClasses(ctx *context) {
+	classes, err := a.db.GetAllUserClasses(ctx.dbCtx)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	var ids []int
+	for id := range classes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	resp := UserClassesResponse{UserClasses: make([]UserClass, 0, len(ids))}
+	for _, id := range ids {
+		privileges, err := a.db.GetUserClassPrivileges(ctx.dbCtx, id)
+		if err != nil {
+			ctx.Error(err, iris.StatusInternalServerError)
+			return
+		}
+
+		class := UserClass{Class: classes[id], Privileges: make([]string, 0, len(privileges))}
+		for _, p := range privileges {
+			class.Privileges = append(class.Privileges, a.c.privileges.MustReverseLookUp(p))
+		}
+		resp.UserClasses = append(resp.UserClasses, class)
+	}
+
+	ctx.Success(resp)
+}
+
+// userBelow returns the user with the given ID, if they are below the class
+// of the user making the request.
+// Staff can only manage users below their own class.
+// If it returns false, a response was already sent.
+func (a *API) userBelow(ctx *context, id int) (*db.User, bool) {
+	u, err := a.db.GetUser(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Fail(userError(err, "not found"), iris.StatusNotFound)
+		return nil, false
+	}
+
+	if u.Class >= ctx.user.Class {
+		ctx.Fail(errors.New("user is not below your class"), iris.StatusForbidden)
+		return nil, false
+	}
+
+	return u, true
+}
+
+// setUserClass moves a user to another class and records the change, with
+// an optional reason, in the class history of the user.
+// Staff can only move users below their own class, to classes up to their
+// own.
+func (a *API) setUserClass(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	if _, ok = a.userBelow(ctx, id); !ok {
+		return
+	}
+
+	class := a.c.userClasses.MustLookUp(ctx.fields.mustGetString("class"))
+	if class > ctx.user.Class {
+		ctx.Fail(errors.New("class is above your class"), iris.StatusForbidden)
+		return
+	}
+
+	reason, _ := ctx.fields.getString("reason")
+	err := a.db.SetUserClass(ctx.dbCtx, &db.UserClassChange{
+		User:      id,
+		To:        class,
+		Changed:   time.Now(),
+		Reason:    reason,
+		ChangedBy: &ctx.user,
+	})
+	if err != nil {
+		ctx.Fail(userError(err, "unable to set class"), iris.StatusBadRequest)
+		return
+	}
+
+	a.getUser(ctx)
+}
+
+// getUserClassHistory responds with the class changes of a user, newest
+// first.
+// Users may always see their own history, the history of other users
+// requires the get_user_privileges privilege.
+func (a *API) getUserClassHistory(ctx *context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+
+	if id != ctx.user.ID {
+		allowed, err := a.containsPrivilege(ctx.user.Privileges, "get_user_privileges")
+		if err != nil {
+			ctx.Error(err, iris.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			ctx.Fail(errors.New("missing privileges"), iris.StatusForbidden)
+			return
+		}
+	}
+
+	p, ok := a.parsePage(ctx, "class_history/"+strconv.Itoa(id), defaultHistoryLimit, maxHistoryLimit)
+	if !ok {
+		return
+	}
+
+	var c db.UserClassChangeCursor
+	if p.cursor != nil {
+		changed, err := time.Parse(time.RFC3339Nano, p.cursor.Key)
+		if err != nil {
+			ctx.Fail(errInvalidCursor, iris.StatusBadRequest)
+			return
+		}
+		c = db.UserClassChangeCursor{Changed: changed, ID: p.cursor.ID}
+	}
+
+	total, err := a.db.CountUserClassHistory(ctx.dbCtx, id)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	// One more change than requested is fetched to find out whether there is
+	// another page.
+	var changes []db.UserClassChange
+	switch {
+	case p.cursor == nil:
+		changes, err = a.db.GetUserClassHistory(ctx.dbCtx, id, p.offset, p.limit+1)
+	case p.backward():
+		changes, err = a.db.GetUserClassHistoryBefore(ctx.dbCtx, id, c, p.limit+1)
+	default:
+		changes, err = a.db.GetUserClassHistoryAfter(ctx.dbCtx, id, c, p.limit+1)
+	}
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	more := len(changes) > p.limit
+	if more {
+		if p.backward() {
+			changes = changes[1:]
+		} else {
+			changes = changes[:p.limit]
+		}
+	}
+
+	resp := UserClassHistoryResponse{
+		Changes: make([]UserClassChange, 0, len(changes)),
+		Total:   total,
+		Offset:  p.offset,
+		Limit:   p.limit,
+	}
+	for _, dbC := range changes {
+		resp.Changes = append(resp.Changes, a.userClassChangeFromDBUserClassChange(&dbC))
+	}
+	resp.NextCursor, resp.PrevCursor = a.cursors(p, len(changes), more, func(i int) (string, int) {
+		return timeKey(changes[i].Changed), changes[i].ID
+	})
+
+	ctx.Success(resp)
+}
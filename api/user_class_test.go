@@ -0,0 +1,163 @@
+package api
+
+import (
+	ctx "context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gavv/httpexpect.v1"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+func TestGetUserClasses(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	_, err = getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	obj := e.GET("/user_classes").
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object()
+	obj.ValueEqual("status", "success")
+	classes := obj.Value("data").Object().Value("user_classes").Array()
+	classes.Length().Equal(5)
+
+	user := classes.Element(0).Object()
+	user.ValueEqual("class", "User")
+	user.Value("privileges").Array().Contains("get_artist", "download_torrent")
+	user.Value("privileges").Array().NotContains("set_user_class")
+
+	sysOp := classes.Element(4).Object()
+	sysOp.ValueEqual("class", "SysOp")
+	sysOp.Value("privileges").Array().Contains("set_user_class", "get_artist")
+}
+
+func TestSetUserClass(t *testing.T) {
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	e.GET("/users/{id}", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("user").Object().ValueEqual("class", "User")
+
+	// Missing privilege
+	e.POST("/users/{id}/class", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("class", "Elite").
+		Expect().Status(403)
+
+	err = tc.db.SetUserClass(ctx.Background(), &db.UserClassChange{User: tc.user.ID, To: a.c.userClasses.MustLookUp("Moderator"), Changed: time.Now()})
+	require.Nil(t, err)
+
+	err = tc.db.SignUpUser(ctx.Background(), "someotheruser", "someotherpw12345", "other@ex.am.ple.com")
+	require.Nil(t, err)
+	other, err := tc.db.LoginAndGetUser(ctx.Background(), "someotheruser", "someotherpw12345")
+	require.Nil(t, err)
+
+	// Moderators have set_user_class
+	user := e.POST("/users/{id}/class", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("class", "Elite").
+		WithFormField("reason", "great uploads").
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().Value("user").Object()
+	user.ValueEqual("id", other.ID)
+	user.ValueEqual("class", "Elite")
+
+	data := e.GET("/users/{id}/class_history", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.ValueEqual("total", 1)
+	change := data.Value("changes").Array().Element(0).Object()
+	change.Keys().ContainsOnly("changed", "from", "to", "reason", "changed_by")
+	change.ValueEqual("from", "User")
+	change.ValueEqual("to", "Elite")
+	change.ValueEqual("reason", "great uploads")
+	change.Value("changed_by").Object().ValueEqual("id", tc.user.ID)
+
+	// Users can see their own history
+	data = e.GET("/users/{id}/class_history", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.ValueEqual("total", 1)
+	data.Value("changes").Array().Element(0).Object().NotContainsKey("changed_by")
+
+	// Histories are paged newest first
+	e.POST("/users/{id}/class", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("class", "Power User").
+		Expect().Status(200)
+	data = e.GET("/users/{id}/class_history", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 1).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.Value("changes").Array().Element(0).Object().ValueEqual("to", "Power User")
+	data.NotContainsKey("prev_cursor")
+	next := data.Value("next_cursor").String().Raw()
+
+	// A change made while paging doesn't shift the next page
+	e.POST("/users/{id}/class", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("class", "User").
+		Expect().Status(200)
+	data = e.GET("/users/{id}/class_history", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 1).
+		WithQuery("cursor", next).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.ValueEqual("total", 3)
+	data.Value("changes").Array().Element(0).Object().ValueEqual("to", "Elite")
+	data.NotContainsKey("next_cursor")
+
+	data = e.GET("/users/{id}/class_history", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("limit", 1).
+		WithQuery("cursor", data.Value("prev_cursor").String().Raw()).
+		Expect().Status(200).
+		JSON().Object().Value("data").Object()
+	data.Value("changes").Array().Element(0).Object().ValueEqual("to", "Power User")
+
+	// cursors are only valid for the history they were issued for
+	e.GET("/users/{id}/class_history", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("cursor", next).
+		Expect().Status(400)
+
+	// Unknown class
+	e.POST("/users/{id}/class", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("class", "Overlord").
+		Expect().Status(400)
+
+	// Above the class of the caller
+	e.POST("/users/{id}/class", other.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("class", "SysOp").
+		Expect().Status(403)
+
+	// The user is not below the caller
+	e.POST("/users/{id}/class", 1).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("class", "User").
+		Expect().Status(403)
+
+	// Unknown user
+	e.POST("/users/{id}/class", 1000).
+		WithHeader("X-User-Token", tc.token).
+		WithFormField("class", "User").
+		Expect().Status(404)
+}
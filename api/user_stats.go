@@ -0,0 +1,190 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kataras/iris"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+const (
+	defaultStatRange = 30 * 24 * time.Hour
+	maxStatBuckets   = 1000
+)
+
+var statBucketDurations = map[db.StatBucket]time.Duration{
+	db.StatBucketHour:  time.Hour,
+	db.StatBucketDay:   24 * time.Hour,
+	db.StatBucketWeek:  7 * 24 * time.Hour,
+	db.StatBucketMonth: 31 * 24 * time.Hour,
+}
+
+type UserStatPoint struct {
+	Time          time.Time `json:"time"`
+	Uploaded      int64     `json:"uploaded"`
+	Downloaded    int64     `json:"downloaded"`
+	RawUploaded   int64     `json:"raw_uploaded"`
+	RawDownloaded int64     `json:"raw_downloaded"`
+}
+
+func userStatPointFromDBUserStatPoint(dbP db.UserStatPoint) UserStatPoint {
+	return UserStatPoint{
+		Time:          dbP.Bucket,
+		Uploaded:      dbP.Uploaded,
+		Downloaded:    dbP.Downloaded,
+		RawUploaded:   dbP.RawUploaded,
+		RawDownloaded: dbP.RawDownloaded,
+	}
+}
+
+type UserTorrentStats struct {
+	Torrent       *int      `json:"torrent"`
+	Uploaded      int64     `json:"uploaded"`
+	Downloaded    int64     `json:"downloaded"`
+	RawUploaded   int64     `json:"raw_uploaded"`
+	RawDownloaded int64     `json:"raw_downloaded"`
+	Announces     int       `json:"announces"`
+	FirstAnnounce time.Time `json:"first_announce"`
+	LastAnnounce  time.Time `json:"last_announce"`
+}
+
+func userTorrentStatsFromDBUserTorrentStats(dbS db.UserTorrentStats) UserTorrentStats {
+	return UserTorrentStats{
+		Torrent:       dbS.Torrent,
+		Uploaded:      dbS.Uploaded,
+		Downloaded:    dbS.Downloaded,
+		RawUploaded:   dbS.RawUploaded,
+		RawDownloaded: dbS.RawDownloaded,
+		Announces:     dbS.Announces,
+		FirstAnnounce: dbS.FirstAnnounce,
+		LastAnnounce:  dbS.LastAnnounce,
+	}
+}
+
+type UserStatHistoryResponse struct {
+	From   time.Time       `json:"from"`
+	To     time.Time       `json:"to"`
+	Bucket string          `json:"bucket"`
+	Series []UserStatPoint `json:"series"`
+}
+
+type UserTorrentStatsResponse struct {
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Torrents []UserTorrentStats `json:"torrents"`
+}
+
+// statsUserAndRange extracts the user ID and the time range of a stats
+// request.
+// Users may always see their own stats, other users' stats require the
+// get_user_stats_not_self privilege.
+// If false is returned, the request has already been failed.
+func (a *API) statsUserAndRange(ctx *context) (int, time.Time, time.Time, bool) {
+	id, err := ctx.Params().GetInt("id")
+	if err != nil {
+		ctx.Fail(userError(err, "invalid ID"), iris.StatusBadRequest)
+		return 0, time.Time{}, time.Time{}, false
+	}
+
+	if id != ctx.user.ID {
+		allowed, err := a.containsPrivilege(ctx.user.Privileges, "get_user_stats_not_self")
+		if err != nil {
+			ctx.Error(err, iris.StatusInternalServerError)
+			return 0, time.Time{}, time.Time{}, false
+		}
+		if !allowed {
+			ctx.Fail(errors.New("missing privileges"), iris.StatusForbidden)
+			return 0, time.Time{}, time.Time{}, false
+		}
+	}
+
+	to := time.Now()
+	if s := ctx.URLParam("to"); s != "" {
+		to, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			ctx.Fail(userError(err, "invalid to"), iris.StatusBadRequest)
+			return 0, time.Time{}, time.Time{}, false
+		}
+	}
+
+	from := to.Add(-defaultStatRange)
+	if s := ctx.URLParam("from"); s != "" {
+		from, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			ctx.Fail(userError(err, "invalid from"), iris.StatusBadRequest)
+			return 0, time.Time{}, time.Time{}, false
+		}
+	}
+
+	if !from.Before(to) {
+		ctx.Fail(errors.New("from must be before to"), iris.StatusBadRequest)
+		return 0, time.Time{}, time.Time{}, false
+	}
+
+	return id, from, to, true
+}
+
+func (a *API) getUserStatHistory(ctx *context) {
+	id, from, to, ok := a.statsUserAndRange(ctx)
+	if !ok {
+		return
+	}
+
+	bucket := db.StatBucketDay
+	if s := ctx.URLParam("bucket"); s != "" {
+		bucket = db.StatBucket(s)
+	}
+	d, ok := statBucketDurations[bucket]
+	if !ok {
+		ctx.Fail(errors.New("invalid bucket"), iris.StatusBadRequest)
+		return
+	}
+	if to.Sub(from)/d > maxStatBuckets {
+		ctx.Fail(errors.New("too many buckets"), iris.StatusBadRequest)
+		return
+	}
+
+	points, err := a.db.GetUserStatHistory(ctx.dbCtx, id, from, to, bucket)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	series := make([]UserStatPoint, 0, len(points))
+	for _, p := range points {
+		series = append(series, userStatPointFromDBUserStatPoint(p))
+	}
+
+	ctx.Success(UserStatHistoryResponse{
+		From:   from,
+		To:     to,
+		Bucket: string(bucket),
+		Series: series,
+	})
+}
+
+func (a *API) getUserTorrentStats(ctx *context) {
+	id, from, to, ok := a.statsUserAndRange(ctx)
+	if !ok {
+		return
+	}
+
+	stats, err := a.db.GetUserTorrentStats(ctx.dbCtx, id, from, to)
+	if err != nil {
+		ctx.Error(err, iris.StatusInternalServerError)
+		return
+	}
+
+	torrents := make([]UserTorrentStats, 0, len(stats))
+	for _, s := range stats {
+		torrents = append(torrents, userTorrentStatsFromDBUserTorrentStats(s))
+	}
+
+	ctx.Success(UserTorrentStatsResponse{
+		From:     from,
+		To:       to,
+		Torrents: torrents,
+	})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	ctx "context"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+func TestGetUserStatHistory(t *testing.T) {
+	dbCtx := ctx.Background()
+
+	tc, err := cleanDBWithLogin()
+	require.Nil(t, err)
+	a, err := getDefaultAPIWithDB(tc.db)
+	require.Nil(t, err)
+
+	r := insertTestRelease(t, tc.db)
+	tor := db.Torrent{
+		Release:    db.Release{ID: r.ID},
+		Uploaded:   time.Now(),
+		UploadedBy: db.User{ID: 1},
+		InfoHash:   [20]byte{1, 2, 3},
+		Info:       []byte("d4:name1:ae"),
+		LeechType:  1, // Freeleech
+	}
+	err = tc.db.InsertTorrent(dbCtx, &tor)
+	require.Nil(t, err)
+
+	passkey, err := tc.db.GenerateNewPasskeyForUser(dbCtx, tc.user.ID)
+	require.Nil(t, err)
+
+	day := time.Date(2017, 10, 14, 0, 0, 0, 0, time.UTC)
+	_, err = tc.db.RecordAnnounces(dbCtx, []db.Announce{
+		{Passkey: passkey, InfoHash: tor.InfoHash, Uploaded: 100, Downloaded: 1000, Left: 10, Event: db.AnnounceEventStarted, ReportedAt: day.Add(time.Hour)},
+		{Passkey: passkey, InfoHash: tor.InfoHash, Uploaded: 100, Downloaded: 1000, Left: 10, ReportedAt: day.Add(25 * time.Hour)},
+	})
+	require.Nil(t, err)
+
+	e := httpexpect.New(t, "http://localhost:8080")
+
+	series := e.GET("/users/{id}/stats/history", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("from", "2017-10-01T00:00:00Z").
+		WithQuery("to", "2017-10-31T00:00:00Z").
+		WithQuery("bucket", "day").
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().
+		ValueEqual("bucket", "day").
+		Value("series").Array()
+	series.Length().Equal(2)
+	series.Element(0).Object().
+		ValueEqual("uploaded", 100).
+		ValueEqual("downloaded", 0).
+		ValueEqual("raw_downloaded", 1000)
+
+	torrents := e.GET("/users/{id}/stats/torrents", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("from", "2017-10-01T00:00:00Z").
+		WithQuery("to", "2017-10-31T00:00:00Z").
+		Expect().Status(200).
+		JSON().Object().Value("data").Object().
+		Value("torrents").Array()
+	torrents.Length().Equal(1)
+	torrents.Element(0).Object().
+		ValueEqual("torrent", tor.ID).
+		ValueEqual("uploaded", 200).
+		ValueEqual("announces", 2)
+
+	// too many buckets
+	e.GET("/users/{id}/stats/history", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("from", "2000-01-01T00:00:00Z").
+		WithQuery("to", "2017-10-31T00:00:00Z").
+		WithQuery("bucket", "hour").
+		Expect().Status(400)
+
+	e.GET("/users/{id}/stats/history", tc.user.ID).
+		WithHeader("X-User-Token", tc.token).
+		WithQuery("bucket", "year").
+		Expect().Status(400)
+
+	// other users' stats require a privilege
+	e.GET("/users/{id}/stats/history", 1).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(403)
+
+	err = givePrivileges(a, tc.user.ID, "get_user_stats_not_self")
+	require.Nil(t, err)
+
+	e.GET("/users/{id}/stats/history", 1).
+		WithHeader("X-User-Token", tc.token).
+		Expect().Status(200)
+}
@@ -27,6 +27,11 @@ func TestGetUserSelf(t *testing.T) {
 	user.ValueEqual("id", tc.user.ID)
 	user.ValueEqual("username", tc.user.Username)
 	user.ValueEqual("email", tc.user.Email)
+
+	ratioWatch := user.Value("ratio_watch").Object()
+	ratioWatch.Keys().ContainsOnly("required_ratio", "status")
+	ratioWatch.ValueEqual("required_ratio", 0)
+	ratioWatch.ValueEqual("status", "ok")
 }
 
 func TestGetUser(t *testing.T) {
@@ -46,6 +51,7 @@ func TestGetUser(t *testing.T) {
 	obj.ValueEqual("status", "success")
 	obj.Value("data").Object().Keys().ContainsOnly("user")
 	user := obj.Value("data").Object().Value("user").Object()
-	user.Keys().ContainsOnly("id", "username", "bio", "joined_at", "uploaded", "downloaded", "enabled")
+	user.Keys().ContainsOnly("id", "username", "bio", "joined_at", "uploaded", "downloaded", "enabled", "class")
 	user.ValueEqual("id", 1)
+	user.ValueEqual("class", "SysOp")
 }
@@ -5,8 +5,35 @@ import (
 	"sort"
 	"strings"
 	"unicode"
+
+	"github.com/kataras/iris"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday"
 )
 
+// idParam returns the id path parameter, which must not be negative.
+// If it returns false, a response was already sent.
+func idParam(ctx *context) (int, bool) {
+	id, err := ctx.Params().GetInt("id")
+	if err != nil {
+		ctx.Fail(userError(err, "invalid ID"), iris.StatusBadRequest)
+		return 0, false
+	}
+	if id < 0 {
+		ctx.Fail(errors.New("invalid ID"), iris.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// compileMarkdown renders user provided markdown to HTML that is safe to
+// embed.
+func compileMarkdown(input []byte) []byte {
+	unsafe := blackfriday.MarkdownCommon(input)
+	html := bluemonday.UGCPolicy().SanitizeBytes(unsafe)
+	return html
+}
+
 // prepareTags prepares tags.
 // Tags are brought to lower case, sorted and deduplicated.
 // If any tag contains whitespace, an error is returned.
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"io/ioutil"
@@ -13,9 +14,12 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/boilingrip/boiling-api/api"
 	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/promotion"
+	"github.com/boilingrip/boiling-api/ratiowatch"
 )
 
 type ConfigFile struct {
@@ -23,30 +27,113 @@ type ConfigFile struct {
 }
 
 type Config struct {
-	Database         string `yaml:"database"`
-	DatabaseUser     string `yaml:"database_user"`
-	DatabasePassword string `yaml:"database_password"`
+	// DatabaseDSN, if set, is used instead of the other connection settings.
+	DatabaseDSN             string        `yaml:"database_dsn"`
+	Database                string        `yaml:"database"`
+	DatabaseUser            string        `yaml:"database_user"`
+	DatabasePassword        string        `yaml:"database_password"`
+	DatabaseHost            string        `yaml:"database_host"`
+	DatabasePort            int           `yaml:"database_port"`
+	DatabaseSSLMode         string        `yaml:"database_sslmode"`
+	DatabaseConnectTimeout  time.Duration `yaml:"database_connect_timeout"`
+	DatabaseApplicationName string        `yaml:"database_application_name"`
+
+	DatabaseMaxOpenConns    int           `yaml:"database_max_open_conns"`
+	DatabaseMaxIdleConns    int           `yaml:"database_max_idle_conns"`
+	DatabaseConnMaxLifetime time.Duration `yaml:"database_conn_max_lifetime"`
 
 	ListenAddress string `yaml:"listen_addr"`
+
+	TrackerAnnounceBase string `yaml:"tracker_announce_base"`
+	TrackerSecret       string `yaml:"tracker_secret"`
+
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+
+	CursorSecret string `yaml:"cursor_secret"`
+
+	// InviteOnly requires users to sign up with an invite.
+	InviteOnly bool `yaml:"invite_only"`
+
+	// PromotionRules enable the automatic promotion and demotion of users
+	// between user classes if set.
+	PromotionInterval time.Duration    `yaml:"promotion_interval"`
+	PromotionRules    []promotion.Rule `yaml:"promotion_rules"`
+
+	// RatioWatch enables putting users under their required ratio on ratio
+	// watch.
+	RatioWatch         bool          `yaml:"ratio_watch"`
+	RatioWatchInterval time.Duration `yaml:"ratio_watch_interval"`
+	RatioWatchGrace    time.Duration `yaml:"ratio_watch_grace"`
 }
 
-func (c Config) validate() error {
-	if len(c.Database) == 0 {
-		return errors.New("database must be set")
+// validateDatabase validates the database settings, which is all the migrate
+// command needs.
+func (c Config) validateDatabase() error {
+	if len(c.DatabaseDSN) == 0 {
+		if len(c.Database) == 0 {
+			return errors.New("database must be set")
+		}
+		if len(c.DatabaseUser) == 0 {
+			return errors.New("database user must be set")
+		}
+		if len(c.DatabasePassword) == 0 {
+			return errors.New("database password must be set")
+		}
 	}
-	if len(c.DatabaseUser) == 0 {
-		return errors.New("database user must be set")
+	if c.DatabasePort < 0 || c.DatabasePort > 65535 {
+		return errors.New("database port is invalid")
 	}
-	if len(c.DatabasePassword) == 0 {
-		return errors.New("database password must be set")
+	if c.DatabaseMaxOpenConns < 0 || c.DatabaseMaxIdleConns < 0 {
+		return errors.New("database connection limits must not be negative")
+	}
+
+	return nil
+}
+
+func (c Config) validate() error {
+	err := c.validateDatabase()
+	if err != nil {
+		return err
 	}
 	if len(c.ListenAddress) == 0 {
 		return errors.New("listen address must be set")
 	}
+	if len(c.TrackerAnnounceBase) == 0 {
+		return errors.New("tracker announce base must be set")
+	}
+	if c.QueryTimeout < 0 {
+		return errors.New("query timeout must not be negative")
+	}
+	if c.PromotionInterval < 0 {
+		return errors.New("promotion interval must not be negative")
+	}
+	if c.RatioWatchInterval < 0 {
+		return errors.New("ratio watch interval must not be negative")
+	}
+	if c.RatioWatchGrace < 0 {
+		return errors.New("ratio watch grace period must not be negative")
+	}
 
 	return nil
 }
 
+func (c Config) dbConfig() db.Config {
+	return db.Config{
+		DSN:             c.DatabaseDSN,
+		Host:            c.DatabaseHost,
+		Port:            c.DatabasePort,
+		Database:        c.Database,
+		User:            c.DatabaseUser,
+		Password:        c.DatabasePassword,
+		SSLMode:         c.DatabaseSSLMode,
+		ConnectTimeout:  c.DatabaseConnectTimeout,
+		ApplicationName: c.DatabaseApplicationName,
+		MaxOpenConns:    c.DatabaseMaxOpenConns,
+		MaxIdleConns:    c.DatabaseMaxIdleConns,
+		ConnMaxLifetime: c.DatabaseConnMaxLifetime,
+	}
+}
+
 func parseConfig(path string) (*ConfigFile, error) {
 	if path == "" {
 		return nil, errors.New("no configPath path specified")
@@ -86,24 +173,95 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	if flag.Arg(0) == "migrate" {
+		err = cfg.Boiling.validateDatabase()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		dbConn, err := db.Open(cfg.Boiling.dbConfig())
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer dbConn.Close()
+
+		err = runMigrate(dbConn, flag.Args()[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	err = cfg.Boiling.validate()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	d, err := db.New(cfg.Boiling.Database, cfg.Boiling.DatabaseUser, cfg.Boiling.DatabasePassword)
+	dbConn, err := db.Open(cfg.Boiling.dbConfig())
 	if err != nil {
 		log.Fatal(err)
 	}
+	err = db.CheckSchema(dbConn)
+	if err != nil {
+		dbConn.Close()
+		log.Fatalln("unable to use database, run boiling migrate up: ", err)
+	}
+	d := db.NewFromConn(dbConn)
 
-	a, err := api.New(d)
+	a, err := api.New(d, api.Config{
+		AnnounceBase:  cfg.Boiling.TrackerAnnounceBase,
+		TrackerSecret: cfg.Boiling.TrackerSecret,
+		QueryTimeout:  cfg.Boiling.QueryTimeout,
+		CursorSecret:  cfg.Boiling.CursorSecret,
+		InviteOnly:    cfg.Boiling.InviteOnly,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	var engine *promotion.Engine
+	if len(cfg.Boiling.PromotionRules) > 0 {
+		engine, err = promotion.New(context.Background(), d, promotion.Config{
+			Interval: cfg.Boiling.PromotionInterval,
+			Rules:    cfg.Boiling.PromotionRules,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var ratioWatch *ratiowatch.Engine
+	if cfg.Boiling.RatioWatch {
+		ratioWatch, err = ratiowatch.New(d, ratiowatch.Config{
+			Interval: cfg.Boiling.RatioWatchInterval,
+			Grace:    cfg.Boiling.RatioWatchGrace,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	wg := sync.WaitGroup{}
 	quit := make(chan os.Signal)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	stopping, stop := context.WithCancel(context.Background())
+
+	if engine != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			engine.Run(stopping)
+		}()
+	}
+
+	if ratioWatch != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ratioWatch.Run(stopping)
+		}()
+	}
 
 	wg.Add(1)
 	go func() {
@@ -111,6 +269,7 @@ func main() {
 		<-quit
 
 		log.Infoln("received SIGINT/SIGTERM, shutting down...")
+		stop()
 		err := a.Stop()
 		if err != nil {
 			log.Warnln("unable to shut down cleanly: ", err)
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+const migrateUsage = `usage: boiling [-config boiling.yaml] migrate <command>
+
+commands:
+  up [version]   apply all pending migrations, or up to and including version
+  down [n]       revert the n most recently applied migrations, default 1
+  status         list all migrations and whether they were applied
+  baseline       mark the initial migration as applied on a database created
+                 from create.sql before migrations existed`
+
+func runMigrate(dbConn *sql.DB, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+
+	var (
+		arg    int
+		hasArg = len(args) == 2
+		err    error
+	)
+	if hasArg {
+		arg, err = strconv.Atoi(args[1])
+		if err != nil || arg < 0 {
+			return fmt.Errorf("invalid argument %q\n%s", args[1], migrateUsage)
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := db.MigrateUp(dbConn, arg)
+		for _, m := range done {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		if !hasArg {
+			arg = 1
+		}
+		done, err := db.MigrateDown(dbConn, arg)
+		for _, m := range done {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("no applied migrations")
+		}
+		return err
+	case "baseline":
+		if hasArg {
+			return errors.New(migrateUsage)
+		}
+		err = db.MigrateBaseline(dbConn)
+		if err == nil {
+			fmt.Println("marked 0001 as applied, run boiling migrate up next")
+		}
+		return err
+	case "status":
+		if hasArg {
+			return errors.New(migrateUsage)
+		}
+		status, err := db.GetMigrationStatus(dbConn)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt.Valid {
+				appliedAt = s.AppliedAt.Time.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	return errors.New(migrateUsage)
+}
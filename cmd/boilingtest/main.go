@@ -1,20 +1,16 @@
 package main
 
 import (
-	"database/sql"
 	"errors"
 	"flag"
-	"fmt"
 	"io/ioutil"
 	"os"
 	"os/signal"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/kataras/iris"
-	_ "github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 
@@ -27,38 +23,87 @@ type ConfigFile struct {
 }
 
 type Config struct {
-	Database         string `yaml:"database"`
-	DatabaseUser     string `yaml:"database_user"`
-	DatabasePassword string `yaml:"database_password"`
+	// DatabaseDSN, if set, is used instead of the other connection settings.
+	DatabaseDSN             string        `yaml:"database_dsn"`
+	Database                string        `yaml:"database"`
+	DatabaseUser            string        `yaml:"database_user"`
+	DatabasePassword        string        `yaml:"database_password"`
+	DatabaseHost            string        `yaml:"database_host"`
+	DatabasePort            int           `yaml:"database_port"`
+	DatabaseSSLMode         string        `yaml:"database_sslmode"`
+	DatabaseConnectTimeout  time.Duration `yaml:"database_connect_timeout"`
+	DatabaseApplicationName string        `yaml:"database_application_name"`
+
+	DatabaseMaxOpenConns    int           `yaml:"database_max_open_conns"`
+	DatabaseMaxIdleConns    int           `yaml:"database_max_idle_conns"`
+	DatabaseConnMaxLifetime time.Duration `yaml:"database_conn_max_lifetime"`
 
 	ListenAddress string `yaml:"listen_addr"`
 	KeyFile       string `yaml:"key_file"`
 	CertFile      string `yaml:"cert_file"`
 
-	CreateSQL string `yaml:"create_sql"`
-	ResetHour int    `yaml:"reset_hour"`
+	TrackerAnnounceBase string `yaml:"tracker_announce_base"`
+	TrackerSecret       string `yaml:"tracker_secret"`
+
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+
+	CursorSecret string `yaml:"cursor_secret"`
+
+	TestDataSQL string `yaml:"test_data_sql"`
+	ResetHour   int    `yaml:"reset_hour"`
 }
 
 func (c Config) validate() error {
-	if len(c.Database) == 0 {
-		return errors.New("database must be set")
+	if len(c.DatabaseDSN) == 0 {
+		if len(c.Database) == 0 {
+			return errors.New("database must be set")
+		}
+		if len(c.DatabaseUser) == 0 {
+			return errors.New("database user must be set")
+		}
+		if len(c.DatabasePassword) == 0 {
+			return errors.New("database password must be set")
+		}
 	}
-	if len(c.DatabaseUser) == 0 {
-		return errors.New("database user must be set")
+	if c.DatabasePort < 0 || c.DatabasePort > 65535 {
+		return errors.New("database port is invalid")
 	}
-	if len(c.DatabasePassword) == 0 {
-		return errors.New("database password must be set")
+	if c.DatabaseMaxOpenConns < 0 || c.DatabaseMaxIdleConns < 0 {
+		return errors.New("database connection limits must not be negative")
 	}
 	if len(c.ListenAddress) == 0 {
 		return errors.New("listen address must be set")
 	}
-	if len(c.CreateSQL) == 0 {
-		return errors.New("create SQL must be set")
+	if len(c.TrackerAnnounceBase) == 0 {
+		return errors.New("tracker announce base must be set")
+	}
+	if c.QueryTimeout < 0 {
+		return errors.New("query timeout must not be negative")
+	}
+	if len(c.TestDataSQL) == 0 {
+		return errors.New("test data SQL must be set")
 	}
 
 	return nil
 }
 
+func (c Config) dbConfig() db.Config {
+	return db.Config{
+		DSN:             c.DatabaseDSN,
+		Host:            c.DatabaseHost,
+		Port:            c.DatabasePort,
+		Database:        c.Database,
+		User:            c.DatabaseUser,
+		Password:        c.DatabasePassword,
+		SSLMode:         c.DatabaseSSLMode,
+		ConnectTimeout:  c.DatabaseConnectTimeout,
+		ApplicationName: c.DatabaseApplicationName,
+		MaxOpenConns:    c.DatabaseMaxOpenConns,
+		MaxIdleConns:    c.DatabaseMaxIdleConns,
+		ConnMaxLifetime: c.DatabaseConnMaxLifetime,
+	}
+}
+
 func parseConfig(path string) (*ConfigFile, error) {
 	if path == "" {
 		return nil, errors.New("no configPath path specified")
@@ -109,12 +154,17 @@ func main() {
 		log.Fatalln("unable to reset DB: ", err)
 	}
 
-	d, err := db.New(cfg.Boiling.Database, cfg.Boiling.DatabaseUser, cfg.Boiling.DatabasePassword)
+	d, err := db.New(cfg.Boiling.dbConfig())
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	a, err := api.New(d)
+	a, err := api.New(d, api.Config{
+		AnnounceBase:  cfg.Boiling.TrackerAnnounceBase,
+		TrackerSecret: cfg.Boiling.TrackerSecret,
+		QueryTimeout:  cfg.Boiling.QueryTimeout,
+		CursorSecret:  cfg.Boiling.CursorSecret,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -201,27 +251,24 @@ func main() {
 }
 
 func cleanDB(cfg Config) error {
-	dbConn, err := sql.Open("postgres", fmt.Sprintf("user=%s password=%s dbname=%s", cfg.DatabaseUser, cfg.DatabasePassword, cfg.Database))
+	dbConn, err := db.Open(cfg.dbConfig())
 	if err != nil {
 		return err
 	}
 	defer dbConn.Close()
 
-	createSQL := os.ExpandEnv(cfg.CreateSQL)
-	log.Infoln("using SQL create script at: ", createSQL)
-	file, err := ioutil.ReadFile(createSQL)
+	err = db.ResetSchema(dbConn)
 	if err != nil {
 		return err
 	}
 
-	requests := strings.Split(string(file), ";")
-
-	for _, request := range requests {
-		_, err := dbConn.Exec(request)
-		if err != nil {
-			return err
-		}
+	testDataSQL := os.ExpandEnv(cfg.TestDataSQL)
+	log.Infoln("using SQL test data at: ", testDataSQL)
+	file, err := ioutil.ReadFile(testDataSQL)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	_, err = dbConn.Exec(string(file))
+	return err
 }
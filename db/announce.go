@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type AnnounceEvent string
+
+const (
+	AnnounceEventNone      AnnounceEvent = ""
+	AnnounceEventStarted   AnnounceEvent = "started"
+	AnnounceEventCompleted AnnounceEvent = "completed"
+	AnnounceEventStopped   AnnounceEvent = "stopped"
+)
+
+func (e AnnounceEvent) Valid() bool {
+	switch e {
+	case AnnounceEventNone, AnnounceEventStarted, AnnounceEventCompleted, AnnounceEventStopped:
+		return true
+	}
+	return false
+}
+
+// Announce is an announce as reported by the tracker.
+// Uploaded and Downloaded are the raw amounts transferred since the last
+// announce of the peer, before any leech type multipliers are applied.
+type Announce struct {
+	Passkey    string
+	InfoHash   [20]byte
+	Uploaded   int64
+	Downloaded int64
+	Left       int64
+	Event      AnnounceEvent
+	ReportedAt time.Time
+}
+
+// swarmDeltas computes the changes to the seeders, leechers and snatches
+// counters of a torrent caused by an announce.
+func (a Announce) swarmDeltas() (seeders, leechers, snatches int) {
+	switch a.Event {
+	case AnnounceEventStarted:
+		if a.Left == 0 {
+			return 1, 0, 0
+		}
+		return 0, 1, 0
+	case AnnounceEventCompleted:
+		return 1, -1, 1
+	case AnnounceEventStopped:
+		if a.Left == 0 {
+			return -1, 0, 0
+		}
+		return 0, -1, 0
+	}
+	return 0, 0, 0
+}
+
+// recordAnnounceTx records a single announce.
+// It returns false if the announce was not recorded because the passkey or
+// the torrent is unknown.
+func recordAnnounceTx(ctx context.Context, a Announce, tx *sql.Tx) (bool, error) {
+	var uid int
+	err := tx.QueryRowContext(ctx, "SELECT uid FROM user_passkeys WHERE passkey = $1 AND valid = TRUE", a.Passkey).Scan(&uid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var (
+		torrent                      int
+		upMultiplier, downMultiplier float64
+	)
+	err = tx.QueryRowContext(ctx, "SELECT t.id,lt.upload_multiplier,lt.download_multiplier FROM torrents t, torrent_trackerdata td, leech_types lt WHERE t.id = td.torrent AND td.leech_type = lt.id AND t.info_hash = $1", a.InfoHash[:]).Scan(
+		&torrent,
+		&upMultiplier,
+		&downMultiplier)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	up := int64(float64(a.Uploaded) * upMultiplier)
+	down := int64(float64(a.Downloaded) * downMultiplier)
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO user_stat_changes(uid,torrent,reported_at,event,raw_uploaded,raw_downloaded,uploaded_delta,downloaded_delta) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)",
+		uid,
+		torrent,
+		a.ReportedAt,
+		string(a.Event),
+		a.Uploaded,
+		a.Downloaded,
+		up,
+		down)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET uploaded = uploaded + $1, downloaded = downloaded + $2 WHERE id = $3", up, down, uid)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected != 1 {
+		return false, errors.New("user not found")
+	}
+
+	seeders, leechers, snatches := a.swarmDeltas()
+	res, err = tx.ExecContext(ctx, "UPDATE torrent_trackerdata SET seeders = GREATEST(seeders + $1, 0), leechers = GREATEST(leechers + $2, 0), snatches = snatches + $3, total_uploaded = total_uploaded + $4, total_downloaded = total_downloaded + $5 WHERE torrent = $6",
+		seeders, leechers, snatches, a.Uploaded, a.Downloaded, torrent)
+	if err != nil {
+		return false, err
+	}
+	affected, err = res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected != 1 {
+		return false, errors.New("torrent not found")
+	}
+
+	return true, nil
+}
+
+// RecordAnnounces records a batch of announces in a single transaction.
+// For every announce, the leech type multipliers of the torrent are applied to
+// the transferred amounts, both the raw and the credited amounts are appended
+// to the user's stat changes and the credited amounts are added to the user's
+// totals.
+// The raw amounts are added to the torrent's totals and the torrent's swarm
+// counters are updated according to the announce event.
+// Announces with an unknown passkey or info hash are skipped.
+// The number of recorded announces is returned.
+func (db *DB) RecordAnnounces(ctx context.Context, announces []Announce) (int, error) {
+	for _, a := range announces {
+		if len(a.Passkey) == 0 {
+			return 0, errors.New("missing passkey")
+		}
+		if a.Uploaded < 0 || a.Downloaded < 0 || a.Left < 0 {
+			return 0, errors.New("negative amounts")
+		}
+		if !a.Event.Valid() {
+			return 0, errors.New("invalid event")
+		}
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var recorded int
+	for _, a := range announces {
+		ok, err := recordAnnounceTx(ctx, a, tx)
+		if err != nil {
+			log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+			tx.Rollback()
+			return 0, err
+		}
+		if ok {
+			recorded++
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return recorded, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAnnounces(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := cleanDB()
+	require.Nil(t, err)
+
+	r := insertTestRelease(t, db)
+
+	normal := Torrent{
+		Release:    Release{ID: r.ID},
+		Uploaded:   time.Now(),
+		UploadedBy: User{ID: 1},
+		InfoHash:   [20]byte{1},
+		Info:       []byte("d4:name1:ae"),
+		LeechType:  0,
+	}
+	err = db.InsertTorrent(ctx, &normal)
+	require.Nil(t, err)
+
+	freeleech := Torrent{
+		Release:    Release{ID: r.ID},
+		Uploaded:   time.Now(),
+		UploadedBy: User{ID: 1},
+		InfoHash:   [20]byte{2},
+		Info:       []byte("d4:name1:be"),
+		LeechType:  1,
+	}
+	err = db.InsertTorrent(ctx, &freeleech)
+	require.Nil(t, err)
+
+	err = db.SignUpUser(ctx, "testuser", "testpwtest1234", "test@example.com")
+	require.Nil(t, err)
+	u, err := db.LoginAndGetUser(ctx, "testuser", "testpwtest1234")
+	require.Nil(t, err)
+
+	pk, err := db.GenerateNewPasskeyForUser(ctx, u.ID)
+	require.Nil(t, err)
+
+	recorded, err := db.RecordAnnounces(ctx, []Announce{
+		{Passkey: pk, InfoHash: normal.InfoHash, Downloaded: 100, Left: 900, Event: AnnounceEventStarted, ReportedAt: time.Now()},
+		{Passkey: pk, InfoHash: freeleech.InfoHash, Uploaded: 50, Downloaded: 1000, Left: 0, Event: AnnounceEventCompleted, ReportedAt: time.Now()},
+		{Passkey: "garbage", InfoHash: normal.InfoHash, Uploaded: 1000, ReportedAt: time.Now()},
+		{Passkey: pk, InfoHash: [20]byte{3}, Uploaded: 1000, ReportedAt: time.Now()},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, recorded)
+
+	u, err = db.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, int64(50), u.Uploaded)
+	require.Equal(t, int64(100), u.Downloaded)
+
+	got, err := db.GetTorrent(ctx, normal.ID)
+	require.Nil(t, err)
+	require.Equal(t, 0, got.Seeders)
+	require.Equal(t, 1, got.Leechers)
+	require.Equal(t, 0, got.Snatches)
+	require.Equal(t, int64(100), got.TotalDownloaded)
+
+	got, err = db.GetTorrent(ctx, freeleech.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, got.Seeders)
+	require.Equal(t, 0, got.Leechers)
+	require.Equal(t, 1, got.Snatches)
+	require.Equal(t, int64(50), got.TotalUploaded)
+	require.Equal(t, int64(1000), got.TotalDownloaded)
+
+	_, err = db.RecordAnnounces(ctx, []Announce{
+		{Passkey: pk, InfoHash: normal.InfoHash, Uploaded: -1},
+	})
+	require.NotNil(t, err)
+
+	_, err = db.RecordAnnounces(ctx, []Announce{
+		{Passkey: pk, InfoHash: normal.InfoHash, Event: "paused"},
+	})
+	require.NotNil(t, err)
+}
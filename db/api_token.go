@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
@@ -28,11 +29,11 @@ func generateRandomKey(length int) string {
 // encoded, so the string representation is actually 128 characters long.
 const tokenLength = 64
 
-func (db *DB) InsertTokenForUser(u User) (*APIToken, error) {
+func (db *DB) InsertTokenForUser(ctx context.Context, u User) (*APIToken, error) {
 	s := generateRandomKey(tokenLength)
 	var t time.Time
 
-	err := db.db.QueryRow("INSERT INTO api_tokens(token,created_at,uid) VALUES ($1,NOW(),$2) RETURNING created_at", s, u.ID).Scan(&t)
+	err := db.db.QueryRowContext(ctx, "INSERT INTO api_tokens(token,created_at,uid) VALUES ($1,NOW(),$2) RETURNING created_at", s, u.ID).Scan(&t)
 	if err != nil {
 		return nil, err
 	}
@@ -44,13 +45,13 @@ func (db *DB) InsertTokenForUser(u User) (*APIToken, error) {
 	}, nil
 }
 
-func (db *DB) GetToken(token string) (*APIToken, error) {
+func (db *DB) GetToken(ctx context.Context, token string) (*APIToken, error) {
 	if len(token) == 0 {
 		return nil, errors.New("invalid token")
 	}
 
 	t := APIToken{Token: token}
-	res := db.db.QueryRow("SELECT t.created_at,t.uid,u.username,u.email,u.last_login,u.last_access,u.enabled,u.can_login,u.uploaded,u.downloaded FROM api_tokens t, users u WHERE t.uid = u.id AND t.token = $1", token)
+	res := db.db.QueryRowContext(ctx, "SELECT t.created_at,t.uid,u.username,u.email,u.last_login,u.last_access,u.enabled,u.can_login,u.uploaded,u.downloaded,u.class,u.ratio_watch_until FROM api_tokens t, users u WHERE t.uid = u.id AND t.token = $1", token)
 
 	err := res.Scan(
 		&t.CreatedAt,
@@ -62,7 +63,9 @@ func (db *DB) GetToken(token string) (*APIToken, error) {
 		&t.User.Enabled,
 		&t.User.CanLogin,
 		&t.User.Uploaded,
-		&t.User.Downloaded)
+		&t.User.Downloaded,
+		&t.User.Class,
+		&t.User.RatioWatchUntil)
 	if err != nil {
 		return nil, err
 	}
@@ -1,12 +1,15 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestInsertGetToken(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -14,7 +17,7 @@ func TestInsertGetToken(t *testing.T) {
 		ID: 1,
 	}
 
-	token, err := db.InsertTokenForUser(u)
+	token, err := db.InsertTokenForUser(ctx, u)
 	require.Nil(t, err)
 	require.NotNil(t, token)
 
@@ -23,7 +26,7 @@ func TestInsertGetToken(t *testing.T) {
 	require.NotEmpty(t, token.CreatedAt)
 	require.Equal(t, tokenLength*2, len(token.Token))
 
-	token2, err := db.GetToken(token.Token)
+	token2, err := db.GetToken(ctx, token.Token)
 	require.Nil(t, err)
 	require.NotNil(t, token2)
 	require.Equal(t, token.Token, token2.Token)
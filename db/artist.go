@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -31,11 +32,11 @@ type RoledReleaseGroup struct {
 	ReleaseGroup ReleaseGroup
 }
 
-func (db *DB) AutocompleteArtists(s string) ([]Artist, error) {
+func (db *DB) AutocompleteArtists(ctx context.Context, s string) ([]Artist, error) {
 	if len(s) == 0 {
 		return nil, errors.New("missing s")
 	}
-	rows, err := db.db.Query("SELECT DISTINCT a.id,a.name,a.bio,a.added,u.id,u.username FROM artists a, artist_aliases al, users u WHERE a.added_by = u.id AND  (a.name LIKE $1 OR (al.alias LIKE $1 AND al.artist = a.id))", fmt.Sprint("%", s, "%"))
+	rows, err := db.db.QueryContext(ctx, "SELECT a.id,a.name,a.bio,a.added,u.id,u.username FROM artists a, users u WHERE a.added_by = u.id AND (a.name LIKE $1 OR EXISTS (SELECT 1 FROM artist_aliases al WHERE al.artist = a.id AND al.alias LIKE $1)) ORDER BY a.id ASC", fmt.Sprint("%", s, "%"))
 	if err != nil {
 		return nil, err
 	}
@@ -54,12 +55,12 @@ func (db *DB) AutocompleteArtists(s string) ([]Artist, error) {
 			return nil, err
 		}
 
-		err = db.populateArtistAliases(&tmp)
+		err = db.populateArtistAliases(ctx, &tmp)
 		if err != nil {
 			return nil, err
 		}
 
-		err = db.populateArtistTags(&tmp)
+		err = db.populateArtistTags(ctx, &tmp)
 		if err != nil {
 			return nil, err
 		}
@@ -70,12 +71,12 @@ func (db *DB) AutocompleteArtists(s string) ([]Artist, error) {
 	return artists, nil
 }
 
-func (db *DB) populateArtistTags(a *Artist) error {
+func (db *DB) populateArtistTags(ctx context.Context, a *Artist) error {
 	if a == nil {
 		return errors.New("missing artist")
 	}
 
-	rows, err := db.db.Query("SELECT t.tag FROM artist_tags t,artist_tags_artists a WHERE a.artist = $1 AND a.tag = t.id ", a.ID)
+	rows, err := db.db.QueryContext(ctx, "SELECT t.tag FROM artist_tags t,artist_tags_artists a WHERE a.artist = $1 AND a.tag = t.id ", a.ID)
 	if err != nil {
 		return err
 	}
@@ -94,12 +95,12 @@ func (db *DB) populateArtistTags(a *Artist) error {
 	return nil
 }
 
-func (db *DB) populateArtistAliases(a *Artist) error {
+func (db *DB) populateArtistAliases(ctx context.Context, a *Artist) error {
 	if a == nil {
 		return errors.New("missing artist")
 	}
 
-	rows, err := db.db.Query("SELECT a.alias,a.added,u.id,u.username FROM artist_aliases a, users u WHERE a.added_by = u.id AND artist=$1", a.ID)
+	rows, err := db.db.QueryContext(ctx, "SELECT a.alias,a.added,u.id,u.username FROM artist_aliases a, users u WHERE a.added_by = u.id AND artist=$1", a.ID)
 	if err != nil {
 		return err
 	}
@@ -121,12 +122,12 @@ func (db *DB) populateArtistAliases(a *Artist) error {
 	return nil
 }
 
-func (db *DB) AutocompleteArtistTags(s string) ([]string, error) {
+func (db *DB) AutocompleteArtistTags(ctx context.Context, s string) ([]string, error) {
 	if len(s) == 0 {
 		return nil, errors.New("missing s")
 	}
 
-	rows, err := db.db.Query("SELECT tag FROM artist_tags WHERE tag LIKE $1", fmt.Sprint("%", s, "%"))
+	rows, err := db.db.QueryContext(ctx, "SELECT tag FROM artist_tags WHERE tag LIKE $1", fmt.Sprint("%", s, "%"))
 	if err != nil {
 		return nil, err
 	}
@@ -144,11 +145,11 @@ func (db *DB) AutocompleteArtistTags(s string) ([]string, error) {
 	return tags, nil
 }
 
-func (db *DB) GetArtist(id int) (*Artist, error) {
+func (db *DB) GetArtist(ctx context.Context, id int) (*Artist, error) {
 	if id < 0 {
 		return nil, errors.New("invalid id")
 	}
-	row := db.db.QueryRow("SELECT a.name,a.bio,a.added,u.id,u.username FROM artists a, users u WHERE a.added_by = u.id AND a.id = $1", id)
+	row := db.db.QueryRowContext(ctx, "SELECT a.name,a.bio,a.added,u.id,u.username FROM artists a, users u WHERE a.added_by = u.id AND a.id = $1", id)
 
 	artist := Artist{ID: id}
 	err := row.Scan(
@@ -161,12 +162,12 @@ func (db *DB) GetArtist(id int) (*Artist, error) {
 		return nil, err
 	}
 
-	err = db.populateArtistAliases(&artist)
+	err = db.populateArtistAliases(ctx, &artist)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.populateArtistTags(&artist)
+	err = db.populateArtistTags(ctx, &artist)
 	if err != nil {
 		return nil, err
 	}
@@ -174,12 +175,12 @@ func (db *DB) GetArtist(id int) (*Artist, error) {
 	return &artist, nil
 }
 
-func (db *DB) PopulateReleaseGroups(artist *Artist) error {
+func (db *DB) PopulateReleaseGroups(ctx context.Context, artist *Artist) error {
 	if artist.ID < 0 {
 		return errors.New("invalid artist ID")
 	}
 
-	rows, err := db.db.Query("SELECT rga.role,rg.id,rg.name,rg.type,rg.release_date FROM release_groups rg, release_groups_artists rga WHERE rg.id = rga.release_group AND rga.artist = $1", artist.ID)
+	rows, err := db.db.QueryContext(ctx, "SELECT rga.role,rg.id,rg.name,rg.type,rg.release_date FROM release_groups rg, release_groups_artists rga WHERE rg.id = rga.release_group AND rga.artist = $1", artist.ID)
 	if err != nil {
 		return err
 	}
@@ -205,7 +206,7 @@ func (db *DB) PopulateReleaseGroups(artist *Artist) error {
 	return nil
 }
 
-func insertArtistTx(artist *Artist, tx *sql.Tx) error {
+func insertArtistTx(ctx context.Context, artist *Artist, tx *sql.Tx) error {
 	if artist.AddedBy.ID < 0 {
 		return errors.New("invalid user ID")
 	}
@@ -215,7 +216,7 @@ func insertArtistTx(artist *Artist, tx *sql.Tx) error {
 		bio = &artist.Bio.String
 	}
 
-	err := tx.QueryRow("INSERT INTO artists(name,bio,added,added_by) VALUES ($1,$2,$3,$4) RETURNING id", artist.Name, bio, artist.Added, artist.AddedBy.ID).Scan(&artist.ID)
+	err := tx.QueryRowContext(ctx, "INSERT INTO artists(name,bio,added,added_by) VALUES ($1,$2,$3,$4) RETURNING id", artist.Name, bio, artist.Added, artist.AddedBy.ID).Scan(&artist.ID)
 	if err != nil {
 		return err
 	}
@@ -223,17 +224,17 @@ func insertArtistTx(artist *Artist, tx *sql.Tx) error {
 	var res sql.Result
 	for _, t := range artist.Tags {
 		var id int
-		err = tx.QueryRow("INSERT INTO artist_tags(tag) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id", t).Scan(&id)
+		err = tx.QueryRowContext(ctx, "INSERT INTO artist_tags(tag) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id", t).Scan(&id)
 		if err != nil && err != sql.ErrNoRows {
 			return err
 		}
 
 		if err == nil {
 			// inserted
-			res, err = tx.Exec("INSERT INTO artist_tags_artists(artist,tag) VALUES($1,$2)", artist.ID, id)
+			res, err = tx.ExecContext(ctx, "INSERT INTO artist_tags_artists(artist,tag) VALUES($1,$2)", artist.ID, id)
 		} else {
 			// already present
-			res, err = tx.Exec("INSERT INTO artist_tags_artists(artist,tag) VALUES($1,(SELECT id FROM artist_tags WHERE tag=$2 LIMIT 1))", artist.ID, t)
+			res, err = tx.ExecContext(ctx, "INSERT INTO artist_tags_artists(artist,tag) VALUES($1,(SELECT id FROM artist_tags WHERE tag=$2 LIMIT 1))", artist.ID, t)
 		}
 		if err != nil {
 			return err
@@ -249,7 +250,7 @@ func insertArtistTx(artist *Artist, tx *sql.Tx) error {
 	}
 
 	for _, a := range artist.Aliases {
-		res, err = tx.Exec("INSERT INTO artist_aliases(artist,alias,added,added_by) VALUES($1,$2,$3,$4)", artist.ID, a.Alias, a.Added, a.AddedBy.ID)
+		res, err = tx.ExecContext(ctx, "INSERT INTO artist_aliases(artist,alias,added,added_by) VALUES($1,$2,$3,$4)", artist.ID, a.Alias, a.Added, a.AddedBy.ID)
 		if err != nil {
 			return err
 		}
@@ -264,16 +265,329 @@ func insertArtistTx(artist *Artist, tx *sql.Tx) error {
 	}
 
 	// TODO insert release groups?
+	return recordRevisionTx(ctx, RevisionArtist, artist.ID, Edit{Edited: artist.Added, EditedBy: artist.AddedBy}, tx)
+}
+
+func (db *DB) InsertArtist(ctx context.Context, artist *Artist) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = insertArtistTx(ctx, artist, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ArtistIDSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "a",
+		col: "id",
+	}
+}
+
+func ArtistNameSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "a",
+		col: "name",
+	}
+}
+
+func updateArtistTx(ctx context.Context, artist Artist, tx *sql.Tx) error {
+	var bio *string
+	if artist.Bio.String != "" {
+		bio = &artist.Bio.String
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE artists SET name=$1,bio=$2 WHERE id=$3", artist.Name, bio, artist.ID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("artist not found")
+	}
+
+	return nil
+}
+
+// UpdateArtist updates the name and bio of an artist.
+// Aliases and tags are managed separately.
+func (db *DB) UpdateArtist(ctx context.Context, artist Artist, e Edit) error {
+	return db.edit(ctx, RevisionArtist, artist.ID, e, func(tx *sql.Tx) error {
+		return updateArtistTx(ctx, artist, tx)
+	})
+}
+
+func addArtistAliasTx(ctx context.Context, artistID int, alias ArtistAlias, tx *sql.Tx) error {
+	res, err := tx.ExecContext(ctx, "INSERT INTO artist_aliases(artist,alias,added,added_by) VALUES($1,$2,$3,$4)", artistID, alias.Alias, alias.Added, alias.AddedBy.ID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("did not insert")
+	}
+
 	return nil
 }
 
-func (db *DB) InsertArtist(artist *Artist) error {
-	tx, err := db.db.Begin()
+// AddArtistAlias adds an alias to an artist.
+// The edit is attributed to the user who added the alias.
+func (db *DB) AddArtistAlias(ctx context.Context, artistID int, alias ArtistAlias) error {
+	if len(alias.Alias) == 0 {
+		return errors.New("missing alias")
+	}
+
+	return db.edit(ctx, RevisionArtist, artistID, Edit{Edited: alias.Added, EditedBy: alias.AddedBy}, func(tx *sql.Tx) error {
+		return addArtistAliasTx(ctx, artistID, alias, tx)
+	})
+}
+
+func removeArtistAliasTx(ctx context.Context, artistID int, alias string, tx *sql.Tx) error {
+	res, err := tx.ExecContext(ctx, "DELETE FROM artist_aliases WHERE artist = $1 AND alias = $2", artistID, alias)
 	if err != nil {
 		return err
 	}
 
-	err = insertArtistTx(artist, tx)
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("alias not found")
+	}
+
+	return nil
+}
+
+func (db *DB) RemoveArtistAlias(ctx context.Context, artistID int, alias string, e Edit) error {
+	return db.edit(ctx, RevisionArtist, artistID, e, func(tx *sql.Tx) error {
+		return removeArtistAliasTx(ctx, artistID, alias, tx)
+	})
+}
+
+func addArtistTagsTx(ctx context.Context, artistID int, tags []string, tx *sql.Tx) error {
+	row := tx.QueryRowContext(ctx, "SELECT 1 FROM artists WHERE id = $1 FOR UPDATE", artistID)
+	var tmp int
+	err := row.Scan(&tmp)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("artist not found")
+		}
+		return err
+	}
+
+	for _, t := range tags {
+		_, err = tx.ExecContext(ctx, "INSERT INTO artist_tags(tag) VALUES ($1) ON CONFLICT DO NOTHING", t)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO artist_tags_artists(artist,tag) VALUES($1,(SELECT id FROM artist_tags WHERE tag=$2 LIMIT 1)) ON CONFLICT DO NOTHING", artistID, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddArtistTags adds tags to an artist.
+// Tags the artist already has are skipped.
+func (db *DB) AddArtistTags(ctx context.Context, artistID int, tags []string, e Edit) error {
+	return db.edit(ctx, RevisionArtist, artistID, e, func(tx *sql.Tx) error {
+		return addArtistTagsTx(ctx, artistID, tags, tx)
+	})
+}
+
+func removeArtistTagTx(ctx context.Context, artistID int, tag string, tx *sql.Tx) error {
+	res, err := tx.ExecContext(ctx, "DELETE FROM artist_tags_artists a USING artist_tags t WHERE a.tag = t.id AND a.artist = $1 AND t.tag = $2", artistID, tag)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("tag not found")
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM artist_tags t WHERE t.tag = $1 AND NOT EXISTS (SELECT * FROM artist_tags_artists a WHERE a.tag = t.id)", tag)
+	return err
+}
+
+// RemoveArtistTag removes a tag from an artist.
+// Tags that are not used by any artist anymore are deleted.
+func (db *DB) RemoveArtistTag(ctx context.Context, artistID int, tag string, e Edit) error {
+	return db.edit(ctx, RevisionArtist, artistID, e, func(tx *sql.Tx) error {
+		return removeArtistTagTx(ctx, artistID, tag, tx)
+	})
+}
+
+// ArtistRedirect records that an artist was merged into another one.
+type ArtistRedirect struct {
+	Artist   int // the merged artist, which does not exist anymore
+	Target   int
+	Merged   time.Time
+	MergedBy User
+}
+
+func mergeArtistsTx(ctx context.Context, r ArtistRedirect, tx *sql.Tx) error {
+	var targetName, name string
+	err := tx.QueryRowContext(ctx, "SELECT name FROM artists WHERE id = $1 FOR UPDATE", r.Target).Scan(&targetName)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, "SELECT name FROM artists WHERE id = $1 FOR UPDATE", r.Artist).Scan(&name)
+	if err != nil {
+		return err
+	}
+
+	// The merge is an edit of the target and of every release group whose
+	// credits are rewritten.
+	edit := Edit{Edited: r.Merged, EditedBy: r.MergedBy}
+	err = beginEditTx(ctx, RevisionArtist, r.Target, tx)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT release_group FROM release_groups_artists WHERE artist = $1 ORDER BY release_group", r.Artist)
+	if err != nil {
+		return err
+	}
+	var groups []int
+	for rows.Next() {
+		var tmp int
+		err = rows.Scan(&tmp)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		groups = append(groups, tmp)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		err = beginEditTx(ctx, RevisionReleaseGroup, g, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Credits, aliases and tags the target already has are dropped.
+	_, err = tx.ExecContext(ctx, "INSERT INTO release_groups_artists(release_group,artist,role) SELECT release_group,$1,role FROM release_groups_artists WHERE artist = $2 ON CONFLICT DO NOTHING", r.Target, r.Artist)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM release_groups_artists WHERE artist = $1", r.Artist)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO artist_aliases(artist,alias,added,added_by) SELECT $1,alias,added,added_by FROM artist_aliases WHERE artist = $2 AND alias <> $3 ON CONFLICT DO NOTHING", r.Target, r.Artist, targetName)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM artist_aliases WHERE artist = $1", r.Artist)
+	if err != nil {
+		return err
+	}
+	if name != targetName {
+		_, err = tx.ExecContext(ctx, "INSERT INTO artist_aliases(artist,alias,added,added_by) VALUES($1,$2,$3,$4) ON CONFLICT DO NOTHING", r.Target, name, r.Merged, r.MergedBy.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO artist_tags_artists(artist,tag) SELECT $1,tag FROM artist_tags_artists WHERE artist = $2 ON CONFLICT DO NOTHING", r.Target, r.Artist)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM artist_tags_artists WHERE artist = $1", r.Artist)
+	if err != nil {
+		return err
+	}
+
+	// The bio of the target wins, if it has one.
+	_, err = tx.ExecContext(ctx, "UPDATE artists SET bio = (SELECT bio FROM artists WHERE id = $2) WHERE id = $1 AND bio IS NULL", r.Target, r.Artist)
+	if err != nil {
+		return err
+	}
+
+	// Artists merged into the merged artist earlier now redirect to the
+	// target, so there are no chains of redirects.
+	_, err = tx.ExecContext(ctx, "UPDATE artist_redirects SET target = $1 WHERE target = $2", r.Target, r.Artist)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO artist_redirects(artist,target,merged,merged_by) VALUES($1,$2,$3,$4)", r.Artist, r.Target, r.Merged, r.MergedBy.ID)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM artists WHERE id = $1", r.Artist)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("did not delete")
+	}
+
+	err = recordRevisionTx(ctx, RevisionArtist, r.Target, edit, tx)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		err = recordRevisionTx(ctx, RevisionReleaseGroup, g, edit, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MergeArtists merges r.Artist into r.Target and deletes it.
+// Its release groups, aliases and tags are moved to the target, its name
+// becomes an alias of the target and a redirect to the target is left
+// behind.
+// Revisions of the target and of the release groups of the merged artist are
+// recorded.
+func (db *DB) MergeArtists(ctx context.Context, r ArtistRedirect) error {
+	if r.Artist < 0 || r.Target < 0 {
+		return errors.New("invalid ID")
+	}
+	if r.Artist == r.Target {
+		return errors.New("can not merge an artist into itself")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = mergeArtistsTx(ctx, r, tx)
 	if err != nil {
 		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
 		tx.Rollback()
@@ -282,3 +596,24 @@ func (db *DB) InsertArtist(artist *Artist) error {
 
 	return tx.Commit()
 }
+
+// GetArtistRedirect returns the redirect left behind by merging the artist
+// with the given ID into another one.
+// It returns sql.ErrNoRows if the artist was not merged.
+func (db *DB) GetArtistRedirect(ctx context.Context, id int) (*ArtistRedirect, error) {
+	if id < 0 {
+		return nil, errors.New("invalid id")
+	}
+
+	r := ArtistRedirect{Artist: id}
+	err := db.db.QueryRowContext(ctx, "SELECT r.target,r.merged,u.id,u.username FROM artist_redirects r, users u WHERE r.merged_by = u.id AND r.artist = $1", id).Scan(
+		&r.Target,
+		&r.Merged,
+		&r.MergedBy.ID,
+		&r.MergedBy.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
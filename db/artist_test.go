@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 	"time"
@@ -9,6 +10,8 @@ import (
 )
 
 func TestAutocompleteArtists(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -35,13 +38,13 @@ func TestAutocompleteArtists(t *testing.T) {
 		AddedBy: User{ID: 0},
 	}
 
-	err = db.InsertArtist(&a1)
+	err = db.InsertArtist(ctx, &a1)
 	require.Nil(t, err)
 
-	err = db.InsertArtist(&a2)
+	err = db.InsertArtist(ctx, &a2)
 	require.Nil(t, err)
 
-	a, err := db.AutocompleteArtists("est1")
+	a, err := db.AutocompleteArtists(ctx, "est1")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(a))
 	require.Equal(t, a1.Name, a[0].Name)
@@ -55,11 +58,11 @@ func TestAutocompleteArtists(t *testing.T) {
 	require.Equal(t, a1.Added, a[0].Added)
 	require.Equal(t, a1.AddedBy.ID, a[0].AddedBy.ID)
 
-	a, err = db.AutocompleteArtists("test")
+	a, err = db.AutocompleteArtists(ctx, "test")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(a))
 
-	a, err = db.AutocompleteArtists("best2")
+	a, err = db.AutocompleteArtists(ctx, "best2")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(a))
 	require.Equal(t, a2.Name, a[0].Name)
@@ -74,6 +77,8 @@ func TestAutocompleteArtists(t *testing.T) {
 }
 
 func TestInsertGetArtist(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -89,10 +94,10 @@ func TestInsertGetArtist(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertArtist(&a1)
+	err = db.InsertArtist(ctx, &a1)
 	require.Nil(t, err)
 
-	a2, err := db.GetArtist(a1.ID)
+	a2, err := db.GetArtist(ctx, a1.ID)
 	require.Nil(t, err)
 	require.NotNil(t, a2)
 	require.Equal(t, a1.Name, a2.Name)
@@ -108,6 +113,8 @@ func TestInsertGetArtist(t *testing.T) {
 }
 
 func TestAutocompleteArtistTags(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -134,20 +141,20 @@ func TestAutocompleteArtistTags(t *testing.T) {
 		AddedBy: User{ID: 0},
 	}
 
-	err = db.InsertArtist(&a1)
+	err = db.InsertArtist(ctx, &a1)
 	require.Nil(t, err)
 
-	err = db.InsertArtist(&a2)
+	err = db.InsertArtist(ctx, &a2)
 	require.Nil(t, err)
 
-	tags, err := db.AutocompleteArtistTags("a")
+	tags, err := db.AutocompleteArtistTags(ctx, "a")
 	require.Nil(t, err)
 	require.Equal(t, 3, len(tags))
 	require.Contains(t, tags, "tag1")
 	require.Contains(t, tags, "tag2")
 	require.Contains(t, tags, "tag3")
 
-	tags, err = db.AutocompleteArtistTags("ag3")
+	tags, err = db.AutocompleteArtistTags(ctx, "ag3")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(tags))
 	require.Equal(t, "tag3", tags[0])
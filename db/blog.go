@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -17,22 +18,22 @@ type BlogEntry struct {
 	Tags     []string
 }
 
-func insertBlogTagsTx(post BlogEntry, tx *sql.Tx) error {
+func insertBlogTagsTx(ctx context.Context, post BlogEntry, tx *sql.Tx) error {
 	var res sql.Result
 	var err error
 	for _, t := range post.Tags {
 		var id int
-		err = tx.QueryRow("INSERT INTO blog_tags(tag) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id", t).Scan(&id)
+		err = tx.QueryRowContext(ctx, "INSERT INTO blog_tags(tag) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id", t).Scan(&id)
 		if err != nil && err != sql.ErrNoRows {
 			return err
 		}
 
 		if err == nil {
 			// inserted
-			res, err = tx.Exec("INSERT INTO blog_tags_blogs(blog,tag) VALUES($1,$2)", post.ID, id)
+			res, err = tx.ExecContext(ctx, "INSERT INTO blog_tags_blogs(blog,tag) VALUES($1,$2)", post.ID, id)
 		} else {
 			// already present
-			res, err = tx.Exec("INSERT INTO blog_tags_blogs(blog,tag) VALUES($1,(SELECT id FROM blog_tags WHERE tag=$2 LIMIT 1))", post.ID, t)
+			res, err = tx.ExecContext(ctx, "INSERT INTO blog_tags_blogs(blog,tag) VALUES($1,(SELECT id FROM blog_tags WHERE tag=$2 LIMIT 1))", post.ID, t)
 		}
 		if err != nil {
 			return err
@@ -50,26 +51,26 @@ func insertBlogTagsTx(post BlogEntry, tx *sql.Tx) error {
 	return nil
 }
 
-func addBlogPostTx(post *BlogEntry, tx *sql.Tx) error {
-	err := tx.QueryRow("INSERT INTO blogs(author,title,content,posted_at) VALUES($1,$2,$3,$4) RETURNING id", post.Author.ID, post.Title, post.Content, post.PostedAt).Scan(&post.ID)
+func addBlogPostTx(ctx context.Context, post *BlogEntry, tx *sql.Tx) error {
+	err := tx.QueryRowContext(ctx, "INSERT INTO blogs(author,title,content,posted_at) VALUES($1,$2,$3,$4) RETURNING id", post.Author.ID, post.Title, post.Content, post.PostedAt).Scan(&post.ID)
 	if err != nil {
 		return err
 	}
 
-	return insertBlogTagsTx(*post, tx)
+	return insertBlogTagsTx(ctx, *post, tx)
 }
 
-func (db *DB) InsertBlogEntry(post *BlogEntry) error {
+func (db *DB) InsertBlogEntry(ctx context.Context, post *BlogEntry) error {
 	if post == nil {
 		return errors.New("no post provided")
 	}
 
-	tx, err := db.db.Begin()
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	err = addBlogPostTx(post, tx)
+	err = addBlogPostTx(ctx, post, tx)
 	if err != nil {
 		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
 		tx.Rollback()
@@ -79,8 +80,8 @@ func (db *DB) InsertBlogEntry(post *BlogEntry) error {
 	return tx.Commit()
 }
 
-func deletePostTagsTx(id int, tx *sql.Tx) error {
-	res, err := tx.Exec("DELETE FROM blog_tags_blogs WHERE blog = $1", id)
+func deletePostTagsTx(ctx context.Context, id int, tx *sql.Tx) error {
+	res, err := tx.ExecContext(ctx, "DELETE FROM blog_tags_blogs WHERE blog = $1", id)
 	if err != nil {
 		return err
 	}
@@ -89,20 +90,20 @@ func deletePostTagsTx(id int, tx *sql.Tx) error {
 		return err
 	}
 	if affected != 0 {
-		_, err = tx.Exec("DELETE FROM blog_tags t WHERE NOT EXISTS (SELECT * FROM blog_tags_blogs b WHERE b.tag = t.id);")
+		_, err = tx.ExecContext(ctx, "DELETE FROM blog_tags t WHERE NOT EXISTS (SELECT * FROM blog_tags_blogs b WHERE b.tag = t.id);")
 		return err
 	}
 
 	return nil
 }
 
-func deleteBlogPostTx(id int, tx *sql.Tx) error {
-	err := deletePostTagsTx(id, tx)
+func deleteBlogPostTx(ctx context.Context, id int, tx *sql.Tx) error {
+	err := deletePostTagsTx(ctx, id, tx)
 	if err != nil {
 		return err
 	}
 
-	res, err := tx.Exec("DELETE FROM blogs WHERE id = $1", id)
+	res, err := tx.ExecContext(ctx, "DELETE FROM blogs WHERE id = $1", id)
 	if err != nil {
 		return err
 	}
@@ -117,17 +118,17 @@ func deleteBlogPostTx(id int, tx *sql.Tx) error {
 	return nil
 }
 
-func (db *DB) DeleteBlogEntry(id int) error {
+func (db *DB) DeleteBlogEntry(ctx context.Context, id int) error {
 	if id < 0 {
 		return errors.New("invalid ID")
 	}
 
-	tx, err := db.db.Begin()
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	err = deleteBlogPostTx(id, tx)
+	err = deleteBlogPostTx(ctx, id, tx)
 	if err != nil {
 		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
 		tx.Rollback()
@@ -137,18 +138,18 @@ func (db *DB) DeleteBlogEntry(id int) error {
 	return tx.Commit()
 }
 
-func updateBlogPostTx(post BlogEntry, tx *sql.Tx) error {
-	err := deletePostTagsTx(post.ID, tx)
+func updateBlogPostTx(ctx context.Context, post BlogEntry, tx *sql.Tx) error {
+	err := deletePostTagsTx(ctx, post.ID, tx)
 	if err != nil {
 		return err
 	}
 
-	err = insertBlogTagsTx(post, tx)
+	err = insertBlogTagsTx(ctx, post, tx)
 	if err != nil {
 		return err
 	}
 
-	res, err := tx.Exec("UPDATE blogs SET author=$1,title=$2,content=$3,posted_at=$4 WHERE id=$5", post.Author.ID, post.Title, post.Content, post.PostedAt, post.ID)
+	res, err := tx.ExecContext(ctx, "UPDATE blogs SET author=$1,title=$2,content=$3,posted_at=$4 WHERE id=$5", post.Author.ID, post.Title, post.Content, post.PostedAt, post.ID)
 	if err != nil {
 		return err
 	}
@@ -164,17 +165,17 @@ func updateBlogPostTx(post BlogEntry, tx *sql.Tx) error {
 	return nil
 }
 
-func (db *DB) UpdateBlogEntry(post BlogEntry) error {
+func (db *DB) UpdateBlogEntry(ctx context.Context, post BlogEntry) error {
 	if post.ID < 0 {
 		return errors.New("invalid ID")
 	}
 
-	tx, err := db.db.Begin()
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	err = updateBlogPostTx(post, tx)
+	err = updateBlogPostTx(ctx, post, tx)
 	if err != nil {
 		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
 		tx.Rollback()
@@ -184,8 +185,8 @@ func (db *DB) UpdateBlogEntry(post BlogEntry) error {
 	return tx.Commit()
 }
 
-func (db *DB) populateBlogTags(entry *BlogEntry) error {
-	inner, err := db.db.Query("SELECT t.tag FROM blog_tags t, blog_tags_blogs b WHERE b.blog = $1 AND b.tag = t.id", entry.ID)
+func (db *DB) populateBlogTags(ctx context.Context, entry *BlogEntry) error {
+	inner, err := db.db.QueryContext(ctx, "SELECT t.tag FROM blog_tags t, blog_tags_blogs b WHERE b.blog = $1 AND b.tag = t.id", entry.ID)
 	if err != nil {
 		return err
 	}
@@ -203,12 +204,12 @@ func (db *DB) populateBlogTags(entry *BlogEntry) error {
 	return nil
 }
 
-func (db *DB) GetBlogEntry(id int) (*BlogEntry, error) {
+func (db *DB) GetBlogEntry(ctx context.Context, id int) (*BlogEntry, error) {
 	if id < 0 {
 		return nil, errors.New("invalid ID")
 	}
 
-	row := db.db.QueryRow("SELECT b.id,b.author,u.username,b.title,b.content,b.posted_at FROM blogs b,users u WHERE b.author = u.id AND b.id = $1", id)
+	row := db.db.QueryRowContext(ctx, "SELECT b.id,b.author,u.username,b.title,b.content,b.posted_at FROM blogs b,users u WHERE b.author = u.id AND b.id = $1", id)
 	var entry BlogEntry
 	err := row.Scan(
 		&entry.ID,
@@ -222,7 +223,7 @@ func (db *DB) GetBlogEntry(id int) (*BlogEntry, error) {
 		return nil, err
 	}
 
-	err = db.populateBlogTags(&entry)
+	err = db.populateBlogTags(ctx, &entry)
 	if err != nil {
 		return nil, err
 	}
@@ -230,15 +231,15 @@ func (db *DB) GetBlogEntry(id int) (*BlogEntry, error) {
 	return &entry, nil
 }
 
-func (db *DB) GetBlogEntries(limit, offset int) ([]BlogEntry, error) {
-	if limit < 1 {
-		return nil, errors.New("invalid limit")
-	}
-	if offset < 0 {
-		return nil, errors.New("invalid offset")
-	}
+// BlogCursor is the position of an entry in the list of all blog entries,
+// which is sorted by PostedAt, newest first, and then by ID.
+type BlogCursor struct {
+	PostedAt time.Time
+	ID       int
+}
 
-	rows, err := db.db.Query("SELECT b.id,b.author,u.username,b.title,b.content,b.posted_at FROM blogs b,users u WHERE b.author = u.id ORDER BY b.posted_at DESC LIMIT $1 OFFSET $2", limit, offset)
+func (db *DB) queryBlogEntries(ctx context.Context, query string, args ...interface{}) ([]BlogEntry, error) {
+	rows, err := db.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -259,7 +260,7 @@ func (db *DB) GetBlogEntries(limit, offset int) ([]BlogEntry, error) {
 			return nil, err
 		}
 
-		err = db.populateBlogTags(&entry)
+		err = db.populateBlogTags(ctx, &entry)
 		if err != nil {
 			return nil, err
 		}
@@ -269,3 +270,43 @@ func (db *DB) GetBlogEntries(limit, offset int) ([]BlogEntry, error) {
 
 	return result, nil
 }
+
+func (db *DB) GetBlogEntries(ctx context.Context, limit, offset int) ([]BlogEntry, error) {
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	return db.queryBlogEntries(ctx, "SELECT b.id,b.author,u.username,b.title,b.content,b.posted_at FROM blogs b,users u WHERE b.author = u.id ORDER BY b.posted_at DESC, b.id ASC LIMIT $1 OFFSET $2", limit, offset)
+}
+
+// GetBlogEntriesAfter returns up to limit entries following c, newest first.
+// The entry at c does not need to exist anymore.
+func (db *DB) GetBlogEntriesAfter(ctx context.Context, c BlogCursor, limit int) ([]BlogEntry, error) {
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	return db.queryBlogEntries(ctx, "SELECT b.id,b.author,u.username,b.title,b.content,b.posted_at FROM blogs b,users u WHERE b.author = u.id AND (b.posted_at < $1 OR (b.posted_at = $1 AND b.id > $2)) ORDER BY b.posted_at DESC, b.id ASC LIMIT $3", c.PostedAt, c.ID, limit)
+}
+
+// GetBlogEntriesBefore returns up to limit entries preceding c, newest first.
+// These are the entries closest to c, not the newest ones.
+func (db *DB) GetBlogEntriesBefore(ctx context.Context, c BlogCursor, limit int) ([]BlogEntry, error) {
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	entries, err := db.queryBlogEntries(ctx, "SELECT b.id,b.author,u.username,b.title,b.content,b.posted_at FROM blogs b,users u WHERE b.author = u.id AND (b.posted_at > $1 OR (b.posted_at = $1 AND b.id < $2)) ORDER BY b.posted_at ASC, b.id DESC LIMIT $3", c.PostedAt, c.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -8,6 +9,8 @@ import (
 )
 
 func TestInsertGetDeleteBlogEntry(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -22,7 +25,7 @@ func TestInsertGetDeleteBlogEntry(t *testing.T) {
 	e := original // make a copy
 
 	// test insert
-	err = db.InsertBlogEntry(&e)
+	err = db.InsertBlogEntry(ctx, &e)
 	require.Nil(t, err)
 
 	// check nothing has been changed
@@ -33,7 +36,7 @@ func TestInsertGetDeleteBlogEntry(t *testing.T) {
 	require.Equal(t, original.Tags, e.Tags)
 
 	// test get post
-	p, err := db.GetBlogEntry(e.ID)
+	p, err := db.GetBlogEntry(ctx, e.ID)
 	require.Nil(t, err)
 	require.Equal(t, original.Author.ID, p.Author.ID)
 	require.Equal(t, original.Title, p.Title)
@@ -43,15 +46,17 @@ func TestInsertGetDeleteBlogEntry(t *testing.T) {
 	require.NotEmpty(t, p.Author.Username)
 
 	// test delete
-	err = db.DeleteBlogEntry(e.ID)
+	err = db.DeleteBlogEntry(ctx, e.ID)
 	require.Nil(t, err)
 
-	entries, err := db.GetBlogEntries(100, 0)
+	entries, err := db.GetBlogEntries(ctx, 100, 0)
 	require.Nil(t, err)
 	require.Equal(t, 0, len(entries))
 }
 
 func TestGetBlogEntriesOrdering(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -64,16 +69,16 @@ func TestGetBlogEntriesOrdering(t *testing.T) {
 		Tags:     []string{"test"},
 	}
 
-	err = db.InsertBlogEntry(&original)
+	err = db.InsertBlogEntry(ctx, &original)
 	require.Nil(t, err)
 
 	original.PostedAt = time.Date(2001, 01, 02, 0, 0, 0, 0, time.FixedZone("", 0))
 	original.Tags = []string{"test", "second"}
 
-	err = db.InsertBlogEntry(&original)
+	err = db.InsertBlogEntry(ctx, &original)
 	require.Nil(t, err)
 
-	p, err := db.GetBlogEntries(100, 0)
+	p, err := db.GetBlogEntries(ctx, 100, 0)
 	require.Nil(t, err)
 	require.Equal(t, 2, len(p))
 
@@ -84,6 +89,8 @@ func TestGetBlogEntriesOrdering(t *testing.T) {
 }
 
 func TestUpdateBlogEntry(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -96,7 +103,7 @@ func TestUpdateBlogEntry(t *testing.T) {
 		Tags:     []string{"test"},
 	}
 
-	err = db.InsertBlogEntry(&post)
+	err = db.InsertBlogEntry(ctx, &post)
 	require.Nil(t, err)
 
 	post.Author = User{ID: 0}
@@ -105,10 +112,10 @@ func TestUpdateBlogEntry(t *testing.T) {
 	post.Content = "Updated content"
 	post.Tags = []string{"something", "else"}
 
-	err = db.UpdateBlogEntry(post)
+	err = db.UpdateBlogEntry(ctx, post)
 	require.Nil(t, err)
 
-	p, err := db.GetBlogEntries(100, 0)
+	p, err := db.GetBlogEntries(ctx, 100, 0)
 	require.Nil(t, err)
 	require.Equal(t, 1, len(p))
 	require.Equal(t, post.ID, p[0].ID)
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config configures the connection to the database and the connection pool.
+// If DSN is set, it is used as-is and the other connection settings are
+// ignored. The pool settings always apply.
+type Config struct {
+	DSN string
+
+	Host            string
+	Port            int
+	Database        string
+	User            string
+	Password        string
+	SSLMode         string
+	ConnectTimeout  time.Duration
+	ApplicationName string
+
+	// MaxOpenConns and MaxIdleConns limit the connection pool, zero means
+	// the database/sql default.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// quoteDSNValue quotes a value of a key=value DSN as described in
+// https://www.postgresql.org/docs/current/static/libpq-connect.html#LIBPQ-CONNSTRING
+func quoteDSNValue(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
+
+func (c Config) dsn() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	params := make(map[string]string)
+	if c.Host != "" {
+		params["host"] = c.Host
+	}
+	if c.Port != 0 {
+		params["port"] = fmt.Sprint(c.Port)
+	}
+	if c.Database != "" {
+		params["dbname"] = c.Database
+	}
+	if c.User != "" {
+		params["user"] = c.User
+	}
+	if c.Password != "" {
+		params["password"] = c.Password
+	}
+	if c.SSLMode != "" {
+		params["sslmode"] = c.SSLMode
+	}
+	if c.ConnectTimeout > 0 {
+		// connect_timeout is in seconds, round up so that short timeouts are
+		// not turned into no timeout at all.
+		params["connect_timeout"] = fmt.Sprint(int64((c.ConnectTimeout + time.Second - 1) / time.Second))
+	}
+	if c.ApplicationName != "" {
+		params["application_name"] = c.ApplicationName
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+quoteDSNValue(params[k]))
+	}
+
+	return strings.Join(parts, " ")
+}
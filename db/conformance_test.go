@@ -0,0 +1,32 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/db/dbtest"
+)
+
+var conformanceConfig = db.Config{
+	Database: "boilingtest",
+	User:     "boilingtest",
+	Password: "boilingtest",
+}
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.BoilingDB {
+		inner, err := db.Open(conformanceConfig)
+		require.Nil(t, err)
+		defer inner.Close()
+
+		err = db.ResetSchema(inner)
+		require.Nil(t, err)
+
+		d, err := db.New(conformanceConfig)
+		require.Nil(t, err)
+
+		return d
+	})
+}
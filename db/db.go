@@ -1,13 +1,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
-	_ "github.com/lib/pq"
-	"github.com/microcosm-cc/bluemonday"
-	"github.com/russross/blackfriday"
+	"github.com/lib/pq"
 )
 
 type DB struct {
@@ -17,80 +16,166 @@ type DB struct {
 type BoilingDB interface {
 	Close() error
 
-	SignUpUser(username, password, email string) error
-	LoginAndGetUser(username, password string) (*User, error)
-	GetUser(id int) (*User, error)
-	UpdateUserDeltaUpDown(id, deltaUp, deltaDown int) error
-	UpdateUserSetLastAccess(id int, lastAccess time.Time) error
-	UpdateUserSetLastLogin(id int, lastLogin time.Time) error
-	UpdateUserAddPrivileges(id int, privileges []int) error
-	PopulateUserPrivileges(u *User) error
-
-	GetPasskeyForUser(id int) (*Passkey, error)
-	GetAllPasskeysForUser(id int) ([]Passkey, error)
-	GenerateNewPasskeyForUser(id int) (string, error)
-
-	InsertTokenForUser(u User) (*APIToken, error)
-	GetToken(token string) (*APIToken, error)
-
-	InsertBlogEntry(post *BlogEntry) error
-	GetBlogEntry(id int) (*BlogEntry, error)
-	UpdateBlogEntry(post BlogEntry) error
-	DeleteBlogEntry(id int) error
-	GetBlogEntries(limit, offset int) ([]BlogEntry, error)
-
-	AutocompleteArtists(s string) ([]Artist, error)
-	AutocompleteArtistTags(s string) ([]string, error)
-	GetArtist(id int) (*Artist, error)
-	PopulateReleaseGroups(artist *Artist) error
-	InsertArtist(artist *Artist) error
-
-	GetAllPrivileges() (map[int]string, error)
-
-	GetAllFormats() (map[int]Format, error)
-
-	GetAllMedia() (map[int]string, error)
-
-	GetAllReleaseGroupRoles() (map[int]string, error)
-
-	GetAllLeechTypes() (map[int]string, error)
-
-	GetAllReleaseProperties() (map[int]string, error)
-	AddReleaseProperty(key string) error
-
-	AutocompleteRecordLabels(s string) ([]RecordLabel, error)
-	GetRecordLabel(id int) (*RecordLabel, error)
-	InsertRecordLabel(label *RecordLabel) error
-
-	AutocompleteReleaseTags(s string) ([]string, error)
-	InsertRelease(release *Release) error
-	SetReleaseProperty(id int, k, v string) error
-	GetRelease(id int) (*Release, error)
-	DeleteRelease(id int) error
-	PopulateTorrents(release *Release) error
-
-	AutocompleteReleaseGroups(s string) ([]ReleaseGroup, error)
-	AutocompleteReleaseGroupTags(s string) ([]string, error)
-	GetAllReleaseGroupTypes() (map[int]string, error)
-	GetReleaseGroup(id int) (*ReleaseGroup, error)
-	InsertReleaseGroup(group *ReleaseGroup) error
-	PopulateReleases(group *ReleaseGroup) error
-	SearchReleaseGroups(q *Query, offset, limit int) ([]ReleaseGroup, error)
+	SignUpUser(ctx context.Context, username, password, email string) error
+	LoginAndGetUser(ctx context.Context, username, password string) (*User, error)
+	GetUser(ctx context.Context, id int) (*User, error)
+	UpdateUserDeltaUpDown(ctx context.Context, id, deltaUp, deltaDown int) error
+	UpdateUserSetRatioWatch(ctx context.Context, id int, until pq.NullTime) error
+	GetUserRatioStats(ctx context.Context) ([]UserRatioStats, error)
+	UpdateUserSetLastAccess(ctx context.Context, id int, lastAccess time.Time) error
+	UpdateUserSetLastLogin(ctx context.Context, id int, lastLogin time.Time) error
+	UpdateUserAddPrivileges(ctx context.Context, id int, privileges []int) error
+	UpdateUserDenyPrivileges(ctx context.Context, id int, privileges []int) error
+	UpdateUserRemovePrivileges(ctx context.Context, id int, privileges []int) error
+	GetUserPrivilegeGrants(ctx context.Context, id int) (map[int]bool, error)
+	PopulateUserPrivileges(ctx context.Context, u *User) error
+
+	UpdateUserSetInvites(ctx context.Context, id, invites int) error
+	InsertInvite(ctx context.Context, inv *Invite) error
+	GetInvitesByUser(ctx context.Context, uid int) ([]Invite, error)
+	SignUpUserWithInvite(ctx context.Context, username, password, email, code string) error
+	GetInviteTree(ctx context.Context, id int) ([]InviteTreeNode, error)
+	UpdateUsersDisable(ctx context.Context, ids []int) error
+
+	GetPasskeyForUser(ctx context.Context, id int) (*Passkey, error)
+	GetAllPasskeysForUser(ctx context.Context, id int) ([]Passkey, error)
+	GenerateNewPasskeyForUser(ctx context.Context, id int) (string, error)
+	GetUserByPasskey(ctx context.Context, passkey string) (*User, error)
+
+	InsertTokenForUser(ctx context.Context, u User) (*APIToken, error)
+	GetToken(ctx context.Context, token string) (*APIToken, error)
+
+	InsertBlogEntry(ctx context.Context, post *BlogEntry) error
+	GetBlogEntry(ctx context.Context, id int) (*BlogEntry, error)
+	UpdateBlogEntry(ctx context.Context, post BlogEntry) error
+	DeleteBlogEntry(ctx context.Context, id int) error
+	GetBlogEntries(ctx context.Context, limit, offset int) ([]BlogEntry, error)
+	GetBlogEntriesAfter(ctx context.Context, c BlogCursor, limit int) ([]BlogEntry, error)
+	GetBlogEntriesBefore(ctx context.Context, c BlogCursor, limit int) ([]BlogEntry, error)
+
+	AutocompleteArtists(ctx context.Context, s string) ([]Artist, error)
+	AutocompleteArtistTags(ctx context.Context, s string) ([]string, error)
+	GetArtist(ctx context.Context, id int) (*Artist, error)
+	PopulateReleaseGroups(ctx context.Context, artist *Artist) error
+	InsertArtist(ctx context.Context, artist *Artist) error
+	UpdateArtist(ctx context.Context, artist Artist, e Edit) error
+	AddArtistAlias(ctx context.Context, artistID int, alias ArtistAlias) error
+	RemoveArtistAlias(ctx context.Context, artistID int, alias string, e Edit) error
+	AddArtistTags(ctx context.Context, artistID int, tags []string, e Edit) error
+	RemoveArtistTag(ctx context.Context, artistID int, tag string, e Edit) error
+	MergeArtists(ctx context.Context, r ArtistRedirect) error
+	GetArtistRedirect(ctx context.Context, id int) (*ArtistRedirect, error)
+
+	GetAllPrivileges(ctx context.Context) (map[int]string, error)
+	InsertPrivilege(ctx context.Context, privilege string) (int, error)
+
+	GetAllUserClasses(ctx context.Context) (map[int]string, error)
+	GetUserClassPrivileges(ctx context.Context, class int) ([]int, error)
+	SetUserClass(ctx context.Context, c *UserClassChange) error
+	GetUserClassHistory(ctx context.Context, uid, offset, limit int) ([]UserClassChange, error)
+	GetUserClassHistoryAfter(ctx context.Context, uid int, c UserClassChangeCursor, limit int) ([]UserClassChange, error)
+	GetUserClassHistoryBefore(ctx context.Context, uid int, c UserClassChangeCursor, limit int) ([]UserClassChange, error)
+	CountUserClassHistory(ctx context.Context, uid int) (int, error)
+	GetUserPromotionStats(ctx context.Context) ([]UserPromotionStats, error)
+
+	GetAllFormats(ctx context.Context) (map[int]Format, error)
+
+	GetAllMedia(ctx context.Context) (map[int]string, error)
+
+	GetAllReleaseGroupRoles(ctx context.Context) (map[int]string, error)
+
+	GetAllLeechTypes(ctx context.Context) (map[int]string, error)
+
+	GetAllReleaseProperties(ctx context.Context) (map[int]string, error)
+	AddReleaseProperty(ctx context.Context, key string) error
+
+	AutocompleteRecordLabels(ctx context.Context, s string) ([]RecordLabel, error)
+	GetRecordLabel(ctx context.Context, id int) (*RecordLabel, error)
+	InsertRecordLabel(ctx context.Context, label *RecordLabel) error
+	UpdateRecordLabel(ctx context.Context, label RecordLabel, e Edit) error
+	GetSubLabels(ctx context.Context, id int) ([]RecordLabel, error)
+	GetRecordLabelReleases(ctx context.Context, id, offset, limit int) ([]Release, error)
+	GetRecordLabelReleasesAfter(ctx context.Context, id int, c RecordLabelReleaseCursor, limit int) ([]Release, error)
+	GetRecordLabelReleasesBefore(ctx context.Context, id int, c RecordLabelReleaseCursor, limit int) ([]Release, error)
+	CountRecordLabelReleases(ctx context.Context, id int) (int, error)
+
+	AutocompleteReleaseTags(ctx context.Context, s string) ([]string, error)
+	InsertRelease(ctx context.Context, release *Release) error
+	UpdateRelease(ctx context.Context, release Release, e Edit) error
+	SetReleaseProperty(ctx context.Context, id int, k, v string, e Edit) error
+	GetRelease(ctx context.Context, id int) (*Release, error)
+	DeleteRelease(ctx context.Context, id int) error
+	PopulateTorrents(ctx context.Context, release *Release) error
+
+	InsertTorrent(ctx context.Context, torrent *Torrent) error
+	GetTorrent(ctx context.Context, id int) (*Torrent, error)
+	GetTorrentByInfoHash(ctx context.Context, infoHash [20]byte) (*Torrent, error)
+	GetTorrentInfo(ctx context.Context, id int) ([]byte, error)
+	DeleteTorrent(ctx context.Context, id int) error
+
+	RecordAnnounces(ctx context.Context, announces []Announce) (int, error)
+	GetUserStatHistory(ctx context.Context, uid int, from, to time.Time, bucket StatBucket) ([]UserStatPoint, error)
+	GetUserTorrentStats(ctx context.Context, uid int, from, to time.Time) ([]UserTorrentStats, error)
+
+	AutocompleteReleaseGroups(ctx context.Context, s string) ([]ReleaseGroup, error)
+	AutocompleteReleaseGroupTags(ctx context.Context, s string) ([]string, error)
+	GetAllReleaseGroupTypes(ctx context.Context) (map[int]string, error)
+	GetReleaseGroup(ctx context.Context, id int) (*ReleaseGroup, error)
+	InsertReleaseGroup(ctx context.Context, group *ReleaseGroup) error
+	UpdateReleaseGroup(ctx context.Context, group ReleaseGroup, e Edit) error
+	DeleteReleaseGroup(ctx context.Context, id int) error
+	PopulateReleases(ctx context.Context, group *ReleaseGroup) error
+	SearchReleaseGroups(ctx context.Context, q *Query, offset, limit int) ([]ReleaseGroup, error)
+	CountReleaseGroups(ctx context.Context, q *Query) (int, error)
+	GetReleaseGroupFacets(ctx context.Context, q *Query) (*ReleaseGroupFacets, error)
+
+	Search(ctx context.Context, q string, types []string, limit int) ([]SearchResult, error)
+
+	GetRevision(ctx context.Context, entityType string, id, revision int) (*Revision, error)
+	GetRevisions(ctx context.Context, entityType string, id, offset, limit int) ([]Revision, error)
+	GetRevisionsAfter(ctx context.Context, entityType string, id, revision, limit int) ([]Revision, error)
+	GetRevisionsBefore(ctx context.Context, entityType string, id, revision, limit int) ([]Revision, error)
+	CountRevisions(ctx context.Context, entityType string, id int) (int, error)
+	RevertRevision(ctx context.Context, entityType string, id, revision int, e Edit) error
 }
 
-func New(database, user, pass string) (BoilingDB, error) {
-	db, err := sql.Open("postgres", fmt.Sprintf("user=%s password=%s dbname=%s", user, pass, database))
+// Open opens a connection pool to the configured postgres database.
+// The connection is verified, so that misconfiguration is reported right away.
+func Open(cfg Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.dsn())
 	if err != nil {
 		return nil, err
 	}
 
-	return &DB{db: db}, nil
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		// zero would disable idle connections instead of keeping the default
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to connect to database: %s", err)
+	}
+
+	return db, nil
+}
+
+func New(cfg Config) (BoilingDB, error) {
+	db, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFromConn(db), nil
 }
 
-func compileMarkdown(input []byte) []byte {
-	unsafe := blackfriday.MarkdownCommon(input)
-	html := bluemonday.UGCPolicy().SanitizeBytes(unsafe)
-	return html
+// NewFromConn returns a BoilingDB using a connection pool returned by Open.
+// Closing the BoilingDB closes the pool.
+func NewFromConn(db *sql.DB) BoilingDB {
+	return &DB{db: db}
 }
 
 func (db *DB) Close() error {
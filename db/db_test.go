@@ -1,33 +1,41 @@
 package db
 
 import (
+	"context"
 	"io/ioutil"
-	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
+var testConfig = Config{
+	Database: "boilingtest",
+	User:     "boilingtest",
+	Password: "boilingtest",
+}
+
 func cleanDB() (BoilingDB, error) {
-	bdb, err := New("boilingtest", "boilingtest", "boilingtest")
+	bdb, err := New(testConfig)
 	if err != nil {
 		return nil, err
 	}
 
 	db := bdb.(*DB)
 
-	file, err := ioutil.ReadFile("create.sql")
+	err = ResetSchema(db.db)
 	if err != nil {
 		return nil, err
 	}
 
-	requests := strings.Split(string(file), ";")
+	file, err := ioutil.ReadFile("testdata.sql")
+	if err != nil {
+		return nil, err
+	}
 
-	for _, request := range requests {
-		_, err := db.db.Exec(request)
-		if err != nil {
-			return nil, err
-		}
+	_, err = db.db.Exec(string(file))
+	if err != nil {
+		return nil, err
 	}
 
 	return db, nil
@@ -38,3 +46,38 @@ func TestCleanDB(t *testing.T) {
 	require.Nil(t, err)
 	require.NotNil(t, db)
 }
+
+func TestCancelledContext(t *testing.T) {
+	db, err := cleanDB()
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = db.GetUser(ctx, 1)
+	require.Equal(t, context.Canceled, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	err = db.SignUpUser(ctx, "sometestuser", "sometestpw12345", "some@ex.am.ple.com")
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestConfigDSN(t *testing.T) {
+	c := Config{
+		Host:            "localhost",
+		Port:            5432,
+		Database:        "boiling",
+		User:            "boiling",
+		Password:        `it's a \ secret`,
+		SSLMode:         "verify-full",
+		ConnectTimeout:  1500 * time.Millisecond,
+		ApplicationName: "boiling api",
+	}
+	require.Equal(t, `application_name='boiling api' connect_timeout='2' dbname='boiling' host='localhost' password='it\'s a \\ secret' port='5432' sslmode='verify-full' user='boiling'`, c.dsn())
+
+	c.DSN = "postgres://boiling@localhost/boiling"
+	require.Equal(t, "postgres://boiling@localhost/boiling", c.dsn())
+}
@@ -0,0 +1,3024 @@
+// Package dbtest provides a conformance test suite for implementations of
+// db.BoilingDB.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+// Factory returns a freshly migrated database without any test data.
+type Factory func(t *testing.T) db.BoilingDB
+
+// Run runs the conformance suite.
+// Every test gets its own database from newDB.
+func Run(t *testing.T, newDB Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, d db.BoilingDB)
+	}{
+		{"LookupTables", testLookupTables},
+		{"Users", testUsers},
+		{"RatioWatch", testRatioWatch},
+		{"Privileges", testPrivileges},
+		{"InsertPrivilege", testInsertPrivilege},
+		{"UserClasses", testUserClasses},
+		{"UserPromotionStats", testUserPromotionStats},
+		{"Invites", testInvites},
+		{"Passkeys", testPasskeys},
+		{"Tokens", testTokens},
+		{"Blogs", testBlogs},
+		{"BlogCursors", testBlogCursors},
+		{"Artists", testArtists},
+		{"UpdateArtists", testUpdateArtists},
+		{"MergeArtists", testMergeArtists},
+		{"RecordLabels", testRecordLabels},
+		{"SubLabels", testSubLabels},
+		{"ReleaseGroups", testReleaseGroups},
+		{"UpdateReleaseGroups", testUpdateReleaseGroups},
+		{"SearchReleaseGroups", testSearchReleaseGroups},
+		{"SearchReleaseGroupsRelated", testSearchReleaseGroupsRelated},
+		{"Search", testSearch},
+		{"Releases", testReleases},
+		{"UpdateReleases", testUpdateReleases},
+		{"RecordLabelReleases", testRecordLabelReleases},
+		{"Torrents", testTorrents},
+		{"Announces", testAnnounces},
+		{"Revisions", testRevisions},
+		{"RevertRevisions", testRevertRevisions},
+		{"CancelledContext", testCancelledContext},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDB(t)
+			defer d.Close()
+
+			tt.test(t, d)
+		})
+	}
+}
+
+const testPassword = "somepassword123"
+
+// signUp signs up a user and returns it, as returned by LoginAndGetUser.
+func signUp(t *testing.T, d db.BoilingDB, username string) *db.User {
+	ctx := context.Background()
+
+	err := d.SignUpUser(ctx, username, testPassword, username+"@boiling.rip")
+	require.Nil(t, err)
+
+	u, err := d.LoginAndGetUser(ctx, username, testPassword)
+	require.Nil(t, err)
+	require.NotNil(t, u)
+
+	return u
+}
+
+func sorted(s []string) []string {
+	res := append([]string(nil), s...)
+	sort.Strings(res)
+	return res
+}
+
+func testLookupTables(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	privileges, err := d.GetAllPrivileges(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 41, len(privileges))
+	require.Equal(t, "get_blogs", privileges[0])
+	require.Equal(t, "get_user_stats_not_self", privileges[14])
+	require.Equal(t, "remove_artist_tag", privileges[20])
+	require.Equal(t, "merge_artists", privileges[21])
+	require.Equal(t, "set_release_property", privileges[28])
+	require.Equal(t, "update_record_label", privileges[31])
+	require.Equal(t, "revert_revision", privileges[32])
+	require.Equal(t, "set_user_class", privileges[33])
+	require.Equal(t, "create_privilege", privileges[36])
+	require.Equal(t, "invite", privileges[37])
+	require.Equal(t, "disable_invite_tree", privileges[40])
+
+	formats, err := d.GetAllFormats(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 5, len(formats))
+	require.Equal(t, db.Format{Format: "FLAC", Encoding: "Lossless"}, formats[0])
+	require.Equal(t, db.Format{Format: "MP3/V2", Encoding: "Lossy"}, formats[4])
+
+	media, err := d.GetAllMedia(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 8, len(media))
+	require.Equal(t, "WEB", media[3])
+
+	roles, err := d.GetAllReleaseGroupRoles(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 6, len(roles))
+	require.Equal(t, "Main", roles[0])
+
+	types, err := d.GetAllReleaseGroupTypes(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 9, len(types))
+	require.Equal(t, "Unknown", types[8])
+
+	leechTypes, err := d.GetAllLeechTypes(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 5, len(leechTypes))
+	require.Equal(t, "Freeleech", leechTypes[1])
+
+	properties, err := d.GetAllReleaseProperties(ctx)
+	require.Nil(t, err)
+	require.Equal(t, map[int]string{
+		0: "LossyMasterApproved",
+		1: "LossyWebApproved",
+		2: "CassetteApproved",
+	}, properties)
+
+	err = d.AddReleaseProperty(ctx, "SomeProperty")
+	require.Nil(t, err)
+
+	err = d.AddReleaseProperty(ctx, "SomeProperty")
+	require.NotNil(t, err)
+
+	err = d.AddReleaseProperty(ctx, "")
+	require.NotNil(t, err)
+
+	properties, err = d.GetAllReleaseProperties(ctx)
+	require.Nil(t, err)
+	require.Equal(t, "SomeProperty", properties[3])
+}
+
+func testUsers(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	err := d.SignUpUser(ctx, "someuser", "short", "someuser@boiling.rip")
+	require.NotNil(t, err)
+
+	err = d.SignUpUser(ctx, "", testPassword, "someuser@boiling.rip")
+	require.NotNil(t, err)
+
+	u := signUp(t, d, "someuser")
+	require.True(t, u.ID > 0)
+	require.Equal(t, "someuser", u.Username)
+	require.Equal(t, "someuser@boiling.rip", u.Email)
+	require.True(t, u.Enabled)
+	require.True(t, u.CanLogin)
+	require.False(t, u.LastLogin.Valid)
+	require.False(t, u.LastAccess.Valid)
+	require.False(t, u.Bio.Valid)
+	require.Equal(t, int64(0), u.Uploaded)
+	require.Equal(t, int64(0), u.Downloaded)
+
+	err = d.SignUpUser(ctx, "someuser", testPassword, "other@boiling.rip")
+	require.NotNil(t, err)
+
+	err = d.SignUpUser(ctx, "otheruser", testPassword, "someuser@boiling.rip")
+	require.NotNil(t, err)
+
+	_, err = d.LoginAndGetUser(ctx, "someuser", "wrongpassword123")
+	require.EqualError(t, err, "invalid password")
+
+	_, err = d.LoginAndGetUser(ctx, "nobody", testPassword)
+	require.EqualError(t, err, "user not found")
+
+	_, err = d.LoginAndGetUser(ctx, "boiling", testPassword)
+	require.EqualError(t, err, "login disabled")
+
+	got, err := d.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, u.Username, got.Username)
+	require.Equal(t, u.Email, got.Email)
+	require.Equal(t, u.PasswordHash, got.PasswordHash)
+	require.True(t, u.JoinedAt.Equal(got.JoinedAt))
+
+	boiling, err := d.GetUser(ctx, 0)
+	require.Nil(t, err)
+	require.Equal(t, "boiling", boiling.Username)
+	require.False(t, boiling.CanLogin)
+
+	_, err = d.GetUser(ctx, u.ID+100)
+	require.EqualError(t, err, "user not found")
+
+	_, err = d.GetUser(ctx, -1)
+	require.NotNil(t, err)
+
+	err = d.UpdateUserDeltaUpDown(ctx, u.ID, 10, 20)
+	require.Nil(t, err)
+	err = d.UpdateUserDeltaUpDown(ctx, u.ID, 5, 0)
+	require.Nil(t, err)
+
+	err = d.UpdateUserDeltaUpDown(ctx, u.ID+100, 5, 0)
+	require.NotNil(t, err)
+
+	got, err = d.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, int64(15), got.Uploaded)
+	require.Equal(t, int64(20), got.Downloaded)
+
+	lastLogin := time.Date(2017, 10, 2, 12, 30, 0, 0, time.UTC)
+	err = d.UpdateUserSetLastLogin(ctx, u.ID, lastLogin)
+	require.Nil(t, err)
+
+	got, err = d.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.True(t, got.LastLogin.Valid)
+	require.True(t, lastLogin.Equal(got.LastLogin.Time))
+	require.True(t, got.LastAccess.Valid)
+	require.True(t, lastLogin.Equal(got.LastAccess.Time))
+
+	lastAccess := lastLogin.Add(time.Hour)
+	err = d.UpdateUserSetLastAccess(ctx, u.ID, lastAccess)
+	require.Nil(t, err)
+
+	got, err = d.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.True(t, lastLogin.Equal(got.LastLogin.Time))
+	require.True(t, lastAccess.Equal(got.LastAccess.Time))
+
+	err = d.UpdateUserSetLastAccess(ctx, u.ID+100, lastAccess)
+	require.EqualError(t, err, "user not found")
+
+	err = d.UpdateUserSetLastLogin(ctx, u.ID+100, lastLogin)
+	require.EqualError(t, err, "user not found")
+}
+
+func testRatioWatch(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	require.False(t, u.RatioWatchUntil.Valid)
+
+	err := d.UpdateUserDeltaUpDown(ctx, u.ID, 10, 20)
+	require.Nil(t, err)
+
+	until := time.Date(2017, 10, 16, 12, 0, 0, 0, time.UTC)
+	err = d.UpdateUserSetRatioWatch(ctx, u.ID, pq.NullTime{Time: until, Valid: true})
+	require.Nil(t, err)
+
+	got, err := d.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.True(t, got.RatioWatchUntil.Valid)
+	require.True(t, until.Equal(got.RatioWatchUntil.Time))
+
+	tok, err := d.InsertTokenForUser(ctx, *got)
+	require.Nil(t, err)
+	gotTok, err := d.GetToken(ctx, tok.Token)
+	require.Nil(t, err)
+	require.True(t, until.Equal(gotTok.User.RatioWatchUntil.Time))
+
+	passkey, err := d.GenerateNewPasskeyForUser(ctx, u.ID)
+	require.Nil(t, err)
+	byPasskey, err := d.GetUserByPasskey(ctx, passkey)
+	require.Nil(t, err)
+	require.True(t, until.Equal(byPasskey.RatioWatchUntil.Time))
+
+	all, err := d.GetUserRatioStats(ctx)
+	require.Nil(t, err)
+	var stats *db.UserRatioStats
+	for i := range all {
+		if i > 0 {
+			require.True(t, all[i-1].User < all[i].User)
+		}
+		if all[i].User == u.ID {
+			stats = &all[i]
+		}
+	}
+	require.NotNil(t, stats)
+	require.Equal(t, int64(10), stats.Uploaded)
+	require.Equal(t, int64(20), stats.Downloaded)
+	require.True(t, until.Equal(stats.RatioWatchUntil.Time))
+
+	err = d.UpdateUserSetRatioWatch(ctx, u.ID, pq.NullTime{})
+	require.Nil(t, err)
+
+	got, err = d.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.False(t, got.RatioWatchUntil.Valid)
+
+	err = d.UpdateUserSetRatioWatch(ctx, u.ID+100, pq.NullTime{})
+	require.EqualError(t, err, "user not found")
+}
+
+func testPrivileges(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	require.Equal(t, 0, u.Class)
+
+	// new users have the privileges of the lowest class
+	err := d.PopulateUserPrivileges(ctx, u)
+	require.Nil(t, err)
+	require.Equal(t, []int{0, 10, 11, 12, 13, 29}, u.Privileges)
+
+	err = d.UpdateUserAddPrivileges(ctx, u.ID, []int{3, 1, 2})
+	require.Nil(t, err)
+
+	// adding a privilege twice is fine, so is adding one the class has
+	err = d.UpdateUserAddPrivileges(ctx, u.ID, []int{2, 0})
+	require.Nil(t, err)
+
+	u.Privileges = nil
+	err = d.PopulateUserPrivileges(ctx, u)
+	require.Nil(t, err)
+	require.Equal(t, []int{0, 1, 2, 3, 10, 11, 12, 13, 29}, u.Privileges)
+
+	// denials win over the class and over grants
+	err = d.UpdateUserDenyPrivileges(ctx, u.ID, []int{10, 2})
+	require.Nil(t, err)
+
+	u.Privileges = nil
+	err = d.PopulateUserPrivileges(ctx, u)
+	require.Nil(t, err)
+	require.Equal(t, []int{0, 1, 3, 11, 12, 13, 29}, u.Privileges)
+
+	// granting a denied privilege lifts the denial
+	err = d.UpdateUserAddPrivileges(ctx, u.ID, []int{10})
+	require.Nil(t, err)
+
+	u.Privileges = nil
+	err = d.PopulateUserPrivileges(ctx, u)
+	require.Nil(t, err)
+	require.Equal(t, []int{0, 1, 3, 10, 11, 12, 13, 29}, u.Privileges)
+
+	err = d.UpdateUserDenyPrivileges(ctx, u.ID, []int{11})
+	require.Nil(t, err)
+
+	grants, err := d.GetUserPrivilegeGrants(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, map[int]bool{0: true, 1: true, 2: false, 3: true, 10: true, 11: false}, grants)
+
+	// removing grants and denials falls back to the class
+	err = d.UpdateUserRemovePrivileges(ctx, u.ID, []int{0, 1, 2, 11, 14})
+	require.Nil(t, err)
+
+	u.Privileges = nil
+	err = d.PopulateUserPrivileges(ctx, u)
+	require.Nil(t, err)
+	require.Equal(t, []int{0, 3, 10, 11, 12, 13, 29}, u.Privileges)
+
+	grants, err = d.GetUserPrivilegeGrants(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, map[int]bool{3: true, 10: true}, grants)
+
+	err = d.UpdateUserAddPrivileges(ctx, -1, []int{0})
+	require.NotNil(t, err)
+
+	err = d.UpdateUserDenyPrivileges(ctx, -1, []int{0})
+	require.NotNil(t, err)
+
+	err = d.UpdateUserRemovePrivileges(ctx, -1, []int{0})
+	require.NotNil(t, err)
+
+	_, err = d.GetUserPrivilegeGrants(ctx, -1)
+	require.NotNil(t, err)
+}
+
+func testInsertPrivilege(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	id, err := d.InsertPrivilege(ctx, "some_privilege")
+	require.Nil(t, err)
+
+	privileges, err := d.GetAllPrivileges(ctx)
+	require.Nil(t, err)
+	require.Equal(t, "some_privilege", privileges[id])
+
+	// the highest class has all privileges
+	classes, err := d.GetAllUserClasses(ctx)
+	require.Nil(t, err)
+	sysOp, err := d.GetUserClassPrivileges(ctx, len(classes)-1)
+	require.Nil(t, err)
+	require.Contains(t, sysOp, id)
+
+	user, err := d.GetUserClassPrivileges(ctx, 0)
+	require.Nil(t, err)
+	require.NotContains(t, user, id)
+
+	_, err = d.InsertPrivilege(ctx, "some_privilege")
+	require.NotNil(t, err)
+
+	_, err = d.InsertPrivilege(ctx, "")
+	require.NotNil(t, err)
+}
+
+func testUserClasses(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	classes, err := d.GetAllUserClasses(ctx)
+	require.Nil(t, err)
+	require.Equal(t, map[int]string{0: "User", 1: "Power User", 2: "Elite", 3: "Moderator", 4: "SysOp"}, classes)
+
+	// every class has the privileges of the classes below it, and more
+	var previous []int
+	for class := 0; class < len(classes); class++ {
+		privileges, err := d.GetUserClassPrivileges(ctx, class)
+		require.Nil(t, err)
+		require.True(t, len(privileges) > len(previous))
+
+		has := make(map[int]bool)
+		for _, p := range privileges {
+			has[p] = true
+		}
+		for _, p := range previous {
+			require.True(t, has[p], "class %d is missing privilege %d", class, p)
+		}
+
+		previous = privileges
+	}
+
+	// SysOps have all privileges
+	all, err := d.GetAllPrivileges(ctx)
+	require.Nil(t, err)
+	require.Equal(t, len(all), len(previous))
+
+	privileges, err := d.GetUserClassPrivileges(ctx, 5)
+	require.Nil(t, err)
+	require.Empty(t, privileges)
+
+	u := signUp(t, d, "someuser")
+	staff := signUp(t, d, "somestaff")
+
+	change := db.UserClassChange{
+		User:      u.ID,
+		To:        3,
+		Changed:   time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC),
+		Reason:    "trusted",
+		ChangedBy: staff,
+	}
+	err = d.SetUserClass(ctx, &change)
+	require.Nil(t, err)
+	require.NotEqual(t, 0, change.ID)
+	require.Equal(t, 0, change.From)
+
+	got, err := d.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, got.Class)
+
+	tok, err := d.InsertTokenForUser(ctx, *got)
+	require.Nil(t, err)
+	gotTok, err := d.GetToken(ctx, tok.Token)
+	require.Nil(t, err)
+	require.Equal(t, 3, gotTok.User.Class)
+
+	// individual denials are kept when the class changes
+	err = d.UpdateUserDenyPrivileges(ctx, u.ID, []int{21})
+	require.Nil(t, err)
+
+	err = d.SetUserClass(ctx, &db.UserClassChange{User: u.ID, To: 2, Changed: time.Date(2017, 10, 3, 12, 0, 0, 0, time.UTC), Reason: "demoted"})
+	require.Nil(t, err)
+	err = d.SetUserClass(ctx, &db.UserClassChange{User: u.ID, To: 3, Changed: time.Date(2017, 10, 4, 12, 0, 0, 0, time.UTC), Reason: "promoted"})
+	require.Nil(t, err)
+
+	moderator, err := d.GetUserClassPrivileges(ctx, 3)
+	require.Nil(t, err)
+	var expected []int
+	for _, p := range moderator {
+		if p != 21 {
+			expected = append(expected, p)
+		}
+	}
+
+	err = d.PopulateUserPrivileges(ctx, got)
+	require.Nil(t, err)
+	require.Equal(t, expected, got.Privileges)
+
+	// nothing is recorded if the class does not change
+	unchanged := db.UserClassChange{User: u.ID, To: 3, Changed: time.Date(2017, 10, 5, 12, 0, 0, 0, time.UTC)}
+	err = d.SetUserClass(ctx, &unchanged)
+	require.Nil(t, err)
+	require.Equal(t, 0, unchanged.ID)
+	require.Equal(t, 3, unchanged.From)
+
+	// conditional changes are skipped if the user is in another class
+	conditional := db.UserClassChange{User: u.ID, From: 2, To: 1, Changed: time.Date(2017, 10, 5, 12, 0, 0, 0, time.UTC), Conditional: true}
+	err = d.SetUserClass(ctx, &conditional)
+	require.Nil(t, err)
+	require.Equal(t, 0, conditional.ID)
+	got, err = d.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, got.Class)
+
+	count, err := d.CountUserClassHistory(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, count)
+
+	history, err := d.GetUserClassHistory(ctx, u.ID, 0, 2)
+	require.Nil(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, "promoted", history[0].Reason)
+	require.Equal(t, 2, history[0].From)
+	require.Equal(t, 3, history[0].To)
+	require.Nil(t, history[0].ChangedBy)
+	require.True(t, history[0].Changed.Equal(time.Date(2017, 10, 4, 12, 0, 0, 0, time.UTC)))
+
+	history, err = d.GetUserClassHistory(ctx, u.ID, 2, 2)
+	require.Nil(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, change.ID, history[0].ID)
+	require.Equal(t, "trusted", history[0].Reason)
+	require.NotNil(t, history[0].ChangedBy)
+	require.Equal(t, staff.ID, history[0].ChangedBy.ID)
+	require.Equal(t, "somestaff", history[0].ChangedBy.Username)
+
+	cursor := func(c db.UserClassChange) db.UserClassChangeCursor {
+		return db.UserClassChangeCursor{Changed: c.Changed, ID: c.ID}
+	}
+	changes, err := d.GetUserClassHistory(ctx, u.ID, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, changes, 3)
+
+	history, err = d.GetUserClassHistoryAfter(ctx, u.ID, cursor(changes[0]), 2)
+	require.Nil(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, "demoted", history[0].Reason)
+	require.Equal(t, change.ID, history[1].ID)
+	require.Equal(t, staff.ID, history[1].ChangedBy.ID)
+
+	history, err = d.GetUserClassHistoryAfter(ctx, u.ID, cursor(changes[2]), 2)
+	require.Nil(t, err)
+	require.Empty(t, history)
+
+	history, err = d.GetUserClassHistoryBefore(ctx, u.ID, cursor(changes[2]), 1)
+	require.Nil(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, "demoted", history[0].Reason)
+
+	history, err = d.GetUserClassHistoryBefore(ctx, u.ID, cursor(changes[0]), 2)
+	require.Nil(t, err)
+	require.Empty(t, history)
+
+	_, err = d.GetUserClassHistoryAfter(ctx, u.ID, cursor(changes[0]), 0)
+	require.NotNil(t, err)
+
+	count, err = d.CountUserClassHistory(ctx, staff.ID)
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+
+	err = d.SetUserClass(ctx, &db.UserClassChange{User: u.ID, To: 5, Changed: time.Now()})
+	require.NotNil(t, err)
+
+	err = d.SetUserClass(ctx, &db.UserClassChange{User: u.ID + 100, To: 0, Changed: time.Now()})
+	require.EqualError(t, err, "user not found")
+
+	_, err = d.GetUserClassHistory(ctx, u.ID, 0, 0)
+	require.NotNil(t, err)
+}
+
+func testUserPromotionStats(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	other := signUp(t, d, "someotheruser")
+
+	release := insertRelease(t, d, u)
+	insertTorrent(t, d, u, release, 1, 0)
+	insertTorrent(t, d, u, release, 2, 0)
+
+	err := d.UpdateUserDeltaUpDown(ctx, u.ID, 2000, 1000)
+	require.Nil(t, err)
+
+	all, err := d.GetUserPromotionStats(ctx)
+	require.Nil(t, err)
+
+	// there may be other users, e.g. seeded ones
+	stats := make(map[int]db.UserPromotionStats)
+	for i, s := range all {
+		if i > 0 {
+			require.True(t, all[i-1].User < s.User)
+		}
+		stats[s.User] = s
+	}
+
+	s, ok := stats[u.ID]
+	require.True(t, ok)
+	require.Equal(t, 0, s.Class)
+	require.Equal(t, int64(2000), s.Uploaded)
+	require.Equal(t, int64(1000), s.Downloaded)
+	require.True(t, s.JoinedAt.Equal(u.JoinedAt))
+	require.Equal(t, 2, s.Uploads)
+
+	s, ok = stats[other.ID]
+	require.True(t, ok)
+	require.Equal(t, 0, s.Uploads)
+}
+
+func testInvites(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	inviter := signUp(t, d, "inviter")
+	require.Equal(t, 0, inviter.Invites)
+
+	err := d.InsertInvite(ctx, &db.Invite{CreatedBy: *inviter, CreatedAt: time.Now()})
+	require.EqualError(t, err, "no invites left")
+
+	err = d.UpdateUserSetInvites(ctx, inviter.ID, 2)
+	require.Nil(t, err)
+	err = d.UpdateUserSetInvites(ctx, inviter.ID+100, 2)
+	require.EqualError(t, err, "user not found")
+
+	first := db.Invite{CreatedBy: *inviter, CreatedAt: time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)}
+	err = d.InsertInvite(ctx, &first)
+	require.Nil(t, err)
+	require.NotEqual(t, 0, first.ID)
+	require.Len(t, first.Code, 32)
+
+	second := db.Invite{CreatedBy: *inviter, CreatedAt: time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)}
+	err = d.InsertInvite(ctx, &second)
+	require.Nil(t, err)
+	require.NotEqual(t, first.Code, second.Code)
+
+	err = d.InsertInvite(ctx, &db.Invite{CreatedBy: *inviter, CreatedAt: time.Now()})
+	require.EqualError(t, err, "no invites left")
+
+	got, err := d.GetUser(ctx, inviter.ID)
+	require.Nil(t, err)
+	require.Equal(t, 0, got.Invites)
+
+	// a failed signup does not use up the invite
+	err = d.SignUpUserWithInvite(ctx, "inviter", testPassword, "other@boiling.rip", first.Code)
+	require.NotNil(t, err)
+	err = d.SignUpUserWithInvite(ctx, "invitee", testPassword, "invitee@boiling.rip", "nosuchcode")
+	require.EqualError(t, err, "invalid invite")
+
+	err = d.SignUpUserWithInvite(ctx, "invitee", testPassword, "invitee@boiling.rip", first.Code)
+	require.Nil(t, err)
+	invitee, err := d.LoginAndGetUser(ctx, "invitee", testPassword)
+	require.Nil(t, err)
+
+	err = d.SignUpUserWithInvite(ctx, "another", testPassword, "another@boiling.rip", first.Code)
+	require.EqualError(t, err, "invalid invite")
+
+	invites, err := d.GetInvitesByUser(ctx, inviter.ID)
+	require.Nil(t, err)
+	require.Len(t, invites, 2)
+	require.Equal(t, second.ID, invites[0].ID)
+	require.Nil(t, invites[0].UsedBy)
+	require.False(t, invites[0].UsedAt.Valid)
+	require.Equal(t, first.ID, invites[1].ID)
+	require.Equal(t, first.Code, invites[1].Code)
+	require.NotNil(t, invites[1].UsedBy)
+	require.Equal(t, invitee.ID, invites[1].UsedBy.ID)
+	require.Equal(t, "invitee", invites[1].UsedBy.Username)
+	require.True(t, invites[1].UsedAt.Valid)
+
+	// the invitee invites someone as well
+	err = d.UpdateUserSetInvites(ctx, invitee.ID, 1)
+	require.Nil(t, err)
+	third := db.Invite{CreatedBy: *invitee, CreatedAt: time.Now()}
+	err = d.InsertInvite(ctx, &third)
+	require.Nil(t, err)
+	err = d.SignUpUserWithInvite(ctx, "invitee2", testPassword, "invitee2@boiling.rip", third.Code)
+	require.Nil(t, err)
+
+	tree, err := d.GetInviteTree(ctx, inviter.ID)
+	require.Nil(t, err)
+	require.Len(t, tree, 2)
+	require.Equal(t, invitee.ID, tree[0].User.ID)
+	require.Equal(t, "invitee", tree[0].User.Username)
+	require.True(t, tree[0].User.Enabled)
+	require.Equal(t, inviter.ID, tree[0].InvitedBy)
+	require.Equal(t, 1, tree[0].Depth)
+	require.Equal(t, "invitee2", tree[1].User.Username)
+	require.Equal(t, invitee.ID, tree[1].InvitedBy)
+	require.Equal(t, 2, tree[1].Depth)
+
+	tree, err = d.GetInviteTree(ctx, tree[1].User.ID)
+	require.Nil(t, err)
+	require.Empty(t, tree)
+
+	token, err := d.InsertTokenForUser(ctx, *invitee)
+	require.Nil(t, err)
+	inviterToken, err := d.InsertTokenForUser(ctx, *inviter)
+	require.Nil(t, err)
+
+	err = d.UpdateUsersDisable(ctx, []int{invitee.ID})
+	require.Nil(t, err)
+	got, err = d.GetUser(ctx, invitee.ID)
+	require.Nil(t, err)
+	require.False(t, got.Enabled)
+
+	// disabled users lose their tokens
+	_, err = d.GetToken(ctx, token.Token)
+	require.Equal(t, sql.ErrNoRows, err)
+	_, err = d.GetToken(ctx, inviterToken.Token)
+	require.Nil(t, err)
+	got, err = d.GetUser(ctx, inviter.ID)
+	require.Nil(t, err)
+	require.True(t, got.Enabled)
+}
+
+func testPasskeys(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+
+	_, err := d.GetPasskeyForUser(ctx, u.ID)
+	require.NotNil(t, err)
+
+	first, err := d.GenerateNewPasskeyForUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, 64, len(first))
+
+	second, err := d.GenerateNewPasskeyForUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.NotEqual(t, first, second)
+
+	p, err := d.GetPasskeyForUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, second, p.Passkey)
+	require.Equal(t, u.ID, p.Uid)
+	require.True(t, p.Valid)
+
+	all, err := d.GetAllPasskeysForUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(all))
+	valid := 0
+	for _, p := range all {
+		if p.Valid {
+			valid++
+			require.Equal(t, second, p.Passkey)
+		}
+	}
+	require.Equal(t, 1, valid)
+
+	got, err := d.GetUserByPasskey(ctx, second)
+	require.Nil(t, err)
+	require.Equal(t, u.ID, got.ID)
+	require.Equal(t, u.Username, got.Username)
+	require.True(t, got.Enabled)
+
+	_, err = d.GetUserByPasskey(ctx, first)
+	require.NotNil(t, err)
+
+	_, err = d.GetUserByPasskey(ctx, "")
+	require.NotNil(t, err)
+
+	_, err = d.GenerateNewPasskeyForUser(ctx, u.ID+100)
+	require.NotNil(t, err)
+}
+
+func testTokens(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+
+	token, err := d.InsertTokenForUser(ctx, *u)
+	require.Nil(t, err)
+	require.Equal(t, 128, len(token.Token))
+	require.Equal(t, u.ID, token.User.ID)
+
+	got, err := d.GetToken(ctx, token.Token)
+	require.Nil(t, err)
+	require.Equal(t, token.Token, got.Token)
+	require.True(t, token.CreatedAt.Equal(got.CreatedAt))
+	require.Equal(t, u.ID, got.User.ID)
+	require.Equal(t, u.Username, got.User.Username)
+	require.Equal(t, u.Email, got.User.Email)
+	require.True(t, got.User.Enabled)
+
+	_, err = d.GetToken(ctx, "sometoken")
+	require.NotNil(t, err)
+
+	_, err = d.GetToken(ctx, "")
+	require.NotNil(t, err)
+
+	_, err = d.InsertTokenForUser(ctx, db.User{ID: u.ID + 100})
+	require.NotNil(t, err)
+}
+
+func testBlogs(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	postedAt := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	var entries []db.BlogEntry
+	for i, tags := range [][]string{{"a", "b"}, {"b", "c"}, nil} {
+		e := db.BlogEntry{
+			Author:   db.User{ID: u.ID},
+			Title:    "Some title",
+			Content:  "Some content",
+			PostedAt: postedAt.Add(time.Duration(i) * time.Hour),
+			Tags:     tags,
+		}
+		err := d.InsertBlogEntry(ctx, &e)
+		require.Nil(t, err)
+		entries = append(entries, e)
+	}
+
+	err := d.InsertBlogEntry(ctx, &db.BlogEntry{
+		Author:   db.User{ID: u.ID},
+		PostedAt: postedAt,
+		Tags:     []string{"a", "a"},
+	})
+	require.NotNil(t, err)
+
+	err = d.InsertBlogEntry(ctx, &db.BlogEntry{
+		Author:   db.User{ID: u.ID + 100},
+		PostedAt: postedAt,
+	})
+	require.NotNil(t, err)
+
+	err = d.InsertBlogEntry(ctx, nil)
+	require.NotNil(t, err)
+
+	got, err := d.GetBlogEntry(ctx, entries[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, entries[0].ID, got.ID)
+	require.Equal(t, u.ID, got.Author.ID)
+	require.Equal(t, u.Username, got.Author.Username)
+	require.Equal(t, "Some title", got.Title)
+	require.Equal(t, "Some content", got.Content)
+	require.True(t, postedAt.Equal(got.PostedAt))
+	require.Equal(t, []string{"a", "b"}, sorted(got.Tags))
+
+	got, err = d.GetBlogEntry(ctx, entries[2].ID)
+	require.Nil(t, err)
+	require.Empty(t, got.Tags)
+
+	page, err := d.GetBlogEntries(ctx, 2, 0)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(page))
+	require.Equal(t, entries[2].ID, page[0].ID)
+	require.Equal(t, entries[1].ID, page[1].ID)
+	require.Equal(t, []string{"b", "c"}, sorted(page[1].Tags))
+
+	page, err = d.GetBlogEntries(ctx, 2, 2)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(page))
+	require.Equal(t, entries[0].ID, page[0].ID)
+
+	page, err = d.GetBlogEntries(ctx, 2, 10)
+	require.Nil(t, err)
+	require.NotNil(t, page)
+	require.Equal(t, 0, len(page))
+
+	_, err = d.GetBlogEntries(ctx, 0, 0)
+	require.NotNil(t, err)
+
+	_, err = d.GetBlogEntries(ctx, 1, -1)
+	require.NotNil(t, err)
+
+	update := entries[0]
+	update.Title = "Other title"
+	update.Tags = []string{"c", "d"}
+	update.PostedAt = postedAt.Add(-time.Hour)
+	err = d.UpdateBlogEntry(ctx, update)
+	require.Nil(t, err)
+
+	got, err = d.GetBlogEntry(ctx, update.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Other title", got.Title)
+	require.Equal(t, []string{"c", "d"}, sorted(got.Tags))
+	require.True(t, update.PostedAt.Equal(got.PostedAt))
+
+	update.ID = entries[2].ID + 100
+	err = d.UpdateBlogEntry(ctx, update)
+	require.NotNil(t, err)
+
+	err = d.DeleteBlogEntry(ctx, entries[1].ID)
+	require.Nil(t, err)
+
+	_, err = d.GetBlogEntry(ctx, entries[1].ID)
+	require.NotNil(t, err)
+
+	err = d.DeleteBlogEntry(ctx, entries[1].ID)
+	require.NotNil(t, err)
+
+	page, err = d.GetBlogEntries(ctx, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(page))
+	require.Equal(t, entries[2].ID, page[0].ID)
+	require.Equal(t, entries[0].ID, page[1].ID)
+}
+
+func testBlogCursors(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	postedAt := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	// e1 and e2 are posted at the same time, so they are sorted by ID
+	var entries []db.BlogEntry
+	for _, hours := range []int{3, 2, 2, 1, 0} {
+		e := db.BlogEntry{
+			Author:   db.User{ID: u.ID},
+			Title:    "Some title",
+			Content:  "Some content",
+			PostedAt: postedAt.Add(time.Duration(hours) * time.Hour),
+		}
+		err := d.InsertBlogEntry(ctx, &e)
+		require.Nil(t, err)
+		entries = append(entries, e)
+	}
+
+	ids := func(page []db.BlogEntry) []int {
+		var res []int
+		for _, e := range page {
+			res = append(res, e.ID)
+		}
+		return res
+	}
+	cursor := func(e db.BlogEntry) db.BlogCursor {
+		return db.BlogCursor{PostedAt: e.PostedAt, ID: e.ID}
+	}
+
+	page, err := d.GetBlogEntries(ctx, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, ids(entries), ids(page))
+
+	page, err = d.GetBlogEntriesAfter(ctx, cursor(entries[0]), 2)
+	require.Nil(t, err)
+	require.Equal(t, []int{entries[1].ID, entries[2].ID}, ids(page))
+
+	page, err = d.GetBlogEntriesAfter(ctx, cursor(entries[1]), 2)
+	require.Nil(t, err)
+	require.Equal(t, []int{entries[2].ID, entries[3].ID}, ids(page))
+
+	page, err = d.GetBlogEntriesAfter(ctx, cursor(entries[3]), 2)
+	require.Nil(t, err)
+	require.Equal(t, []int{entries[4].ID}, ids(page))
+
+	page, err = d.GetBlogEntriesAfter(ctx, cursor(entries[4]), 2)
+	require.Nil(t, err)
+	require.NotNil(t, page)
+	require.Empty(t, page)
+
+	page, err = d.GetBlogEntriesBefore(ctx, cursor(entries[4]), 2)
+	require.Nil(t, err)
+	require.Equal(t, []int{entries[2].ID, entries[3].ID}, ids(page))
+
+	page, err = d.GetBlogEntriesBefore(ctx, cursor(entries[2]), 2)
+	require.Nil(t, err)
+	require.Equal(t, []int{entries[0].ID, entries[1].ID}, ids(page))
+
+	page, err = d.GetBlogEntriesBefore(ctx, cursor(entries[1]), 2)
+	require.Nil(t, err)
+	require.Equal(t, []int{entries[0].ID}, ids(page))
+
+	page, err = d.GetBlogEntriesBefore(ctx, cursor(entries[0]), 2)
+	require.Nil(t, err)
+	require.NotNil(t, page)
+	require.Empty(t, page)
+
+	// entries inserted or deleted elsewhere do not shift the page
+	err = d.DeleteBlogEntry(ctx, entries[1].ID)
+	require.Nil(t, err)
+	err = d.InsertBlogEntry(ctx, &db.BlogEntry{
+		Author:   db.User{ID: u.ID},
+		Title:    "Newer title",
+		Content:  "Newer content",
+		PostedAt: postedAt.Add(4 * time.Hour),
+	})
+	require.Nil(t, err)
+
+	page, err = d.GetBlogEntriesAfter(ctx, cursor(entries[1]), 2)
+	require.Nil(t, err)
+	require.Equal(t, []int{entries[2].ID, entries[3].ID}, ids(page))
+
+	_, err = d.GetBlogEntriesAfter(ctx, cursor(entries[0]), 0)
+	require.NotNil(t, err)
+
+	_, err = d.GetBlogEntriesBefore(ctx, cursor(entries[0]), 0)
+	require.NotNil(t, err)
+}
+
+func testArtists(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	added := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	justice := db.Artist{
+		Name:    "Justice",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+		Tags:    []string{"electronic"},
+	}
+	err := d.InsertArtist(ctx, &justice)
+	require.Nil(t, err)
+
+	// matching by name must not depend on any aliases existing
+	artists, err := d.AutocompleteArtists(ctx, "Just")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(artists))
+	require.Equal(t, justice.ID, artists[0].ID)
+
+	daftPunk := db.Artist{
+		Name:    "Daft Punk",
+		Bio:     sql.NullString{String: "Some bio"},
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+		Tags:    []string{"french", "electronic"},
+		Aliases: []db.ArtistAlias{{
+			Alias:   "Thomas and Guy-Manuel",
+			Added:   added.Add(time.Hour),
+			AddedBy: db.User{ID: u.ID},
+		}},
+	}
+	err = d.InsertArtist(ctx, &daftPunk)
+	require.Nil(t, err)
+	require.NotEqual(t, justice.ID, daftPunk.ID)
+
+	got, err := d.GetArtist(ctx, daftPunk.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Daft Punk", got.Name)
+	require.True(t, got.Bio.Valid)
+	require.Equal(t, "Some bio", got.Bio.String)
+	require.True(t, added.Equal(got.Added))
+	require.Equal(t, u.ID, got.AddedBy.ID)
+	require.Equal(t, u.Username, got.AddedBy.Username)
+	require.Equal(t, []string{"electronic", "french"}, sorted(got.Tags))
+	require.Equal(t, 1, len(got.Aliases))
+	require.Equal(t, "Thomas and Guy-Manuel", got.Aliases[0].Alias)
+	require.True(t, added.Add(time.Hour).Equal(got.Aliases[0].Added))
+	require.Equal(t, u.ID, got.Aliases[0].AddedBy.ID)
+
+	got, err = d.GetArtist(ctx, justice.ID)
+	require.Nil(t, err)
+	require.False(t, got.Bio.Valid)
+	require.Empty(t, got.Aliases)
+
+	_, err = d.GetArtist(ctx, daftPunk.ID+100)
+	require.NotNil(t, err)
+
+	// by alias
+	artists, err = d.AutocompleteArtists(ctx, "Guy")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(artists))
+	require.Equal(t, daftPunk.ID, artists[0].ID)
+	require.Equal(t, 1, len(artists[0].Aliases))
+	require.Equal(t, []string{"electronic", "french"}, sorted(artists[0].Tags))
+
+	// case sensitive
+	artists, err = d.AutocompleteArtists(ctx, "guy")
+	require.Nil(t, err)
+	require.Empty(t, artists)
+
+	artists, err = d.AutocompleteArtists(ctx, "u")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(artists))
+
+	_, err = d.AutocompleteArtists(ctx, "")
+	require.NotNil(t, err)
+
+	// tags are shared
+	tags, err := d.AutocompleteArtistTags(ctx, "lectr")
+	require.Nil(t, err)
+	require.Equal(t, []string{"electronic"}, tags)
+
+	tags, err = d.AutocompleteArtistTags(ctx, "LECTR")
+	require.Nil(t, err)
+	require.Empty(t, tags)
+
+	err = d.InsertArtist(ctx, &db.Artist{
+		Name:    "Some artist",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+		Tags:    []string{"a", "a"},
+	})
+	require.NotNil(t, err)
+
+	err = d.InsertArtist(ctx, &db.Artist{
+		Name:    "Some artist",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID + 100},
+	})
+	require.NotNil(t, err)
+}
+
+func testUpdateArtists(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	added := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+	edit := db.Edit{Edited: added.Add(24 * time.Hour), EditedBy: db.User{ID: u.ID}}
+
+	justice := db.Artist{
+		Name:    "Justice",
+		Bio:     sql.NullString{String: "Some bio"},
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+		Tags:    []string{"electronic", "french"},
+	}
+	err := d.InsertArtist(ctx, &justice)
+	require.Nil(t, err)
+
+	daftPunk := db.Artist{
+		Name:    "Daft Punk",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+		Tags:    []string{"electronic"},
+	}
+	err = d.InsertArtist(ctx, &daftPunk)
+	require.Nil(t, err)
+
+	update := justice
+	update.Name = "Justice!"
+	update.Bio = sql.NullString{String: "Other bio"}
+	err = d.UpdateArtist(ctx, update, edit)
+	require.Nil(t, err)
+
+	got, err := d.GetArtist(ctx, justice.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Justice!", got.Name)
+	require.Equal(t, "Other bio", got.Bio.String)
+	require.True(t, added.Equal(got.Added))
+	require.Equal(t, []string{"electronic", "french"}, sorted(got.Tags))
+
+	// an empty bio removes it
+	update.Bio = sql.NullString{}
+	err = d.UpdateArtist(ctx, update, edit)
+	require.Nil(t, err)
+
+	got, err = d.GetArtist(ctx, justice.ID)
+	require.Nil(t, err)
+	require.False(t, got.Bio.Valid)
+
+	update.ID = daftPunk.ID + 100
+	err = d.UpdateArtist(ctx, update, edit)
+	require.NotNil(t, err)
+
+	// aliases
+	alias := db.ArtistAlias{
+		Alias:   "Gaspard and Xavier",
+		Added:   added.Add(time.Hour),
+		AddedBy: db.User{ID: u.ID},
+	}
+	err = d.AddArtistAlias(ctx, justice.ID, alias)
+	require.Nil(t, err)
+
+	err = d.AddArtistAlias(ctx, justice.ID, alias)
+	require.NotNil(t, err)
+
+	// aliases are per artist
+	err = d.AddArtistAlias(ctx, daftPunk.ID, alias)
+	require.Nil(t, err)
+
+	err = d.AddArtistAlias(ctx, daftPunk.ID+100, alias)
+	require.NotNil(t, err)
+
+	err = d.AddArtistAlias(ctx, justice.ID, db.ArtistAlias{
+		Alias:   "Justice Ltd.",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID + 100},
+	})
+	require.NotNil(t, err)
+
+	got, err = d.GetArtist(ctx, justice.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(got.Aliases))
+	require.Equal(t, "Gaspard and Xavier", got.Aliases[0].Alias)
+	require.True(t, added.Add(time.Hour).Equal(got.Aliases[0].Added))
+	require.Equal(t, u.ID, got.Aliases[0].AddedBy.ID)
+
+	err = d.RemoveArtistAlias(ctx, justice.ID, "Gaspard and Xavier", edit)
+	require.Nil(t, err)
+
+	err = d.RemoveArtistAlias(ctx, justice.ID, "Gaspard and Xavier", edit)
+	require.NotNil(t, err)
+
+	got, err = d.GetArtist(ctx, justice.ID)
+	require.Nil(t, err)
+	require.Empty(t, got.Aliases)
+
+	got, err = d.GetArtist(ctx, daftPunk.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(got.Aliases))
+
+	// tags
+	err = d.AddArtistTags(ctx, daftPunk.ID, []string{"electronic", "house"}, edit)
+	require.Nil(t, err)
+
+	got, err = d.GetArtist(ctx, daftPunk.ID)
+	require.Nil(t, err)
+	require.Equal(t, []string{"electronic", "house"}, sorted(got.Tags))
+
+	err = d.AddArtistTags(ctx, daftPunk.ID+100, []string{"house"}, edit)
+	require.NotNil(t, err)
+
+	err = d.RemoveArtistTag(ctx, justice.ID, "french", edit)
+	require.Nil(t, err)
+
+	err = d.RemoveArtistTag(ctx, justice.ID, "french", edit)
+	require.NotNil(t, err)
+
+	err = d.RemoveArtistTag(ctx, justice.ID, "house", edit)
+	require.NotNil(t, err)
+
+	got, err = d.GetArtist(ctx, justice.ID)
+	require.Nil(t, err)
+	require.Equal(t, []string{"electronic"}, got.Tags)
+
+	// unused tags are removed, shared ones are kept
+	tags, err := d.AutocompleteArtistTags(ctx, "e")
+	require.Nil(t, err)
+	require.Equal(t, []string{"electronic", "house"}, sorted(tags))
+
+	err = d.RemoveArtistTag(ctx, justice.ID, "electronic", edit)
+	require.Nil(t, err)
+
+	tags, err = d.AutocompleteArtistTags(ctx, "electronic")
+	require.Nil(t, err)
+	require.Equal(t, []string{"electronic"}, tags)
+}
+
+func testMergeArtists(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	added := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+	merged := added.Add(24 * time.Hour)
+
+	insert := func(name string, bio string, tags []string, aliases ...string) db.Artist {
+		a := db.Artist{
+			Name:    name,
+			Bio:     sql.NullString{String: bio},
+			Added:   added,
+			AddedBy: db.User{ID: u.ID},
+			Tags:    tags,
+		}
+		for _, al := range aliases {
+			a.Aliases = append(a.Aliases, db.ArtistAlias{Alias: al, Added: added, AddedBy: db.User{ID: u.ID}})
+		}
+		err := d.InsertArtist(ctx, &a)
+		require.Nil(t, err)
+		return a
+	}
+
+	daftPunk := insert("Daft Punk", "", []string{"electronic"}, "Darlin'")
+	dupe := insert("Daft Punk (FR)", "Some bio", []string{"electronic", "house"}, "Darlin'", "Daft")
+	other := insert("Daftpunk", "", nil)
+
+	// Homework is credited to both, with the same role, Discovery to the
+	// duplicate only.
+	homework := db.ReleaseGroup{
+		Name:        "Homework",
+		ReleaseDate: time.Date(1997, 1, 20, 0, 0, 0, 0, time.UTC),
+		Added:       added,
+		AddedBy:     db.User{ID: u.ID},
+		Artists:     []db.RoledArtist{{Role: 0, Artist: daftPunk}, {Role: 0, Artist: dupe}, {Role: 5, Artist: dupe}},
+	}
+	err := d.InsertReleaseGroup(ctx, &homework)
+	require.Nil(t, err)
+
+	discovery := db.ReleaseGroup{
+		Name:        "Discovery",
+		ReleaseDate: time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC),
+		Added:       added,
+		AddedBy:     db.User{ID: u.ID},
+		Artists:     []db.RoledArtist{{Role: 0, Artist: dupe}},
+	}
+	err = d.InsertReleaseGroup(ctx, &discovery)
+	require.Nil(t, err)
+
+	err = d.MergeArtists(ctx, db.ArtistRedirect{Artist: dupe.ID, Target: daftPunk.ID, Merged: merged, MergedBy: db.User{ID: u.ID}})
+	require.Nil(t, err)
+
+	_, err = d.GetArtist(ctx, dupe.ID)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	got, err := d.GetArtist(ctx, daftPunk.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Daft Punk", got.Name)
+	require.Equal(t, "Some bio", got.Bio.String)
+	require.Equal(t, []string{"electronic", "house"}, sorted(got.Tags))
+
+	var aliases []string
+	for _, al := range got.Aliases {
+		aliases = append(aliases, al.Alias)
+		if al.Alias == "Daft Punk (FR)" {
+			require.True(t, merged.Equal(al.Added))
+			require.Equal(t, u.ID, al.AddedBy.ID)
+		}
+	}
+	require.Equal(t, []string{"Daft", "Daft Punk (FR)", "Darlin'"}, sorted(aliases))
+
+	err = d.PopulateReleaseGroups(ctx, got)
+	require.Nil(t, err)
+	var credits []string
+	for _, g := range got.ReleaseGroups {
+		credits = append(credits, fmt.Sprintf("%s/%d", g.ReleaseGroup.Name, g.Role))
+	}
+	require.Equal(t, []string{"Discovery/0", "Homework/0", "Homework/5"}, sorted(credits))
+
+	r, err := d.GetArtistRedirect(ctx, dupe.ID)
+	require.Nil(t, err)
+	require.Equal(t, dupe.ID, r.Artist)
+	require.Equal(t, daftPunk.ID, r.Target)
+	require.True(t, merged.Equal(r.Merged))
+	require.Equal(t, u.ID, r.MergedBy.ID)
+	require.Equal(t, "someuser", r.MergedBy.Username)
+
+	_, err = d.GetArtistRedirect(ctx, daftPunk.ID)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	// the merge is recorded as an edit of the target and of the release
+	// groups whose credits were rewritten
+	revision, err := d.GetRevision(ctx, db.RevisionArtist, daftPunk.ID, 2)
+	require.Nil(t, err)
+	require.True(t, merged.Equal(revision.Edited))
+	require.Equal(t, u.ID, revision.EditedBy.ID)
+
+	revision, err = d.GetRevision(ctx, db.RevisionReleaseGroup, homework.ID, 2)
+	require.Nil(t, err)
+	require.True(t, merged.Equal(revision.Edited))
+	require.JSONEq(t, fmt.Sprintf(`{"artists":{"old":[{"artist":%d,"role":"Main"},{"artist":%d,"role":"Main"},{"artist":%d,"role":"Producer"}],"new":[{"artist":%d,"role":"Main"},{"artist":%d,"role":"Producer"}]}}`, daftPunk.ID, dupe.ID, dupe.ID, daftPunk.ID, daftPunk.ID), string(revision.Diff))
+
+	count, err := d.CountRevisions(ctx, db.RevisionReleaseGroup, discovery.ID)
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+
+	// redirects to a merged artist follow it
+	err = d.MergeArtists(ctx, db.ArtistRedirect{Artist: daftPunk.ID, Target: other.ID, Merged: merged, MergedBy: db.User{ID: u.ID}})
+	require.Nil(t, err)
+
+	r, err = d.GetArtistRedirect(ctx, dupe.ID)
+	require.Nil(t, err)
+	require.Equal(t, other.ID, r.Target)
+
+	got, err = d.GetArtist(ctx, other.ID)
+	require.Nil(t, err)
+	require.Equal(t, 4, len(got.Aliases))
+
+	// errors
+	err = d.MergeArtists(ctx, db.ArtistRedirect{Artist: other.ID, Target: other.ID, Merged: merged, MergedBy: db.User{ID: u.ID}})
+	require.NotNil(t, err)
+
+	err = d.MergeArtists(ctx, db.ArtistRedirect{Artist: dupe.ID, Target: other.ID, Merged: merged, MergedBy: db.User{ID: u.ID}})
+	require.NotNil(t, err)
+
+	err = d.MergeArtists(ctx, db.ArtistRedirect{Artist: other.ID, Target: daftPunk.ID, Merged: merged, MergedBy: db.User{ID: u.ID}})
+	require.NotNil(t, err)
+
+	_, err = d.GetArtist(ctx, other.ID)
+	require.Nil(t, err)
+}
+
+func testRecordLabels(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+
+	ninja := db.RecordLabel{
+		Name:        "Ninja Tune",
+		Description: sql.NullString{String: "Some description"},
+		Founded:     pq.NullTime{Time: time.Date(1990, 5, 3, 15, 0, 0, 0, time.UTC), Valid: true},
+		AddedBy:     db.User{ID: u.ID},
+	}
+	err := d.InsertRecordLabel(ctx, &ninja)
+	require.Nil(t, err)
+
+	warp := db.RecordLabel{
+		Name:    "Warp Records",
+		AddedBy: db.User{ID: u.ID},
+	}
+	err = d.InsertRecordLabel(ctx, &warp)
+	require.Nil(t, err)
+	require.NotEqual(t, ninja.ID, warp.ID)
+
+	got, err := d.GetRecordLabel(ctx, ninja.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Ninja Tune", got.Name)
+	require.True(t, got.Description.Valid)
+	require.Equal(t, "Some description", got.Description.String)
+	require.True(t, got.Founded.Valid)
+	require.True(t, time.Date(1990, 5, 3, 0, 0, 0, 0, time.UTC).Equal(got.Founded.Time))
+	require.Equal(t, u.ID, got.AddedBy.ID)
+	require.Equal(t, u.Username, got.AddedBy.Username)
+
+	got, err = d.GetRecordLabel(ctx, warp.ID)
+	require.Nil(t, err)
+	require.False(t, got.Description.Valid)
+	require.False(t, got.Founded.Valid)
+
+	_, err = d.GetRecordLabel(ctx, warp.ID+100)
+	require.NotNil(t, err)
+
+	// case insensitive
+	labels, err := d.AutocompleteRecordLabels(ctx, "NINJA")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(labels))
+	require.Equal(t, ninja.ID, labels[0].ID)
+
+	labels, err = d.AutocompleteRecordLabels(ctx, "r")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(labels))
+	require.Equal(t, warp.ID, labels[0].ID)
+
+	_, err = d.AutocompleteRecordLabels(ctx, "")
+	require.NotNil(t, err)
+
+	err = d.InsertRecordLabel(ctx, &db.RecordLabel{
+		Name:    "Some label",
+		AddedBy: db.User{ID: u.ID + 100},
+	})
+	require.NotNil(t, err)
+}
+
+func testSubLabels(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	edit := db.Edit{Edited: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), EditedBy: db.User{ID: u.ID}}
+
+	warner := db.RecordLabel{Name: "Warner", AddedBy: db.User{ID: u.ID}}
+	err := d.InsertRecordLabel(ctx, &warner)
+	require.Nil(t, err)
+
+	parlophone := db.RecordLabel{Name: "Parlophone", AddedBy: db.User{ID: u.ID}, Parent: &db.RecordLabel{ID: warner.ID}}
+	err = d.InsertRecordLabel(ctx, &parlophone)
+	require.Nil(t, err)
+
+	atlantic := db.RecordLabel{Name: "Atlantic", AddedBy: db.User{ID: u.ID}, Parent: &db.RecordLabel{ID: warner.ID}}
+	err = d.InsertRecordLabel(ctx, &atlantic)
+	require.Nil(t, err)
+
+	err = d.InsertRecordLabel(ctx, &db.RecordLabel{Name: "Nobody", AddedBy: db.User{ID: u.ID}, Parent: &db.RecordLabel{ID: atlantic.ID + 100}})
+	require.NotNil(t, err)
+
+	got, err := d.GetRecordLabel(ctx, parlophone.ID)
+	require.Nil(t, err)
+	require.NotNil(t, got.Parent)
+	require.Equal(t, warner.ID, got.Parent.ID)
+	require.Equal(t, "Warner", got.Parent.Name)
+
+	got, err = d.GetRecordLabel(ctx, warner.ID)
+	require.Nil(t, err)
+	require.Nil(t, got.Parent)
+
+	labels, err := d.AutocompleteRecordLabels(ctx, "parlo")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(labels))
+	require.Equal(t, warner.ID, labels[0].Parent.ID)
+
+	subs, err := d.GetSubLabels(ctx, warner.ID)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(subs))
+	require.Equal(t, "Atlantic", subs[0].Name)
+	require.Equal(t, "Parlophone", subs[1].Name)
+	require.Equal(t, "Warner", subs[1].Parent.Name)
+
+	subs, err = d.GetSubLabels(ctx, parlophone.ID)
+	require.Nil(t, err)
+	require.Empty(t, subs)
+
+	// update
+	update := *got
+	update.Name = "Warner Music Group"
+	update.Description = sql.NullString{String: "A major label"}
+	update.Founded = pq.NullTime{Time: time.Date(2004, 3, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	err = d.UpdateRecordLabel(ctx, update, edit)
+	require.Nil(t, err)
+
+	got, err = d.GetRecordLabel(ctx, warner.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Warner Music Group", got.Name)
+	require.Equal(t, "A major label", got.Description.String)
+	require.True(t, update.Founded.Time.Equal(got.Founded.Time))
+
+	// Parlophone moves below Atlantic
+	moved, err := d.GetRecordLabel(ctx, parlophone.ID)
+	require.Nil(t, err)
+	moved.Parent = &db.RecordLabel{ID: atlantic.ID}
+	err = d.UpdateRecordLabel(ctx, *moved, edit)
+	require.Nil(t, err)
+
+	subs, err = d.GetSubLabels(ctx, warner.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(subs))
+
+	// cycles are rejected
+	update.Parent = &db.RecordLabel{ID: parlophone.ID}
+	err = d.UpdateRecordLabel(ctx, update, edit)
+	require.NotNil(t, err)
+
+	update.Parent = &db.RecordLabel{ID: warner.ID}
+	err = d.UpdateRecordLabel(ctx, update, edit)
+	require.NotNil(t, err)
+
+	got, err = d.GetRecordLabel(ctx, warner.ID)
+	require.Nil(t, err)
+	require.Nil(t, got.Parent)
+
+	// removing the parent
+	moved.Parent = nil
+	err = d.UpdateRecordLabel(ctx, *moved, edit)
+	require.Nil(t, err)
+
+	got, err = d.GetRecordLabel(ctx, parlophone.ID)
+	require.Nil(t, err)
+	require.Nil(t, got.Parent)
+
+	update.ID = atlantic.ID + 100
+	update.Parent = nil
+	err = d.UpdateRecordLabel(ctx, update, edit)
+	require.NotNil(t, err)
+}
+
+func testRecordLabelReleases(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	release := insertRelease(t, d, u)
+	label := release.RecordLabel
+
+	insert := func(catalogueNumber string, releaseDate time.Time) db.Release {
+		r := release
+		r.CatalogueNumber = sql.NullString{String: catalogueNumber}
+		r.ReleaseDate = releaseDate
+		err := d.InsertRelease(ctx, &r)
+		require.Nil(t, err)
+		return r
+	}
+	b := insert("VJCP-2", time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC))
+	a2 := insert("VJCP-1", time.Date(2002, 3, 12, 0, 0, 0, 0, time.UTC))
+	a1 := insert("VJCP-1", time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC))
+
+	count, err := d.CountRecordLabelReleases(ctx, label.ID)
+	require.Nil(t, err)
+	require.Equal(t, 4, count)
+
+	releases, err := d.GetRecordLabelReleases(ctx, label.ID, 0, 10)
+	require.Nil(t, err)
+	var ids []int
+	for _, r := range releases {
+		ids = append(ids, r.ID)
+	}
+	// releases without catalogue number come last
+	require.Equal(t, []int{a1.ID, a2.ID, b.ID, release.ID}, ids)
+	require.Equal(t, "Discovery", releases[0].ReleaseGroup.Name)
+	require.True(t, time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC).Equal(releases[0].ReleaseGroup.ReleaseDate))
+	require.Equal(t, "Virgin", releases[0].RecordLabel.Name)
+	require.Equal(t, []string{"japan", "remaster"}, sorted(releases[0].Tags))
+	require.Equal(t, map[string]string{"LossyWebApproved": "yes"}, releases[0].Properties)
+
+	releases, err = d.GetRecordLabelReleases(ctx, label.ID, 1, 2)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(releases))
+	require.Equal(t, a2.ID, releases[0].ID)
+	require.Equal(t, b.ID, releases[1].ID)
+
+	// cursors, including one without catalogue number
+	catalog, err := d.GetRecordLabelReleases(ctx, label.ID, 0, 10)
+	require.Nil(t, err)
+	cursor := func(i int) db.RecordLabelReleaseCursor {
+		return db.RecordLabelReleaseCursor{CatalogueNumber: catalog[i].CatalogueNumber, ReleaseDate: catalog[i].ReleaseDate, ID: catalog[i].ID}
+	}
+
+	releases, err = d.GetRecordLabelReleasesAfter(ctx, label.ID, cursor(0), 2)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(releases))
+	require.Equal(t, a2.ID, releases[0].ID)
+	require.Equal(t, b.ID, releases[1].ID)
+	require.Equal(t, "Discovery", releases[0].ReleaseGroup.Name)
+
+	releases, err = d.GetRecordLabelReleasesAfter(ctx, label.ID, cursor(2), 2)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(releases))
+	require.Equal(t, release.ID, releases[0].ID)
+
+	releases, err = d.GetRecordLabelReleasesAfter(ctx, label.ID, cursor(3), 2)
+	require.Nil(t, err)
+	require.Empty(t, releases)
+
+	releases, err = d.GetRecordLabelReleasesBefore(ctx, label.ID, cursor(3), 2)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(releases))
+	require.Equal(t, a2.ID, releases[0].ID)
+	require.Equal(t, b.ID, releases[1].ID)
+
+	releases, err = d.GetRecordLabelReleasesBefore(ctx, label.ID, cursor(1), 2)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(releases))
+	require.Equal(t, a1.ID, releases[0].ID)
+
+	releases, err = d.GetRecordLabelReleasesBefore(ctx, label.ID, cursor(0), 2)
+	require.Nil(t, err)
+	require.Empty(t, releases)
+
+	_, err = d.GetRecordLabelReleasesAfter(ctx, label.ID, cursor(0), 0)
+	require.NotNil(t, err)
+
+	releases, err = d.GetRecordLabelReleases(ctx, label.ID+100, 0, 10)
+	require.Nil(t, err)
+	require.Empty(t, releases)
+
+	count, err = d.CountRecordLabelReleases(ctx, label.ID+100)
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+
+	_, err = d.GetRecordLabelReleases(ctx, label.ID, 0, 0)
+	require.NotNil(t, err)
+}
+
+// insertReleaseGroups inserts an artist and three release groups of theirs.
+func insertReleaseGroups(t *testing.T, d db.BoilingDB, u *db.User) (db.Artist, []db.ReleaseGroup) {
+	ctx := context.Background()
+	added := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	artist := db.Artist{
+		Name:    "Daft Punk",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+	}
+	err := d.InsertArtist(ctx, &artist)
+	require.Nil(t, err)
+
+	groups := []db.ReleaseGroup{
+		{
+			Name:        "Discovery",
+			ReleaseDate: time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC),
+			Type:        0,
+			Tags:        []string{"house", "electronic"},
+		},
+		{
+			Name:        "Homework",
+			ReleaseDate: time.Date(1997, 1, 20, 0, 0, 0, 0, time.UTC),
+			Type:        0,
+			Tags:        []string{"house"},
+		},
+		{
+			Name:        "Alive 2007",
+			ReleaseDate: time.Date(2007, 11, 19, 0, 0, 0, 0, time.UTC),
+			Type:        5,
+		},
+	}
+	for i := range groups {
+		groups[i].Added = added.Add(time.Duration(i) * time.Hour)
+		groups[i].AddedBy = db.User{ID: u.ID}
+		groups[i].Artists = []db.RoledArtist{{Role: 0, Artist: artist}}
+
+		err = d.InsertReleaseGroup(ctx, &groups[i])
+		require.Nil(t, err)
+	}
+
+	return artist, groups
+}
+
+func testReleaseGroups(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	artist, groups := insertReleaseGroups(t, d, u)
+
+	got, err := d.GetReleaseGroup(ctx, groups[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, "Discovery", got.Name)
+	require.Equal(t, 0, got.Type)
+	require.True(t, groups[0].ReleaseDate.Equal(got.ReleaseDate))
+	require.True(t, groups[0].Added.Equal(got.Added))
+	require.Equal(t, u.ID, got.AddedBy.ID)
+	require.Equal(t, u.Username, got.AddedBy.Username)
+	require.Equal(t, []string{"electronic", "house"}, sorted(got.Tags))
+	require.Equal(t, 1, len(got.Artists))
+	require.Equal(t, 0, got.Artists[0].Role)
+	require.Equal(t, artist.ID, got.Artists[0].Artist.ID)
+	require.Equal(t, artist.Name, got.Artists[0].Artist.Name)
+
+	_, err = d.GetReleaseGroup(ctx, groups[2].ID+100)
+	require.NotNil(t, err)
+
+	// case insensitive
+	found, err := d.AutocompleteReleaseGroups(ctx, "DISCO")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(found))
+	require.Equal(t, groups[0].ID, found[0].ID)
+	require.Equal(t, []string{"electronic", "house"}, sorted(found[0].Tags))
+	require.Equal(t, 1, len(found[0].Artists))
+
+	found, err = d.AutocompleteReleaseGroups(ctx, "o")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(found))
+
+	_, err = d.AutocompleteReleaseGroups(ctx, "")
+	require.NotNil(t, err)
+
+	// tags are shared and case sensitive
+	tags, err := d.AutocompleteReleaseGroupTags(ctx, "ous")
+	require.Nil(t, err)
+	require.Equal(t, []string{"house"}, tags)
+
+	tags, err = d.AutocompleteReleaseGroupTags(ctx, "OUS")
+	require.Nil(t, err)
+	require.Empty(t, tags)
+
+	err = d.PopulateReleaseGroups(ctx, &artist)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(artist.ReleaseGroups))
+	names := make([]string, 0, 3)
+	for _, g := range artist.ReleaseGroups {
+		require.Equal(t, 0, g.Role)
+		names = append(names, g.ReleaseGroup.Name)
+	}
+	require.Equal(t, []string{"Alive 2007", "Discovery", "Homework"}, sorted(names))
+
+	invalid := []db.ReleaseGroup{
+		{Name: "Unknown type", Type: 100},
+		{Name: "Unknown artist", Artists: []db.RoledArtist{{Role: 0, Artist: db.Artist{ID: artist.ID + 100}}}},
+		{Name: "Unknown role", Artists: []db.RoledArtist{{Role: 100, Artist: artist}}},
+		{Name: "Duplicate tags", Tags: []string{"a", "a"}},
+	}
+	for _, g := range invalid {
+		g.AddedBy = db.User{ID: u.ID}
+		err = d.InsertReleaseGroup(ctx, &g)
+		require.NotNil(t, err, g.Name)
+	}
+}
+
+func testUpdateReleaseGroups(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	edit := db.Edit{Edited: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), EditedBy: db.User{ID: u.ID}}
+	artist, groups := insertReleaseGroups(t, d, u)
+
+	thomas := db.Artist{
+		Name:    "Thomas Bangalter",
+		Added:   time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC),
+		AddedBy: db.User{ID: u.ID},
+	}
+	err := d.InsertArtist(ctx, &thomas)
+	require.Nil(t, err)
+
+	update := groups[0]
+	update.Name = "Discovery (Remastered)"
+	update.ReleaseDate = time.Date(2001, 3, 13, 0, 0, 0, 0, time.UTC)
+	update.Type = 3
+	update.Tags = []string{"electronic", "french"}
+	update.Artists = []db.RoledArtist{{Role: 0, Artist: artist}, {Role: 5, Artist: thomas}}
+	err = d.UpdateReleaseGroup(ctx, update, edit)
+	require.Nil(t, err)
+
+	got, err := d.GetReleaseGroup(ctx, groups[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, "Discovery (Remastered)", got.Name)
+	require.True(t, update.ReleaseDate.Equal(got.ReleaseDate))
+	require.True(t, groups[0].Added.Equal(got.Added))
+	require.Equal(t, 3, got.Type)
+	require.Equal(t, []string{"electronic", "french"}, sorted(got.Tags))
+	require.Equal(t, 2, len(got.Artists))
+
+	// house is still used by Homework
+	tags, err := d.AutocompleteReleaseGroupTags(ctx, "house")
+	require.Nil(t, err)
+	require.Equal(t, []string{"house"}, tags)
+
+	invalid := update
+	invalid.Type = 100
+	err = d.UpdateReleaseGroup(ctx, invalid, edit)
+	require.NotNil(t, err)
+
+	invalid = update
+	invalid.Artists = []db.RoledArtist{{Role: 0, Artist: db.Artist{ID: thomas.ID + 100}}}
+	err = d.UpdateReleaseGroup(ctx, invalid, edit)
+	require.NotNil(t, err)
+
+	invalid = update
+	invalid.ID = groups[2].ID + 100
+	err = d.UpdateReleaseGroup(ctx, invalid, edit)
+	require.NotNil(t, err)
+
+	// failed updates change nothing
+	got, err = d.GetReleaseGroup(ctx, groups[0].ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, got.Type)
+	require.Equal(t, 2, len(got.Artists))
+
+	err = d.DeleteReleaseGroup(ctx, groups[1].ID)
+	require.Nil(t, err)
+
+	_, err = d.GetReleaseGroup(ctx, groups[1].ID)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	// unused tags are removed
+	tags, err = d.AutocompleteReleaseGroupTags(ctx, "house")
+	require.Nil(t, err)
+	require.Empty(t, tags)
+
+	err = d.DeleteReleaseGroup(ctx, groups[1].ID)
+	require.NotNil(t, err)
+
+	// release groups with releases can not be deleted
+	release := insertRelease(t, d, u)
+	err = d.DeleteReleaseGroup(ctx, release.ReleaseGroup.ID)
+	require.NotNil(t, err)
+
+	err = d.DeleteRelease(ctx, release.ID)
+	require.Nil(t, err)
+	err = d.DeleteReleaseGroup(ctx, release.ReleaseGroup.ID)
+	require.Nil(t, err)
+}
+
+func testSearchReleaseGroups(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	artist, groups := insertReleaseGroups(t, d, u)
+
+	ids := func(groups []db.ReleaseGroup) []int {
+		res := make([]int, 0, len(groups))
+		for _, g := range groups {
+			res = append(res, g.ID)
+		}
+		return res
+	}
+
+	q := db.NewQuery(db.Eq(db.ReleaseGroupTypeSelector(), 0))
+	q.SetSorter(db.SortAscending(db.ReleaseGroupReleaseDateSelector()))
+	found, err := d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[1].ID, groups[0].ID}, ids(found))
+	require.Equal(t, []string{"electronic", "house"}, sorted(found[1].Tags))
+	require.Equal(t, 1, len(found[1].Artists))
+	require.Equal(t, u.Username, found[1].AddedBy.Username)
+
+	q = db.NewQuery(db.Or(
+		db.Eq(db.ReleaseGroupTypeSelector(), 0),
+		db.Eq(db.ReleaseGroupTypeSelector(), 5),
+	))
+	q.SetSorter(db.SortDescending(db.ReleaseGroupReleaseDateSelector()))
+	found, err = d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[2].ID, groups[0].ID, groups[1].ID}, ids(found))
+
+	found, err = d.SearchReleaseGroups(ctx, q, 1, 1)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[0].ID}, ids(found))
+
+	found, err = d.SearchReleaseGroups(ctx, q, 3, 1)
+	require.Nil(t, err)
+	require.Empty(t, found)
+
+	q = db.NewQuery(db.And(
+		db.Eq(db.ReleaseGroupTypeSelector(), 0),
+		db.Neq(db.ReleaseGroupReleaseDateSelector(), groups[0].ReleaseDate),
+	))
+	found, err = d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[1].ID}, ids(found))
+
+	q = db.NewQuery(db.Eq(db.ReleaseGroupAddedSelector(), groups[2].Added))
+	found, err = d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[2].ID}, ids(found))
+
+	// ranges
+	q = db.NewQuery(db.And(
+		db.Gte(db.ReleaseGroupReleaseDateSelector(), groups[1].ReleaseDate),
+		db.Lt(db.ReleaseGroupReleaseDateSelector(), groups[2].ReleaseDate),
+	))
+	found, err = d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[0].ID, groups[1].ID}, ids(found))
+
+	q = db.NewQuery(db.Gt(db.ReleaseGroupAddedSelector(), groups[0].Added))
+	q.SetSorter(db.SortDescending(db.ReleaseGroupAddedSelector()))
+	found, err = d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[2].ID, groups[1].ID}, ids(found))
+
+	q = db.NewQuery(db.Lte(db.ReleaseGroupTypeSelector(), 0))
+	count, err := d.CountReleaseGroups(ctx, q)
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+
+	// tags and artists
+	q = db.NewQuery(db.ReleaseGroupHasTag("house"))
+	q.SetSorter(db.SortAscending(db.ReleaseGroupReleaseDateSelector()))
+	found, err = d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[1].ID, groups[0].ID}, ids(found))
+
+	q = db.NewQuery(db.And(db.ReleaseGroupHasTag("house"), db.ReleaseGroupHasTag("electronic")))
+	found, err = d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[0].ID}, ids(found))
+
+	q = db.NewQuery(db.ReleaseGroupHasTag("hous"))
+	count, err = d.CountReleaseGroups(ctx, q)
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+
+	q = db.NewQuery(db.And(db.ReleaseGroupHasArtist(artist.ID), db.Eq(db.ReleaseGroupTypeSelector(), 5)))
+	found, err = d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[2].ID}, ids(found))
+
+	q = db.NewQuery(db.ReleaseGroupHasArtist(artist.ID + 100))
+	count, err = d.CountReleaseGroups(ctx, q)
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+
+	// no condition, ties ordered by ID
+	q = db.NewQuery(nil)
+	found, err = d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[0].ID, groups[1].ID, groups[2].ID}, ids(found))
+
+	q.SetSorter(db.SortAscending(db.ReleaseGroupTypeSelector()))
+	found, err = d.SearchReleaseGroups(ctx, q, 1, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{groups[1].ID, groups[2].ID}, ids(found))
+
+	count, err = d.CountReleaseGroups(ctx, q)
+	require.Nil(t, err)
+	require.Equal(t, 3, count)
+
+	_, err = d.CountReleaseGroups(ctx, nil)
+	require.NotNil(t, err)
+
+	_, err = d.SearchReleaseGroups(ctx, nil, 0, 10)
+	require.NotNil(t, err)
+
+	_, err = d.SearchReleaseGroups(ctx, q, 0, 0)
+	require.NotNil(t, err)
+
+	_, err = d.SearchReleaseGroups(ctx, q, -1, 10)
+	require.NotNil(t, err)
+}
+
+func testSearchReleaseGroupsRelated(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	_, groups := insertReleaseGroups(t, d, u)
+	discovery, homework, alive := groups[0], groups[1], groups[2]
+
+	label := db.RecordLabel{
+		Name:    "Virgin",
+		AddedBy: db.User{ID: u.ID},
+	}
+	err := d.InsertRecordLabel(ctx, &label)
+	require.Nil(t, err)
+
+	// Discovery has a FLAC CD and an MP3 vinyl, Homework a FLAC vinyl and an
+	// MP3 CD.
+	releases := []struct {
+		group           db.ReleaseGroup
+		medium          int
+		catalogueNumber string
+		format          int
+		leechType       int
+	}{
+		{discovery, 0, "VIR 123", 0, 0},
+		{discovery, 2, "VIR 124", 3, 0},
+		{homework, 2, "V2821", 0, 1},
+		{homework, 0, "", 3, 0},
+	}
+	for i, r := range releases {
+		release := db.Release{
+			ReleaseGroup:    r.group,
+			Medium:          r.medium,
+			ReleaseDate:     r.group.ReleaseDate,
+			CatalogueNumber: sql.NullString{String: r.catalogueNumber},
+			RecordLabel:     label,
+			Added:           r.group.Added,
+			AddedBy:         db.User{ID: u.ID},
+		}
+		err = d.InsertRelease(ctx, &release)
+		require.Nil(t, err)
+
+		torrent := db.Torrent{
+			Release:    release,
+			Uploaded:   r.group.Added,
+			UploadedBy: db.User{ID: u.ID},
+			InfoHash:   [20]byte{byte(i)},
+			Info:       []byte("d4:name4:teste"),
+			Format:     r.format,
+			Size:       100,
+			LeechType:  r.leechType,
+		}
+		err = d.InsertTorrent(ctx, &torrent)
+		require.Nil(t, err)
+	}
+
+	ids := func(groups []db.ReleaseGroup) []int {
+		res := make([]int, 0, len(groups))
+		for _, g := range groups {
+			res = append(res, g.ID)
+		}
+		return res
+	}
+
+	flacVinyl := db.ReleaseGroupReleases(db.And(
+		db.Eq(db.ReleaseMediumSelector(), 2),
+		db.ReleaseTorrents(db.In(db.TorrentFormatSelector(), 0, 1)),
+	))
+
+	tests := []struct {
+		name     string
+		b        db.Boolean
+		expected []int
+	}{
+		{
+			name: "FLAC vinyl releases of house albums from 1990-1999",
+			b: db.And(
+				db.And(
+					db.Eq(db.ReleaseGroupTypeSelector(), 0),
+					db.ReleaseGroupHasTag("house"),
+				),
+				db.And(
+					db.And(
+						db.Gte(db.ReleaseGroupReleaseDateSelector(), time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)),
+						db.Lt(db.ReleaseGroupReleaseDateSelector(), time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+					),
+					flacVinyl,
+				),
+			),
+			expected: []int{homework.ID},
+		},
+		{
+			name:     "FLAC vinyl releases",
+			b:        flacVinyl,
+			expected: []int{homework.ID},
+		},
+		{
+			name:     "FLAC vinyl releases of electronic albums",
+			b:        db.And(flacVinyl, db.ReleaseGroupHasTag("electronic")),
+			expected: []int{},
+		},
+		{
+			name:     "no CD release",
+			b:        db.Not(db.ReleaseGroupReleases(db.Eq(db.ReleaseMediumSelector(), 0))),
+			expected: []int{alive.ID},
+		},
+		{
+			name:     "release without catalogue number",
+			b:        db.ReleaseGroupReleases(db.IsNull(db.ReleaseCatalogueNumberSelector())),
+			expected: []int{homework.ID},
+		},
+		{
+			name:     "catalogue number pattern",
+			b:        db.ReleaseGroupReleases(db.ILike(db.ReleaseCatalogueNumberSelector(), "vir%")),
+			expected: []int{discovery.ID},
+		},
+		{
+			name:     "only releases with catalogue numbers",
+			b:        db.Not(db.ReleaseGroupReleases(db.IsNull(db.ReleaseCatalogueNumberSelector()))),
+			expected: []int{discovery.ID, alive.ID},
+		},
+		{
+			// NOT (NULL ILIKE '%') is NULL, not TRUE
+			name:     "NULL is neither like nor unlike",
+			b:        db.Not(db.ReleaseGroupReleases(db.Not(db.ILike(db.ReleaseCatalogueNumberSelector(), "%")))),
+			expected: []int{discovery.ID, homework.ID, alive.ID},
+		},
+		{
+			name:     "label",
+			b:        db.ReleaseGroupReleases(db.Eq(db.ReleaseRecordLabelSelector(), label.ID)),
+			expected: []int{discovery.ID, homework.ID},
+		},
+		{
+			name:     "freeleech",
+			b:        db.ReleaseGroupReleases(db.ReleaseTorrents(db.Eq(db.TorrentLeechTypeSelector(), 1))),
+			expected: []int{homework.ID},
+		},
+		{
+			name:     "artist name",
+			b:        db.ReleaseGroupArtists(db.ILike(db.ArtistNameSelector(), "DAFT%")),
+			expected: []int{discovery.ID, homework.ID, alive.ID},
+		},
+		{
+			name: "artist role",
+			b: db.ReleaseGroupArtists(db.And(
+				db.Eq(db.ArtistNameSelector(), "Daft Punk"),
+				db.Neq(db.ReleaseGroupArtistRoleSelector(), 0),
+			)),
+			expected: []int{},
+		},
+		{
+			name:     "tags",
+			b:        db.ReleaseGroupTags(db.In(db.ReleaseGroupTagSelector(), "electronic", "techno")),
+			expected: []int{discovery.ID},
+		},
+		{
+			name:     "name",
+			b:        db.Or(db.ILike(db.ReleaseGroupNameSelector(), "%WORK"), db.In(db.ReleaseGroupNameSelector())),
+			expected: []int{homework.ID},
+		},
+	}
+	for _, tt := range tests {
+		q := db.NewQuery(tt.b)
+		found, err := d.SearchReleaseGroups(ctx, q, 0, 10)
+		require.Nil(t, err, tt.name)
+		require.Equal(t, tt.expected, ids(found), tt.name)
+
+		count, err := d.CountReleaseGroups(ctx, q)
+		require.Nil(t, err, tt.name)
+		require.Equal(t, len(tt.expected), count, tt.name)
+	}
+
+	q := db.NewQuery(nil)
+	q.SetSorter(db.SortBy(
+		db.SortDescending(db.ReleaseGroupTypeSelector()),
+		db.SortAscending(db.ReleaseGroupReleaseDateSelector()),
+	))
+	found, err := d.SearchReleaseGroups(ctx, q, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []int{alive.ID, homework.ID, discovery.ID}, ids(found))
+
+	// facets
+	facets, err := d.GetReleaseGroupFacets(ctx, q)
+	require.Nil(t, err)
+	require.Equal(t, &db.ReleaseGroupFacets{
+		Types:      map[int]int{0: 2, 5: 1},
+		Decades:    map[int]int{1990: 1, 2000: 2},
+		Media:      map[int]int{0: 2, 2: 2},
+		Formats:    map[int]int{0: 2, 3: 2},
+		LeechTypes: map[int]int{0: 2, 1: 1},
+		Tags:       map[string]int{"house": 2, "electronic": 1},
+	}, facets)
+
+	facets, err = d.GetReleaseGroupFacets(ctx, db.NewQuery(flacVinyl))
+	require.Nil(t, err)
+	require.Equal(t, &db.ReleaseGroupFacets{
+		Types:      map[int]int{0: 1},
+		Decades:    map[int]int{1990: 1},
+		Media:      map[int]int{0: 1, 2: 1},
+		Formats:    map[int]int{0: 1, 3: 1},
+		LeechTypes: map[int]int{0: 1, 1: 1},
+		Tags:       map[string]int{"house": 1},
+	}, facets)
+
+	facets, err = d.GetReleaseGroupFacets(ctx, db.NewQuery(db.ReleaseGroupHasTag("techno")))
+	require.Nil(t, err)
+	require.Equal(t, db.NewReleaseGroupFacets(), facets)
+
+	_, err = d.GetReleaseGroupFacets(ctx, nil)
+	require.NotNil(t, err)
+}
+
+func testSearch(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	added := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	motorhead := db.Artist{
+		Name:    "Motörhead",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+		Tags:    []string{"metal", "rock"},
+	}
+	err := d.InsertArtist(ctx, &motorhead)
+	require.Nil(t, err)
+
+	daftPunk := db.Artist{
+		Name:    "Daft Punk",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+		Aliases: []db.ArtistAlias{{Alias: "Darlin'", Added: added, AddedBy: db.User{ID: u.ID}}},
+	}
+	err = d.InsertArtist(ctx, &daftPunk)
+	require.Nil(t, err)
+
+	var groups []db.ReleaseGroup
+	for _, g := range []struct {
+		name string
+		tags []string
+	}{
+		{"Motörhead", nil},
+		{"Ace of Spades", []string{"metal"}},
+		{"Rock 'n' Roll", nil},
+	} {
+		group := db.ReleaseGroup{
+			Name:        g.name,
+			ReleaseDate: added,
+			Added:       added,
+			AddedBy:     db.User{ID: u.ID},
+			Tags:        g.tags,
+			Artists:     []db.RoledArtist{{Role: 0, Artist: motorhead}},
+		}
+		err = d.InsertReleaseGroup(ctx, &group)
+		require.Nil(t, err)
+		groups = append(groups, group)
+	}
+
+	label := db.RecordLabel{
+		Name:    "Bronze Records",
+		AddedBy: db.User{ID: u.ID},
+	}
+	err = d.InsertRecordLabel(ctx, &label)
+	require.Nil(t, err)
+
+	type result struct {
+		Type      string
+		ID        int
+		Highlight string
+	}
+	search := func(q string, types []string, limit int) []result {
+		found, err := d.Search(ctx, q, types, limit)
+		require.Nil(t, err, q)
+
+		res := make([]result, 0, len(found))
+		for _, r := range found {
+			res = append(res, result{Type: r.Type, ID: r.ID, Highlight: r.Highlight})
+		}
+		return res
+	}
+
+	// diacritics are ignored, equal ranks are ordered by type
+	require.Equal(t, []result{
+		{db.SearchResultArtist, motorhead.ID, "<b>Motörhead</b>"},
+		{db.SearchResultReleaseGroup, groups[0].ID, "<b>Motörhead</b>"},
+	}, search("Motorhead", nil, 10))
+	require.Equal(t, search("Motorhead", nil, 10), search("MOTÖR", nil, 10))
+
+	// names rank higher than tags
+	require.Equal(t, []result{
+		{db.SearchResultReleaseGroup, groups[2].ID, "<b>Rock</b> 'n' Roll"},
+		{db.SearchResultArtist, motorhead.ID, "Motörhead"},
+	}, search("rock", nil, 10))
+
+	require.Equal(t, []result{
+		{db.SearchResultArtist, motorhead.ID, "Motörhead"},
+		{db.SearchResultReleaseGroup, groups[1].ID, "Ace of Spades"},
+	}, search("metal", nil, 10))
+
+	// all terms must match, the last one may be incomplete
+	require.Equal(t, []result{
+		{db.SearchResultReleaseGroup, groups[1].ID, "<b>Ace</b> of <b>Spades</b>"},
+	}, search("ace spa", nil, 10))
+	require.Empty(t, search("ace punk", nil, 10))
+
+	// aliases
+	require.Equal(t, []result{
+		{db.SearchResultArtist, daftPunk.ID, "Daft Punk"},
+	}, search("darlin", nil, 10))
+
+	require.Equal(t, []result{
+		{db.SearchResultRecordLabel, label.ID, "<b>Bronze</b> Records"},
+	}, search("bronze", nil, 10))
+
+	// types and limit
+	require.Equal(t, []result{
+		{db.SearchResultReleaseGroup, groups[0].ID, "<b>Motörhead</b>"},
+	}, search("motorhead", []string{db.SearchResultReleaseGroup, db.SearchResultRecordLabel}, 10))
+	require.Equal(t, []result{
+		{db.SearchResultArtist, motorhead.ID, "<b>Motörhead</b>"},
+	}, search("motorhead", nil, 1))
+
+	// nothing to search for
+	for _, q := range []string{"", " ", "&!:*"} {
+		_, err = d.Search(ctx, q, nil, 10)
+		require.NotNil(t, err, q)
+	}
+
+	_, err = d.Search(ctx, "motorhead", nil, 0)
+	require.NotNil(t, err)
+}
+
+// insertRelease inserts a release group, a record label and a release.
+func insertRelease(t *testing.T, d db.BoilingDB, u *db.User) db.Release {
+	ctx := context.Background()
+	added := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	group := db.ReleaseGroup{
+		Name:        "Discovery",
+		ReleaseDate: time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC),
+		Added:       added,
+		AddedBy:     db.User{ID: u.ID},
+	}
+	err := d.InsertReleaseGroup(ctx, &group)
+	require.Nil(t, err)
+
+	label := db.RecordLabel{
+		Name:    "Virgin",
+		AddedBy: db.User{ID: u.ID},
+	}
+	err = d.InsertRecordLabel(ctx, &label)
+	require.Nil(t, err)
+
+	release := db.Release{
+		ReleaseGroup: group,
+		Edition:      sql.NullString{String: "Some edition"},
+		Medium:       0,
+		ReleaseDate:  time.Date(2001, 3, 12, 15, 30, 0, 0, time.UTC),
+		RecordLabel:  label,
+		Added:        added,
+		AddedBy:      db.User{ID: u.ID},
+		Original:     true,
+		Tags:         []string{"remaster", "japan"},
+		Properties:   map[string]string{"LossyWebApproved": "yes"},
+	}
+	err = d.InsertRelease(ctx, &release)
+	require.Nil(t, err)
+
+	return release
+}
+
+func testReleases(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	edit := db.Edit{Edited: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), EditedBy: db.User{ID: u.ID}}
+	release := insertRelease(t, d, u)
+
+	got, err := d.GetRelease(ctx, release.ID)
+	require.Nil(t, err)
+	require.Equal(t, release.ReleaseGroup.ID, got.ReleaseGroup.ID)
+	require.Equal(t, "Discovery", got.ReleaseGroup.Name)
+	require.True(t, got.Edition.Valid)
+	require.Equal(t, "Some edition", got.Edition.String)
+	require.False(t, got.CatalogueNumber.Valid)
+	require.Equal(t, 0, got.Medium)
+	require.True(t, time.Date(2001, 3, 12, 0, 0, 0, 0, time.UTC).Equal(got.ReleaseDate))
+	require.Equal(t, release.RecordLabel.ID, got.RecordLabel.ID)
+	require.Equal(t, "Virgin", got.RecordLabel.Name)
+	require.True(t, release.Added.Equal(got.Added))
+	require.Equal(t, u.ID, got.AddedBy.ID)
+	require.Equal(t, u.Username, got.AddedBy.Username)
+	require.True(t, got.Original)
+	require.Equal(t, []string{"japan", "remaster"}, sorted(got.Tags))
+	require.Equal(t, map[string]string{"LossyWebApproved": "yes"}, got.Properties)
+
+	_, err = d.GetRelease(ctx, release.ID+100)
+	require.NotNil(t, err)
+
+	err = d.SetReleaseProperty(ctx, release.ID, "CassetteApproved", "", edit)
+	require.Nil(t, err)
+	err = d.SetReleaseProperty(ctx, release.ID, "LossyWebApproved", "no", edit)
+	require.Nil(t, err)
+
+	err = d.SetReleaseProperty(ctx, release.ID, "UnknownProperty", "", edit)
+	require.NotNil(t, err)
+
+	err = d.SetReleaseProperty(ctx, release.ID+100, "CassetteApproved", "", edit)
+	require.NotNil(t, err)
+
+	got, err = d.GetRelease(ctx, release.ID)
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"LossyWebApproved": "no", "CassetteApproved": ""}, got.Properties)
+
+	tags, err := d.AutocompleteReleaseTags(ctx, "emas")
+	require.Nil(t, err)
+	require.Equal(t, []string{"remaster"}, tags)
+
+	group := release.ReleaseGroup
+	group.Releases = nil
+	err = d.PopulateReleases(ctx, &group)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(group.Releases))
+	require.Equal(t, release.ID, group.Releases[0].ID)
+	require.Equal(t, "Virgin", group.Releases[0].RecordLabel.Name)
+	require.Equal(t, []string{"japan", "remaster"}, sorted(group.Releases[0].Tags))
+	require.Equal(t, 2, len(group.Releases[0].Properties))
+
+	invalid := release
+	invalid.Properties = map[string]string{"UnknownProperty": ""}
+	err = d.InsertRelease(ctx, &invalid)
+	require.NotNil(t, err)
+
+	invalid = release
+	invalid.Medium = 100
+	err = d.InsertRelease(ctx, &invalid)
+	require.NotNil(t, err)
+
+	invalid = release
+	invalid.Tags = []string{"a", "a"}
+	err = d.InsertRelease(ctx, &invalid)
+	require.NotNil(t, err)
+
+	err = d.DeleteRelease(ctx, release.ID)
+	require.Nil(t, err)
+
+	_, err = d.GetRelease(ctx, release.ID)
+	require.NotNil(t, err)
+
+	// unused release tags are removed
+	tags, err = d.AutocompleteReleaseTags(ctx, "emas")
+	require.Nil(t, err)
+	require.Empty(t, tags)
+
+	err = d.DeleteRelease(ctx, release.ID)
+	require.NotNil(t, err)
+}
+
+func testUpdateReleases(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	edit := db.Edit{Edited: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), EditedBy: db.User{ID: u.ID}}
+	release := insertRelease(t, d, u)
+
+	label := db.RecordLabel{
+		Name:    "Daft Life",
+		AddedBy: db.User{ID: u.ID},
+	}
+	err := d.InsertRecordLabel(ctx, &label)
+	require.Nil(t, err)
+
+	update := release
+	update.Edition = sql.NullString{}
+	update.CatalogueNumber = sql.NullString{String: "VJCP-68300"}
+	update.Medium = 2
+	update.ReleaseDate = time.Date(2001, 3, 13, 0, 0, 0, 0, time.UTC)
+	update.RecordLabel = label
+	update.Original = false
+	update.Tags = []string{"japan", "vinyl"}
+	err = d.UpdateRelease(ctx, update, edit)
+	require.Nil(t, err)
+
+	got, err := d.GetRelease(ctx, release.ID)
+	require.Nil(t, err)
+	require.Equal(t, release.ReleaseGroup.ID, got.ReleaseGroup.ID)
+	require.False(t, got.Edition.Valid)
+	require.Equal(t, "VJCP-68300", got.CatalogueNumber.String)
+	require.Equal(t, 2, got.Medium)
+	require.True(t, update.ReleaseDate.Equal(got.ReleaseDate))
+	require.Equal(t, "Daft Life", got.RecordLabel.Name)
+	require.False(t, got.Original)
+	require.Equal(t, []string{"japan", "vinyl"}, sorted(got.Tags))
+	require.Equal(t, map[string]string{"LossyWebApproved": "yes"}, got.Properties)
+
+	tags, err := d.AutocompleteReleaseTags(ctx, "remaster")
+	require.Nil(t, err)
+	require.Empty(t, tags)
+
+	invalid := update
+	invalid.Medium = 100
+	err = d.UpdateRelease(ctx, invalid, edit)
+	require.NotNil(t, err)
+
+	invalid = update
+	invalid.RecordLabel = db.RecordLabel{ID: label.ID + 100}
+	err = d.UpdateRelease(ctx, invalid, edit)
+	require.NotNil(t, err)
+
+	invalid = update
+	invalid.ID = release.ID + 100
+	err = d.UpdateRelease(ctx, invalid, edit)
+	require.NotNil(t, err)
+}
+
+func insertTorrent(t *testing.T, d db.BoilingDB, u *db.User, release db.Release, hash byte, leechType int) db.Torrent {
+	torrent := db.Torrent{
+		Release:     release,
+		Uploaded:    time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC),
+		UploadedBy:  db.User{ID: u.ID},
+		InfoHash:    [20]byte{hash},
+		Info:        []byte("d4:name4:testf"),
+		Format:      0,
+		Size:        300,
+		Description: sql.NullString{String: "Some description"},
+		LeechType:   leechType,
+		FileList: []db.TorrentFile{
+			{Path: "b.flac", Size: 200},
+			{Path: "a.flac", Size: 100},
+		},
+	}
+	err := d.InsertTorrent(context.Background(), &torrent)
+	require.Nil(t, err)
+
+	return torrent
+}
+
+func testTorrents(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	release := insertRelease(t, d, u)
+	first := insertTorrent(t, d, u, release, 1, 1)
+	second := insertTorrent(t, d, u, release, 2, 0)
+
+	got, err := d.GetTorrent(ctx, first.ID)
+	require.Nil(t, err)
+	require.Equal(t, first.ID, got.ID)
+	require.Equal(t, release.ID, got.Release.ID)
+	require.True(t, first.Uploaded.Equal(got.Uploaded))
+	require.Equal(t, u.ID, got.UploadedBy.ID)
+	require.Equal(t, u.Username, got.UploadedBy.Username)
+	require.Equal(t, first.InfoHash, got.InfoHash)
+	require.Nil(t, got.Info)
+	require.Equal(t, 0, got.Format)
+	require.Equal(t, int64(300), got.Size)
+	require.Equal(t, "Some description", got.Description.String)
+	require.Equal(t, 1, got.LeechType)
+	require.Equal(t, 0, got.Seeders)
+	require.Equal(t, 0, got.Leechers)
+	require.Equal(t, 0, got.Snatches)
+	require.Equal(t, first.FileList, got.FileList)
+
+	got, err = d.GetTorrentByInfoHash(ctx, second.InfoHash)
+	require.Nil(t, err)
+	require.Equal(t, second.ID, got.ID)
+	require.Empty(t, got.FileList)
+
+	_, err = d.GetTorrentByInfoHash(ctx, [20]byte{3})
+	require.NotNil(t, err)
+
+	info, err := d.GetTorrentInfo(ctx, first.ID)
+	require.Nil(t, err)
+	require.Equal(t, first.Info, info)
+
+	_, err = d.GetTorrentInfo(ctx, second.ID+100)
+	require.NotNil(t, err)
+
+	r := db.Release{ID: release.ID}
+	err = d.PopulateTorrents(ctx, &r)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(r.Torrents))
+	require.Equal(t, first.ID, r.Torrents[0].ID)
+	require.Equal(t, second.ID, r.Torrents[1].ID)
+	require.Equal(t, second.FileList, r.Torrents[1].FileList)
+
+	duplicate := first
+	err = d.InsertTorrent(ctx, &duplicate)
+	require.NotNil(t, err)
+
+	invalid := first
+	invalid.InfoHash = [20]byte{4}
+	invalid.Release = db.Release{ID: release.ID + 100}
+	err = d.InsertTorrent(ctx, &invalid)
+	require.NotNil(t, err)
+
+	invalid = first
+	invalid.InfoHash = [20]byte{5}
+	invalid.Info = nil
+	err = d.InsertTorrent(ctx, &invalid)
+	require.NotNil(t, err)
+
+	// the release still has torrents
+	err = d.DeleteRelease(ctx, release.ID)
+	require.NotNil(t, err)
+
+	err = d.DeleteTorrent(ctx, first.ID)
+	require.Nil(t, err)
+
+	_, err = d.GetTorrent(ctx, first.ID)
+	require.NotNil(t, err)
+
+	err = d.DeleteTorrent(ctx, first.ID)
+	require.NotNil(t, err)
+
+	r = db.Release{ID: release.ID}
+	err = d.PopulateTorrents(ctx, &r)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(r.Torrents))
+}
+
+func testAnnounces(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	release := insertRelease(t, d, u)
+	doubleUp := insertTorrent(t, d, u, release, 1, 3)
+	normal := insertTorrent(t, d, u, release, 2, 0)
+
+	passkey, err := d.GenerateNewPasskeyForUser(ctx, u.ID)
+	require.Nil(t, err)
+
+	// a monday
+	reportedAt := time.Date(2017, 10, 2, 10, 15, 0, 0, time.UTC)
+
+	recorded, err := d.RecordAnnounces(ctx, []db.Announce{
+		{
+			Passkey:    passkey,
+			InfoHash:   doubleUp.InfoHash,
+			Uploaded:   100,
+			Downloaded: 50,
+			Left:       10,
+			Event:      db.AnnounceEventStarted,
+			ReportedAt: reportedAt,
+		},
+		{
+			Passkey:    "unknown",
+			InfoHash:   doubleUp.InfoHash,
+			Uploaded:   100,
+			ReportedAt: reportedAt,
+		},
+		{
+			Passkey:    passkey,
+			InfoHash:   [20]byte{3},
+			Uploaded:   100,
+			ReportedAt: reportedAt,
+		},
+		{
+			Passkey:    passkey,
+			InfoHash:   normal.InfoHash,
+			Uploaded:   10,
+			Downloaded: 20,
+			Event:      db.AnnounceEventCompleted,
+			ReportedAt: reportedAt.Add(2 * time.Hour),
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, recorded)
+
+	got, err := d.GetUser(ctx, u.ID)
+	require.Nil(t, err)
+	require.Equal(t, int64(210), got.Uploaded)
+	require.Equal(t, int64(70), got.Downloaded)
+
+	torrent, err := d.GetTorrent(ctx, doubleUp.ID)
+	require.Nil(t, err)
+	require.Equal(t, 0, torrent.Seeders)
+	require.Equal(t, 1, torrent.Leechers)
+	require.Equal(t, int64(100), torrent.TotalUploaded)
+	require.Equal(t, int64(50), torrent.TotalDownloaded)
+
+	// leechers never drop below zero
+	torrent, err = d.GetTorrent(ctx, normal.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, torrent.Seeders)
+	require.Equal(t, 0, torrent.Leechers)
+	require.Equal(t, 1, torrent.Snatches)
+
+	for _, a := range []db.Announce{
+		{Passkey: "", InfoHash: normal.InfoHash},
+		{Passkey: passkey, InfoHash: normal.InfoHash, Uploaded: -1},
+		{Passkey: passkey, InfoHash: normal.InfoHash, Event: "paused"},
+	} {
+		_, err = d.RecordAnnounces(ctx, []db.Announce{a})
+		require.NotNil(t, err)
+	}
+
+	from, to := reportedAt.Add(-24*time.Hour), reportedAt.Add(24*time.Hour)
+
+	points, err := d.GetUserStatHistory(ctx, u.ID, from, to, db.StatBucketHour)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(points))
+	require.True(t, time.Date(2017, 10, 2, 10, 0, 0, 0, time.UTC).Equal(points[0].Bucket))
+	require.Equal(t, int64(200), points[0].Uploaded)
+	require.Equal(t, int64(100), points[0].RawUploaded)
+	require.Equal(t, int64(50), points[0].Downloaded)
+	require.True(t, time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC).Equal(points[1].Bucket))
+
+	points, err = d.GetUserStatHistory(ctx, u.ID, from, to, db.StatBucketWeek)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(points))
+	require.True(t, time.Date(2017, 10, 2, 0, 0, 0, 0, time.UTC).Equal(points[0].Bucket))
+	require.Equal(t, int64(210), points[0].Uploaded)
+	require.Equal(t, int64(110), points[0].RawUploaded)
+	require.Equal(t, int64(70), points[0].Downloaded)
+	require.Equal(t, int64(70), points[0].RawDownloaded)
+
+	points, err = d.GetUserStatHistory(ctx, u.ID, from, to, db.StatBucketMonth)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(points))
+	require.True(t, time.Date(2017, 10, 1, 0, 0, 0, 0, time.UTC).Equal(points[0].Bucket))
+
+	// to is exclusive
+	points, err = d.GetUserStatHistory(ctx, u.ID, from, reportedAt.Add(2*time.Hour), db.StatBucketDay)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(points))
+	require.Equal(t, int64(200), points[0].Uploaded)
+
+	_, err = d.GetUserStatHistory(ctx, u.ID, from, to, "year")
+	require.NotNil(t, err)
+
+	_, err = d.GetUserStatHistory(ctx, u.ID, to, from, db.StatBucketDay)
+	require.NotNil(t, err)
+
+	stats, err := d.GetUserTorrentStats(ctx, u.ID, from, to)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(stats))
+	require.Equal(t, normal.ID, *stats[0].Torrent)
+	require.Equal(t, int64(10), stats[0].Uploaded)
+	require.Equal(t, 1, stats[0].Announces)
+	require.Equal(t, doubleUp.ID, *stats[1].Torrent)
+	require.Equal(t, int64(200), stats[1].Uploaded)
+	require.Equal(t, int64(100), stats[1].RawUploaded)
+	require.True(t, reportedAt.Equal(stats[1].FirstAnnounce))
+	require.True(t, reportedAt.Equal(stats[1].LastAnnounce))
+
+	// deleting a torrent keeps its stat changes, without the torrent
+	err = d.DeleteTorrent(ctx, normal.ID)
+	require.Nil(t, err)
+
+	stats, err = d.GetUserTorrentStats(ctx, u.ID, from, to)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(stats))
+	require.Nil(t, stats[0].Torrent)
+	require.Equal(t, int64(10), stats[0].Uploaded)
+	require.Equal(t, int64(20), stats[0].Downloaded)
+	require.Equal(t, doubleUp.ID, *stats[1].Torrent)
+
+	points, err = d.GetUserStatHistory(ctx, u.ID, from, to, db.StatBucketWeek)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(points))
+	require.Equal(t, int64(210), points[0].Uploaded)
+	require.Equal(t, int64(70), points[0].Downloaded)
+}
+
+func testRevisions(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	added := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+	edited := added.Add(24 * time.Hour)
+	edit := db.Edit{Edited: edited, EditedBy: db.User{ID: u.ID}}
+
+	artist := db.Artist{
+		Name:    "Daft Punk",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+		Tags:    []string{"electronic"},
+	}
+	err := d.InsertArtist(ctx, &artist)
+	require.Nil(t, err)
+
+	// inserting an entity records its first revision
+	first, err := d.GetRevision(ctx, db.RevisionArtist, artist.ID, 1)
+	require.Nil(t, err)
+	require.Equal(t, db.RevisionArtist, first.EntityType)
+	require.Equal(t, artist.ID, first.Entity)
+	require.Equal(t, 1, first.Revision)
+	require.JSONEq(t, `{"name":"Daft Punk","tags":["electronic"]}`, string(first.Snapshot))
+	require.JSONEq(t, `{"name":{"new":"Daft Punk"},"tags":{"new":["electronic"]}}`, string(first.Diff))
+	require.True(t, added.Equal(first.Edited))
+	require.Equal(t, u.ID, first.EditedBy.ID)
+	require.Equal(t, "someuser", first.EditedBy.Username)
+
+	artist.Bio = sql.NullString{String: "French duo"}
+	err = d.UpdateArtist(ctx, artist, edit)
+	require.Nil(t, err)
+
+	second, err := d.GetRevision(ctx, db.RevisionArtist, artist.ID, 2)
+	require.Nil(t, err)
+	require.NotEqual(t, first.ID, second.ID)
+	require.JSONEq(t, `{"name":"Daft Punk","bio":"French duo","tags":["electronic"]}`, string(second.Snapshot))
+	require.JSONEq(t, `{"bio":{"new":"French duo"}}`, string(second.Diff))
+	require.True(t, edited.Equal(second.Edited))
+
+	artist.Bio = sql.NullString{}
+	err = d.UpdateArtist(ctx, artist, edit)
+	require.Nil(t, err)
+	err = d.AddArtistTags(ctx, artist.ID, []string{"house"}, edit)
+	require.Nil(t, err)
+
+	// failed edits record nothing
+	err = d.RemoveArtistTag(ctx, artist.ID, "techno", edit)
+	require.NotNil(t, err)
+	err = d.UpdateArtist(ctx, artist, db.Edit{Edited: edited, EditedBy: db.User{ID: u.ID + 100}})
+	require.NotNil(t, err)
+
+	count, err := d.CountRevisions(ctx, db.RevisionArtist, artist.ID)
+	require.Nil(t, err)
+	require.Equal(t, 4, count)
+
+	latest, err := d.GetRevision(ctx, db.RevisionArtist, artist.ID, 4)
+	require.Nil(t, err)
+	require.JSONEq(t, `{"tags":{"old":["electronic"],"new":["electronic","house"]}}`, string(latest.Diff))
+
+	_, err = d.GetRevision(ctx, db.RevisionArtist, artist.ID, 5)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	// revisions count per entity and entity type
+	label := db.RecordLabel{Name: "Virgin", AddedBy: db.User{ID: u.ID}}
+	err = d.InsertRecordLabel(ctx, &label)
+	require.Nil(t, err)
+
+	got, err := d.GetRevision(ctx, db.RevisionRecordLabel, label.ID, 1)
+	require.Nil(t, err)
+	require.JSONEq(t, `{"name":"Virgin"}`, string(got.Snapshot))
+	require.True(t, label.Added.Equal(got.Edited))
+
+	_, err = d.GetRevision(ctx, db.RevisionRecordLabel, label.ID, 2)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	revisions, err := d.GetRevisions(ctx, db.RevisionArtist, artist.ID, 0, 2)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(revisions))
+	require.Equal(t, 4, revisions[0].Revision)
+	require.Equal(t, 3, revisions[1].Revision)
+
+	revisions, err = d.GetRevisions(ctx, db.RevisionArtist, artist.ID, 2, 2)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(revisions))
+	require.Equal(t, first.ID, revisions[1].ID)
+
+	revisions, err = d.GetRevisions(ctx, db.RevisionArtist, artist.ID, 4, 2)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(revisions))
+
+	revisions, err = d.GetRevisionsAfter(ctx, db.RevisionArtist, artist.ID, 4, 2)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(revisions))
+	require.Equal(t, 3, revisions[0].Revision)
+	require.Equal(t, 2, revisions[1].Revision)
+
+	revisions, err = d.GetRevisionsAfter(ctx, db.RevisionArtist, artist.ID, 2, 2)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(revisions))
+	require.Equal(t, first.ID, revisions[0].ID)
+
+	revisions, err = d.GetRevisionsAfter(ctx, db.RevisionArtist, artist.ID, 1, 2)
+	require.Nil(t, err)
+	require.Empty(t, revisions)
+
+	revisions, err = d.GetRevisionsBefore(ctx, db.RevisionArtist, artist.ID, 1, 2)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(revisions))
+	require.Equal(t, 3, revisions[0].Revision)
+	require.Equal(t, 2, revisions[1].Revision)
+
+	revisions, err = d.GetRevisionsBefore(ctx, db.RevisionArtist, artist.ID, 3, 2)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(revisions))
+	require.Equal(t, 4, revisions[0].Revision)
+
+	revisions, err = d.GetRevisionsBefore(ctx, db.RevisionArtist, artist.ID, 4, 2)
+	require.Nil(t, err)
+	require.Empty(t, revisions)
+
+	_, err = d.GetRevisionsAfter(ctx, db.RevisionArtist, artist.ID, 4, 0)
+	require.NotNil(t, err)
+
+	count, err = d.CountRevisions(ctx, db.RevisionRelease, artist.ID)
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+
+	_, err = d.GetRevisions(ctx, "blog", artist.ID, 0, 2)
+	require.NotNil(t, err)
+
+	err = d.RevertRevision(ctx, "blog", artist.ID, 1, edit)
+	require.NotNil(t, err)
+}
+
+func testRevertRevisions(t *testing.T, d db.BoilingDB) {
+	ctx := context.Background()
+
+	u := signUp(t, d, "someuser")
+	added := time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+	edited := added.Add(24 * time.Hour)
+	edit := db.Edit{Edited: edited, EditedBy: db.User{ID: u.ID}}
+
+	// artists
+	artist := db.Artist{
+		Name:    "Daft Punk",
+		Added:   added,
+		AddedBy: db.User{ID: u.ID},
+		Aliases: []db.ArtistAlias{{Alias: "Darlin'", Added: added, AddedBy: db.User{ID: u.ID}}},
+		Tags:    []string{"electronic"},
+	}
+	err := d.InsertArtist(ctx, &artist)
+	require.Nil(t, err)
+
+	update := artist
+	update.Name = "Daft Punk!"
+	update.Bio = sql.NullString{String: "French duo"}
+	err = d.UpdateArtist(ctx, update, edit)
+	require.Nil(t, err)
+	err = d.AddArtistAlias(ctx, artist.ID, db.ArtistAlias{Alias: "Daft", Added: edited, AddedBy: db.User{ID: u.ID}})
+	require.Nil(t, err)
+	err = d.RemoveArtistAlias(ctx, artist.ID, "Darlin'", edit)
+	require.Nil(t, err)
+	err = d.AddArtistTags(ctx, artist.ID, []string{"house"}, edit)
+	require.Nil(t, err)
+	err = d.RemoveArtistTag(ctx, artist.ID, "electronic", edit)
+	require.Nil(t, err)
+
+	err = d.RevertRevision(ctx, db.RevisionArtist, artist.ID, 1, edit)
+	require.Nil(t, err)
+
+	gotArtist, err := d.GetArtist(ctx, artist.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Daft Punk", gotArtist.Name)
+	require.False(t, gotArtist.Bio.Valid)
+	require.Equal(t, []string{"electronic"}, gotArtist.Tags)
+	require.Equal(t, 1, len(gotArtist.Aliases))
+	require.Equal(t, "Darlin'", gotArtist.Aliases[0].Alias)
+	require.True(t, edited.Equal(gotArtist.Aliases[0].Added))
+
+	// the revert is recorded
+	count, err := d.CountRevisions(ctx, db.RevisionArtist, artist.ID)
+	require.Nil(t, err)
+	require.Equal(t, 7, count)
+
+	first, err := d.GetRevision(ctx, db.RevisionArtist, artist.ID, 1)
+	require.Nil(t, err)
+	reverted, err := d.GetRevision(ctx, db.RevisionArtist, artist.ID, 7)
+	require.Nil(t, err)
+	require.JSONEq(t, string(first.Snapshot), string(reverted.Snapshot))
+	require.True(t, edited.Equal(reverted.Edited))
+
+	err = d.RevertRevision(ctx, db.RevisionArtist, artist.ID, 8, edit)
+	require.Equal(t, sql.ErrNoRows, err)
+	err = d.RevertRevision(ctx, db.RevisionArtist, artist.ID+100, 1, edit)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	count, err = d.CountRevisions(ctx, db.RevisionArtist, artist.ID)
+	require.Nil(t, err)
+	require.Equal(t, 7, count)
+
+	// release groups, credits of merged artists go to the artist they were
+	// merged into
+	dupe := db.Artist{Name: "Daft Punk (FR)", Added: added, AddedBy: db.User{ID: u.ID}}
+	err = d.InsertArtist(ctx, &dupe)
+	require.Nil(t, err)
+
+	group := db.ReleaseGroup{
+		Name:        "Homework",
+		ReleaseDate: time.Date(1997, 1, 20, 0, 0, 0, 0, time.UTC),
+		Added:       added,
+		AddedBy:     db.User{ID: u.ID},
+		Artists:     []db.RoledArtist{{Role: 0, Artist: dupe}, {Role: 0, Artist: artist}},
+		Tags:        []string{"house"},
+	}
+	err = d.InsertReleaseGroup(ctx, &group)
+	require.Nil(t, err)
+
+	updateGroup := group
+	updateGroup.Name = "Homework!"
+	updateGroup.Type = 5
+	updateGroup.Tags = nil
+	err = d.UpdateReleaseGroup(ctx, updateGroup, edit)
+	require.Nil(t, err)
+
+	err = d.MergeArtists(ctx, db.ArtistRedirect{Artist: dupe.ID, Target: artist.ID, Merged: edited, MergedBy: db.User{ID: u.ID}})
+	require.Nil(t, err)
+
+	err = d.RevertRevision(ctx, db.RevisionReleaseGroup, group.ID, 1, edit)
+	require.Nil(t, err)
+
+	gotGroup, err := d.GetReleaseGroup(ctx, group.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Homework", gotGroup.Name)
+	require.Equal(t, 0, gotGroup.Type)
+	require.Equal(t, []string{"house"}, gotGroup.Tags)
+	require.Equal(t, 1, len(gotGroup.Artists))
+	require.Equal(t, artist.ID, gotGroup.Artists[0].Artist.ID)
+	require.Equal(t, 0, gotGroup.Artists[0].Role)
+
+	// releases, properties set after the revision are kept
+	release := insertRelease(t, d, u)
+
+	updateRelease := release
+	updateRelease.Edition = sql.NullString{}
+	updateRelease.Tags = []string{"vinyl"}
+	err = d.UpdateRelease(ctx, updateRelease, edit)
+	require.Nil(t, err)
+	err = d.SetReleaseProperty(ctx, release.ID, "LossyWebApproved", "no", edit)
+	require.Nil(t, err)
+	err = d.SetReleaseProperty(ctx, release.ID, "CassetteApproved", "", edit)
+	require.Nil(t, err)
+
+	err = d.RevertRevision(ctx, db.RevisionRelease, release.ID, 1, edit)
+	require.Nil(t, err)
+
+	gotRelease, err := d.GetRelease(ctx, release.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Some edition", gotRelease.Edition.String)
+	require.Equal(t, []string{"japan", "remaster"}, sorted(gotRelease.Tags))
+	require.Equal(t, map[string]string{"LossyWebApproved": "yes", "CassetteApproved": ""}, gotRelease.Properties)
+
+	// record labels, a revert that fails records nothing
+	ninja := db.RecordLabel{Name: "Ninja Tune", AddedBy: db.User{ID: u.ID}}
+	err = d.InsertRecordLabel(ctx, &ninja)
+	require.Nil(t, err)
+	bigDada := db.RecordLabel{Name: "Big Dada", AddedBy: db.User{ID: u.ID}}
+	err = d.InsertRecordLabel(ctx, &bigDada)
+	require.Nil(t, err)
+
+	updateLabel := ninja
+	updateLabel.Name = "Ninja"
+	updateLabel.Description = sql.NullString{String: "Some description"}
+	updateLabel.Parent = &db.RecordLabel{ID: bigDada.ID}
+	err = d.UpdateRecordLabel(ctx, updateLabel, edit)
+	require.Nil(t, err)
+
+	err = d.RevertRevision(ctx, db.RevisionRecordLabel, ninja.ID, 1, edit)
+	require.Nil(t, err)
+
+	gotLabel, err := d.GetRecordLabel(ctx, ninja.ID)
+	require.Nil(t, err)
+	require.Equal(t, "Ninja Tune", gotLabel.Name)
+	require.False(t, gotLabel.Description.Valid)
+	require.Nil(t, gotLabel.Parent)
+
+	bigDada.Parent = &db.RecordLabel{ID: ninja.ID}
+	err = d.UpdateRecordLabel(ctx, bigDada, edit)
+	require.Nil(t, err)
+
+	err = d.RevertRevision(ctx, db.RevisionRecordLabel, ninja.ID, 2, edit)
+	require.NotNil(t, err)
+
+	count, err = d.CountRevisions(ctx, db.RevisionRecordLabel, ninja.ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, count)
+}
+
+func testCancelledContext(t *testing.T, d db.BoilingDB) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := d.GetUser(ctx, 0)
+	require.Equal(t, context.Canceled, err)
+
+	err = d.SignUpUser(ctx, "someuser", testPassword, "someuser@boiling.rip")
+	require.Equal(t, context.Canceled, err)
+
+	_, err = d.GetAllPrivileges(ctx)
+	require.Equal(t, context.Canceled, err)
+}
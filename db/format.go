@@ -1,12 +1,14 @@
 package db
 
+import "context"
+
 type Format struct {
 	Format   string
 	Encoding string
 }
 
-func (db *DB) GetAllFormats() (map[int]Format, error) {
-	rows, err := db.db.Query("SELECT id,format,encoding FROM formats")
+func (db *DB) GetAllFormats(ctx context.Context) (map[int]Format, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,format,encoding FROM formats")
 	if err != nil {
 		return nil, err
 	}
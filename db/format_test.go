@@ -1,16 +1,19 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetAllFormats(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	f, err := db.GetAllFormats()
+	f, err := db.GetAllFormats(ctx)
 	require.Nil(t, err)
 	require.NotNil(t, f)
 	require.NotEmpty(t, f)
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// Types of full-text search results.
+const (
+	SearchResultArtist       = "artist"
+	SearchResultReleaseGroup = "release_group"
+	SearchResultRecordLabel  = "record_label"
+)
+
+// SearchResultTypes are all types of full-text search results.
+var SearchResultTypes = []string{SearchResultArtist, SearchResultReleaseGroup, SearchResultRecordLabel}
+
+// SearchHighlightStart and SearchHighlightStop enclose the matched words in
+// the highlight of a SearchResult.
+const (
+	SearchHighlightStart = "<b>"
+	SearchHighlightStop  = "</b>"
+)
+
+type SearchResult struct {
+	Type      string
+	ID        int
+	Name      string
+	Highlight string // Name with the matched words highlighted
+	Rank      float64
+}
+
+// SearchTerms splits a full-text search query into its terms.
+// Everything but letters and digits separates terms, so the terms never
+// contain tsquery operators.
+func SearchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Search searches the names of artists, release groups and record labels,
+// the aliases of artists and the tags of artists and release groups.
+// Results must match all terms of q, the last word of a term may be
+// incomplete. Diacritics are ignored.
+// Results are ordered by rank, matches of names rank highest, then aliases,
+// then tags.
+// types restricts the results to the given types, all types are searched if
+// it is empty.
+func (db *DB) Search(ctx context.Context, q string, types []string, limit int) ([]SearchResult, error) {
+	terms := SearchTerms(q)
+	if len(terms) == 0 {
+		return nil, errors.New("missing q")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if len(types) == 0 {
+		types = SearchResultTypes
+	}
+
+	for i := range terms {
+		terms[i] += ":*"
+	}
+	tsquery := strings.Join(terms, " & ")
+
+	rows, err := db.db.QueryContext(ctx, "WITH query AS (SELECT to_tsquery('boiling_search', $1) AS q) SELECT type, id, name, highlight, rank FROM (SELECT 'artist' AS type, a.id, a.name, ts_headline('boiling_search', a.name, query.q, $2) AS highlight, ts_rank(a.search, query.q) AS rank FROM artists a, query WHERE a.search @@ query.q UNION ALL SELECT 'release_group', rg.id, rg.name, ts_headline('boiling_search', rg.name, query.q, $2), ts_rank(rg.search, query.q) FROM release_groups rg, query WHERE rg.search @@ query.q UNION ALL SELECT 'record_label', l.id, l.name, ts_headline('boiling_search', l.name, query.q, $2), ts_rank(l.search, query.q) FROM record_labels l, query WHERE l.search @@ query.q) results WHERE type = ANY($3) ORDER BY rank DESC, type ASC, id ASC LIMIT $4",
+		tsquery,
+		"StartSel="+SearchHighlightStart+", StopSel="+SearchHighlightStop+", HighlightAll=TRUE",
+		pq.Array(types),
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var tmp SearchResult
+		err = rows.Scan(
+			&tmp.Type,
+			&tmp.ID,
+			&tmp.Name,
+			&tmp.Highlight,
+			&tmp.Rank)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, tmp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
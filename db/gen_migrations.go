@@ -0,0 +1,108 @@
+// +build ignore
+
+// gen_migrations compiles the SQL files in the migrations directory into
+// migrations_gen.go.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type migration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+func main() {
+	files, err := ioutil.ReadDir("migrations")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, f := range files {
+		parts := fileName.FindStringSubmatch(f.Name())
+		if parts == nil {
+			log.Fatalf("invalid migration file name: %s", f.Name())
+		}
+
+		version, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if version <= 0 {
+			log.Fatalf("invalid migration version: %s", f.Name())
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join("migrations", f.Name()))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if strings.Contains(string(contents), "`") {
+			log.Fatalf("migration must not contain backticks: %s", f.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: parts[2]}
+			byVersion[version] = m
+		}
+		if m.name != parts[2] {
+			log.Fatalf("conflicting names for migration %d: %s and %s", version, m.name, parts[2])
+		}
+
+		if parts[3] == "up" {
+			m.up = string(contents)
+		} else {
+			m.down = string(contents)
+		}
+	}
+
+	var versions []int
+	for v, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			log.Fatalf("migration %d must have an up and a down file", v)
+		}
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "// Code generated by gen_migrations.go. DO NOT EDIT.")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "package db")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "var migrations = []Migration{")
+	for _, v := range versions {
+		m := byVersion[v]
+		fmt.Fprintln(buf, "{")
+		fmt.Fprintf(buf, "Version: %d,\n", m.version)
+		fmt.Fprintf(buf, "Name: %q,\n", m.name)
+		fmt.Fprintf(buf, "Up: `%s`,\n", m.up)
+		fmt.Fprintf(buf, "Down: `%s`,\n", m.down)
+		fmt.Fprintln(buf, "},")
+	}
+	fmt.Fprintln(buf, "}")
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = ioutil.WriteFile("migrations_gen.go", out, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+// An Invite is a one-time code to sign up with.
+type Invite struct {
+	ID        int
+	Code      string
+	CreatedBy User
+	CreatedAt time.Time
+
+	// UsedBy is the user who signed up with the invite, nil if it is unused.
+	UsedBy *User
+	UsedAt pq.NullTime
+}
+
+// inviteCodeLength defines the length of an invite code.
+// Note that this is the number of random bytes generated - they're then base16
+// encoded, so the string representation is actually 32 characters long.
+const inviteCodeLength = 16
+
+// UpdateUserSetInvites sets the number of invites a user can still issue.
+func (db *DB) UpdateUserSetInvites(ctx context.Context, id, invites int) error {
+	if id < 0 {
+		return errors.New("invalid id")
+	}
+	if invites < 0 {
+		return errors.New("invalid number of invites")
+	}
+
+	res, err := db.db.ExecContext(ctx, "UPDATE users SET invites = $1 WHERE id = $2", invites, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+func insertInviteTx(ctx context.Context, inv *Invite, tx *sql.Tx) error {
+	var invites int
+	err := tx.QueryRowContext(ctx, "SELECT invites FROM users WHERE id = $1 FOR UPDATE", inv.CreatedBy.ID).Scan(&invites)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("user not found")
+		}
+		return err
+	}
+	if invites < 1 {
+		return errors.New("no invites left")
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET invites = invites - 1 WHERE id = $1", inv.CreatedBy.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.QueryRowContext(ctx, "INSERT INTO invites(code,created_by,created_at) VALUES ($1,$2,$3) RETURNING id", inv.Code, inv.CreatedBy.ID, inv.CreatedAt).Scan(&inv.ID)
+}
+
+// InsertInvite issues an invite by inv.CreatedBy, which uses up one of their
+// invites.
+// It sets the ID and the code of inv.
+func (db *DB) InsertInvite(ctx context.Context, inv *Invite) error {
+	if inv.CreatedBy.ID < 0 {
+		return errors.New("invalid ID")
+	}
+
+	inv.Code = generateRandomKey(inviteCodeLength)
+	inv.UsedBy = nil
+	inv.UsedAt = pq.NullTime{}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = insertInviteTx(ctx, inv, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetInvitesByUser returns the invites issued by a user, newest first.
+func (db *DB) GetInvitesByUser(ctx context.Context, uid int) ([]Invite, error) {
+	if uid < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	rows, err := db.db.QueryContext(ctx, "SELECT i.id,i.code,i.created_at,i.used_by,u.username,i.used_at FROM invites i LEFT JOIN users u ON i.used_by = u.id WHERE i.created_by = $1 ORDER BY i.created_at DESC, i.id DESC", uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []Invite
+	for rows.Next() {
+		var (
+			inv        = Invite{CreatedBy: User{ID: uid}}
+			usedBy     sql.NullInt64
+			usedByName sql.NullString
+		)
+		err = rows.Scan(
+			&inv.ID,
+			&inv.Code,
+			&inv.CreatedAt,
+			&usedBy,
+			&usedByName,
+			&inv.UsedAt)
+		if err != nil {
+			return nil, err
+		}
+		if usedBy.Valid {
+			inv.UsedBy = &User{ID: int(usedBy.Int64), Username: usedByName.String}
+		}
+
+		invites = append(invites, inv)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+func signUpUserWithInviteTx(ctx context.Context, username, email string, pwHash []byte, code string, tx *sql.Tx) error {
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM invites WHERE code = $1 AND used_by IS NULL FOR UPDATE", code).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("invalid invite")
+		}
+		return err
+	}
+
+	var uid int
+	err = tx.QueryRowContext(ctx, "INSERT INTO users(username, email, password, enabled, can_login, joined_at) VALUES ($1,$2,$3,TRUE,TRUE,NOW()) RETURNING id", username, email, pwHash).Scan(&uid)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE invites SET used_by = $1, used_at = NOW() WHERE id = $2", uid, id)
+	return err
+}
+
+// SignUpUserWithInvite signs up a user like SignUpUser, using up the unused
+// invite with the given code.
+// The creator of the invite becomes the inviter of the user.
+func (db *DB) SignUpUserWithInvite(ctx context.Context, username, password, email, code string) error {
+	if len(code) == 0 {
+		return errors.New("missing invite")
+	}
+
+	pwHash, err := hashSignUpPassword(username, password, email)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = signUpUserWithInviteTx(ctx, username, email, pwHash, code, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// An InviteTreeNode is a user in the invite tree of another user.
+type InviteTreeNode struct {
+	// User is the invited user, only ID, Username, Enabled, JoinedAt and
+	// Class are set.
+	User User
+
+	// InvitedBy is the ID of the inviter of the user.
+	InvitedBy int
+
+	// Depth is the distance to the root of the tree, the users invited by
+	// the root have depth 1.
+	Depth int
+}
+
+// GetInviteTree returns all users invited by a user, directly or indirectly,
+// ordered by their depth and ID.
+// The user themselves is not included.
+func (db *DB) GetInviteTree(ctx context.Context, id int) ([]InviteTreeNode, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	rows, err := db.db.QueryContext(ctx, "WITH RECURSIVE tree(uid, invited_by, depth) AS (SELECT used_by, created_by, 1 FROM invites WHERE created_by = $1 AND used_by IS NOT NULL UNION ALL SELECT i.used_by, i.created_by, t.depth + 1 FROM invites i, tree t WHERE i.created_by = t.uid AND i.used_by IS NOT NULL) SELECT t.uid,t.invited_by,t.depth,u.username,u.enabled,u.joined_at,u.class FROM tree t, users u WHERE t.uid = u.id ORDER BY t.depth ASC, t.uid ASC", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []InviteTreeNode
+	for rows.Next() {
+		var n InviteTreeNode
+		err = rows.Scan(
+			&n.User.ID,
+			&n.InvitedBy,
+			&n.Depth,
+			&n.User.Username,
+			&n.User.Enabled,
+			&n.User.JoinedAt,
+			&n.User.Class)
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, n)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+func updateUsersDisableTx(ctx context.Context, ids []int, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "UPDATE users SET enabled = FALSE WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	// Tokens outlive logins, disabled users must not keep using theirs.
+	_, err = tx.ExecContext(ctx, "DELETE FROM api_tokens WHERE uid = ANY($1)", pq.Array(ids))
+	return err
+}
+
+// UpdateUsersDisable disables the users with the given IDs and deletes their
+// API tokens.
+// Disabled users can't log in, download or announce.
+func (db *DB) UpdateUsersDisable(ctx context.Context, ids []int) error {
+	for _, id := range ids {
+		if id < 0 {
+			return errors.New("invalid ID")
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = updateUsersDisableTx(ctx, ids, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
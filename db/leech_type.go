@@ -1,7 +1,9 @@
 package db
 
-func (db *DB) GetAllLeechTypes() (map[int]string, error) {
-	rows, err := db.db.Query("SELECT id,type FROM leech_types")
+import "context"
+
+func (db *DB) GetAllLeechTypes(ctx context.Context) (map[int]string, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,type FROM leech_types")
 	if err != nil {
 		return nil, err
 	}
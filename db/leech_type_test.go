@@ -1,16 +1,19 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetAllLeechTypes(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	types, err := db.GetAllLeechTypes()
+	types, err := db.GetAllLeechTypes(ctx)
 	require.Nil(t, err)
 	require.NotNil(t, types)
 	require.NotEmpty(t, types)
@@ -1,7 +1,9 @@
 package db
 
-func (db *DB) GetAllMedia() (map[int]string, error) {
-	rows, err := db.db.Query("SELECT id,medium FROM media")
+import "context"
+
+func (db *DB) GetAllMedia(ctx context.Context) (map[int]string, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,medium FROM media")
 	if err != nil {
 		return nil, err
 	}
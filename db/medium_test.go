@@ -1,16 +1,19 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetAllMedia(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	media, err := db.GetAllMedia()
+	media, err := db.GetAllMedia(ctx)
 	require.Nil(t, err)
 	require.NotNil(t, media)
 	require.NotEmpty(t, media)
@@ -0,0 +1,118 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type statChange struct {
+	uid             int
+	torrent         int // 0 once the torrent was deleted
+	reportedAt      time.Time
+	event           db.AnnounceEvent
+	rawUploaded     int64
+	rawDownloaded   int64
+	uploadedDelta   int64
+	downloadedDelta int64
+}
+
+// swarmDeltas mirrors the swarm counter updates of the postgres
+// implementation.
+func swarmDeltas(a db.Announce) (seeders, leechers, snatches int) {
+	switch a.Event {
+	case db.AnnounceEventStarted:
+		if a.Left == 0 {
+			return 1, 0, 0
+		}
+		return 0, 1, 0
+	case db.AnnounceEventCompleted:
+		return 1, -1, 1
+	case db.AnnounceEventStopped:
+		if a.Left == 0 {
+			return -1, 0, 0
+		}
+		return 0, -1, 0
+	}
+	return 0, 0, 0
+}
+
+func (d *DB) validPasskeyOwner(passkey string) (int, bool) {
+	for _, p := range d.passkeys {
+		if p.Passkey == passkey && p.Valid {
+			return p.Uid, true
+		}
+	}
+	return 0, false
+}
+
+func (d *DB) RecordAnnounces(ctx context.Context, announces []db.Announce) (int, error) {
+	for _, a := range announces {
+		if len(a.Passkey) == 0 {
+			return 0, errors.New("missing passkey")
+		}
+		if a.Uploaded < 0 || a.Downloaded < 0 || a.Left < 0 {
+			return 0, errors.New("negative amounts")
+		}
+		if !a.Event.Valid() {
+			return 0, errors.New("invalid event")
+		}
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer d.mu.Unlock()
+
+	var recorded int
+	for _, a := range announces {
+		uid, ok := d.validPasskeyOwner(a.Passkey)
+		if !ok {
+			continue
+		}
+		t, ok := d.torrentByInfoHash(a.InfoHash)
+		if !ok {
+			continue
+		}
+		lt := d.leechTypes[t.leechType]
+
+		up := int64(float64(a.Uploaded) * lt.upMultiplier)
+		down := int64(float64(a.Downloaded) * lt.downMultiplier)
+
+		d.statChanges = append(d.statChanges, statChange{
+			uid:             uid,
+			torrent:         t.id,
+			reportedAt:      timestamp(a.ReportedAt),
+			event:           a.Event,
+			rawUploaded:     a.Uploaded,
+			rawDownloaded:   a.Downloaded,
+			uploadedDelta:   up,
+			downloadedDelta: down,
+		})
+
+		u := d.users[uid]
+		u.Uploaded += up
+		u.Downloaded += down
+
+		seeders, leechers, snatches := swarmDeltas(a)
+		t.seeders = max(t.seeders+seeders, 0)
+		t.leechers = max(t.leechers+leechers, 0)
+		t.snatches += snatches
+		t.totalUploaded += a.Uploaded
+		t.totalDownloaded += a.Downloaded
+
+		recorded++
+	}
+
+	return recorded, nil
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,85 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type apiToken struct {
+	uid       int
+	createdAt time.Time
+}
+
+func (d *DB) InsertTokenForUser(ctx context.Context, u db.User) (*db.APIToken, error) {
+	err := d.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.Unlock()
+
+	err = d.checkUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := generateRandomKey(tokenLength)
+	if _, ok := d.tokens[s]; ok {
+		return nil, errors.New("unique violation: duplicate token")
+	}
+
+	t := apiToken{
+		uid:       u.ID,
+		createdAt: now(),
+	}
+	d.tokens[s] = t
+
+	return &db.APIToken{
+		Token:     s,
+		CreatedAt: t.createdAt,
+		User:      u,
+	}, nil
+}
+
+func (d *DB) GetToken(ctx context.Context, token string) (*db.APIToken, error) {
+	if len(token) == 0 {
+		return nil, errors.New("invalid token")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	t, ok := d.tokens[token]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	u, ok := d.users[t.uid]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &db.APIToken{
+		Token:     token,
+		CreatedAt: t.createdAt,
+		User: db.User{
+			ID:         u.ID,
+			Username:   u.Username,
+			Email:      u.Email,
+			LastLogin:  u.LastLogin,
+			LastAccess: u.LastAccess,
+			Enabled:    u.Enabled,
+			CanLogin:   u.CanLogin,
+			Uploaded:   u.Uploaded,
+			Downloaded: u.Downloaded,
+			Class:      u.Class,
+
+			RatioWatchUntil: u.RatioWatchUntil,
+		},
+	}, nil
+}
@@ -0,0 +1,491 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type artistRow struct {
+	id      int
+	name    string
+	bio     sql.NullString
+	added   time.Time
+	addedBy int
+	aliases []aliasRow
+	tags    []int
+}
+
+type aliasRow struct {
+	alias   string
+	added   time.Time
+	addedBy int
+}
+
+// artist returns the artist with its aliases and tags populated.
+func (d *DB) artist(a *artistRow) db.Artist {
+	res := db.Artist{
+		ID:      a.id,
+		Name:    a.name,
+		Bio:     a.bio,
+		Added:   a.added,
+		AddedBy: d.userRef(a.addedBy),
+		Tags:    tagValues(d.artistTags, a.tags),
+	}
+	for _, al := range a.aliases {
+		res.Aliases = append(res.Aliases, db.ArtistAlias{
+			Alias:   al.alias,
+			Added:   al.added,
+			AddedBy: d.userRef(al.addedBy),
+		})
+	}
+	return res
+}
+
+func (d *DB) artistIDs() []int {
+	var ids []int
+	for id := range d.artists {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (d *DB) AutocompleteArtists(ctx context.Context, s string) ([]db.Artist, error) {
+	if len(s) == 0 {
+		return nil, errors.New("missing s")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	re := contains(s, false)
+	var artists []db.Artist
+	for _, id := range d.artistIDs() {
+		a := d.artists[id]
+		match := re.MatchString(a.name)
+		for _, al := range a.aliases {
+			match = match || re.MatchString(al.alias)
+		}
+		if match {
+			artists = append(artists, d.artist(a))
+		}
+	}
+
+	return artists, nil
+}
+
+func (d *DB) AutocompleteArtistTags(ctx context.Context, s string) ([]string, error) {
+	if len(s) == 0 {
+		return nil, errors.New("missing s")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	return d.artistTags.match(contains(s, false)), nil
+}
+
+func (d *DB) GetArtist(ctx context.Context, id int) (*db.Artist, error) {
+	if id < 0 {
+		return nil, errors.New("invalid id")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	a, ok := d.artists[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	artist := d.artist(a)
+	return &artist, nil
+}
+
+func (d *DB) PopulateReleaseGroups(ctx context.Context, artist *db.Artist) error {
+	if artist.ID < 0 {
+		return errors.New("invalid artist ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.RUnlock()
+
+	for _, id := range d.releaseGroupIDs() {
+		g := d.releaseGroups[id]
+		for _, a := range g.artists {
+			if a.artist != artist.ID {
+				continue
+			}
+
+			artist.ReleaseGroups = append(artist.ReleaseGroups, db.RoledReleaseGroup{
+				Role: a.role,
+				ReleaseGroup: db.ReleaseGroup{
+					ID:          g.id,
+					Name:        g.name,
+					Type:        g.typ,
+					ReleaseDate: g.releaseDate,
+				},
+			})
+		}
+	}
+
+	return nil
+}
+
+func (d *DB) InsertArtist(ctx context.Context, artist *db.Artist) error {
+	if artist.AddedBy.ID < 0 {
+		return errors.New("invalid user ID")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	err = d.checkUser(artist.AddedBy.ID)
+	if err != nil {
+		return err
+	}
+	err = checkTags(artist.Tags)
+	if err != nil {
+		return err
+	}
+
+	aliases := make(map[string]struct{})
+	for _, a := range artist.Aliases {
+		if _, ok := aliases[a.Alias]; ok {
+			return fmt.Errorf("duplicate alias %q", a.Alias)
+		}
+		aliases[a.Alias] = struct{}{}
+
+		err = d.checkUser(a.AddedBy.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.artistSeq++
+	artist.ID = d.artistSeq
+
+	a := &artistRow{
+		id:      artist.ID,
+		name:    artist.Name,
+		bio:     nullString(artist.Bio),
+		added:   timestamp(artist.Added),
+		addedBy: artist.AddedBy.ID,
+	}
+	a.tags, _ = insertTags(d.artistTags, artist.Tags)
+	for _, al := range artist.Aliases {
+		a.aliases = append(a.aliases, aliasRow{
+			alias:   al.Alias,
+			added:   timestamp(al.Added),
+			addedBy: al.AddedBy.ID,
+		})
+	}
+	d.artists[a.id] = a
+
+	return d.recordRevision(db.RevisionArtist, a.id, db.Edit{Edited: artist.Added, EditedBy: artist.AddedBy})
+}
+
+func (d *DB) UpdateArtist(ctx context.Context, artist db.Artist, e db.Edit) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(db.RevisionArtist, artist.ID, e, func() error {
+		a := d.artists[artist.ID]
+		a.name = artist.Name
+		a.bio = nullString(artist.Bio)
+		return nil
+	})
+}
+
+func (d *DB) AddArtistAlias(ctx context.Context, artistID int, alias db.ArtistAlias) error {
+	if len(alias.Alias) == 0 {
+		return errors.New("missing alias")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(db.RevisionArtist, artistID, db.Edit{Edited: alias.Added, EditedBy: alias.AddedBy}, func() error {
+		a := d.artists[artistID]
+		for _, al := range a.aliases {
+			if al.alias == alias.Alias {
+				return fmt.Errorf("duplicate alias %q", alias.Alias)
+			}
+		}
+
+		a.aliases = append(a.aliases, aliasRow{
+			alias:   alias.Alias,
+			added:   timestamp(alias.Added),
+			addedBy: alias.AddedBy.ID,
+		})
+		return nil
+	})
+}
+
+func (d *DB) RemoveArtistAlias(ctx context.Context, artistID int, alias string, e db.Edit) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(db.RevisionArtist, artistID, e, func() error {
+		a := d.artists[artistID]
+		for i, al := range a.aliases {
+			if al.alias == alias {
+				a.aliases = append(a.aliases[:i], a.aliases[i+1:]...)
+				return nil
+			}
+		}
+		return errors.New("alias not found")
+	})
+}
+
+func (d *DB) AddArtistTags(ctx context.Context, artistID int, tags []string, e db.Edit) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(db.RevisionArtist, artistID, e, func() error {
+		a := d.artists[artistID]
+	outer:
+		for _, t := range tags {
+			id := d.artistTags.insert(t)
+			for _, existing := range a.tags {
+				if existing == id {
+					continue outer
+				}
+			}
+			a.tags = append(a.tags, id)
+		}
+		return nil
+	})
+}
+
+// pruneArtistTags removes artist tags that are not used by any artist anymore.
+func (d *DB) pruneArtistTags() {
+	d.artistTags.prune(func(id int) bool {
+		for _, a := range d.artists {
+			for _, t := range a.tags {
+				if t == id {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+func (d *DB) RemoveArtistTag(ctx context.Context, artistID int, tag string, e db.Edit) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(db.RevisionArtist, artistID, e, func() error {
+		a := d.artists[artistID]
+		id, ok := d.artistTags.lookUp(tag)
+		if !ok {
+			return errors.New("tag not found")
+		}
+		for i, t := range a.tags {
+			if t == id {
+				a.tags = append(a.tags[:i], a.tags[i+1:]...)
+				d.pruneArtistTags()
+				return nil
+			}
+		}
+		return errors.New("tag not found")
+	})
+}
+
+type artistRedirectRow struct {
+	target   int
+	merged   time.Time
+	mergedBy int
+}
+
+func (d *DB) MergeArtists(ctx context.Context, r db.ArtistRedirect) error {
+	if r.Artist < 0 || r.Target < 0 {
+		return errors.New("invalid ID")
+	}
+	if r.Artist == r.Target {
+		return errors.New("can not merge an artist into itself")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	target, ok := d.artists[r.Target]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	a, ok := d.artists[r.Artist]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	err = d.checkUser(r.MergedBy.ID)
+	if err != nil {
+		return err
+	}
+
+	// The merge is an edit of the target and of every release group whose
+	// credits are rewritten.
+	edit := db.Edit{Edited: r.Merged, EditedBy: r.MergedBy}
+	err = d.beginEdit(db.RevisionArtist, r.Target)
+	if err != nil {
+		return err
+	}
+	var groups []int
+	for _, id := range d.releaseGroupIDs() {
+		for _, ra := range d.releaseGroups[id].artists {
+			if ra.artist == r.Artist {
+				groups = append(groups, id)
+				break
+			}
+		}
+	}
+	for _, id := range groups {
+		err = d.beginEdit(db.RevisionReleaseGroup, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, g := range d.releaseGroups {
+		var artists []roledArtistRow
+		seen := make(map[roledArtistRow]struct{})
+		for _, ra := range g.artists {
+			if ra.artist == r.Artist {
+				ra.artist = r.Target
+			}
+			if _, ok := seen[ra]; ok {
+				continue
+			}
+			seen[ra] = struct{}{}
+			artists = append(artists, ra)
+		}
+		g.artists = artists
+	}
+
+	hasAlias := func(alias string) bool {
+		if alias == target.name {
+			return true
+		}
+		for _, al := range target.aliases {
+			if al.alias == alias {
+				return true
+			}
+		}
+		return false
+	}
+	for _, al := range a.aliases {
+		if !hasAlias(al.alias) {
+			target.aliases = append(target.aliases, al)
+		}
+	}
+	if !hasAlias(a.name) {
+		target.aliases = append(target.aliases, aliasRow{
+			alias:   a.name,
+			added:   timestamp(r.Merged),
+			addedBy: r.MergedBy.ID,
+		})
+	}
+
+outer:
+	for _, t := range a.tags {
+		for _, existing := range target.tags {
+			if existing == t {
+				continue outer
+			}
+		}
+		target.tags = append(target.tags, t)
+	}
+
+	if !target.bio.Valid {
+		target.bio = a.bio
+	}
+
+	for _, redirect := range d.artistRedirects {
+		if redirect.target == r.Artist {
+			redirect.target = r.Target
+		}
+	}
+	d.artistRedirects[r.Artist] = &artistRedirectRow{
+		target:   r.Target,
+		merged:   timestamp(r.Merged),
+		mergedBy: r.MergedBy.ID,
+	}
+
+	delete(d.artists, r.Artist)
+
+	err = d.recordRevision(db.RevisionArtist, r.Target, edit)
+	if err != nil {
+		return err
+	}
+	for _, id := range groups {
+		err = d.recordRevision(db.RevisionReleaseGroup, id, edit)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *DB) GetArtistRedirect(ctx context.Context, id int) (*db.ArtistRedirect, error) {
+	if id < 0 {
+		return nil, errors.New("invalid id")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	r, ok := d.artistRedirects[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &db.ArtistRedirect{
+		Artist:   id,
+		Target:   r.target,
+		Merged:   r.merged,
+		MergedBy: d.userRef(r.mergedBy),
+	}, nil
+}
@@ -0,0 +1,254 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type blogRow struct {
+	id       int
+	author   int
+	title    string
+	content  string
+	postedAt time.Time
+	tags     []int
+}
+
+func (d *DB) blogEntry(b *blogRow) db.BlogEntry {
+	return db.BlogEntry{
+		ID:       b.id,
+		Author:   d.userRef(b.author),
+		Title:    b.title,
+		Content:  b.content,
+		PostedAt: b.postedAt,
+		Tags:     tagValues(d.blogTags, b.tags),
+	}
+}
+
+// pruneBlogTags removes blog tags that are not used by any post anymore.
+func (d *DB) pruneBlogTags() {
+	d.blogTags.prune(func(id int) bool {
+		for _, b := range d.blogs {
+			for _, t := range b.tags {
+				if t == id {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+func (d *DB) InsertBlogEntry(ctx context.Context, post *db.BlogEntry) error {
+	if post == nil {
+		return errors.New("no post provided")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	err = d.checkUser(post.Author.ID)
+	if err != nil {
+		return err
+	}
+	err = checkTags(post.Tags)
+	if err != nil {
+		return err
+	}
+
+	tags, _ := insertTags(d.blogTags, post.Tags)
+	d.blogSeq++
+	post.ID = d.blogSeq
+	d.blogs[post.ID] = &blogRow{
+		id:       post.ID,
+		author:   post.Author.ID,
+		title:    post.Title,
+		content:  post.Content,
+		postedAt: timestamp(post.PostedAt),
+		tags:     tags,
+	}
+
+	return nil
+}
+
+func (d *DB) DeleteBlogEntry(ctx context.Context, id int) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	if _, ok := d.blogs[id]; !ok {
+		return errors.New("did not delete")
+	}
+
+	delete(d.blogs, id)
+	d.pruneBlogTags()
+
+	return nil
+}
+
+func (d *DB) UpdateBlogEntry(ctx context.Context, post db.BlogEntry) error {
+	if post.ID < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	b, ok := d.blogs[post.ID]
+	if !ok {
+		return errors.New("entry not found")
+	}
+	err = d.checkUser(post.Author.ID)
+	if err != nil {
+		return err
+	}
+	err = checkTags(post.Tags)
+	if err != nil {
+		return err
+	}
+
+	b.tags = nil
+	d.pruneBlogTags()
+
+	b.tags, _ = insertTags(d.blogTags, post.Tags)
+	b.author = post.Author.ID
+	b.title = post.Title
+	b.content = post.Content
+	b.postedAt = timestamp(post.PostedAt)
+
+	return nil
+}
+
+func (d *DB) GetBlogEntry(ctx context.Context, id int) (*db.BlogEntry, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	b, ok := d.blogs[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	entry := d.blogEntry(b)
+	return &entry, nil
+}
+
+// GetBlogEntries returns blog entries, most recent first.
+// Entries posted at the same time are ordered by ID.
+// blogLess orders blog entries like the database does, newest first.
+func blogLess(a, b db.BlogCursor) bool {
+	if a.PostedAt.Equal(b.PostedAt) {
+		return a.ID < b.ID
+	}
+	return a.PostedAt.After(b.PostedAt)
+}
+
+func blogCursor(b *blogRow) db.BlogCursor {
+	return db.BlogCursor{PostedAt: b.postedAt, ID: b.id}
+}
+
+// sortedBlogs returns all entries matching f, newest first.
+func (d *DB) sortedBlogs(f func(b *blogRow) bool) []*blogRow {
+	var entries []*blogRow
+	for _, b := range d.blogs {
+		if f(b) {
+			entries = append(entries, b)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return blogLess(blogCursor(entries[i]), blogCursor(entries[j]))
+	})
+	return entries
+}
+
+func (d *DB) GetBlogEntries(ctx context.Context, limit, offset int) ([]db.BlogEntry, error) {
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	entries := d.sortedBlogs(func(*blogRow) bool { return true })
+
+	result := make([]db.BlogEntry, 0)
+	for i := offset; i < len(entries) && i < offset+limit; i++ {
+		result = append(result, d.blogEntry(entries[i]))
+	}
+
+	return result, nil
+}
+
+func (d *DB) GetBlogEntriesAfter(ctx context.Context, c db.BlogCursor, limit int) ([]db.BlogEntry, error) {
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	entries := d.sortedBlogs(func(b *blogRow) bool { return blogLess(c, blogCursor(b)) })
+
+	result := make([]db.BlogEntry, 0)
+	for i := 0; i < len(entries) && i < limit; i++ {
+		result = append(result, d.blogEntry(entries[i]))
+	}
+
+	return result, nil
+}
+
+func (d *DB) GetBlogEntriesBefore(ctx context.Context, c db.BlogCursor, limit int) ([]db.BlogEntry, error) {
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	entries := d.sortedBlogs(func(b *blogRow) bool { return blogLess(blogCursor(b), c) })
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	result := make([]db.BlogEntry, 0)
+	for _, b := range entries {
+		result = append(result, d.blogEntry(b))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,244 @@
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+// Weights of the parts of a document, the defaults of ts_rank.
+const (
+	weightA = 1.0 // names
+	weightB = 0.4 // aliases
+	weightC = 0.2 // tags
+)
+
+// unaccentRules is a subset of the rules of the unaccent extension, for lower
+// case letters.
+var unaccentRules = map[rune]string{}
+
+func init() {
+	for base, accented := range map[string]string{
+		"a":  "àáâãäåāăą",
+		"ae": "æ",
+		"c":  "çćĉċč",
+		"d":  "ðďđ",
+		"e":  "èéêëēĕėęě",
+		"g":  "ĝğġģ",
+		"h":  "ĥħ",
+		"i":  "ìíîïĩīĭįı",
+		"ij": "ĳ",
+		"j":  "ĵ",
+		"k":  "ķ",
+		"l":  "ĺļľŀł",
+		"n":  "ñńņňŉ",
+		"o":  "òóôõöøōŏő",
+		"oe": "œ",
+		"r":  "ŕŗř",
+		"s":  "śŝşš",
+		"ss": "ß",
+		"t":  "ţťŧ",
+		"th": "þ",
+		"u":  "ùúûüũūŭůűų",
+		"w":  "ŵ",
+		"y":  "ýÿŷ",
+		"z":  "źżž",
+	} {
+		for _, r := range accented {
+			unaccentRules[r] = base
+		}
+	}
+}
+
+// normalize brings a word to the form it is indexed in: lower case, without
+// diacritics.
+func normalize(word string) string {
+	var b bytes.Buffer
+	for _, r := range strings.ToLower(word) {
+		if s, ok := unaccentRules[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type word struct {
+	start, end int // byte offsets in the text
+	normalized string
+}
+
+func words(text string) []word {
+	var res []word
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWordRune && start < 0:
+			start = i
+		case !isWordRune && start >= 0:
+			res = append(res, word{start: start, end: i, normalized: normalize(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		res = append(res, word{start: start, end: len(text), normalized: normalize(text[start:])})
+	}
+	return res
+}
+
+// matchesTerm reports whether any word of the texts starts with term.
+func matchesTerm(texts []string, term string) bool {
+	for _, t := range texts {
+		for _, w := range words(t) {
+			if strings.HasPrefix(w.normalized, term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// document is the searchable text of a search result, like the tsvector
+// columns.
+type document struct {
+	result db.SearchResult
+	names  []string
+	alias  []string
+	tags   []string
+}
+
+// rank returns whether the document matches all terms and how well, the sum
+// of the highest weight each term matches with.
+func (doc document) rank(terms []string) (float64, bool) {
+	var rank float64
+	for _, t := range terms {
+		switch {
+		case matchesTerm(doc.names, t):
+			rank += weightA
+		case matchesTerm(doc.alias, t):
+			rank += weightB
+		case matchesTerm(doc.tags, t):
+			rank += weightC
+		default:
+			return 0, false
+		}
+	}
+	return rank, true
+}
+
+// highlight highlights the words of text matching any of the terms, like
+// ts_headline.
+func highlight(text string, terms []string) string {
+	var b bytes.Buffer
+	last := 0
+	for _, w := range words(text) {
+		for _, t := range terms {
+			if strings.HasPrefix(w.normalized, t) {
+				b.WriteString(text[last:w.start])
+				b.WriteString(db.SearchHighlightStart)
+				b.WriteString(text[w.start:w.end])
+				b.WriteString(db.SearchHighlightStop)
+				last = w.end
+				break
+			}
+		}
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (d *DB) documents(types []string) []document {
+	var docs []document
+	for _, typ := range types {
+		switch typ {
+		case db.SearchResultArtist:
+			for _, id := range d.artistIDs() {
+				a := d.artists[id]
+				doc := document{
+					result: db.SearchResult{Type: typ, ID: a.id, Name: a.name},
+					names:  []string{a.name},
+					tags:   tagValues(d.artistTags, a.tags),
+				}
+				for _, al := range a.aliases {
+					doc.alias = append(doc.alias, al.alias)
+				}
+				docs = append(docs, doc)
+			}
+		case db.SearchResultReleaseGroup:
+			for _, id := range d.releaseGroupIDs() {
+				g := d.releaseGroups[id]
+				docs = append(docs, document{
+					result: db.SearchResult{Type: typ, ID: g.id, Name: g.name},
+					names:  []string{g.name},
+					tags:   tagValues(d.releaseGroupTags, g.tags),
+				})
+			}
+		case db.SearchResultRecordLabel:
+			for _, id := range d.recordLabelIDs() {
+				l := d.recordLabels[id]
+				docs = append(docs, document{
+					result: db.SearchResult{Type: typ, ID: l.id, Name: l.name},
+					names:  []string{l.name},
+				})
+			}
+		}
+	}
+	return docs
+}
+
+func (d *DB) Search(ctx context.Context, q string, types []string, limit int) ([]db.SearchResult, error) {
+	terms := db.SearchTerms(q)
+	if len(terms) == 0 {
+		return nil, errors.New("missing q")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if len(types) == 0 {
+		types = db.SearchResultTypes
+	}
+	for i := range terms {
+		terms[i] = normalize(terms[i])
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var results []db.SearchResult
+	for _, doc := range d.documents(types) {
+		rank, ok := doc.rank(terms)
+		if !ok {
+			continue
+		}
+
+		res := doc.result
+		res.Highlight = highlight(res.Name, terms)
+		res.Rank = rank
+		results = append(results, res)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		if results[i].Type != results[j].Type {
+			return results[i].Type < results[j].Type
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
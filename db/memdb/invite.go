@@ -0,0 +1,247 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+const inviteCodeLength = 16
+
+type inviteRow struct {
+	id        int
+	code      string
+	createdBy int
+	createdAt time.Time
+	usedBy    int // -1 for unused invites
+	usedAt    pq.NullTime
+}
+
+func (d *DB) UpdateUserSetInvites(ctx context.Context, id, invites int) error {
+	if id < 0 {
+		return errors.New("invalid id")
+	}
+	if invites < 0 {
+		return errors.New("invalid number of invites")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	u, ok := d.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	u.Invites = invites
+
+	return nil
+}
+
+func (d *DB) InsertInvite(ctx context.Context, inv *db.Invite) error {
+	if inv.CreatedBy.ID < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	u, ok := d.users[inv.CreatedBy.ID]
+	if !ok {
+		return errors.New("user not found")
+	}
+	if u.Invites < 1 {
+		return errors.New("no invites left")
+	}
+
+	code := generateRandomKey(inviteCodeLength)
+	for _, row := range d.invites {
+		if row.code == code {
+			return errors.New("unique violation: duplicate invite code")
+		}
+	}
+
+	u.Invites--
+	d.inviteSeq++
+	d.invites = append(d.invites, &inviteRow{
+		id:        d.inviteSeq,
+		code:      code,
+		createdBy: inv.CreatedBy.ID,
+		createdAt: timestamp(inv.CreatedAt),
+		usedBy:    -1,
+	})
+	inv.ID = d.inviteSeq
+	inv.Code = code
+	inv.UsedBy = nil
+	inv.UsedAt = pq.NullTime{}
+
+	return nil
+}
+
+func (d *DB) GetInvitesByUser(ctx context.Context, uid int) ([]db.Invite, error) {
+	if uid < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var rows []*inviteRow
+	for _, row := range d.invites {
+		if row.createdBy == uid {
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].createdAt.Equal(rows[j].createdAt) {
+			return rows[i].createdAt.After(rows[j].createdAt)
+		}
+		return rows[i].id > rows[j].id
+	})
+
+	var invites []db.Invite
+	for _, row := range rows {
+		inv := db.Invite{
+			ID:        row.id,
+			Code:      row.code,
+			CreatedBy: db.User{ID: uid},
+			CreatedAt: row.createdAt,
+			UsedAt:    row.usedAt,
+		}
+		if row.usedBy >= 0 {
+			u := d.userRef(row.usedBy)
+			inv.UsedBy = &u
+		}
+		invites = append(invites, inv)
+	}
+
+	return invites, nil
+}
+
+func (d *DB) SignUpUserWithInvite(ctx context.Context, username, password, email, code string) error {
+	if len(code) == 0 {
+		return errors.New("missing invite")
+	}
+
+	pwHash, err := hashSignUpPassword(username, password, email)
+	if err != nil {
+		return err
+	}
+
+	err = d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	var inv *inviteRow
+	for _, row := range d.invites {
+		if row.code == code && row.usedBy < 0 {
+			inv = row
+			break
+		}
+	}
+	if inv == nil {
+		return errors.New("invalid invite")
+	}
+
+	id, err := d.insertSignUp(username, email, pwHash)
+	if err != nil {
+		return err
+	}
+	inv.usedBy = id
+	inv.usedAt = nullTime(now())
+
+	return nil
+}
+
+func (d *DB) GetInviteTree(ctx context.Context, id int) ([]db.InviteTreeNode, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var nodes []db.InviteTreeNode
+	parents := []int{id}
+	for depth := 1; len(parents) > 0; depth++ {
+		var level []db.InviteTreeNode
+		for _, parent := range parents {
+			for _, row := range d.invites {
+				if row.createdBy != parent || row.usedBy < 0 {
+					continue
+				}
+				u := d.users[row.usedBy]
+				level = append(level, db.InviteTreeNode{
+					User: db.User{
+						ID:       u.ID,
+						Username: u.Username,
+						Enabled:  u.Enabled,
+						JoinedAt: u.JoinedAt,
+						Class:    u.Class,
+					},
+					InvitedBy: parent,
+					Depth:     depth,
+				})
+			}
+		}
+		sort.Slice(level, func(i, j int) bool {
+			return level[i].User.ID < level[j].User.ID
+		})
+
+		parents = nil
+		for _, n := range level {
+			parents = append(parents, n.User.ID)
+		}
+		nodes = append(nodes, level...)
+	}
+
+	return nodes, nil
+}
+
+func (d *DB) UpdateUsersDisable(ctx context.Context, ids []int) error {
+	for _, id := range ids {
+		if id < 0 {
+			return errors.New("invalid ID")
+		}
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	disabled := make(map[int]bool)
+	for _, id := range ids {
+		if u, ok := d.users[id]; ok {
+			u.Enabled = false
+			disabled[id] = true
+		}
+	}
+
+	for s, t := range d.tokens {
+		if disabled[t.uid] {
+			delete(d.tokens, s)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type leechType struct {
+	name           string
+	upMultiplier   float64
+	downMultiplier float64
+}
+
+func (d *DB) GetAllPrivileges(ctx context.Context) (map[int]string, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	return copyMap(d.privileges), nil
+}
+
+func (d *DB) InsertPrivilege(ctx context.Context, privilege string) (int, error) {
+	if len(privilege) == 0 {
+		return 0, errors.New("missing privilege")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer d.mu.Unlock()
+
+	id := 0
+	for k, v := range d.privileges {
+		if v == privilege {
+			return 0, errors.New("unique violation: privilege already exists")
+		}
+		if k >= id {
+			id = k + 1
+		}
+	}
+
+	d.privileges[id] = privilege
+	d.userClassPrivileges[sysOpClass][id] = struct{}{}
+	return id, nil
+}
+
+func (d *DB) GetAllFormats(ctx context.Context) (map[int]db.Format, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	m := make(map[int]db.Format)
+	for k, v := range d.formats {
+		m[k] = v
+	}
+
+	return m, nil
+}
+
+func (d *DB) GetAllMedia(ctx context.Context) (map[int]string, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	return copyMap(d.media), nil
+}
+
+func (d *DB) GetAllReleaseGroupRoles(ctx context.Context) (map[int]string, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	return copyMap(d.releaseRoles), nil
+}
+
+func (d *DB) GetAllReleaseGroupTypes(ctx context.Context) (map[int]string, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	return copyMap(d.releaseGroupTypes), nil
+}
+
+func (d *DB) GetAllLeechTypes(ctx context.Context) (map[int]string, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	m := make(map[int]string)
+	for k, v := range d.leechTypes {
+		m[k] = v.name
+	}
+
+	return m, nil
+}
+
+func (d *DB) GetAllReleaseProperties(ctx context.Context) (map[int]string, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	return d.releaseProperties.toMap(), nil
+}
+
+func (d *DB) AddReleaseProperty(ctx context.Context, key string) error {
+	if len(key) == 0 {
+		return errors.New("missing key")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	if _, ok := d.releaseProperties.lookUp(key); ok {
+		return errors.New("unique violation: property already exists")
+	}
+	d.releaseProperties.insert(key)
+
+	return nil
+}
@@ -0,0 +1,544 @@
+// Package memdb provides an in-memory implementation of db.BoilingDB.
+//
+// It mirrors the semantics of the postgres implementation, including the
+// reference data inserted by the migrations, and is meant for tests and local
+// development. Nothing is persisted.
+// Column length limits are not enforced.
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+var errClosed = errors.New("database is closed")
+
+type DB struct {
+	mu     sync.RWMutex
+	closed bool
+
+	privileges        map[int]string
+	formats           map[int]db.Format
+	media             map[int]string
+	releaseRoles      map[int]string
+	releaseGroupTypes map[int]string
+	leechTypes        map[int]leechType
+	releaseProperties *lookupTable
+
+	userClasses         map[int]string
+	userClassPrivileges map[int]map[int]struct{}
+	userClassHistory    []*userClassChangeRow
+	userClassHistorySeq int
+
+	users          map[int]*db.User
+	userSeq        int
+	userPrivileges map[int]map[int]bool // true for grants, false for denials
+	passkeys       []db.Passkey
+	tokens         map[string]apiToken
+	invites        []*inviteRow
+	inviteSeq      int
+
+	blogTags *lookupTable
+	blogs    map[int]*blogRow
+	blogSeq  int
+
+	recordLabels   map[int]*recordLabelRow
+	recordLabelSeq int
+
+	artistTags      *lookupTable
+	artists         map[int]*artistRow
+	artistSeq       int
+	artistRedirects map[int]*artistRedirectRow
+
+	releaseGroupTags *lookupTable
+	releaseGroups    map[int]*releaseGroupRow
+	releaseGroupSeq  int
+
+	releaseTags *lookupTable
+	releases    map[int]*releaseRow
+	releaseSeq  int
+
+	torrents    map[int]*torrentRow
+	torrentSeq  int
+	statChanges []statChange
+
+	revisions   map[revisionKey][]*revisionRow
+	revisionSeq int
+}
+
+var _ db.BoilingDB = &DB{}
+
+// New returns an empty database in the state of a freshly migrated postgres
+// database, without the test user created by the initial schema.
+func New() *DB {
+	d := &DB{
+		privileges: map[int]string{
+			0:  "get_blogs",
+			1:  "post_blog",
+			2:  "post_blog_override_posted_at",
+			3:  "post_blog_override_author",
+			4:  "update_blog",
+			5:  "update_blog_not_owner",
+			6:  "update_blog_override_posted_at",
+			7:  "update_blog_override_author",
+			8:  "delete_blog",
+			9:  "delete_blog_not_owner",
+			10: "get_artist",
+			11: "get_release_group",
+			12: "upload_torrent",
+			13: "download_torrent",
+			14: "get_user_stats_not_self",
+			15: "create_artist",
+			16: "update_artist",
+			17: "add_artist_alias",
+			18: "remove_artist_alias",
+			19: "add_artist_tag",
+			20: "remove_artist_tag",
+			21: "merge_artists",
+			22: "create_release_group",
+			23: "update_release_group",
+			24: "delete_release_group",
+			25: "create_release",
+			26: "update_release",
+			27: "delete_release",
+			28: "set_release_property",
+			29: "get_record_label",
+			30: "create_record_label",
+			31: "update_record_label",
+			32: "revert_revision",
+			33: "set_user_class",
+			34: "get_user_privileges",
+			35: "set_user_privileges",
+			36: "create_privilege",
+			37: "invite",
+			38: "set_user_invites",
+			39: "get_invite_tree",
+			40: "disable_invite_tree",
+		},
+		formats: map[int]db.Format{
+			0: {Format: "FLAC", Encoding: "Lossless"},
+			1: {Format: "FLAC/24bit", Encoding: "Lossless"},
+			2: {Format: "MP3/320", Encoding: "Lossy"},
+			3: {Format: "MP3/V0", Encoding: "Lossy"},
+			4: {Format: "MP3/V2", Encoding: "Lossy"},
+		},
+		media: map[int]string{
+			0: "CD",
+			1: "DVD",
+			2: "Vinyl",
+			3: "WEB",
+			4: "Blu-Ray",
+			5: "SACD",
+			6: "Cassette",
+			7: "DAT",
+		},
+		releaseRoles: map[int]string{
+			0: "Main",
+			1: "Guest",
+			2: "Composer",
+			3: "Conductor",
+			4: "Remixer",
+			5: "Producer",
+		},
+		releaseGroupTypes: map[int]string{
+			0: "Album",
+			1: "EP",
+			2: "Single",
+			3: "Compilation",
+			4: "Soundtrack",
+			5: "Live album",
+			6: "Bootleg",
+			7: "Mixtape",
+			8: "Unknown",
+		},
+		leechTypes: map[int]leechType{
+			0: {name: "Normal", upMultiplier: 1, downMultiplier: 1},
+			1: {name: "Freeleech", upMultiplier: 1, downMultiplier: 0},
+			2: {name: "Neutral", upMultiplier: 0, downMultiplier: 0},
+			3: {name: "DoubleUp", upMultiplier: 2, downMultiplier: 1},
+			4: {name: "DoubleDown", upMultiplier: 1, downMultiplier: 2},
+		},
+		releaseProperties: newLookupTable(),
+
+		userClasses: map[int]string{
+			0: "User",
+			1: "Power User",
+			2: "Elite",
+			3: "Moderator",
+			4: "SysOp",
+		},
+		userClassPrivileges: make(map[int]map[int]struct{}),
+
+		users:          make(map[int]*db.User),
+		userSeq:        1,
+		userPrivileges: make(map[int]map[int]bool),
+		tokens:         make(map[string]apiToken),
+
+		blogTags: newLookupTable(),
+		blogs:    make(map[int]*blogRow),
+
+		recordLabels: make(map[int]*recordLabelRow),
+
+		artistTags:      newLookupTable(),
+		artists:         make(map[int]*artistRow),
+		artistRedirects: make(map[int]*artistRedirectRow),
+
+		releaseGroupTags: newLookupTable(),
+		releaseGroups:    make(map[int]*releaseGroupRow),
+
+		releaseTags: newLookupTable(),
+		releases:    make(map[int]*releaseRow),
+
+		torrents: make(map[int]*torrentRow),
+
+		revisions: make(map[revisionKey][]*revisionRow),
+	}
+
+	d.seedUserClasses()
+
+	for _, p := range []string{"LossyMasterApproved", "LossyWebApproved", "CassetteApproved"} {
+		d.releaseProperties.insert(p)
+	}
+
+	t := timestamp(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	d.users[0] = &db.User{
+		ID:         0,
+		Username:   "boiling",
+		Email:      "boiling@boiling.rip",
+		Bio:        sql.NullString{String: "The one", Valid: true},
+		Enabled:    true,
+		JoinedAt:   t,
+		LastLogin:  nullTime(t),
+		LastAccess: nullTime(t),
+	}
+
+	return d
+}
+
+// InsertUser inserts a user as is, including the ID, the password hash, the
+// class and the privileges, which are granted individually.
+// It is meant to load fixtures, use SignUpUser otherwise.
+func (d *DB) InsertUser(u db.User) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return errClosed
+	}
+	if u.ID < 0 {
+		return errors.New("invalid ID")
+	}
+	if _, ok := d.users[u.ID]; ok {
+		return errors.New("duplicate user ID")
+	}
+	err := d.checkUniqueUser(u.Username, u.Email)
+	if err != nil {
+		return err
+	}
+	err = d.checkUserClass(u.Class)
+	if err != nil {
+		return err
+	}
+
+	tmp := u
+	tmp.Privileges = nil
+	tmp.JoinedAt = timestamp(u.JoinedAt)
+	tmp.LastLogin = nullTimestamp(u.LastLogin)
+	tmp.LastAccess = nullTimestamp(u.LastAccess)
+	tmp.RatioWatchUntil = nullTimestamp(u.RatioWatchUntil)
+	d.users[u.ID] = &tmp
+
+	for _, p := range u.Privileges {
+		d.setPrivilege(u.ID, p, true)
+	}
+
+	if u.ID >= d.userSeq {
+		d.userSeq = u.ID + 1
+	}
+
+	return nil
+}
+
+func (d *DB) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	return nil
+}
+
+// check must be called with d.mu held.
+func (d *DB) check(ctx context.Context) error {
+	if d.closed {
+		return errClosed
+	}
+	return ctx.Err()
+}
+
+// lock acquires the write lock, if the database is still usable.
+func (d *DB) lock(ctx context.Context) error {
+	d.mu.Lock()
+	err := d.check(ctx)
+	if err != nil {
+		d.mu.Unlock()
+	}
+	return err
+}
+
+// rlock acquires the read lock, if the database is still usable.
+func (d *DB) rlock(ctx context.Context) error {
+	d.mu.RLock()
+	err := d.check(ctx)
+	if err != nil {
+		d.mu.RUnlock()
+	}
+	return err
+}
+
+// userRef returns a user with only the ID and the username populated, the
+// way joined queries return them.
+func (d *DB) userRef(id int) db.User {
+	u := db.User{ID: id}
+	if tmp, ok := d.users[id]; ok {
+		u.Username = tmp.Username
+	}
+	return u
+}
+
+func (d *DB) checkUser(id int) error {
+	if _, ok := d.users[id]; !ok {
+		return fmt.Errorf("foreign key violation: user %d does not exist", id)
+	}
+	return nil
+}
+
+// timestamp converts t the way a TIMESTAMP column stores it: the time zone is
+// dropped, keeping the wall clock, and it's rounded to microseconds.
+// The result is in the zone lib/pq uses for values read from such columns.
+func timestamp(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.FixedZone("", 0)).Round(time.Microsecond)
+}
+
+// date converts t the way a DATE column stores it.
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.FixedZone("", 0))
+}
+
+func now() time.Time {
+	return timestamp(time.Now().UTC())
+}
+
+func nullTime(t time.Time) pq.NullTime {
+	return pq.NullTime{Time: t, Valid: true}
+}
+
+func nullTimestamp(t pq.NullTime) pq.NullTime {
+	if !t.Valid {
+		return pq.NullTime{}
+	}
+	return nullTime(timestamp(t.Time))
+}
+
+// nullString converts s the way the postgres implementation inserts optional
+// strings: empty strings are stored as NULL.
+func nullString(s sql.NullString) sql.NullString {
+	if s.String == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: s.String, Valid: true}
+}
+
+// nullValue returns the value of s the way a query sees it, nil for NULL.
+func nullValue(s sql.NullString) interface{} {
+	if !s.Valid {
+		return nil
+	}
+	return s.String
+}
+
+// likePattern compiles a LIKE pattern to a regular expression.
+func likePattern(pattern string, caseInsensitive bool) *regexp.Regexp {
+	var b bytes.Buffer
+	if caseInsensitive {
+		b.WriteString("(?is)")
+	} else {
+		b.WriteString("(?s)")
+	}
+	b.WriteString("^")
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
+}
+
+// contains returns the matcher used by the autocomplete methods, the
+// equivalent of LIKE (or ILIKE) '%s%'.
+func contains(s string, caseInsensitive bool) *regexp.Regexp {
+	return likePattern(fmt.Sprint("%", s, "%"), caseInsensitive)
+}
+
+// lookupTable is a table of unique strings with serial IDs, like the tag and
+// property tables.
+type lookupTable struct {
+	ids    map[string]int
+	values map[int]string
+	seq    int
+}
+
+func newLookupTable() *lookupTable {
+	return &lookupTable{
+		ids:    make(map[string]int),
+		values: make(map[int]string),
+	}
+}
+
+// insert returns the ID of v, inserting it if necessary.
+func (t *lookupTable) insert(v string) int {
+	if id, ok := t.ids[v]; ok {
+		return id
+	}
+	id := t.seq
+	t.seq++
+	t.ids[v] = id
+	t.values[id] = v
+	return id
+}
+
+func (t *lookupTable) lookUp(v string) (int, bool) {
+	id, ok := t.ids[v]
+	return id, ok
+}
+
+func (t *lookupTable) value(id int) string {
+	return t.values[id]
+}
+
+// match returns all values matching re, in order of insertion.
+func (t *lookupTable) match(re *regexp.Regexp) []string {
+	var res []string
+	for _, id := range t.sortedIDs() {
+		if re.MatchString(t.values[id]) {
+			res = append(res, t.values[id])
+		}
+	}
+	return res
+}
+
+// prune removes all values for which used returns false.
+func (t *lookupTable) prune(used func(id int) bool) {
+	for id, v := range t.values {
+		if !used(id) {
+			delete(t.values, id)
+			delete(t.ids, v)
+		}
+	}
+}
+
+func (t *lookupTable) sortedIDs() []int {
+	ids := make([]int, 0, len(t.values))
+	for id := range t.values {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (t *lookupTable) toMap() map[int]string {
+	m := make(map[int]string)
+	for id, v := range t.values {
+		m[id] = v
+	}
+	return m
+}
+
+// insertTags returns the IDs of the given tags, inserting missing ones into
+// the table.
+// Like the primary keys of the tag relations, it rejects duplicate tags.
+func insertTags(t *lookupTable, tags []string) ([]int, error) {
+	err := checkTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, tag := range tags {
+		ids = append(ids, t.insert(tag))
+	}
+	return ids, nil
+}
+
+func checkTags(tags []string) error {
+	seen := make(map[string]struct{})
+	for _, t := range tags {
+		if _, ok := seen[t]; ok {
+			return fmt.Errorf("duplicate tag %q", t)
+		}
+		seen[t] = struct{}{}
+	}
+	return nil
+}
+
+func tagValues(t *lookupTable, ids []int) []string {
+	var tags []string
+	for _, id := range ids {
+		tags = append(tags, t.value(id))
+	}
+	return tags
+}
+
+// tagColumns provides the columns of a tag table to evaluate queries.
+func tagColumns(t *lookupTable, id int) db.Row {
+	return func(column string) (interface{}, error) {
+		switch column {
+		case "id":
+			return id, nil
+		case "tag":
+			return t.value(id), nil
+		}
+		return nil, fmt.Errorf("column %q does not exist", column)
+	}
+}
+
+func copyMap(m map[int]string) map[int]string {
+	res := make(map[int]string)
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+// lookUpValue returns the ID of a value of a lookup table stored as a map.
+func lookUpValue(m map[int]string, v string) (int, bool) {
+	for id, tmp := range m {
+		if tmp == v {
+			return id, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,14 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/db/dbtest"
+)
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.BoilingDB {
+		return New()
+	})
+}
@@ -0,0 +1,140 @@
+package memdb
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+// generateRandomKey generates keys like the postgres implementation does.
+func generateRandomKey(length int) string {
+	buf := make([]byte, length)
+	_, err := rand.Read(buf)
+	if err != nil {
+		panic(err) // out of randomness, should never happen
+	}
+
+	return hex.EncodeToString(buf)
+}
+
+const (
+	passkeyLength = 32
+	tokenLength   = 64
+)
+
+func (d *DB) GetPasskeyForUser(ctx context.Context, id int) (*db.Passkey, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	for _, p := range d.passkeys {
+		if p.Uid == id && p.Valid {
+			tmp := p
+			return &tmp, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (d *DB) GetAllPasskeysForUser(ctx context.Context, id int) ([]db.Passkey, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var passkeys []db.Passkey
+	for _, p := range d.passkeys {
+		if p.Uid == id {
+			passkeys = append(passkeys, p)
+		}
+	}
+
+	return passkeys, nil
+}
+
+func (d *DB) GenerateNewPasskeyForUser(ctx context.Context, id int) (string, error) {
+	err := d.lock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer d.mu.Unlock()
+
+	err = d.checkUser(id)
+	if err != nil {
+		return "", err
+	}
+
+	passkey := generateRandomKey(passkeyLength)
+	for _, p := range d.passkeys {
+		if p.Passkey == passkey {
+			return "", fmt.Errorf("unique violation: duplicate passkey")
+		}
+	}
+
+	for i := range d.passkeys {
+		if d.passkeys[i].Uid == id {
+			d.passkeys[i].Valid = false
+		}
+	}
+
+	d.passkeys = append(d.passkeys, db.Passkey{
+		Uid:       id,
+		Passkey:   passkey,
+		CreatedAt: now(),
+		Valid:     true,
+	})
+
+	return passkey, nil
+}
+
+func (d *DB) GetUserByPasskey(ctx context.Context, passkey string) (*db.User, error) {
+	if len(passkey) == 0 {
+		return nil, errors.New("missing passkey")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	for _, p := range d.passkeys {
+		if p.Passkey != passkey || !p.Valid {
+			continue
+		}
+
+		u, ok := d.users[p.Uid]
+		if !ok {
+			break
+		}
+
+		return &db.User{
+			ID:              u.ID,
+			Username:        u.Username,
+			Enabled:         u.Enabled,
+			CanLogin:        u.CanLogin,
+			Uploaded:        u.Uploaded,
+			Downloaded:      u.Downloaded,
+			RatioWatchUntil: u.RatioWatchUntil,
+		}, nil
+	}
+
+	return nil, sql.ErrNoRows
+}
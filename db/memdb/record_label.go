@@ -0,0 +1,347 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type recordLabelRow struct {
+	id          int
+	name        string
+	description sql.NullString
+	founded     pq.NullTime
+	added       time.Time
+	addedBy     int
+	parent      sql.NullInt64
+}
+
+func (d *DB) recordLabel(l *recordLabelRow) db.RecordLabel {
+	label := db.RecordLabel{
+		ID:          l.id,
+		Name:        l.name,
+		Description: l.description,
+		Founded:     l.founded,
+		Added:       l.added,
+		AddedBy:     d.userRef(l.addedBy),
+	}
+	if l.parent.Valid {
+		id := int(l.parent.Int64)
+		label.Parent = &db.RecordLabel{ID: id, Name: d.recordLabels[id].name}
+	}
+	return label
+}
+
+// checkParent checks that the parent of a label exists.
+func (d *DB) checkParent(label db.RecordLabel) (sql.NullInt64, error) {
+	if label.Parent == nil {
+		return sql.NullInt64{}, nil
+	}
+	if _, ok := d.recordLabels[label.Parent.ID]; !ok {
+		return sql.NullInt64{}, fmt.Errorf("foreign key violation: record label %d does not exist", label.Parent.ID)
+	}
+	return sql.NullInt64{Int64: int64(label.Parent.ID), Valid: true}, nil
+}
+
+func (d *DB) recordLabelIDs() []int {
+	var ids []int
+	for id := range d.recordLabels {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (d *DB) AutocompleteRecordLabels(ctx context.Context, s string) ([]db.RecordLabel, error) {
+	if len(s) == 0 {
+		return nil, errors.New("misssing s")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	re := contains(s, true)
+	var labels []db.RecordLabel
+	for _, id := range d.recordLabelIDs() {
+		l := d.recordLabels[id]
+		if re.MatchString(l.name) {
+			labels = append(labels, d.recordLabel(l))
+		}
+	}
+
+	return labels, nil
+}
+
+func (d *DB) GetRecordLabel(ctx context.Context, id int) (*db.RecordLabel, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	l, ok := d.recordLabels[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	label := d.recordLabel(l)
+	return &label, nil
+}
+
+func (d *DB) InsertRecordLabel(ctx context.Context, label *db.RecordLabel) error {
+	if label == nil {
+		return errors.New("missing label")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	err = d.checkUser(label.AddedBy.ID)
+	if err != nil {
+		return err
+	}
+	parent, err := d.checkParent(*label)
+	if err != nil {
+		return err
+	}
+
+	var founded pq.NullTime
+	if !label.Founded.Time.IsZero() {
+		founded = nullTime(date(label.Founded.Time))
+	}
+
+	d.recordLabelSeq++
+	label.ID = d.recordLabelSeq
+	label.Added = now()
+	d.recordLabels[label.ID] = &recordLabelRow{
+		id:          label.ID,
+		name:        label.Name,
+		description: nullString(label.Description),
+		founded:     founded,
+		added:       label.Added,
+		addedBy:     label.AddedBy.ID,
+		parent:      parent,
+	}
+
+	return d.recordRevision(db.RevisionRecordLabel, label.ID, db.Edit{Edited: label.Added, EditedBy: label.AddedBy})
+}
+
+// updateRecordLabel updates an existing record label.
+func (d *DB) updateRecordLabel(label db.RecordLabel) error {
+	parent, err := d.checkParent(label)
+	if err != nil {
+		return err
+	}
+	for p := parent; p.Valid; p = d.recordLabels[int(p.Int64)].parent {
+		if int(p.Int64) == label.ID {
+			return errors.New("record label can not be a sub-label of itself")
+		}
+	}
+
+	var founded pq.NullTime
+	if label.Founded.Valid {
+		founded = nullTime(date(label.Founded.Time))
+	}
+
+	l := d.recordLabels[label.ID]
+	l.name = label.Name
+	l.description = nullString(label.Description)
+	l.founded = founded
+	l.parent = parent
+
+	return nil
+}
+
+func (d *DB) UpdateRecordLabel(ctx context.Context, label db.RecordLabel, e db.Edit) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(db.RevisionRecordLabel, label.ID, e, func() error {
+		return d.updateRecordLabel(label)
+	})
+}
+
+func (d *DB) GetSubLabels(ctx context.Context, id int) ([]db.RecordLabel, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var labels []db.RecordLabel
+	for _, l := range d.recordLabels {
+		if l.parent.Valid && int(l.parent.Int64) == id {
+			labels = append(labels, d.recordLabel(l))
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].Name != labels[j].Name {
+			return labels[i].Name < labels[j].Name
+		}
+		return labels[i].ID < labels[j].ID
+	})
+
+	return labels, nil
+}
+
+// catalogLess orders the releases of a record label like the database does.
+func catalogLess(a, b db.RecordLabelReleaseCursor) bool {
+	if a.CatalogueNumber.Valid != b.CatalogueNumber.Valid {
+		return a.CatalogueNumber.Valid
+	}
+	if a.CatalogueNumber.String != b.CatalogueNumber.String {
+		return a.CatalogueNumber.String < b.CatalogueNumber.String
+	}
+	if !a.ReleaseDate.Equal(b.ReleaseDate) {
+		return a.ReleaseDate.Before(b.ReleaseDate)
+	}
+	return a.ID < b.ID
+}
+
+func catalogCursor(r *releaseRow) db.RecordLabelReleaseCursor {
+	return db.RecordLabelReleaseCursor{CatalogueNumber: r.catalogueNumber, ReleaseDate: r.releaseDate, ID: r.id}
+}
+
+// recordLabelReleases returns the releases on a record label matching f in
+// catalog order.
+func (d *DB) recordLabelReleases(id int, f func(r *releaseRow) bool) []*releaseRow {
+	var releases []*releaseRow
+	for _, r := range d.releases {
+		if r.recordLabel == id && f(r) {
+			releases = append(releases, r)
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return catalogLess(catalogCursor(releases[i]), catalogCursor(releases[j]))
+	})
+	return releases
+}
+
+func (d *DB) catalogRelease(r *releaseRow) db.Release {
+	release := d.release(r)
+	g := d.releaseGroups[r.releaseGroup]
+	release.ReleaseGroup.ReleaseDate = g.releaseDate
+	release.ReleaseGroup.Type = g.typ
+	return release
+}
+
+func (d *DB) GetRecordLabelReleases(ctx context.Context, id, offset, limit int) ([]db.Release, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var releases []db.Release
+	for i, r := range d.recordLabelReleases(id, func(*releaseRow) bool { return true }) {
+		if i < offset {
+			continue
+		}
+		if len(releases) == limit {
+			break
+		}
+		releases = append(releases, d.catalogRelease(r))
+	}
+
+	return releases, nil
+}
+
+func (d *DB) GetRecordLabelReleasesAfter(ctx context.Context, id int, c db.RecordLabelReleaseCursor, limit int) ([]db.Release, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var releases []db.Release
+	for _, r := range d.recordLabelReleases(id, func(r *releaseRow) bool { return catalogLess(c, catalogCursor(r)) }) {
+		if len(releases) == limit {
+			break
+		}
+		releases = append(releases, d.catalogRelease(r))
+	}
+
+	return releases, nil
+}
+
+func (d *DB) GetRecordLabelReleasesBefore(ctx context.Context, id int, c db.RecordLabelReleaseCursor, limit int) ([]db.Release, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	rows := d.recordLabelReleases(id, func(r *releaseRow) bool { return catalogLess(catalogCursor(r), c) })
+	if len(rows) > limit {
+		rows = rows[len(rows)-limit:]
+	}
+
+	var releases []db.Release
+	for _, r := range rows {
+		releases = append(releases, d.catalogRelease(r))
+	}
+
+	return releases, nil
+}
+
+func (d *DB) CountRecordLabelReleases(ctx context.Context, id int) (int, error) {
+	if id < 0 {
+		return 0, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer d.mu.RUnlock()
+
+	return len(d.recordLabelReleases(id, func(*releaseRow) bool { return true })), nil
+}
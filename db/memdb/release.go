@@ -0,0 +1,286 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type releaseRow struct {
+	id              int
+	releaseGroup    int
+	edition         sql.NullString
+	medium          int
+	releaseDate     time.Time
+	catalogueNumber sql.NullString
+	recordLabel     int
+	added           time.Time
+	addedBy         int
+	original        bool
+	tags            []int
+	properties      map[int]sql.NullString
+}
+
+// release returns the release with its tags and properties populated.
+func (d *DB) release(r *releaseRow) db.Release {
+	res := db.Release{
+		ID: r.id,
+		ReleaseGroup: db.ReleaseGroup{
+			ID:   r.releaseGroup,
+			Name: d.releaseGroups[r.releaseGroup].name,
+		},
+		Edition:         r.edition,
+		Medium:          r.medium,
+		ReleaseDate:     r.releaseDate,
+		CatalogueNumber: r.catalogueNumber,
+		RecordLabel: db.RecordLabel{
+			ID:   r.recordLabel,
+			Name: d.recordLabels[r.recordLabel].name,
+		},
+		Added:      r.added,
+		AddedBy:    d.userRef(r.addedBy),
+		Original:   r.original,
+		Tags:       tagValues(d.releaseTags, r.tags),
+		Properties: make(map[string]string),
+	}
+	for k, v := range r.properties {
+		res.Properties[d.releaseProperties.value(k)] = v.String
+	}
+	return res
+}
+
+// releaseColumns provides the columns of the releases table to evaluate
+// queries.
+// The torrents column provides the related rows for subqueries.
+func (d *DB) releaseColumns(r *releaseRow) db.Row {
+	return func(column string) (interface{}, error) {
+		switch column {
+		case "id":
+			return r.id, nil
+		case "medium":
+			return r.medium, nil
+		case "release_group":
+			return r.releaseGroup, nil
+		case "record_label":
+			return r.recordLabel, nil
+		case "added":
+			return r.added, nil
+		case "added_by":
+			return r.addedBy, nil
+		case "release_date":
+			return r.releaseDate, nil
+		case "original":
+			return r.original, nil
+		case "edition":
+			return nullValue(r.edition), nil
+		case "catalogue_number":
+			return nullValue(r.catalogueNumber), nil
+		case "torrents":
+			var rows []db.Row
+			for _, t := range d.torrents {
+				if t.release == r.id {
+					rows = append(rows, torrentColumns(t))
+				}
+			}
+			return rows, nil
+		}
+		return nil, fmt.Errorf("column %q does not exist", column)
+	}
+}
+
+func (d *DB) releaseIDs() []int {
+	var ids []int
+	for id := range d.releases {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// pruneReleaseTags removes release tags that are not used by any release
+// anymore.
+func (d *DB) pruneReleaseTags() {
+	d.releaseTags.prune(func(id int) bool {
+		for _, r := range d.releases {
+			for _, t := range r.tags {
+				if t == id {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+func (d *DB) AutocompleteReleaseTags(ctx context.Context, s string) ([]string, error) {
+	if len(s) == 0 {
+		return nil, errors.New("missing s")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	return d.releaseTags.match(contains(s, false)), nil
+}
+
+func (d *DB) InsertRelease(ctx context.Context, release *db.Release) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	if _, ok := d.media[release.Medium]; !ok {
+		return fmt.Errorf("foreign key violation: medium %d does not exist", release.Medium)
+	}
+	if _, ok := d.releaseGroups[release.ReleaseGroup.ID]; !ok {
+		return fmt.Errorf("foreign key violation: release group %d does not exist", release.ReleaseGroup.ID)
+	}
+	if _, ok := d.recordLabels[release.RecordLabel.ID]; !ok {
+		return fmt.Errorf("foreign key violation: record label %d does not exist", release.RecordLabel.ID)
+	}
+	err = d.checkUser(release.AddedBy.ID)
+	if err != nil {
+		return err
+	}
+	err = checkTags(release.Tags)
+	if err != nil {
+		return err
+	}
+
+	properties := make(map[int]sql.NullString)
+	for k, v := range release.Properties {
+		id, ok := d.releaseProperties.lookUp(k)
+		if !ok {
+			return fmt.Errorf("unknown release property %q", k)
+		}
+		properties[id] = sql.NullString{String: v, Valid: true}
+	}
+
+	d.releaseSeq++
+	release.ID = d.releaseSeq
+
+	r := &releaseRow{
+		id:              release.ID,
+		releaseGroup:    release.ReleaseGroup.ID,
+		edition:         nullString(release.Edition),
+		medium:          release.Medium,
+		releaseDate:     date(release.ReleaseDate),
+		catalogueNumber: nullString(release.CatalogueNumber),
+		recordLabel:     release.RecordLabel.ID,
+		added:           timestamp(release.Added),
+		addedBy:         release.AddedBy.ID,
+		original:        release.Original,
+		properties:      properties,
+	}
+	r.tags, _ = insertTags(d.releaseTags, release.Tags)
+	d.releases[r.id] = r
+
+	return d.recordRevision(db.RevisionRelease, r.id, db.Edit{Edited: release.Added, EditedBy: release.AddedBy})
+}
+
+// updateRelease updates an existing release.
+func (d *DB) updateRelease(release db.Release) error {
+	if _, ok := d.media[release.Medium]; !ok {
+		return fmt.Errorf("foreign key violation: medium %d does not exist", release.Medium)
+	}
+	if _, ok := d.recordLabels[release.RecordLabel.ID]; !ok {
+		return fmt.Errorf("foreign key violation: record label %d does not exist", release.RecordLabel.ID)
+	}
+	err := checkTags(release.Tags)
+	if err != nil {
+		return err
+	}
+
+	r := d.releases[release.ID]
+	r.edition = nullString(release.Edition)
+	r.medium = release.Medium
+	r.releaseDate = date(release.ReleaseDate)
+	r.catalogueNumber = nullString(release.CatalogueNumber)
+	r.recordLabel = release.RecordLabel.ID
+	r.original = release.Original
+	r.tags, _ = insertTags(d.releaseTags, release.Tags)
+	d.pruneReleaseTags()
+
+	return nil
+}
+
+func (d *DB) UpdateRelease(ctx context.Context, release db.Release, e db.Edit) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(db.RevisionRelease, release.ID, e, func() error {
+		return d.updateRelease(release)
+	})
+}
+
+func (d *DB) SetReleaseProperty(ctx context.Context, id int, k, v string, e db.Edit) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(db.RevisionRelease, id, e, func() error {
+		property, ok := d.releaseProperties.lookUp(k)
+		if !ok {
+			return fmt.Errorf("unknown release property %q", k)
+		}
+
+		d.releases[id].properties[property] = nullString(sql.NullString{String: v})
+		return nil
+	})
+}
+
+func (d *DB) GetRelease(ctx context.Context, id int) (*db.Release, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	r, ok := d.releases[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	release := d.release(r)
+	return &release, nil
+}
+
+func (d *DB) DeleteRelease(ctx context.Context, id int) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	if _, ok := d.releases[id]; !ok {
+		return errors.New("release not found")
+	}
+	for _, t := range d.torrents {
+		if t.release == id {
+			return fmt.Errorf("foreign key violation: release %d has torrents", id)
+		}
+	}
+
+	delete(d.releases, id)
+	d.pruneReleaseTags()
+
+	return nil
+}
@@ -0,0 +1,488 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type releaseGroupRow struct {
+	id          int
+	name        string
+	releaseDate time.Time
+	added       time.Time
+	addedBy     int
+	typ         int
+	artists     []roledArtistRow
+	tags        []int
+}
+
+type roledArtistRow struct {
+	artist int
+	role   int
+}
+
+// releaseGroupColumns provides the columns of the release_groups table to
+// evaluate queries.
+// The tags, artists and releases columns provide the related rows for
+// subqueries.
+func (d *DB) releaseGroupColumns(g *releaseGroupRow) db.Row {
+	return func(column string) (interface{}, error) {
+		switch column {
+		case "id":
+			return g.id, nil
+		case "name":
+			return g.name, nil
+		case "release_date":
+			return g.releaseDate, nil
+		case "added":
+			return g.added, nil
+		case "added_by":
+			return g.addedBy, nil
+		case "type":
+			return g.typ, nil
+		case "tags":
+			var rows []db.Row
+			for _, t := range g.tags {
+				rows = append(rows, tagColumns(d.releaseGroupTags, t))
+			}
+			return rows, nil
+		case "artists":
+			var rows []db.Row
+			for _, a := range g.artists {
+				rows = append(rows, d.roledArtistColumns(a))
+			}
+			return rows, nil
+		case "releases":
+			var rows []db.Row
+			for _, id := range d.releaseIDs() {
+				if r := d.releases[id]; r.releaseGroup == g.id {
+					rows = append(rows, d.releaseColumns(r))
+				}
+			}
+			return rows, nil
+		}
+		return nil, fmt.Errorf("column %q does not exist", column)
+	}
+}
+
+// roledArtistColumns provides the columns of release_groups_artists joined
+// with artists.
+func (d *DB) roledArtistColumns(ra roledArtistRow) db.Row {
+	a := d.artists[ra.artist]
+	return func(column string) (interface{}, error) {
+		switch column {
+		case "role":
+			return ra.role, nil
+		case "id":
+			return a.id, nil
+		case "name":
+			return a.name, nil
+		case "bio":
+			return nullValue(a.bio), nil
+		case "added":
+			return a.added, nil
+		case "added_by":
+			return a.addedBy, nil
+		}
+		return nil, fmt.Errorf("column %q does not exist", column)
+	}
+}
+
+// releaseGroup returns the release group with its tags and artists populated.
+func (d *DB) releaseGroup(g *releaseGroupRow) db.ReleaseGroup {
+	res := db.ReleaseGroup{
+		ID:          g.id,
+		Name:        g.name,
+		ReleaseDate: g.releaseDate,
+		Added:       g.added,
+		AddedBy:     d.userRef(g.addedBy),
+		Type:        g.typ,
+		Tags:        tagValues(d.releaseGroupTags, g.tags),
+	}
+	for _, a := range g.artists {
+		artist := d.artists[a.artist]
+		res.Artists = append(res.Artists, db.RoledArtist{
+			Role: a.role,
+			Artist: db.Artist{
+				ID:   artist.id,
+				Name: artist.name,
+				Bio:  artist.bio,
+			},
+		})
+	}
+	return res
+}
+
+func (d *DB) releaseGroupIDs() []int {
+	var ids []int
+	for id := range d.releaseGroups {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (d *DB) AutocompleteReleaseGroups(ctx context.Context, s string) ([]db.ReleaseGroup, error) {
+	if len(s) == 0 {
+		return nil, errors.New("missing s")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	re := contains(s, true)
+	var groups []db.ReleaseGroup
+	for _, id := range d.releaseGroupIDs() {
+		g := d.releaseGroups[id]
+		if re.MatchString(g.name) {
+			groups = append(groups, d.releaseGroup(g))
+		}
+	}
+
+	return groups, nil
+}
+
+func (d *DB) AutocompleteReleaseGroupTags(ctx context.Context, s string) ([]string, error) {
+	if len(s) == 0 {
+		return nil, errors.New("missing s")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	return d.releaseGroupTags.match(contains(s, false)), nil
+}
+
+func (d *DB) GetReleaseGroup(ctx context.Context, id int) (*db.ReleaseGroup, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	g, ok := d.releaseGroups[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	group := d.releaseGroup(g)
+	return &group, nil
+}
+
+// checkReleaseGroup checks the type, tags and artists of a release group
+// like the constraints of the postgres schema.
+func (d *DB) checkReleaseGroup(group db.ReleaseGroup) error {
+	if _, ok := d.releaseGroupTypes[group.Type]; !ok {
+		return fmt.Errorf("foreign key violation: release group type %d does not exist", group.Type)
+	}
+	err := checkTags(group.Tags)
+	if err != nil {
+		return err
+	}
+
+	roled := make(map[roledArtistRow]struct{})
+	for _, a := range group.Artists {
+		r := roledArtistRow{artist: a.Artist.ID, role: a.Role}
+		if _, ok := roled[r]; ok {
+			return fmt.Errorf("duplicate artist %d with role %d", r.artist, r.role)
+		}
+		roled[r] = struct{}{}
+
+		if _, ok := d.artists[r.artist]; !ok {
+			return fmt.Errorf("foreign key violation: artist %d does not exist", r.artist)
+		}
+		if _, ok := d.releaseRoles[r.role]; !ok {
+			return fmt.Errorf("foreign key violation: role %d does not exist", r.role)
+		}
+	}
+
+	return nil
+}
+
+// pruneReleaseGroupTags removes release group tags that are not used by any
+// release group anymore.
+func (d *DB) pruneReleaseGroupTags() {
+	d.releaseGroupTags.prune(func(id int) bool {
+		for _, g := range d.releaseGroups {
+			for _, t := range g.tags {
+				if t == id {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+func (d *DB) InsertReleaseGroup(ctx context.Context, group *db.ReleaseGroup) error {
+	if group.AddedBy.ID < 0 {
+		return errors.New("invalid user ID")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	err = d.checkUser(group.AddedBy.ID)
+	if err != nil {
+		return err
+	}
+	err = d.checkReleaseGroup(*group)
+	if err != nil {
+		return err
+	}
+
+	d.releaseGroupSeq++
+	group.ID = d.releaseGroupSeq
+
+	g := &releaseGroupRow{
+		id:          group.ID,
+		name:        group.Name,
+		releaseDate: timestamp(group.ReleaseDate),
+		added:       timestamp(group.Added),
+		addedBy:     group.AddedBy.ID,
+		typ:         group.Type,
+	}
+	g.tags, _ = insertTags(d.releaseGroupTags, group.Tags)
+	for _, a := range group.Artists {
+		g.artists = append(g.artists, roledArtistRow{artist: a.Artist.ID, role: a.Role})
+	}
+	d.releaseGroups[g.id] = g
+
+	return d.recordRevision(db.RevisionReleaseGroup, g.id, db.Edit{Edited: group.Added, EditedBy: group.AddedBy})
+}
+
+// updateReleaseGroup updates an existing release group.
+func (d *DB) updateReleaseGroup(group db.ReleaseGroup) error {
+	err := d.checkReleaseGroup(group)
+	if err != nil {
+		return err
+	}
+
+	g := d.releaseGroups[group.ID]
+	g.name = group.Name
+	g.releaseDate = timestamp(group.ReleaseDate)
+	g.typ = group.Type
+	g.tags, _ = insertTags(d.releaseGroupTags, group.Tags)
+	g.artists = nil
+	for _, a := range group.Artists {
+		g.artists = append(g.artists, roledArtistRow{artist: a.Artist.ID, role: a.Role})
+	}
+	d.pruneReleaseGroupTags()
+
+	return nil
+}
+
+func (d *DB) UpdateReleaseGroup(ctx context.Context, group db.ReleaseGroup, e db.Edit) error {
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(db.RevisionReleaseGroup, group.ID, e, func() error {
+		return d.updateReleaseGroup(group)
+	})
+}
+
+func (d *DB) DeleteReleaseGroup(ctx context.Context, id int) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	if _, ok := d.releaseGroups[id]; !ok {
+		return errors.New("release group not found")
+	}
+	for _, r := range d.releases {
+		if r.releaseGroup == id {
+			return errors.New("release group has releases")
+		}
+	}
+
+	delete(d.releaseGroups, id)
+	d.pruneReleaseGroupTags()
+
+	return nil
+}
+
+func (d *DB) PopulateReleases(ctx context.Context, group *db.ReleaseGroup) error {
+	if group.ID < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.RUnlock()
+
+	for _, id := range d.releaseIDs() {
+		r := d.releases[id]
+		if r.releaseGroup != group.ID {
+			continue
+		}
+
+		tmp := d.release(r)
+		tmp.ReleaseGroup = db.ReleaseGroup{}
+		group.Releases = append(group.Releases, tmp)
+	}
+
+	return nil
+}
+
+// searchReleaseGroups returns all release groups matching q, sorted.
+// Release groups the sorter of q considers equal are ordered by ID.
+func (d *DB) searchReleaseGroups(q *db.Query) ([]*releaseGroupRow, error) {
+	var matches []*releaseGroupRow
+	for _, id := range d.releaseGroupIDs() {
+		g := d.releaseGroups[id]
+		ok, err := q.Match(d.releaseGroupColumns(g))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, g)
+		}
+	}
+
+	var err error
+	sort.SliceStable(matches, func(i, j int) bool {
+		less, lessErr := q.Less(d.releaseGroupColumns(matches[i]), d.releaseGroupColumns(matches[j]))
+		if lessErr != nil && err == nil {
+			err = lessErr
+		}
+		return less
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+func (d *DB) SearchReleaseGroups(ctx context.Context, q *db.Query, offset, limit int) ([]db.ReleaseGroup, error) {
+	if q == nil {
+		return nil, errors.New("missing q")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	matches, err := d.searchReleaseGroups(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []db.ReleaseGroup
+	for i := offset; i < len(matches) && i < offset+limit; i++ {
+		groups = append(groups, d.releaseGroup(matches[i]))
+	}
+
+	return groups, nil
+}
+
+func (d *DB) CountReleaseGroups(ctx context.Context, q *db.Query) (int, error) {
+	if q == nil {
+		return 0, errors.New("missing q")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer d.mu.RUnlock()
+
+	matches, err := d.searchReleaseGroups(q)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(matches), nil
+}
+
+func (d *DB) GetReleaseGroupFacets(ctx context.Context, q *db.Query) (*db.ReleaseGroupFacets, error) {
+	if q == nil {
+		return nil, errors.New("missing q")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	matches, err := d.searchReleaseGroups(q)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := db.NewReleaseGroupFacets()
+	for _, g := range matches {
+		facets.Types[g.typ]++
+		facets.Decades[g.releaseDate.Year()/10*10]++
+		for _, t := range g.tags {
+			facets.Tags[d.releaseGroupTags.value(t)]++
+		}
+
+		media := make(map[int]struct{})
+		formats := make(map[int]struct{})
+		leechTypes := make(map[int]struct{})
+		for _, r := range d.releases {
+			if r.releaseGroup != g.id {
+				continue
+			}
+			media[r.medium] = struct{}{}
+
+			for _, t := range d.torrents {
+				if t.release == r.id {
+					formats[t.format] = struct{}{}
+					leechTypes[t.leechType] = struct{}{}
+				}
+			}
+		}
+		for m := range media {
+			facets.Media[m]++
+		}
+		for f := range formats {
+			facets.Formats[f]++
+		}
+		for l := range leechTypes {
+			facets.LeechTypes[l]++
+		}
+	}
+
+	return facets, nil
+}
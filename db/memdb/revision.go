@@ -0,0 +1,566 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type revisionKey struct {
+	entityType string
+	entity     int
+}
+
+type revisionRow struct {
+	id       int
+	snapshot json.RawMessage
+	diff     json.RawMessage
+	edited   time.Time
+	editedBy int
+}
+
+func validRevisionEntityType(entityType string) bool {
+	switch entityType {
+	case db.RevisionArtist, db.RevisionReleaseGroup, db.RevisionRelease, db.RevisionRecordLabel:
+		return true
+	}
+	return false
+}
+
+func (d *DB) revision(k revisionKey, i int) db.Revision {
+	r := d.revisions[k][i]
+	return db.Revision{
+		ID:         r.id,
+		EntityType: k.entityType,
+		Entity:     k.entity,
+		Revision:   i + 1,
+		Snapshot:   r.snapshot,
+		Diff:       r.diff,
+		Edited:     r.edited,
+		EditedBy:   d.userRef(r.editedBy),
+	}
+}
+
+func (d *DB) artistSnapshot(a *artistRow) *db.ArtistSnapshot {
+	s := db.ArtistSnapshot{
+		Name: a.name,
+		Tags: tagValues(d.artistTags, a.tags),
+	}
+	if a.bio.Valid {
+		s.Bio = &a.bio.String
+	}
+	for _, al := range a.aliases {
+		s.Aliases = append(s.Aliases, al.alias)
+	}
+
+	s.Normalize()
+	return &s
+}
+
+func (d *DB) releaseGroupSnapshot(g *releaseGroupRow) *db.ReleaseGroupSnapshot {
+	s := db.ReleaseGroupSnapshot{
+		Name:        g.name,
+		Type:        d.releaseGroupTypes[g.typ],
+		ReleaseDate: g.releaseDate,
+		Tags:        tagValues(d.releaseGroupTags, g.tags),
+	}
+	for _, ra := range g.artists {
+		s.Artists = append(s.Artists, db.SnapshotArtist{
+			Artist: ra.artist,
+			Role:   d.releaseRoles[ra.role],
+		})
+	}
+
+	s.Normalize()
+	return &s
+}
+
+func (d *DB) releaseSnapshot(r *releaseRow) *db.ReleaseSnapshot {
+	s := db.ReleaseSnapshot{
+		ReleaseGroup: r.releaseGroup,
+		Medium:       d.media[r.medium],
+		ReleaseDate:  r.releaseDate,
+		RecordLabel:  r.recordLabel,
+		Original:     r.original,
+		Tags:         tagValues(d.releaseTags, r.tags),
+	}
+	if r.edition.Valid {
+		s.Edition = &r.edition.String
+	}
+	if r.catalogueNumber.Valid {
+		s.CatalogueNumber = &r.catalogueNumber.String
+	}
+	for k, v := range r.properties {
+		if s.Properties == nil {
+			s.Properties = make(map[string]string)
+		}
+		s.Properties[d.releaseProperties.value(k)] = v.String
+	}
+
+	s.Normalize()
+	return &s
+}
+
+func recordLabelSnapshot(l *recordLabelRow) *db.RecordLabelSnapshot {
+	s := db.RecordLabelSnapshot{
+		Name: l.name,
+	}
+	if l.description.Valid {
+		s.Description = &l.description.String
+	}
+	if l.founded.Valid {
+		s.Founded = &l.founded.Time
+	}
+	if l.parent.Valid {
+		p := int(l.parent.Int64)
+		s.Parent = &p
+	}
+
+	return &s
+}
+
+// snapshot returns the current snapshot of an entity, and the time and
+// user it was added at and by.
+// It returns sql.ErrNoRows if the entity does not exist.
+func (d *DB) snapshot(entityType string, id int) (interface{}, db.Edit, error) {
+	switch entityType {
+	case db.RevisionArtist:
+		if a, ok := d.artists[id]; ok {
+			return d.artistSnapshot(a), db.Edit{Edited: a.added, EditedBy: d.userRef(a.addedBy)}, nil
+		}
+	case db.RevisionReleaseGroup:
+		if g, ok := d.releaseGroups[id]; ok {
+			return d.releaseGroupSnapshot(g), db.Edit{Edited: g.added, EditedBy: d.userRef(g.addedBy)}, nil
+		}
+	case db.RevisionRelease:
+		if r, ok := d.releases[id]; ok {
+			return d.releaseSnapshot(r), db.Edit{Edited: r.added, EditedBy: d.userRef(r.addedBy)}, nil
+		}
+	case db.RevisionRecordLabel:
+		if l, ok := d.recordLabels[id]; ok {
+			return recordLabelSnapshot(l), db.Edit{Edited: l.added, EditedBy: d.userRef(l.addedBy)}, nil
+		}
+	default:
+		return nil, db.Edit{}, fmt.Errorf("unknown entity type %q", entityType)
+	}
+
+	return nil, db.Edit{}, sql.ErrNoRows
+}
+
+// recordRevision records the current state of an entity as a new revision.
+func (d *DB) recordRevision(entityType string, id int, e db.Edit) error {
+	s, _, err := d.snapshot(entityType, id)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	k := revisionKey{entityType: entityType, entity: id}
+	var previous json.RawMessage
+	if n := len(d.revisions[k]); n > 0 {
+		previous = d.revisions[k][n-1].snapshot
+	}
+	diff, err := db.DiffSnapshots(previous, b)
+	if err != nil {
+		return err
+	}
+
+	d.revisionSeq++
+	d.revisions[k] = append(d.revisions[k], &revisionRow{
+		id:       d.revisionSeq,
+		snapshot: b,
+		diff:     diff,
+		edited:   timestamp(e.Edited),
+		editedBy: e.EditedBy.ID,
+	})
+
+	return nil
+}
+
+// beginEdit records the current state of an entity attributed to the user
+// who added it, if the entity has no revisions yet.
+// It returns sql.ErrNoRows if the entity does not exist.
+func (d *DB) beginEdit(entityType string, id int) error {
+	_, added, err := d.snapshot(entityType, id)
+	if err != nil {
+		return err
+	}
+	if len(d.revisions[revisionKey{entityType: entityType, entity: id}]) > 0 {
+		return nil
+	}
+
+	return d.recordRevision(entityType, id, added)
+}
+
+// edit runs f and records the resulting state of the entity as a new
+// revision, like the transactions of the postgres implementation.
+// f must not change anything if it returns an error.
+// The caller must hold the write lock.
+func (d *DB) edit(entityType string, id int, e db.Edit, f func() error) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+	err := d.checkUser(e.EditedBy.ID)
+	if err != nil {
+		return err
+	}
+
+	k := revisionKey{entityType: entityType, entity: id}
+	n := len(d.revisions[k])
+	err = d.beginEdit(entityType, id)
+	if err != nil {
+		return err
+	}
+
+	err = f()
+	if err != nil {
+		// roll back the revision recorded by beginEdit
+		d.revisions[k] = d.revisions[k][:n]
+		if n == 0 {
+			delete(d.revisions, k)
+		}
+		return err
+	}
+
+	return d.recordRevision(entityType, id, e)
+}
+
+func containsString(s []string, v string) bool {
+	for _, tmp := range s {
+		if tmp == v {
+			return true
+		}
+	}
+	return false
+}
+
+// revertArtist restores the name, bio, aliases and tags of an artist.
+// Restored aliases are attributed to the reverting user.
+func (d *DB) revertArtist(id int, b json.RawMessage, e db.Edit) error {
+	var s db.ArtistSnapshot
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	a := d.artists[id]
+	var (
+		aliases []aliasRow
+		current []string
+	)
+	for _, al := range a.aliases {
+		current = append(current, al.alias)
+		if containsString(s.Aliases, al.alias) {
+			aliases = append(aliases, al)
+		}
+	}
+	for _, alias := range s.Aliases {
+		if !containsString(current, alias) {
+			aliases = append(aliases, aliasRow{
+				alias:   alias,
+				added:   timestamp(e.Edited),
+				addedBy: e.EditedBy.ID,
+			})
+		}
+	}
+
+	a.name = s.Name
+	a.bio = sql.NullString{}
+	if s.Bio != nil {
+		a.bio = sql.NullString{String: *s.Bio, Valid: true}
+	}
+	a.aliases = aliases
+	a.tags, _ = insertTags(d.artistTags, s.Tags)
+	d.pruneArtistTags()
+
+	return nil
+}
+
+// revertReleaseGroup restores a release group.
+// Artists that were merged into another artist since are replaced by that
+// artist.
+func (d *DB) revertReleaseGroup(id int, b json.RawMessage) error {
+	var s db.ReleaseGroupSnapshot
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	group := db.ReleaseGroup{
+		ID:          id,
+		Name:        s.Name,
+		ReleaseDate: s.ReleaseDate,
+		Tags:        s.Tags,
+	}
+	var ok bool
+	group.Type, ok = lookUpValue(d.releaseGroupTypes, s.Type)
+	if !ok {
+		return fmt.Errorf("unknown release group type %q", s.Type)
+	}
+
+	seen := make(map[roledArtistRow]bool)
+	for _, ra := range s.Artists {
+		role, ok := lookUpValue(d.releaseRoles, ra.Role)
+		if !ok {
+			return fmt.Errorf("unknown role %q", ra.Role)
+		}
+		artist := ra.Artist
+		if r, ok := d.artistRedirects[artist]; ok {
+			artist = r.target
+		}
+
+		// the artist might have been merged into another credited artist
+		key := roledArtistRow{artist: artist, role: role}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		group.Artists = append(group.Artists, db.RoledArtist{Role: role, Artist: db.Artist{ID: artist}})
+	}
+
+	return d.updateReleaseGroup(group)
+}
+
+// revertRelease restores a release.
+// Properties set after the revision are kept, as they can not be removed.
+func (d *DB) revertRelease(id int, b json.RawMessage) error {
+	var s db.ReleaseSnapshot
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	release := db.Release{
+		ID:          id,
+		ReleaseDate: s.ReleaseDate,
+		RecordLabel: db.RecordLabel{ID: s.RecordLabel},
+		Original:    s.Original,
+		Tags:        s.Tags,
+	}
+	if s.Edition != nil {
+		release.Edition = sql.NullString{String: *s.Edition, Valid: true}
+	}
+	if s.CatalogueNumber != nil {
+		release.CatalogueNumber = sql.NullString{String: *s.CatalogueNumber, Valid: true}
+	}
+	var ok bool
+	release.Medium, ok = lookUpValue(d.media, s.Medium)
+	if !ok {
+		return fmt.Errorf("unknown medium %q", s.Medium)
+	}
+	properties := make(map[int]sql.NullString)
+	for k, v := range s.Properties {
+		property, ok := d.releaseProperties.lookUp(k)
+		if !ok {
+			return fmt.Errorf("unknown release property %q", k)
+		}
+		properties[property] = nullString(sql.NullString{String: v})
+	}
+
+	err = d.updateRelease(release)
+	if err != nil {
+		return err
+	}
+
+	r := d.releases[id]
+	for k, v := range properties {
+		r.properties[k] = v
+	}
+
+	return nil
+}
+
+func (d *DB) revertRecordLabel(id int, b json.RawMessage) error {
+	var s db.RecordLabelSnapshot
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	label := db.RecordLabel{ID: id, Name: s.Name}
+	if s.Description != nil {
+		label.Description = sql.NullString{String: *s.Description, Valid: true}
+	}
+	if s.Founded != nil {
+		label.Founded = pq.NullTime{Time: *s.Founded, Valid: true}
+	}
+	if s.Parent != nil {
+		label.Parent = &db.RecordLabel{ID: *s.Parent}
+	}
+
+	return d.updateRecordLabel(label)
+}
+
+func (d *DB) RevertRevision(ctx context.Context, entityType string, id, revision int, e db.Edit) error {
+	if !validRevisionEntityType(entityType) {
+		return errors.New("invalid entity type")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	return d.edit(entityType, id, e, func() error {
+		revisions := d.revisions[revisionKey{entityType: entityType, entity: id}]
+		if revision < 1 || revision > len(revisions) {
+			return sql.ErrNoRows
+		}
+		b := revisions[revision-1].snapshot
+
+		switch entityType {
+		case db.RevisionArtist:
+			return d.revertArtist(id, b, e)
+		case db.RevisionReleaseGroup:
+			return d.revertReleaseGroup(id, b)
+		case db.RevisionRelease:
+			return d.revertRelease(id, b)
+		}
+		return d.revertRecordLabel(id, b)
+	})
+}
+
+func (d *DB) GetRevision(ctx context.Context, entityType string, id, revision int) (*db.Revision, error) {
+	if !validRevisionEntityType(entityType) {
+		return nil, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	k := revisionKey{entityType: entityType, entity: id}
+	if revision < 1 || revision > len(d.revisions[k]) {
+		return nil, sql.ErrNoRows
+	}
+
+	r := d.revision(k, revision-1)
+	return &r, nil
+}
+
+func (d *DB) GetRevisions(ctx context.Context, entityType string, id, offset, limit int) ([]db.Revision, error) {
+	if !validRevisionEntityType(entityType) {
+		return nil, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	k := revisionKey{entityType: entityType, entity: id}
+	var revisions []db.Revision
+	for i := len(d.revisions[k]) - 1 - offset; i >= 0 && len(revisions) < limit; i-- {
+		revisions = append(revisions, d.revision(k, i))
+	}
+
+	return revisions, nil
+}
+
+func (d *DB) GetRevisionsAfter(ctx context.Context, entityType string, id, revision, limit int) ([]db.Revision, error) {
+	if !validRevisionEntityType(entityType) {
+		return nil, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	// revision n is at index n-1
+	k := revisionKey{entityType: entityType, entity: id}
+	start := revision - 2
+	if start > len(d.revisions[k])-1 {
+		start = len(d.revisions[k]) - 1
+	}
+	var revisions []db.Revision
+	for i := start; i >= 0 && len(revisions) < limit; i-- {
+		revisions = append(revisions, d.revision(k, i))
+	}
+
+	return revisions, nil
+}
+
+func (d *DB) GetRevisionsBefore(ctx context.Context, entityType string, id, revision, limit int) ([]db.Revision, error) {
+	if !validRevisionEntityType(entityType) {
+		return nil, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	// revision n is at index n-1
+	k := revisionKey{entityType: entityType, entity: id}
+	if revision < 0 {
+		revision = 0
+	}
+	start := revision - 1 + limit
+	if start > len(d.revisions[k])-1 {
+		start = len(d.revisions[k]) - 1
+	}
+	var revisions []db.Revision
+	for i := start; i >= revision; i-- {
+		revisions = append(revisions, d.revision(k, i))
+	}
+
+	return revisions, nil
+}
+
+func (d *DB) CountRevisions(ctx context.Context, entityType string, id int) (int, error) {
+	if !validRevisionEntityType(entityType) {
+		return 0, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return 0, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer d.mu.RUnlock()
+
+	return len(d.revisions[revisionKey{entityType: entityType, entity: id}]), nil
+}
@@ -0,0 +1,260 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+type torrentRow struct {
+	id          int
+	release     int
+	uploaded    time.Time
+	uploader    int
+	infoHash    [20]byte
+	info        []byte
+	format      int
+	size        int64
+	description sql.NullString
+	files       []db.TorrentFile
+
+	leechType       int
+	seeders         int
+	leechers        int
+	snatches        int
+	totalUploaded   int64
+	totalDownloaded int64
+}
+
+// torrent returns the torrent without its info and file list.
+func (d *DB) torrent(t *torrentRow) db.Torrent {
+	return db.Torrent{
+		ID:              t.id,
+		Release:         db.Release{ID: t.release},
+		Uploaded:        t.uploaded,
+		UploadedBy:      d.userRef(t.uploader),
+		InfoHash:        t.infoHash,
+		Format:          t.format,
+		Size:            t.size,
+		Description:     t.description,
+		LeechType:       t.leechType,
+		Seeders:         t.seeders,
+		Leechers:        t.leechers,
+		Snatches:        t.snatches,
+		TotalUploaded:   t.totalUploaded,
+		TotalDownloaded: t.totalDownloaded,
+	}
+}
+
+// torrentColumns provides the columns of the torrents table joined with
+// torrent_trackerdata to evaluate queries.
+func torrentColumns(t *torrentRow) db.Row {
+	return func(column string) (interface{}, error) {
+		switch column {
+		case "id":
+			return t.id, nil
+		case "release":
+			return t.release, nil
+		case "uploaded":
+			return t.uploaded, nil
+		case "uploader":
+			return t.uploader, nil
+		case "format":
+			return t.format, nil
+		case "size":
+			return t.size, nil
+		case "description":
+			return nullValue(t.description), nil
+		case "leech_type":
+			return t.leechType, nil
+		case "seeders":
+			return t.seeders, nil
+		case "leechers":
+			return t.leechers, nil
+		case "snatches":
+			return t.snatches, nil
+		}
+		return nil, fmt.Errorf("column %q does not exist", column)
+	}
+}
+
+func (d *DB) torrentByInfoHash(infoHash [20]byte) (*torrentRow, bool) {
+	for _, t := range d.torrents {
+		if t.infoHash == infoHash {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (d *DB) InsertTorrent(ctx context.Context, torrent *db.Torrent) error {
+	if torrent == nil {
+		return errors.New("missing torrent")
+	}
+	if torrent.Release.ID < 0 {
+		return errors.New("invalid release ID")
+	}
+	if torrent.UploadedBy.ID < 0 {
+		return errors.New("invalid user ID")
+	}
+	if len(torrent.Info) == 0 {
+		return errors.New("missing info")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	if _, ok := d.releases[torrent.Release.ID]; !ok {
+		return fmt.Errorf("foreign key violation: release %d does not exist", torrent.Release.ID)
+	}
+	err = d.checkUser(torrent.UploadedBy.ID)
+	if err != nil {
+		return err
+	}
+	if _, ok := d.formats[torrent.Format]; !ok {
+		return fmt.Errorf("foreign key violation: format %d does not exist", torrent.Format)
+	}
+	if _, ok := d.leechTypes[torrent.LeechType]; !ok {
+		return fmt.Errorf("foreign key violation: leech type %d does not exist", torrent.LeechType)
+	}
+	if _, ok := d.torrentByInfoHash(torrent.InfoHash); ok {
+		return errors.New("unique violation: duplicate info hash")
+	}
+
+	d.torrentSeq++
+	torrent.ID = d.torrentSeq
+
+	d.torrents[torrent.ID] = &torrentRow{
+		id:          torrent.ID,
+		release:     torrent.Release.ID,
+		uploaded:    timestamp(torrent.Uploaded),
+		uploader:    torrent.UploadedBy.ID,
+		infoHash:    torrent.InfoHash,
+		info:        append([]byte(nil), torrent.Info...),
+		format:      torrent.Format,
+		size:        torrent.Size,
+		description: nullString(torrent.Description),
+		files:       append([]db.TorrentFile(nil), torrent.FileList...),
+		leechType:   torrent.LeechType,
+	}
+
+	return nil
+}
+
+func (d *DB) GetTorrent(ctx context.Context, id int) (*db.Torrent, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	t, ok := d.torrents[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	torrent := d.torrent(t)
+	torrent.FileList = append(torrent.FileList, t.files...)
+	return &torrent, nil
+}
+
+func (d *DB) GetTorrentByInfoHash(ctx context.Context, infoHash [20]byte) (*db.Torrent, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	t, ok := d.torrentByInfoHash(infoHash)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	torrent := d.torrent(t)
+	return &torrent, nil
+}
+
+func (d *DB) GetTorrentInfo(ctx context.Context, id int) ([]byte, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	t, ok := d.torrents[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return append([]byte(nil), t.info...), nil
+}
+
+func (d *DB) PopulateTorrents(ctx context.Context, release *db.Release) error {
+	if release.ID < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.RUnlock()
+
+	var ids []int
+	for id, t := range d.torrents {
+		if t.release == release.ID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		t := d.torrents[id]
+		torrent := d.torrent(t)
+		torrent.FileList = append(torrent.FileList, t.files...)
+		release.Torrents = append(release.Torrents, torrent)
+	}
+
+	return nil
+}
+
+func (d *DB) DeleteTorrent(ctx context.Context, id int) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	if _, ok := d.torrents[id]; !ok {
+		return errors.New("torrent not found")
+	}
+
+	for i := range d.statChanges {
+		if d.statChanges[i].torrent == id {
+			d.statChanges[i].torrent = 0
+		}
+	}
+	delete(d.torrents, id)
+
+	return nil
+}
@@ -0,0 +1,364 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+func (d *DB) checkUniqueUser(username, email string) error {
+	for _, u := range d.users {
+		if u.Username == username {
+			return errors.New("unique violation: username already exists")
+		}
+		if u.Email == email {
+			return errors.New("unique violation: email already exists")
+		}
+	}
+	return nil
+}
+
+func (d *DB) setPrivilege(uid, privilege int, granted bool) {
+	p, ok := d.userPrivileges[uid]
+	if !ok {
+		p = make(map[int]bool)
+		d.userPrivileges[uid] = p
+	}
+	p[privilege] = granted
+}
+
+func (d *DB) UpdateUserSetLastLogin(ctx context.Context, id int, lastLogin time.Time) error {
+	if id < 0 {
+		return errors.New("invalid id")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	u, ok := d.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+
+	u.LastLogin = nullTime(timestamp(lastLogin))
+	u.LastAccess = u.LastLogin
+	return nil
+}
+
+func (d *DB) UpdateUserSetLastAccess(ctx context.Context, id int, lastAccess time.Time) error {
+	if id < 0 {
+		return errors.New("invalid id")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	u, ok := d.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+
+	u.LastAccess = nullTime(timestamp(lastAccess))
+	return nil
+}
+
+// UpdateUserAddPrivileges grants privileges to a user.
+// Like users_privileges, which has no foreign keys, it accepts unknown users
+// and privileges.
+func (d *DB) UpdateUserAddPrivileges(ctx context.Context, id int, privileges []int) error {
+	return d.updateUserPrivileges(ctx, id, privileges, true)
+}
+
+// UpdateUserDenyPrivileges denies privileges to a user.
+// Like UpdateUserAddPrivileges, it accepts unknown users and privileges.
+func (d *DB) UpdateUserDenyPrivileges(ctx context.Context, id int, privileges []int) error {
+	return d.updateUserPrivileges(ctx, id, privileges, false)
+}
+
+func (d *DB) UpdateUserRemovePrivileges(ctx context.Context, id int, privileges []int) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	for _, p := range privileges {
+		delete(d.userPrivileges[id], p)
+	}
+
+	return nil
+}
+
+func (d *DB) GetUserPrivilegeGrants(ctx context.Context, id int) (map[int]bool, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	m := make(map[int]bool)
+	for p, granted := range d.userPrivileges[id] {
+		m[p] = granted
+	}
+
+	return m, nil
+}
+
+func (d *DB) updateUserPrivileges(ctx context.Context, id int, privileges []int, granted bool) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	for _, p := range privileges {
+		d.setPrivilege(id, p, granted)
+	}
+
+	return nil
+}
+
+func (d *DB) UpdateUserDeltaUpDown(ctx context.Context, id, deltaUp, deltaDown int) error {
+	if id < 0 {
+		return errors.New("invalid id")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	u, ok := d.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+
+	u.Uploaded += int64(deltaUp)
+	u.Downloaded += int64(deltaDown)
+	return nil
+}
+
+func (d *DB) UpdateUserSetRatioWatch(ctx context.Context, id int, until pq.NullTime) error {
+	if id < 0 {
+		return errors.New("invalid id")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	u, ok := d.users[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+
+	u.RatioWatchUntil = nullTimestamp(until)
+	return nil
+}
+
+func (d *DB) GetUserRatioStats(ctx context.Context) ([]db.UserRatioStats, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var stats []db.UserRatioStats
+	for _, u := range d.users {
+		if !u.Enabled {
+			continue
+		}
+		stats = append(stats, db.UserRatioStats{
+			User:            u.ID,
+			Uploaded:        u.Uploaded,
+			Downloaded:      u.Downloaded,
+			RatioWatchUntil: u.RatioWatchUntil,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].User < stats[j].User })
+
+	return stats, nil
+}
+
+func hashSignUpPassword(username, password, email string) ([]byte, error) {
+	if len(username) == 0 || len(password) == 0 || len(email) == 0 {
+		return nil, errors.New("missing username/password/email")
+	}
+
+	if len(password) < 12 {
+		return nil, errors.New("password does not meet the requirements")
+	}
+
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+// insertSignUp inserts a signed up user and returns their ID.
+// The caller must hold the write lock.
+func (d *DB) insertSignUp(username, email string, pwHash []byte) (int, error) {
+	err := d.checkUniqueUser(username, email)
+	if err != nil {
+		return 0, err
+	}
+
+	id := d.userSeq
+	d.userSeq++
+	d.users[id] = &db.User{
+		ID:           id,
+		Username:     username,
+		Email:        email,
+		PasswordHash: string(pwHash),
+		Enabled:      true,
+		CanLogin:     true,
+		JoinedAt:     now(),
+	}
+
+	return id, nil
+}
+
+func (d *DB) SignUpUser(ctx context.Context, username, password, email string) error {
+	pwHash, err := hashSignUpPassword(username, password, email)
+	if err != nil {
+		return err
+	}
+
+	err = d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	_, err = d.insertSignUp(username, email, pwHash)
+	return err
+}
+
+func (d *DB) PopulateUserPrivileges(ctx context.Context, u *db.User) error {
+	if u.ID < 0 {
+		return errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.RUnlock()
+
+	effective := make(map[int]struct{})
+	if tmp, ok := d.users[u.ID]; ok {
+		for p := range d.userClassPrivileges[tmp.Class] {
+			effective[p] = struct{}{}
+		}
+	}
+	for p, granted := range d.userPrivileges[u.ID] {
+		if granted {
+			effective[p] = struct{}{}
+		} else {
+			delete(effective, p)
+		}
+	}
+
+	var privileges []int
+	for p := range effective {
+		privileges = append(privileges, p)
+	}
+	sort.Ints(privileges)
+
+	u.Privileges = append(u.Privileges, privileges...)
+	return nil
+}
+
+// getUser returns a copy of the user as returned by GetUser.
+func (d *DB) getUser(id int) (*db.User, bool) {
+	u, ok := d.users[id]
+	if !ok {
+		return nil, false
+	}
+	tmp := *u
+	return &tmp, true
+}
+
+func (d *DB) GetUser(ctx context.Context, id int) (*db.User, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	u, ok := d.getUser(id)
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+
+	return u, nil
+}
+
+func (d *DB) LoginAndGetUser(ctx context.Context, username, password string) (*db.User, error) {
+	if len(username) == 0 || len(password) == 0 {
+		return nil, errors.New("missing username/password")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *db.User
+	for id, u := range d.users {
+		if u.Username == username {
+			user, _ = d.getUser(id)
+			break
+		}
+	}
+	d.mu.RUnlock()
+
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	if !user.Enabled {
+		return nil, errors.New("user disabled")
+	}
+	if !user.CanLogin {
+		return nil, errors.New("login disabled")
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		if err != bcrypt.ErrMismatchedHashAndPassword {
+			log.Warnln("Bcrypt error", log.Fields{"err": err})
+		}
+		return nil, errors.New("invalid password")
+	}
+
+	return user, nil
+}
@@ -0,0 +1,317 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+// sysOpClass is the highest class.
+const sysOpClass = 4
+
+// classPrivileges lists the privileges each class adds to the ones of the
+// classes below it, like the migrations.
+// SysOps have all privileges.
+var classPrivileges = map[int][]int{
+	0: {0, 10, 11, 12, 13, 29},
+	1: {15, 17, 19, 22, 25, 30, 37},
+	2: {16, 18, 20, 23, 26, 28, 31},
+	3: {1, 4, 5, 8, 9, 14, 21, 24, 27, 32, 33, 34, 35, 38, 39, 40},
+}
+
+// seedUserClasses must be called after the privileges are set up.
+func (d *DB) seedUserClasses() {
+	for class := range d.userClasses {
+		d.userClassPrivileges[class] = make(map[int]struct{})
+	}
+
+	for class, privileges := range classPrivileges {
+		for c := class; c < sysOpClass; c++ {
+			for _, p := range privileges {
+				d.userClassPrivileges[c][p] = struct{}{}
+			}
+		}
+	}
+	for p := range d.privileges {
+		d.userClassPrivileges[sysOpClass][p] = struct{}{}
+	}
+}
+
+func (d *DB) checkUserClass(class int) error {
+	if _, ok := d.userClasses[class]; !ok {
+		return fmt.Errorf("foreign key violation: user class %d does not exist", class)
+	}
+	return nil
+}
+
+func (d *DB) GetAllUserClasses(ctx context.Context) (map[int]string, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	return copyMap(d.userClasses), nil
+}
+
+func (d *DB) GetUserClassPrivileges(ctx context.Context, class int) ([]int, error) {
+	if class < 0 {
+		return nil, errors.New("invalid class")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var privileges []int
+	for p := range d.userClassPrivileges[class] {
+		privileges = append(privileges, p)
+	}
+	sort.Ints(privileges)
+
+	return privileges, nil
+}
+
+type userClassChangeRow struct {
+	id        int
+	uid       int
+	changed   time.Time
+	from      int
+	to        int
+	reason    string
+	changedBy int // -1 for automatic changes
+}
+
+func (d *DB) SetUserClass(ctx context.Context, c *db.UserClassChange) error {
+	if c.User < 0 {
+		return errors.New("invalid ID")
+	}
+	if c.To < 0 {
+		return errors.New("invalid class")
+	}
+
+	err := d.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.mu.Unlock()
+
+	u, ok := d.users[c.User]
+	if !ok {
+		return errors.New("user not found")
+	}
+	if c.Conditional && u.Class != c.From {
+		return nil
+	}
+	c.From = u.Class
+	if c.From == c.To {
+		return nil
+	}
+
+	err = d.checkUserClass(c.To)
+	if err != nil {
+		return err
+	}
+	changedBy := -1
+	if c.ChangedBy != nil {
+		err = d.checkUser(c.ChangedBy.ID)
+		if err != nil {
+			return err
+		}
+		changedBy = c.ChangedBy.ID
+	}
+
+	u.Class = c.To
+	d.userClassHistorySeq++
+	d.userClassHistory = append(d.userClassHistory, &userClassChangeRow{
+		id:        d.userClassHistorySeq,
+		uid:       c.User,
+		changed:   timestamp(c.Changed),
+		from:      c.From,
+		to:        c.To,
+		reason:    c.Reason,
+		changedBy: changedBy,
+	})
+	c.ID = d.userClassHistorySeq
+	return nil
+}
+
+// classHistoryLess orders class changes like the database does, newest
+// first.
+func classHistoryLess(a, b db.UserClassChangeCursor) bool {
+	if !a.Changed.Equal(b.Changed) {
+		return a.Changed.After(b.Changed)
+	}
+	return a.ID > b.ID
+}
+
+func classHistoryCursor(row *userClassChangeRow) db.UserClassChangeCursor {
+	return db.UserClassChangeCursor{Changed: row.changed, ID: row.id}
+}
+
+// sortedUserClassHistory returns the class changes of a user matching f,
+// newest first.
+func (d *DB) sortedUserClassHistory(uid int, f func(row *userClassChangeRow) bool) []*userClassChangeRow {
+	var rows []*userClassChangeRow
+	for _, row := range d.userClassHistory {
+		if row.uid == uid && f(row) {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return classHistoryLess(classHistoryCursor(rows[i]), classHistoryCursor(rows[j]))
+	})
+	return rows
+}
+
+func (d *DB) userClassChange(row *userClassChangeRow) db.UserClassChange {
+	c := db.UserClassChange{
+		ID:      row.id,
+		User:    row.uid,
+		Changed: row.changed,
+		From:    row.from,
+		To:      row.to,
+		Reason:  row.reason,
+	}
+	if row.changedBy >= 0 {
+		u := d.userRef(row.changedBy)
+		c.ChangedBy = &u
+	}
+	return c
+}
+
+func (d *DB) GetUserClassHistory(ctx context.Context, uid, offset, limit int) ([]db.UserClassChange, error) {
+	if uid < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	rows := d.sortedUserClassHistory(uid, func(*userClassChangeRow) bool { return true })
+
+	var changes []db.UserClassChange
+	for i := offset; i < len(rows) && len(changes) < limit; i++ {
+		changes = append(changes, d.userClassChange(rows[i]))
+	}
+
+	return changes, nil
+}
+
+func (d *DB) GetUserClassHistoryAfter(ctx context.Context, uid int, c db.UserClassChangeCursor, limit int) ([]db.UserClassChange, error) {
+	if uid < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	rows := d.sortedUserClassHistory(uid, func(row *userClassChangeRow) bool { return classHistoryLess(c, classHistoryCursor(row)) })
+
+	var changes []db.UserClassChange
+	for i := 0; i < len(rows) && len(changes) < limit; i++ {
+		changes = append(changes, d.userClassChange(rows[i]))
+	}
+
+	return changes, nil
+}
+
+func (d *DB) GetUserClassHistoryBefore(ctx context.Context, uid int, c db.UserClassChangeCursor, limit int) ([]db.UserClassChange, error) {
+	if uid < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	rows := d.sortedUserClassHistory(uid, func(row *userClassChangeRow) bool { return classHistoryLess(classHistoryCursor(row), c) })
+	if len(rows) > limit {
+		rows = rows[len(rows)-limit:]
+	}
+
+	var changes []db.UserClassChange
+	for _, row := range rows {
+		changes = append(changes, d.userClassChange(row))
+	}
+
+	return changes, nil
+}
+
+func (d *DB) CountUserClassHistory(ctx context.Context, uid int) (int, error) {
+	if uid < 0 {
+		return 0, errors.New("invalid ID")
+	}
+
+	err := d.rlock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer d.mu.RUnlock()
+
+	count := 0
+	for _, row := range d.userClassHistory {
+		if row.uid == uid {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (d *DB) GetUserPromotionStats(ctx context.Context) ([]db.UserPromotionStats, error) {
+	err := d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	uploads := make(map[int]int)
+	for _, t := range d.torrents {
+		uploads[t.uploader]++
+	}
+
+	var stats []db.UserPromotionStats
+	for _, u := range d.users {
+		if !u.Enabled {
+			continue
+		}
+		stats = append(stats, db.UserPromotionStats{
+			User:       u.ID,
+			Class:      u.Class,
+			Uploaded:   u.Uploaded,
+			Downloaded: u.Downloaded,
+			JoinedAt:   u.JoinedAt,
+			Uploads:    uploads[u.ID],
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].User < stats[j].User })
+
+	return stats, nil
+}
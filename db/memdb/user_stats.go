@@ -0,0 +1,145 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+// truncate truncates t like date_trunc does.
+func truncate(t time.Time, bucket db.StatBucket) time.Time {
+	y, m, day := t.Date()
+	switch bucket {
+	case db.StatBucketHour:
+		return time.Date(y, m, day, t.Hour(), 0, 0, 0, t.Location())
+	case db.StatBucketDay:
+		return time.Date(y, m, day, 0, 0, 0, 0, t.Location())
+	case db.StatBucketWeek:
+		// weeks start on monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, day-offset, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+func validateStatRange(uid int, from, to time.Time) error {
+	if uid < 0 {
+		return errors.New("invalid ID")
+	}
+	if !from.Before(to) {
+		return errors.New("invalid time range")
+	}
+	return nil
+}
+
+// userStatChanges returns the stat changes of the user reported in [from, to).
+func (d *DB) userStatChanges(uid int, from, to time.Time) []statChange {
+	from, to = timestamp(from), timestamp(to)
+
+	var changes []statChange
+	for _, c := range d.statChanges {
+		if c.uid == uid && !c.reportedAt.Before(from) && c.reportedAt.Before(to) {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func (d *DB) GetUserStatHistory(ctx context.Context, uid int, from, to time.Time, bucket db.StatBucket) ([]db.UserStatPoint, error) {
+	err := validateStatRange(uid, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if !bucket.Valid() {
+		return nil, errors.New("invalid bucket")
+	}
+
+	err = d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var points []db.UserStatPoint
+	byBucket := make(map[int64]int)
+	for _, c := range d.userStatChanges(uid, from, to) {
+		b := truncate(c.reportedAt, bucket)
+		i, ok := byBucket[b.UnixNano()]
+		if !ok {
+			i = len(points)
+			byBucket[b.UnixNano()] = i
+			points = append(points, db.UserStatPoint{Bucket: b})
+		}
+
+		points[i].Uploaded += c.uploadedDelta
+		points[i].Downloaded += c.downloadedDelta
+		points[i].RawUploaded += c.rawUploaded
+		points[i].RawDownloaded += c.rawDownloaded
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Bucket.Before(points[j].Bucket)
+	})
+
+	return points, nil
+}
+
+func (d *DB) GetUserTorrentStats(ctx context.Context, uid int, from, to time.Time) ([]db.UserTorrentStats, error) {
+	err := validateStatRange(uid, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.rlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	var stats []db.UserTorrentStats
+	byTorrent := make(map[int]int)
+	for _, c := range d.userStatChanges(uid, from, to) {
+		i, ok := byTorrent[c.torrent]
+		if !ok {
+			i = len(stats)
+			byTorrent[c.torrent] = i
+			stats = append(stats, db.UserTorrentStats{
+				FirstAnnounce: c.reportedAt,
+				LastAnnounce:  c.reportedAt,
+			})
+			if c.torrent != 0 {
+				torrent := c.torrent
+				stats[i].Torrent = &torrent
+			}
+		}
+
+		s := &stats[i]
+		s.Uploaded += c.uploadedDelta
+		s.Downloaded += c.downloadedDelta
+		s.RawUploaded += c.rawUploaded
+		s.RawDownloaded += c.rawDownloaded
+		s.Announces++
+		if c.reportedAt.Before(s.FirstAnnounce) {
+			s.FirstAnnounce = c.reportedAt
+		}
+		if c.reportedAt.After(s.LastAnnounce) {
+			s.LastAnnounce = c.reportedAt
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].LastAnnounce.Equal(stats[j].LastAnnounce) {
+			// Like postgres, changes without torrent sort last.
+			if stats[i].Torrent == nil || stats[j].Torrent == nil {
+				return stats[j].Torrent == nil && stats[i].Torrent != nil
+			}
+			return *stats[i].Torrent < *stats[j].Torrent
+		}
+		return stats[i].LastAnnounce.After(stats[j].LastAnnounce)
+	})
+
+	return stats, nil
+}
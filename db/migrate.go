@@ -0,0 +1,333 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+//go:generate go run gen_migrations.go
+
+// Migration is a versioned change to the database schema.
+// Migrations live in the migrations directory as pairs of
+// <version>_<name>.up.sql and <version>_<name>.down.sql files and are compiled
+// into the binary by running go generate.
+// Down must revert Up and must be safe to run against a partially migrated
+// schema, i.e. use DROP ... IF EXISTS.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration together with the time it was applied.
+// AppliedAt is invalid for pending migrations.
+type MigrationStatus struct {
+	Migration
+	AppliedAt pq.NullTime
+}
+
+// migrationLockID is the key of the advisory lock held while migrating, so
+// that concurrent migrations wait for each other.
+const migrationLockID = 0x626f696c
+
+// Migrations returns all known migrations, ordered by version.
+func Migrations() []Migration {
+	out := make([]Migration, len(migrations))
+	copy(out, migrations)
+	return out
+}
+
+// LatestVersion returns the version of the most recent known migration.
+func LatestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func createMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL DEFAULT NOW())")
+	return err
+}
+
+func getAppliedMigrations(db *sql.DB) (map[int]pq.NullTime, error) {
+	rows, err := db.Query("SELECT version,applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]pq.NullTime)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt pq.NullTime
+		)
+		err = rows.Scan(
+			&version,
+			&appliedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// applyMigrationTx applies (or, if up is false, reverts) a migration.
+// It returns false if the migration had already been applied (or reverted) by
+// someone else.
+func applyMigrationTx(m Migration, up bool, tx *sql.Tx) (bool, error) {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID)
+	if err != nil {
+		return false, err
+	}
+
+	var applied bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", m.Version).Scan(&applied)
+	if err != nil {
+		return false, err
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	if up {
+		_, err = tx.Exec(m.Up)
+		if err != nil {
+			return false, fmt.Errorf("migration %d (%s): %s", m.Version, m.Name, err)
+		}
+
+		_, err = tx.Exec("INSERT INTO schema_migrations(version,name) VALUES ($1,$2)", m.Version, m.Name)
+		return err == nil, err
+	}
+
+	_, err = tx.Exec(m.Down)
+	if err != nil {
+		return false, fmt.Errorf("migration %d (%s): %s", m.Version, m.Name, err)
+	}
+
+	_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	return err == nil, err
+}
+
+func applyMigration(db *sql.DB, m Migration, up bool) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := applyMigrationTx(m, up, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return false, err
+	}
+
+	return ok, tx.Commit()
+}
+
+// MigrateUp applies all pending migrations up to and including the given
+// version, each in its own transaction.
+// If target is 0, all pending migrations are applied.
+// The applied migrations are returned, even if an error occurred.
+func MigrateUp(db *sql.DB, target int) ([]Migration, error) {
+	if target < 0 {
+		return nil, errors.New("invalid target version")
+	}
+	if target == 0 {
+		target = LatestVersion()
+	}
+
+	err := createMigrationsTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if m.Version > target {
+			break
+		}
+
+		ok, err := applyMigration(db, m, true)
+		if err != nil {
+			return done, err
+		}
+		if ok {
+			done = append(done, m)
+		}
+	}
+
+	return done, nil
+}
+
+// MigrateDown reverts the n most recently applied migrations, each in its own
+// transaction.
+// The reverted migrations are returned, even if an error occurred.
+func MigrateDown(db *sql.DB, n int) ([]Migration, error) {
+	if n < 0 {
+		return nil, errors.New("invalid number of migrations")
+	}
+
+	err := createMigrationsTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := getAppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < n; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		ok, err := applyMigration(db, m, false)
+		if err != nil {
+			return done, err
+		}
+		if ok {
+			done = append(done, m)
+		}
+	}
+
+	return done, nil
+}
+
+func baselineTx(tx *sql.Tx) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID)
+	if err != nil {
+		return err
+	}
+
+	var migrated bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations)").Scan(&migrated)
+	if err != nil {
+		return err
+	}
+	if migrated {
+		return errors.New("database schema is already managed by migrations")
+	}
+
+	var exists bool
+	err = tx.QueryRow("SELECT to_regclass('users') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("database schema does not exist, migrate up instead")
+	}
+
+	m := migrations[0]
+	_, err = tx.Exec("INSERT INTO schema_migrations(version,name) VALUES ($1,$2)", m.Version, m.Name)
+	return err
+}
+
+// MigrateBaseline marks the initial migration as applied on a database that
+// was created from create.sql before migrations existed.
+// The remaining migrations can then be applied with MigrateUp.
+func MigrateBaseline(db *sql.DB) error {
+	err := createMigrationsTable(db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = baselineTx(tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetMigrationStatus returns the status of all known migrations.
+func GetMigrationStatus(db *sql.DB) ([]MigrationStatus, error) {
+	err := createMigrationsTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := getAppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status = append(status, MigrationStatus{
+			Migration: m,
+			AppliedAt: applied[m.Version],
+		})
+	}
+
+	return status, nil
+}
+
+// CheckSchema returns an error if the database schema does not match the
+// migrations known to this binary.
+func CheckSchema(db *sql.DB) error {
+	err := createMigrationsTable(db)
+	if err != nil {
+		return err
+	}
+
+	applied, err := getAppliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	var pending int
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending++
+		}
+		delete(applied, m.Version)
+	}
+	if len(applied) > 0 {
+		return errors.New("database schema contains unknown migrations")
+	}
+	if pending > 0 {
+		return fmt.Errorf("database schema has %d pending migrations", pending)
+	}
+
+	return nil
+}
+
+// ResetSchema drops everything created by the known migrations and migrates
+// to the latest version.
+// All data is lost, this is only meant for tests and the test instance.
+func ResetSchema(db *sql.DB) error {
+	for i := len(migrations) - 1; i >= 0; i-- {
+		_, err := db.Exec(migrations[i].Down)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %s", migrations[i].Version, migrations[i].Name, err)
+		}
+	}
+
+	_, err := db.Exec("DROP TABLE IF EXISTS schema_migrations")
+	if err != nil {
+		return err
+	}
+
+	_, err = MigrateUp(db, 0)
+	return err
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsGenerated(t *testing.T) {
+	files, err := filepath.Glob("migrations/*.sql")
+	require.Nil(t, err)
+	require.Equal(t, 2*len(migrations), len(files), "migrations_gen.go is out of date, run go generate")
+
+	for i, m := range migrations {
+		if i > 0 {
+			require.True(t, migrations[i-1].Version < m.Version)
+		}
+
+		up, err := ioutil.ReadFile(fmt.Sprintf("migrations/%04d_%s.up.sql", m.Version, m.Name))
+		require.Nil(t, err)
+		require.Equal(t, string(up), m.Up, "migrations_gen.go is out of date, run go generate")
+
+		down, err := ioutil.ReadFile(fmt.Sprintf("migrations/%04d_%s.down.sql", m.Version, m.Name))
+		require.Nil(t, err)
+		require.Equal(t, string(down), m.Down, "migrations_gen.go is out of date, run go generate")
+	}
+}
+
+func TestMigrateDownUp(t *testing.T) {
+	bdb, err := cleanDB()
+	require.Nil(t, err)
+	db := bdb.(*DB)
+
+	err = CheckSchema(db.db)
+	require.Nil(t, err)
+
+	status, err := GetMigrationStatus(db.db)
+	require.Nil(t, err)
+	require.Equal(t, len(migrations), len(status))
+	for _, s := range status {
+		require.True(t, s.AppliedAt.Valid)
+	}
+
+	// nothing to do
+	done, err := MigrateUp(db.db, 0)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(done))
+
+	done, err = MigrateDown(db.db, 1)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(done))
+	require.Equal(t, LatestVersion(), done[0].Version)
+
+	err = CheckSchema(db.db)
+	require.NotNil(t, err)
+
+	done, err = MigrateDown(db.db, len(migrations))
+	require.Nil(t, err)
+	require.Equal(t, len(migrations)-1, len(done))
+
+	status, err = GetMigrationStatus(db.db)
+	require.Nil(t, err)
+	for _, s := range status {
+		require.False(t, s.AppliedAt.Valid)
+	}
+
+	done, err = MigrateUp(db.db, 0)
+	require.Nil(t, err)
+	require.Equal(t, len(migrations), len(done))
+
+	err = CheckSchema(db.db)
+	require.Nil(t, err)
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	bdb, err := cleanDB()
+	require.Nil(t, err)
+	db := bdb.(*DB)
+
+	// already migrated
+	err = MigrateBaseline(db.db)
+	require.NotNil(t, err)
+
+	_, err = MigrateDown(db.db, len(migrations))
+	require.Nil(t, err)
+
+	// nothing to baseline
+	err = MigrateBaseline(db.db)
+	require.NotNil(t, err)
+
+	// a database created before migrations existed
+	_, err = db.db.Exec(migrations[0].Up)
+	require.Nil(t, err)
+
+	err = MigrateBaseline(db.db)
+	require.Nil(t, err)
+
+	status, err := GetMigrationStatus(db.db)
+	require.Nil(t, err)
+	require.True(t, status[0].AppliedAt.Valid)
+	require.False(t, status[1].AppliedAt.Valid)
+
+	done, err := MigrateUp(db.db, 0)
+	require.Nil(t, err)
+	require.Equal(t, len(migrations)-1, len(done))
+
+	err = CheckSchema(db.db)
+	require.Nil(t, err)
+}
+
+func TestMigrateUpAdmin(t *testing.T) {
+	bdb, err := cleanDB()
+	require.Nil(t, err)
+	db := bdb.(*DB)
+
+	_, err = MigrateDown(db.db, len(migrations))
+	require.Nil(t, err)
+
+	_, err = MigrateUp(db.db, 0)
+	require.Nil(t, err)
+
+	// the initial admin can hand out the privileges added after the initial
+	// schema
+	u := User{ID: 1}
+	err = db.PopulateUserPrivileges(context.Background(), &u)
+	require.Nil(t, err)
+	require.Contains(t, u.Privileges, 33) // set_user_class
+	require.Contains(t, u.Privileges, 34) // get_user_privileges
+}
@@ -0,0 +1,30 @@
+DROP TABLE IF EXISTS api_tokens CASCADE;
+DROP TABLE IF EXISTS release_properties_releases CASCADE;
+DROP TABLE IF EXISTS release_tags_releases CASCADE;
+DROP TABLE IF EXISTS release_group_tags_release_groups CASCADE;
+DROP TABLE IF EXISTS artist_tags_artists CASCADE;
+DROP TABLE IF EXISTS user_stat_changes CASCADE;
+DROP TABLE IF EXISTS torrent_trackerdata CASCADE;
+DROP TABLE IF EXISTS torrents CASCADE;
+DROP TABLE IF EXISTS releases CASCADE;
+DROP TABLE IF EXISTS release_groups_artists CASCADE;
+DROP TABLE IF EXISTS release_groups CASCADE;
+DROP TABLE IF EXISTS artist_aliases CASCADE;
+DROP TABLE IF EXISTS artists CASCADE;
+DROP TABLE IF EXISTS release_group_types CASCADE;
+DROP TABLE IF EXISTS release_group_tags CASCADE;
+DROP TABLE IF EXISTS artist_tags CASCADE;
+DROP TABLE IF EXISTS formats CASCADE;
+DROP TABLE IF EXISTS leech_types CASCADE;
+DROP TABLE IF EXISTS media CASCADE;
+DROP TABLE IF EXISTS release_properties CASCADE;
+DROP TABLE IF EXISTS release_tags CASCADE;
+DROP TABLE IF EXISTS release_roles CASCADE;
+DROP TABLE IF EXISTS record_labels CASCADE;
+DROP TABLE IF EXISTS blog_tags_blogs CASCADE;
+DROP TABLE IF EXISTS blogs CASCADE;
+DROP TABLE IF EXISTS blog_tags CASCADE;
+DROP TABLE IF EXISTS users_privileges CASCADE;
+DROP TABLE IF EXISTS privileges CASCADE;
+DROP TABLE IF EXISTS user_passkeys CASCADE;
+DROP TABLE IF EXISTS users CASCADE;
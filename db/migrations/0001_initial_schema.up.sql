@@ -1,4 +1,3 @@
-DROP TABLE IF EXISTS users CASCADE;
 CREATE TABLE users
 (
   id          SERIAL PRIMARY KEY,
@@ -19,7 +18,6 @@ CREATE UNIQUE INDEX users_username_uindex
 CREATE UNIQUE INDEX users_email_uindex
   ON users (email);
 
-DROP TABLE IF EXISTS user_passkeys CASCADE;
 CREATE TABLE user_passkeys
 (
   uid        INT PRIMARY KEY,
@@ -31,7 +29,6 @@ CREATE TABLE user_passkeys
 CREATE UNIQUE INDEX user_passkeys_passkey_uindex
   ON user_passkeys (passkey);
 
-DROP TABLE IF EXISTS privileges CASCADE;
 CREATE TABLE privileges
 (
   id        SERIAL PRIMARY KEY,
@@ -40,7 +37,6 @@ CREATE TABLE privileges
 CREATE UNIQUE INDEX privileges_privilege_uindex
   ON privileges (privilege);
 
-DROP TABLE IF EXISTS users_privileges CASCADE;
 CREATE TABLE users_privileges
 (
   uid       INT NOT NULL,
@@ -48,7 +44,6 @@ CREATE TABLE users_privileges
   PRIMARY KEY (uid, privilege)
 );
 
-DROP TABLE IF EXISTS blog_tags CASCADE;
 CREATE TABLE blog_tags
 (
   id  SERIAL PRIMARY KEY,
@@ -57,7 +52,6 @@ CREATE TABLE blog_tags
 CREATE UNIQUE INDEX blog_tags_tag_uindex
   ON blog_tags (tag);
 
-DROP TABLE IF EXISTS blogs CASCADE;
 CREATE TABLE blogs
 (
   id        SERIAL PRIMARY KEY,
@@ -68,7 +62,6 @@ CREATE TABLE blogs
   CONSTRAINT blogs_users_id_fk FOREIGN KEY (author) REFERENCES users (id)
 );
 
-DROP TABLE IF EXISTS blog_tags_blogs CASCADE;
 CREATE TABLE blog_tags_blogs
 (
   blog INT NOT NULL,
@@ -79,7 +72,6 @@ CREATE TABLE blog_tags_blogs
 );
 
 -- Music-specific tables
-DROP TABLE IF EXISTS record_labels CASCADE;
 CREATE TABLE record_labels
 (
   id          SERIAL PRIMARY KEY,
@@ -91,7 +83,6 @@ CREATE TABLE record_labels
   CONSTRAINT record_labels_users_id_fk FOREIGN KEY (added_by) REFERENCES users (id)
 );
 
-DROP TABLE IF EXISTS release_roles CASCADE;
 CREATE TABLE release_roles
 (
   id   SERIAL PRIMARY KEY,
@@ -100,7 +91,6 @@ CREATE TABLE release_roles
 CREATE UNIQUE INDEX release_roles_role_uindex
   ON release_roles (role);
 
-DROP TABLE IF EXISTS release_tags CASCADE;
 CREATE TABLE release_tags
 (
   id  SERIAL PRIMARY KEY,
@@ -109,7 +99,6 @@ CREATE TABLE release_tags
 CREATE UNIQUE INDEX release_tags_tag_uindex
   ON release_tags (tag);
 
-DROP TABLE IF EXISTS release_properties CASCADE;
 CREATE TABLE release_properties
 (
   id       SERIAL PRIMARY KEY,
@@ -118,7 +107,6 @@ CREATE TABLE release_properties
 CREATE UNIQUE INDEX release_properties_property_uindex
   ON release_properties (property);
 
-DROP TABLE IF EXISTS media CASCADE;
 CREATE TABLE media
 (
   id     SERIAL PRIMARY KEY,
@@ -127,7 +115,6 @@ CREATE TABLE media
 CREATE UNIQUE INDEX medias_media_uindex
   ON media (medium);
 
-DROP TABLE IF EXISTS leech_types CASCADE;
 CREATE TABLE leech_types
 (
   id   SERIAL PRIMARY KEY,
@@ -136,7 +123,6 @@ CREATE TABLE leech_types
 CREATE UNIQUE INDEX leech_types_type_uindex
   ON leech_types (type);
 
-DROP TABLE IF EXISTS formats CASCADE;
 CREATE TABLE formats
 (
   id       SERIAL PRIMARY KEY,
@@ -146,7 +132,6 @@ CREATE TABLE formats
 CREATE UNIQUE INDEX formats_format_uindex
   ON formats (format);
 
-DROP TABLE IF EXISTS artist_tags CASCADE;
 CREATE TABLE artist_tags
 (
   id  SERIAL PRIMARY KEY,
@@ -155,7 +140,6 @@ CREATE TABLE artist_tags
 CREATE UNIQUE INDEX artist_tags_tag_uindex
   ON artist_tags (tag);
 
-DROP TABLE IF EXISTS release_group_tags CASCADE;
 CREATE TABLE release_group_tags
 (
   id  SERIAL PRIMARY KEY,
@@ -164,7 +148,6 @@ CREATE TABLE release_group_tags
 CREATE UNIQUE INDEX release_group_tags_tag_uindex
   ON release_group_tags (tag);
 
-DROP TABLE IF EXISTS release_group_types CASCADE;
 CREATE TABLE release_group_types
 (
   id   SERIAL PRIMARY KEY,
@@ -173,7 +156,6 @@ CREATE TABLE release_group_types
 CREATE UNIQUE INDEX release_group_types_type_uindex
   ON release_group_types (type);
 
-DROP TABLE IF EXISTS artists CASCADE;
 CREATE TABLE artists
 (
   id       SERIAL PRIMARY KEY,
@@ -184,7 +166,6 @@ CREATE TABLE artists
   CONSTRAINT artists_users_id_fk FOREIGN KEY (added_by) REFERENCES users (id)
 );
 
-DROP TABLE IF EXISTS artist_aliases CASCADE;
 CREATE TABLE artist_aliases
 (
   artist   INT          NOT NULL,
@@ -196,7 +177,6 @@ CREATE TABLE artist_aliases
   CONSTRAINT artist_aliases_users_id_fk FOREIGN KEY (added_by) REFERENCES users (id)
 );
 
-DROP TABLE IF EXISTS release_groups CASCADE;
 CREATE TABLE release_groups
 (
   id           SERIAL PRIMARY KEY,
@@ -209,7 +189,6 @@ CREATE TABLE release_groups
   CONSTRAINT release_groups_release_group_types_id_fk FOREIGN KEY (type) REFERENCES release_group_types (id)
 );
 
-DROP TABLE IF EXISTS release_groups_artists CASCADE;
 CREATE TABLE release_groups_artists
 (
   release_group INT NOT NULL,
@@ -221,7 +200,6 @@ CREATE TABLE release_groups_artists
   CONSTRAINT release_groups_artists_release_roles_role_id_fk FOREIGN KEY (role) REFERENCES release_roles (id)
 );
 
-DROP TABLE IF EXISTS releases CASCADE;
 CREATE TABLE releases
 (
   id               SERIAL PRIMARY KEY,
@@ -240,7 +218,6 @@ CREATE TABLE releases
   CONSTRAINT releases_users_id_fk FOREIGN KEY (added_by) REFERENCES users (id)
 );
 
-DROP TABLE IF EXISTS torrents CASCADE;
 CREATE TABLE torrents
 (
   id        SERIAL PRIMARY KEY,
@@ -258,7 +235,6 @@ CREATE TABLE torrents
 CREATE UNIQUE INDEX torrents_info_hash_uindex
   ON torrents (info_hash);
 
-DROP TABLE IF EXISTS torrent_trackerdata CASCADE;
 CREATE TABLE torrent_trackerdata
 (
   torrent          INT PRIMARY KEY,
@@ -272,7 +248,6 @@ CREATE TABLE torrent_trackerdata
   CONSTRAINT torrent_metadata_leech_types_id_fk FOREIGN KEY (leech_type) REFERENCES leech_types (id)
 );
 
-DROP TABLE IF EXISTS user_stat_changes CASCADE;
 CREATE TABLE user_stat_changes
 (
   uid              INT PRIMARY KEY,
@@ -284,7 +259,6 @@ CREATE TABLE user_stat_changes
   CONSTRAINT user_stat_changes_torrents_id_fk FOREIGN KEY (torrent) REFERENCES torrents (id)
 );
 
-DROP TABLE IF EXISTS artist_tags_artists CASCADE;
 CREATE TABLE artist_tags_artists
 (
   artist INT NOT NULL,
@@ -294,7 +268,6 @@ CREATE TABLE artist_tags_artists
   CONSTRAINT artist_tags_artists_artist_tags_id_fk FOREIGN KEY (tag) REFERENCES artist_tags (id)
 );
 
-DROP TABLE IF EXISTS release_group_tags_release_groups CASCADE;
 CREATE TABLE release_group_tags_release_groups
 (
   release_group INT NOT NULL,
@@ -304,7 +277,6 @@ CREATE TABLE release_group_tags_release_groups
   CONSTRAINT release_group_tags_release_groups_release_group_tags_id_fk FOREIGN KEY (tag) REFERENCES release_group_tags (id)
 );
 
-DROP TABLE IF EXISTS release_tags_releases CASCADE;
 CREATE TABLE release_tags_releases
 (
   release INT NOT NULL,
@@ -314,7 +286,6 @@ CREATE TABLE release_tags_releases
   CONSTRAINT release_tags_releases_release_tags_id_fk FOREIGN KEY (tag) REFERENCES release_tags (id)
 );
 
-DROP TABLE IF EXISTS release_properties_releases CASCADE;
 CREATE TABLE release_properties_releases
 (
   release  INT NOT NULL,
@@ -326,7 +297,6 @@ CREATE TABLE release_properties_releases
 );
 
 -- Other
-DROP TABLE IF EXISTS api_tokens CASCADE;
 CREATE TABLE api_tokens
 (
   token      VARCHAR(128) PRIMARY KEY,
@@ -420,4 +390,4 @@ ALTER SEQUENCE users_id_seq RESTART WITH 2;
 INSERT INTO users_privileges (uid, privilege) SELECT
                                                 1,
                                                 id
-                                              FROM privileges;
\ No newline at end of file
+                                              FROM privileges;
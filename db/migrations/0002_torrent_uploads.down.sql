@@ -0,0 +1,24 @@
+DROP TABLE IF EXISTS torrent_files;
+
+ALTER TABLE IF EXISTS torrents
+  DROP COLUMN IF EXISTS info;
+
+-- The privileges and torrents tables do not exist anymore if the initial
+-- schema was reverted already.
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    DELETE FROM users_privileges
+    WHERE privilege IN (12, 13);
+    DELETE FROM privileges
+    WHERE id IN (12, 13);
+    ALTER SEQUENCE privileges_id_seq RESTART WITH 10;
+  END IF;
+  IF EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'torrents' AND column_name = 'description') THEN
+    ALTER TABLE torrents
+      ALTER COLUMN description TYPE VARCHAR(255) USING left(description, 255);
+    ALTER TABLE torrents
+      RENAME COLUMN description TO comment;
+  END IF;
+END
+$$;
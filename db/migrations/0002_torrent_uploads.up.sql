@@ -0,0 +1,25 @@
+-- Torrents keep their info dictionary, so that .torrent files can be served,
+-- and their file list.
+-- Torrents added before this have an empty info dictionary.
+ALTER TABLE torrents
+  RENAME COLUMN comment TO description;
+ALTER TABLE torrents
+  ALTER COLUMN description TYPE TEXT,
+  ADD COLUMN info BYTEA NOT NULL DEFAULT '';
+ALTER TABLE torrents
+  ALTER COLUMN info DROP DEFAULT;
+
+CREATE TABLE torrent_files
+(
+  torrent INT    NOT NULL,
+  idx     INT    NOT NULL,
+  path    TEXT   NOT NULL,
+  size    BIGINT NOT NULL,
+  PRIMARY KEY (torrent, idx),
+  CONSTRAINT torrent_files_torrents_id_fk FOREIGN KEY (torrent) REFERENCES torrents (id)
+);
+
+INSERT INTO privileges (id, privilege) VALUES
+  (12, 'upload_torrent'),
+  (13, 'download_torrent');
+ALTER SEQUENCE privileges_id_seq RESTART WITH 14;
@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS leech_types
+  DROP COLUMN IF EXISTS upload_multiplier,
+  DROP COLUMN IF EXISTS download_multiplier;
@@ -0,0 +1,10 @@
+-- Announced traffic is multiplied by the multipliers of the leech type of the
+-- torrent before it is added to the user's totals.
+ALTER TABLE leech_types
+  ADD COLUMN upload_multiplier NUMERIC(4,2) NOT NULL DEFAULT 1,
+  ADD COLUMN download_multiplier NUMERIC(4,2) NOT NULL DEFAULT 1;
+
+UPDATE leech_types SET upload_multiplier = 1, download_multiplier = 0 WHERE id = 1;
+UPDATE leech_types SET upload_multiplier = 0, download_multiplier = 0 WHERE id = 2;
+UPDATE leech_types SET upload_multiplier = 2, download_multiplier = 1 WHERE id = 3;
+UPDATE leech_types SET upload_multiplier = 1, download_multiplier = 2 WHERE id = 4;
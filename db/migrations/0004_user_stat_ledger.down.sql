@@ -0,0 +1,32 @@
+DROP INDEX IF EXISTS user_stat_changes_uid_reported_at_index;
+DROP INDEX IF EXISTS user_stat_changes_torrent_reported_at_index;
+
+-- The privileges and user_stat_changes tables do not exist anymore if the
+-- initial schema was reverted already.
+-- Only the latest change of every user is kept.
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    DELETE FROM users_privileges
+    WHERE privilege = 14;
+    DELETE FROM privileges
+    WHERE id = 14;
+    ALTER SEQUENCE privileges_id_seq RESTART WITH 14;
+  END IF;
+  IF EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'user_stat_changes' AND column_name = 'id') THEN
+    DELETE FROM user_stat_changes c
+    USING user_stat_changes n
+    WHERE c.uid = n.uid AND (n.reported_at, n.id) > (c.reported_at, c.id);
+    ALTER TABLE user_stat_changes
+      DROP CONSTRAINT user_stat_changes_delta_check,
+      DROP COLUMN id,
+      DROP COLUMN event,
+      DROP COLUMN raw_uploaded,
+      DROP COLUMN raw_downloaded,
+      ALTER COLUMN uploaded_delta TYPE INT,
+      ALTER COLUMN downloaded_delta TYPE INT;
+    ALTER TABLE user_stat_changes
+      ADD PRIMARY KEY (uid);
+  END IF;
+END
+$$;
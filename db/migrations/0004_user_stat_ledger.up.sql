@@ -0,0 +1,22 @@
+-- user_stat_changes becomes a ledger with one row per announce instead of
+-- the latest change per user.
+ALTER TABLE user_stat_changes
+  DROP CONSTRAINT user_stat_changes_pkey;
+ALTER TABLE user_stat_changes
+  ALTER COLUMN uid SET NOT NULL,
+  ADD COLUMN id BIGSERIAL PRIMARY KEY,
+  ADD COLUMN event VARCHAR(16) NOT NULL DEFAULT '',
+  ADD COLUMN raw_uploaded BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN raw_downloaded BIGINT NOT NULL DEFAULT 0,
+  ALTER COLUMN uploaded_delta TYPE BIGINT,
+  ALTER COLUMN downloaded_delta TYPE BIGINT,
+  ADD CONSTRAINT user_stat_changes_raw_check CHECK (raw_uploaded >= 0 AND raw_downloaded >= 0),
+  ADD CONSTRAINT user_stat_changes_delta_check CHECK (uploaded_delta >= 0 AND downloaded_delta >= 0);
+CREATE INDEX user_stat_changes_uid_reported_at_index
+  ON user_stat_changes (uid, reported_at);
+CREATE INDEX user_stat_changes_torrent_reported_at_index
+  ON user_stat_changes (torrent, reported_at);
+
+INSERT INTO privileges (id, privilege) VALUES
+  (14, 'get_user_stats_not_self');
+ALTER SEQUENCE privileges_id_seq RESTART WITH 15;
@@ -0,0 +1,21 @@
+-- Dropping the functions drops the triggers using them.
+DROP FUNCTION IF EXISTS artists_search_trigger() CASCADE;
+DROP FUNCTION IF EXISTS artist_relations_search_trigger() CASCADE;
+DROP FUNCTION IF EXISTS release_groups_search_trigger() CASCADE;
+DROP FUNCTION IF EXISTS release_group_relations_search_trigger() CASCADE;
+DROP FUNCTION IF EXISTS record_labels_search_trigger() CASCADE;
+DROP FUNCTION IF EXISTS artist_search_vector(INT, TEXT);
+DROP FUNCTION IF EXISTS release_group_search_vector(INT, TEXT);
+
+-- Dropping the columns drops the indexes on them.
+ALTER TABLE IF EXISTS artists
+  DROP COLUMN IF EXISTS search;
+ALTER TABLE IF EXISTS release_groups
+  DROP COLUMN IF EXISTS search;
+ALTER TABLE IF EXISTS record_labels
+  DROP COLUMN IF EXISTS search;
+
+DROP TEXT SEARCH CONFIGURATION IF EXISTS boiling_search;
+
+-- The unaccent extension is left installed, it needs a superuser to drop and
+-- might be used by others.
@@ -0,0 +1,121 @@
+-- unaccent must be installed by a superuser before migrating, see README.md.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- Names are not natural language, so no stemming or stop words.
+CREATE TEXT SEARCH CONFIGURATION boiling_search (COPY = simple);
+ALTER TEXT SEARCH CONFIGURATION boiling_search
+  ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+
+ALTER TABLE artists
+  ADD COLUMN search TSVECTOR NOT NULL DEFAULT '';
+ALTER TABLE release_groups
+  ADD COLUMN search TSVECTOR NOT NULL DEFAULT '';
+ALTER TABLE record_labels
+  ADD COLUMN search TSVECTOR NOT NULL DEFAULT '';
+
+-- Names weigh A, aliases B and tags C.
+CREATE FUNCTION artist_search_vector(artist_id INT, artist_name TEXT)
+  RETURNS TSVECTOR AS $$
+SELECT setweight(to_tsvector('boiling_search', artist_name), 'A') ||
+       setweight(to_tsvector('boiling_search', coalesce((SELECT string_agg(alias, ' ')
+                                                         FROM artist_aliases
+                                                         WHERE artist = artist_id), '')), 'B') ||
+       setweight(to_tsvector('boiling_search', coalesce((SELECT string_agg(t.tag, ' ')
+                                                         FROM artist_tags t, artist_tags_artists ta
+                                                         WHERE t.id = ta.tag AND ta.artist = artist_id), '')), 'C')
+$$ LANGUAGE SQL STABLE;
+
+CREATE FUNCTION release_group_search_vector(release_group_id INT, release_group_name TEXT)
+  RETURNS TSVECTOR AS $$
+SELECT setweight(to_tsvector('boiling_search', release_group_name), 'A') ||
+       setweight(to_tsvector('boiling_search', coalesce((SELECT string_agg(t.tag, ' ')
+                                                         FROM release_group_tags t, release_group_tags_release_groups trg
+                                                         WHERE t.id = trg.tag AND trg.release_group = release_group_id), '')), 'C')
+$$ LANGUAGE SQL STABLE;
+
+CREATE FUNCTION artists_search_trigger()
+  RETURNS TRIGGER AS $$
+BEGIN
+  NEW.search := artist_search_vector(NEW.id, NEW.name);
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION artist_relations_search_trigger()
+  RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    UPDATE artists SET search = artist_search_vector(id, name) WHERE id = OLD.artist;
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    UPDATE artists SET search = artist_search_vector(id, name) WHERE id = NEW.artist;
+  END IF;
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION release_groups_search_trigger()
+  RETURNS TRIGGER AS $$
+BEGIN
+  NEW.search := release_group_search_vector(NEW.id, NEW.name);
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION release_group_relations_search_trigger()
+  RETURNS TRIGGER AS $$
+BEGIN
+  IF TG_OP IN ('UPDATE', 'DELETE') THEN
+    UPDATE release_groups SET search = release_group_search_vector(id, name) WHERE id = OLD.release_group;
+  END IF;
+  IF TG_OP IN ('INSERT', 'UPDATE') THEN
+    UPDATE release_groups SET search = release_group_search_vector(id, name) WHERE id = NEW.release_group;
+  END IF;
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION record_labels_search_trigger()
+  RETURNS TRIGGER AS $$
+BEGIN
+  NEW.search := setweight(to_tsvector('boiling_search', NEW.name), 'A');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+-- Updating only the search column does not fire the BEFORE triggers again.
+CREATE TRIGGER artists_search
+  BEFORE INSERT OR UPDATE OF name
+  ON artists
+  FOR EACH ROW EXECUTE PROCEDURE artists_search_trigger();
+CREATE TRIGGER artist_aliases_search
+  AFTER INSERT OR UPDATE OR DELETE
+  ON artist_aliases
+  FOR EACH ROW EXECUTE PROCEDURE artist_relations_search_trigger();
+CREATE TRIGGER artist_tags_artists_search
+  AFTER INSERT OR UPDATE OR DELETE
+  ON artist_tags_artists
+  FOR EACH ROW EXECUTE PROCEDURE artist_relations_search_trigger();
+CREATE TRIGGER release_groups_search
+  BEFORE INSERT OR UPDATE OF name
+  ON release_groups
+  FOR EACH ROW EXECUTE PROCEDURE release_groups_search_trigger();
+CREATE TRIGGER release_group_tags_release_groups_search
+  AFTER INSERT OR UPDATE OR DELETE
+  ON release_group_tags_release_groups
+  FOR EACH ROW EXECUTE PROCEDURE release_group_relations_search_trigger();
+CREATE TRIGGER record_labels_search
+  BEFORE INSERT OR UPDATE OF name
+  ON record_labels
+  FOR EACH ROW EXECUTE PROCEDURE record_labels_search_trigger();
+
+UPDATE artists SET search = artist_search_vector(id, name);
+UPDATE release_groups SET search = release_group_search_vector(id, name);
+UPDATE record_labels SET search = setweight(to_tsvector('boiling_search', name), 'A');
+
+CREATE INDEX artists_search_index
+  ON artists USING GIN (search);
+CREATE INDEX release_groups_search_index
+  ON release_groups USING GIN (search);
+CREATE INDEX record_labels_search_index
+  ON record_labels USING GIN (search);
@@ -0,0 +1,13 @@
+-- The privileges table does not exist anymore if the initial schema was
+-- reverted already.
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    DELETE FROM users_privileges
+    WHERE privilege IN (15, 16, 17, 18, 19, 20);
+    DELETE FROM privileges
+    WHERE id IN (15, 16, 17, 18, 19, 20);
+    ALTER SEQUENCE privileges_id_seq RESTART WITH 15;
+  END IF;
+END
+$$;
@@ -0,0 +1,8 @@
+INSERT INTO privileges (id, privilege) VALUES
+  (15, 'create_artist'),
+  (16, 'update_artist'),
+  (17, 'add_artist_alias'),
+  (18, 'remove_artist_alias'),
+  (19, 'add_artist_tag'),
+  (20, 'remove_artist_tag');
+ALTER SEQUENCE privileges_id_seq RESTART WITH 21;
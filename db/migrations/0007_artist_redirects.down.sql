@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS artist_redirects;
+
+-- The privileges table does not exist anymore if the initial schema was
+-- reverted already.
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    DELETE FROM users_privileges
+    WHERE privilege = 21;
+    DELETE FROM privileges
+    WHERE id = 21;
+    ALTER SEQUENCE privileges_id_seq RESTART WITH 21;
+  END IF;
+END
+$$;
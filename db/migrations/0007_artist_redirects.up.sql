@@ -0,0 +1,17 @@
+-- Artists merged into others are deleted, artist_redirects remembers where
+-- they went.
+CREATE TABLE artist_redirects
+(
+  artist    INT       NOT NULL PRIMARY KEY,
+  target    INT       NOT NULL,
+  merged    TIMESTAMP NOT NULL,
+  merged_by INT       NOT NULL,
+  CONSTRAINT artist_redirects_artists_id_fk FOREIGN KEY (target) REFERENCES artists (id),
+  CONSTRAINT artist_redirects_users_id_fk FOREIGN KEY (merged_by) REFERENCES users (id)
+);
+CREATE INDEX artist_redirects_target_index
+  ON artist_redirects (target);
+
+INSERT INTO privileges (id, privilege) VALUES
+  (21, 'merge_artists');
+ALTER SEQUENCE privileges_id_seq RESTART WITH 22;
@@ -0,0 +1,13 @@
+-- The privileges table does not exist anymore if the initial schema was
+-- reverted already.
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    DELETE FROM users_privileges
+    WHERE privilege IN (22, 23, 24, 25, 26, 27, 28);
+    DELETE FROM privileges
+    WHERE id IN (22, 23, 24, 25, 26, 27, 28);
+    ALTER SEQUENCE privileges_id_seq RESTART WITH 22;
+  END IF;
+END
+$$;
@@ -0,0 +1,9 @@
+INSERT INTO privileges (id, privilege) VALUES
+  (22, 'create_release_group'),
+  (23, 'update_release_group'),
+  (24, 'delete_release_group'),
+  (25, 'create_release'),
+  (26, 'update_release'),
+  (27, 'delete_release'),
+  (28, 'set_release_property');
+ALTER SEQUENCE privileges_id_seq RESTART WITH 29;
@@ -0,0 +1,19 @@
+DROP INDEX IF EXISTS releases_record_label_index;
+
+-- Dropping the column drops the constraint and index on it.
+ALTER TABLE IF EXISTS record_labels
+  DROP COLUMN IF EXISTS parent;
+
+-- The privileges table does not exist anymore if the initial schema was
+-- reverted already.
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    DELETE FROM users_privileges
+    WHERE privilege IN (29, 30, 31);
+    DELETE FROM privileges
+    WHERE id IN (29, 30, 31);
+    ALTER SEQUENCE privileges_id_seq RESTART WITH 29;
+  END IF;
+END
+$$;
@@ -0,0 +1,15 @@
+-- Imprints and sub-labels belong to a parent label.
+ALTER TABLE record_labels
+  ADD COLUMN parent INT,
+  ADD CONSTRAINT record_labels_record_labels_id_fk FOREIGN KEY (parent) REFERENCES record_labels (id);
+CREATE INDEX record_labels_parent_index
+  ON record_labels (parent);
+
+CREATE INDEX releases_record_label_index
+  ON releases (record_label);
+
+INSERT INTO privileges (id, privilege) VALUES
+  (29, 'get_record_label'),
+  (30, 'create_record_label'),
+  (31, 'update_record_label');
+ALTER SEQUENCE privileges_id_seq RESTART WITH 32;
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS revisions;
+
+-- The privileges table does not exist anymore if the initial schema was
+-- reverted already.
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    DELETE FROM users_privileges
+    WHERE privilege = 32;
+    DELETE FROM privileges
+    WHERE id = 32;
+    ALTER SEQUENCE privileges_id_seq RESTART WITH 32;
+  END IF;
+END
+$$;
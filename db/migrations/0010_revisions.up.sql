@@ -0,0 +1,21 @@
+-- Every edit of an artist, release group, release or record label is
+-- recorded as a revision, holding a JSON snapshot of the entity after the
+-- edit and the changes to the previous revision.
+CREATE TABLE revisions
+(
+  id          SERIAL    NOT NULL PRIMARY KEY,
+  entity_type TEXT      NOT NULL,
+  entity      INT       NOT NULL,
+  revision    INT       NOT NULL,
+  snapshot    JSONB     NOT NULL,
+  diff        JSONB     NOT NULL,
+  edited      TIMESTAMP NOT NULL,
+  edited_by   INT       NOT NULL,
+  CONSTRAINT revisions_entity_type_check CHECK (entity_type IN ('artist', 'release_group', 'release', 'record_label')),
+  CONSTRAINT revisions_entity_revision_unique UNIQUE (entity_type, entity, revision),
+  CONSTRAINT revisions_users_id_fk FOREIGN KEY (edited_by) REFERENCES users (id)
+);
+
+INSERT INTO privileges (id, privilege) VALUES
+  (32, 'revert_revision');
+ALTER SEQUENCE privileges_id_seq RESTART WITH 33;
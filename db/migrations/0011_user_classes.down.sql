@@ -0,0 +1,27 @@
+-- The privileges table does not exist anymore if the initial schema was
+-- reverted already.
+-- Denials must go before the granted column, they'd become grants otherwise.
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    UPDATE users SET class = 0 WHERE id = 1;
+    DELETE FROM users_privileges
+    WHERE NOT granted OR privilege = 33;
+    DELETE FROM user_classes_privileges
+    WHERE privilege = 33;
+    DELETE FROM privileges
+    WHERE id = 33;
+    ALTER SEQUENCE privileges_id_seq RESTART WITH 33;
+  END IF;
+END
+$$;
+
+ALTER TABLE IF EXISTS users_privileges
+  DROP COLUMN IF EXISTS granted;
+
+-- Dropping the column drops the constraint on it.
+ALTER TABLE IF EXISTS users
+  DROP COLUMN IF EXISTS class;
+
+DROP TABLE IF EXISTS user_classes_privileges;
+DROP TABLE IF EXISTS user_classes;
@@ -0,0 +1,90 @@
+-- User classes bundle privileges. Users have the privileges of their class,
+-- plus the ones granted to them and minus the ones denied to them in
+-- users_privileges.
+-- Classes are ranked by their ID, from the lowest to the highest.
+CREATE TABLE user_classes
+(
+  id    SERIAL PRIMARY KEY,
+  class VARCHAR(50) NOT NULL
+);
+CREATE UNIQUE INDEX user_classes_class_uindex
+  ON user_classes (class);
+
+CREATE TABLE user_classes_privileges
+(
+  class     INT NOT NULL,
+  privilege INT NOT NULL,
+  PRIMARY KEY (class, privilege),
+  CONSTRAINT user_classes_privileges_user_classes_id_fk FOREIGN KEY (class) REFERENCES user_classes (id),
+  CONSTRAINT user_classes_privileges_privileges_id_fk FOREIGN KEY (privilege) REFERENCES privileges (id)
+);
+
+INSERT INTO user_classes (id, class) VALUES
+  (0, 'User'),
+  (1, 'Power User'),
+  (2, 'Elite'),
+  (3, 'Moderator'),
+  (4, 'SysOp');
+ALTER SEQUENCE user_classes_id_seq RESTART WITH 5;
+
+INSERT INTO privileges (id, privilege) VALUES
+  (33, 'set_user_class');
+ALTER SEQUENCE privileges_id_seq RESTART WITH 34;
+
+-- Every class has the privileges of the classes below it.
+INSERT INTO user_classes_privileges (class, privilege)
+  SELECT
+    c.id,
+    p.privilege
+  FROM user_classes c, (VALUES
+    (0, 0), -- get_blogs
+    (0, 10), -- get_artist
+    (0, 11), -- get_release_group
+    (0, 12), -- upload_torrent
+    (0, 13), -- download_torrent
+    (0, 29), -- get_record_label
+    (1, 15), -- create_artist
+    (1, 17), -- add_artist_alias
+    (1, 19), -- add_artist_tag
+    (1, 22), -- create_release_group
+    (1, 25), -- create_release
+    (1, 30), -- create_record_label
+    (2, 16), -- update_artist
+    (2, 18), -- remove_artist_alias
+    (2, 20), -- remove_artist_tag
+    (2, 23), -- update_release_group
+    (2, 26), -- update_release
+    (2, 28), -- set_release_property
+    (2, 31), -- update_record_label
+    (3, 1), -- post_blog
+    (3, 4), -- update_blog
+    (3, 5), -- update_blog_not_owner
+    (3, 8), -- delete_blog
+    (3, 9), -- delete_blog_not_owner
+    (3, 14), -- get_user_stats_not_self
+    (3, 21), -- merge_artists
+    (3, 24), -- delete_release_group
+    (3, 27), -- delete_release
+    (3, 32), -- revert_revision
+    (3, 33) -- set_user_class
+  ) AS p (class, privilege)
+  WHERE c.id >= p.class AND c.id < 4;
+
+-- SysOps have all privileges.
+INSERT INTO user_classes_privileges (class, privilege)
+  SELECT
+    4,
+    id
+  FROM privileges;
+
+ALTER TABLE users
+  ADD COLUMN class INT NOT NULL DEFAULT 0,
+  ADD CONSTRAINT users_user_classes_id_fk FOREIGN KEY (class) REFERENCES user_classes (id);
+
+-- The initial admin gets all privileges, including the ones added later,
+-- through its class.
+UPDATE users SET class = 4 WHERE id = 1;
+
+-- Denied privileges are taken away even if the class of the user has them.
+ALTER TABLE users_privileges
+  ADD COLUMN granted BOOLEAN NOT NULL DEFAULT TRUE;
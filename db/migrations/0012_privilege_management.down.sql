@@ -0,0 +1,14 @@
+-- Privileges created at runtime are dropped as well.
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    DELETE FROM users_privileges
+    WHERE privilege >= 34;
+    DELETE FROM user_classes_privileges
+    WHERE privilege >= 34;
+    DELETE FROM privileges
+    WHERE id >= 34;
+    ALTER SEQUENCE privileges_id_seq RESTART WITH 34;
+  END IF;
+END
+$$;
@@ -0,0 +1,14 @@
+INSERT INTO privileges (id, privilege) VALUES
+  (34, 'get_user_privileges'),
+  (35, 'set_user_privileges'),
+  (36, 'create_privilege');
+ALTER SEQUENCE privileges_id_seq RESTART WITH 37;
+
+-- Privileges can be created at runtime from now on, later migrations must not
+-- rely on privilege IDs.
+INSERT INTO user_classes_privileges (class, privilege) VALUES
+  (3, 34),
+  (3, 35),
+  (4, 34),
+  (4, 35),
+  (4, 36);
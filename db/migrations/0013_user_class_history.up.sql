@@ -0,0 +1,18 @@
+-- Every change of the class of a user, made by staff or by the automatic
+-- promotion, with its reason.
+CREATE TABLE user_class_history
+(
+  id         SERIAL PRIMARY KEY,
+  uid        INT       NOT NULL,
+  changed    TIMESTAMP NOT NULL,
+  from_class INT       NOT NULL,
+  to_class   INT       NOT NULL,
+  reason     TEXT      NOT NULL,
+  changed_by INT, -- NULL for automatic changes
+  CONSTRAINT user_class_history_users_id_fk FOREIGN KEY (uid) REFERENCES users (id),
+  CONSTRAINT user_class_history_from_class_fk FOREIGN KEY (from_class) REFERENCES user_classes (id),
+  CONSTRAINT user_class_history_to_class_fk FOREIGN KEY (to_class) REFERENCES user_classes (id),
+  CONSTRAINT user_class_history_changed_by_fk FOREIGN KEY (changed_by) REFERENCES users (id)
+);
+CREATE INDEX user_class_history_uid_changed_index
+  ON user_class_history (uid, changed);
@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS users
+  DROP COLUMN IF EXISTS ratio_watch_until;
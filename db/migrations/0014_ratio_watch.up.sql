@@ -0,0 +1,5 @@
+-- Users under their required ratio are put on ratio watch until this
+-- deadline. If they are still under it afterwards, they can't download
+-- anymore. NULL if the user is not on ratio watch.
+ALTER TABLE users
+  ADD COLUMN ratio_watch_until TIMESTAMP;
@@ -0,0 +1,15 @@
+DO $$
+BEGIN
+  IF to_regclass('privileges') IS NOT NULL THEN
+    DELETE FROM users_privileges
+    WHERE privilege IN (SELECT id FROM privileges WHERE privilege IN ('invite', 'set_user_invites', 'get_invite_tree', 'disable_invite_tree'));
+    DELETE FROM user_classes_privileges
+    WHERE privilege IN (SELECT id FROM privileges WHERE privilege IN ('invite', 'set_user_invites', 'get_invite_tree', 'disable_invite_tree'));
+    DELETE FROM privileges
+    WHERE privilege IN ('invite', 'set_user_invites', 'get_invite_tree', 'disable_invite_tree');
+  END IF;
+END
+$$;
+DROP TABLE IF EXISTS invites;
+ALTER TABLE IF EXISTS users
+  DROP COLUMN IF EXISTS invites;
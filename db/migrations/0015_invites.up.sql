@@ -0,0 +1,38 @@
+-- The number of invites a user can still issue.
+ALTER TABLE users
+  ADD COLUMN invites INT NOT NULL DEFAULT 0;
+
+-- Invites are one-time codes to sign up with. The creator of the invite a user
+-- signed up with is their inviter, which makes up the invite tree.
+CREATE TABLE invites
+(
+  id         SERIAL PRIMARY KEY,
+  code       VARCHAR(32) NOT NULL,
+  created_by INT         NOT NULL,
+  created_at TIMESTAMP   NOT NULL,
+  used_by    INT, -- NULL for unused invites
+  used_at    TIMESTAMP,
+  CONSTRAINT invites_created_by_fk FOREIGN KEY (created_by) REFERENCES users (id),
+  CONSTRAINT invites_used_by_fk FOREIGN KEY (used_by) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX invites_code_uindex
+  ON invites (code);
+CREATE UNIQUE INDEX invites_used_by_uindex
+  ON invites (used_by);
+CREATE INDEX invites_created_by_index
+  ON invites (created_by);
+
+INSERT INTO privileges (privilege) VALUES
+  ('invite'),
+  ('set_user_invites'),
+  ('get_invite_tree'),
+  ('disable_invite_tree');
+
+-- Power Users and above can invite, staff manages invites and invite trees.
+INSERT INTO user_classes_privileges (class, privilege)
+  SELECT
+    c.id,
+    p.id
+  FROM user_classes c, privileges p
+  WHERE (p.privilege = 'invite' AND c.id >= 1)
+        OR (p.privilege IN ('set_user_invites', 'get_invite_tree', 'disable_invite_tree') AND c.id >= 3);
@@ -0,0 +1,15 @@
+-- The user_stat_changes table does not exist anymore if the initial schema
+-- was reverted already.
+-- Changes of deleted torrents are dropped, as they were before.
+DO $$
+BEGIN
+  IF to_regclass('user_stat_changes') IS NOT NULL THEN
+    DELETE FROM user_stat_changes
+    WHERE torrent IS NULL;
+    ALTER TABLE user_stat_changes
+      ALTER COLUMN torrent SET NOT NULL,
+      DROP CONSTRAINT user_stat_changes_torrents_id_fk,
+      ADD CONSTRAINT user_stat_changes_torrents_id_fk FOREIGN KEY (torrent) REFERENCES torrents (id);
+  END IF;
+END
+$$;
@@ -0,0 +1,6 @@
+-- Stat changes outlive their torrent, so the traffic of a user still adds up
+-- after a torrent was deleted. The torrent of such changes is NULL.
+ALTER TABLE user_stat_changes
+  ALTER COLUMN torrent DROP NOT NULL,
+  DROP CONSTRAINT user_stat_changes_torrents_id_fk,
+  ADD CONSTRAINT user_stat_changes_torrents_id_fk FOREIGN KEY (torrent) REFERENCES torrents (id) ON DELETE SET NULL;
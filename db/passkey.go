@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -20,7 +21,7 @@ type Passkey struct {
 // encoded, so the string representation is actually 64 characters long.
 const passkeyLength = 32
 
-func (db *DB) GetPasskeyForUser(id int) (*Passkey, error) {
+func (db *DB) GetPasskeyForUser(ctx context.Context, id int) (*Passkey, error) {
 	if id < 0 {
 		return nil, errors.New("invalid ID")
 	}
@@ -29,7 +30,7 @@ func (db *DB) GetPasskeyForUser(id int) (*Passkey, error) {
 		Uid:   id,
 		Valid: true,
 	}
-	err := db.db.QueryRow("SELECT passkey,created_at FROM user_passkeys WHERE uid = $1 AND valid=TRUE", id).Scan(
+	err := db.db.QueryRowContext(ctx, "SELECT passkey,created_at FROM user_passkeys WHERE uid = $1 AND valid=TRUE", id).Scan(
 		&passkey.Passkey,
 		&passkey.CreatedAt)
 	if err != nil {
@@ -39,12 +40,12 @@ func (db *DB) GetPasskeyForUser(id int) (*Passkey, error) {
 	return &passkey, nil
 }
 
-func (db *DB) GetAllPasskeysForUser(id int) ([]Passkey, error) {
+func (db *DB) GetAllPasskeysForUser(ctx context.Context, id int) ([]Passkey, error) {
 	if id < 0 {
 		return nil, errors.New("invalid ID")
 	}
 
-	rows, err := db.db.Query("SELECT passkey,created_at,valid FROM user_passkeys WHERE uid = $1", id)
+	rows, err := db.db.QueryContext(ctx, "SELECT passkey,created_at,valid FROM user_passkeys WHERE uid = $1", id)
 	if err != nil {
 		return nil, err
 	}
@@ -69,25 +70,25 @@ func (db *DB) GetAllPasskeysForUser(id int) ([]Passkey, error) {
 	return passkeys, nil
 }
 
-func generateNewPasskeyForUserTx(id int, passkey string, tx *sql.Tx) error {
-	_, err := tx.Exec("UPDATE user_passkeys SET valid=FALSE WHERE uid=$1", id)
+func generateNewPasskeyForUserTx(ctx context.Context, id int, passkey string, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "UPDATE user_passkeys SET valid=FALSE WHERE uid=$1", id)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec("INSERT INTO user_passkeys(uid,passkey,created_at,valid) VALUES ($1,$2,now(),TRUE)", id, passkey)
+	_, err = tx.ExecContext(ctx, "INSERT INTO user_passkeys(uid,passkey,created_at,valid) VALUES ($1,$2,now(),TRUE)", id, passkey)
 	return err
 }
 
-func (db *DB) GenerateNewPasskeyForUser(id int) (string, error) {
-	tx, err := db.db.Begin()
+func (db *DB) GenerateNewPasskeyForUser(ctx context.Context, id int) (string, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return "", err
 	}
 
 	passkey := generateRandomKey(passkeyLength)
 
-	err = generateNewPasskeyForUserTx(id, passkey, tx)
+	err = generateNewPasskeyForUserTx(ctx, id, passkey, tx)
 	if err != nil {
 		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
 		tx.Rollback()
@@ -101,3 +102,28 @@ func (db *DB) GenerateNewPasskeyForUser(id int) (string, error) {
 
 	return passkey, nil
 }
+
+// GetUserByPasskey returns the user owning the given passkey, if the passkey
+// is valid.
+// Only ID, Username, Enabled, CanLogin, Uploaded, Downloaded and
+// RatioWatchUntil are populated.
+func (db *DB) GetUserByPasskey(ctx context.Context, passkey string) (*User, error) {
+	if len(passkey) == 0 {
+		return nil, errors.New("missing passkey")
+	}
+
+	var u User
+	err := db.db.QueryRowContext(ctx, "SELECT u.id,u.username,u.enabled,u.can_login,u.uploaded,u.downloaded,u.ratio_watch_until FROM user_passkeys p, users u WHERE p.uid = u.id AND p.valid = TRUE AND p.passkey = $1", passkey).Scan(
+		&u.ID,
+		&u.Username,
+		&u.Enabled,
+		&u.CanLogin,
+		&u.Uploaded,
+		&u.Downloaded,
+		&u.RatioWatchUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
@@ -1,20 +1,24 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestGenerateGetPasskey(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	pk, err := db.GenerateNewPasskeyForUser(1)
+	pk, err := db.GenerateNewPasskeyForUser(ctx, 1)
 	require.Nil(t, err)
 	require.NotEmpty(t, pk)
 
-	got, err := db.GetPasskeyForUser(1)
+	got, err := db.GetPasskeyForUser(ctx, 1)
 	require.Nil(t, err)
 	require.NotNil(t, got)
 	require.Equal(t, 1, got.Uid)
@@ -23,16 +27,43 @@ func TestGenerateGetPasskey(t *testing.T) {
 }
 
 func TestGetAllPasskeysForUser(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	pks1, err := db.GetAllPasskeysForUser(1)
+	pks1, err := db.GetAllPasskeysForUser(ctx, 1)
 	require.Nil(t, err)
 
-	_, err = db.GenerateNewPasskeyForUser(1)
+	_, err = db.GenerateNewPasskeyForUser(ctx, 1)
 	require.Nil(t, err)
 
-	pks2, err := db.GetAllPasskeysForUser(1)
+	pks2, err := db.GetAllPasskeysForUser(ctx, 1)
 	require.Nil(t, err)
 	require.Equal(t, len(pks1)+1, len(pks2))
 }
+
+func TestGetUserByPasskey(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := cleanDB()
+	require.Nil(t, err)
+
+	old, err := db.GenerateNewPasskeyForUser(ctx, 1)
+	require.Nil(t, err)
+
+	pk, err := db.GenerateNewPasskeyForUser(ctx, 1)
+	require.Nil(t, err)
+
+	u, err := db.GetUserByPasskey(ctx, pk)
+	require.Nil(t, err)
+	require.Equal(t, 1, u.ID)
+	require.Equal(t, "test", u.Username)
+	require.True(t, u.Enabled)
+
+	_, err = db.GetUserByPasskey(ctx, old)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	_, err = db.GetUserByPasskey(ctx, "garbage")
+	require.Equal(t, sql.ErrNoRows, err)
+}
@@ -1,7 +1,15 @@
 package db
 
-func (db *DB) GetAllPrivileges() (map[int]string, error) {
-	rows, err := db.db.Query("SELECT id,privilege FROM privileges")
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (db *DB) GetAllPrivileges(ctx context.Context) (map[int]string, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,privilege FROM privileges")
 	if err != nil {
 		return nil, err
 	}
@@ -22,3 +30,41 @@ func (db *DB) GetAllPrivileges() (map[int]string, error) {
 
 	return m, nil
 }
+
+func insertPrivilegeTx(ctx context.Context, privilege string, tx *sql.Tx) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, "INSERT INTO privileges(privilege) VALUES ($1) RETURNING id", privilege).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	// the highest class has all privileges
+	_, err = tx.ExecContext(ctx, "INSERT INTO user_classes_privileges(class,privilege) SELECT MAX(id),$1 FROM user_classes", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// InsertPrivilege creates a privilege and returns its ID.
+// The privilege is added to the highest user class.
+func (db *DB) InsertPrivilege(ctx context.Context, privilege string) (int, error) {
+	if len(privilege) == 0 {
+		return 0, errors.New("missing privilege")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := insertPrivilegeTx(ctx, privilege, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
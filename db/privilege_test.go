@@ -1,16 +1,19 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetAllPrivileges(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	p, err := db.GetAllPrivileges()
+	p, err := db.GetAllPrivileges(ctx)
 	require.Nil(t, err)
 	require.NotNil(t, p)
 	require.NotEmpty(t, p)
@@ -1,12 +1,14 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
 )
 
 type RecordLabel struct {
@@ -16,14 +18,25 @@ type RecordLabel struct {
 	Founded     pq.NullTime
 	Added       time.Time
 	AddedBy     User
+
+	// Parent is the label this label is an imprint or sub-label of, or nil.
+	// Only its ID and name are populated.
+	Parent *RecordLabel
 }
 
-func (db *DB) AutocompleteRecordLabels(s string) ([]RecordLabel, error) {
+func parentLabel(id sql.NullInt64, name sql.NullString) *RecordLabel {
+	if !id.Valid {
+		return nil
+	}
+	return &RecordLabel{ID: int(id.Int64), Name: name.String}
+}
+
+func (db *DB) AutocompleteRecordLabels(ctx context.Context, s string) ([]RecordLabel, error) {
 	if len(s) == 0 {
 		return nil, errors.New("misssing s")
 	}
 
-	rows, err := db.db.Query("SELECT l.id,l.name,l.description,l.founded,l.added,l.added_by,u.username FROM record_labels l, users u WHERE u.id = l.added_by AND l.name ILIKE $1", fmt.Sprint("%", s, "%"))
+	rows, err := db.db.QueryContext(ctx, "SELECT l.id,l.name,l.description,l.founded,l.added,l.added_by,u.username,p.id,p.name FROM record_labels l JOIN users u ON u.id = l.added_by LEFT JOIN record_labels p ON p.id = l.parent WHERE l.name ILIKE $1", fmt.Sprint("%", s, "%"))
 	if err != nil {
 		return nil, err
 	}
@@ -31,7 +44,11 @@ func (db *DB) AutocompleteRecordLabels(s string) ([]RecordLabel, error) {
 
 	var labels []RecordLabel
 	for rows.Next() {
-		var tmp RecordLabel
+		var (
+			tmp        RecordLabel
+			parentID   sql.NullInt64
+			parentName sql.NullString
+		)
 		err = rows.Scan(
 			&tmp.ID,
 			&tmp.Name,
@@ -39,54 +56,318 @@ func (db *DB) AutocompleteRecordLabels(s string) ([]RecordLabel, error) {
 			&tmp.Founded,
 			&tmp.Added,
 			&tmp.AddedBy.ID,
-			&tmp.AddedBy.Username)
+			&tmp.AddedBy.Username,
+			&parentID,
+			&parentName)
 		if err != nil {
 			return nil, err
 		}
+		tmp.Parent = parentLabel(parentID, parentName)
 		labels = append(labels, tmp)
 	}
 
 	return labels, nil
 }
 
-func (db *DB) GetRecordLabel(id int) (*RecordLabel, error) {
+func (db *DB) GetRecordLabel(ctx context.Context, id int) (*RecordLabel, error) {
 	if id < 0 {
 		return nil, errors.New("invalid ID")
 	}
 
-	row := db.db.QueryRow("SELECT l.name,l.description,l.founded,l.added,l.added_by,u.username FROM record_labels l, users u WHERE u.id = l.added_by AND l.id = $1", id)
+	row := db.db.QueryRowContext(ctx, "SELECT l.name,l.description,l.founded,l.added,l.added_by,u.username,p.id,p.name FROM record_labels l JOIN users u ON u.id = l.added_by LEFT JOIN record_labels p ON p.id = l.parent WHERE l.id = $1", id)
 
-	label := RecordLabel{ID: id}
+	var (
+		label      = RecordLabel{ID: id}
+		parentID   sql.NullInt64
+		parentName sql.NullString
+	)
 	err := row.Scan(
 		&label.Name,
 		&label.Description,
 		&label.Founded,
 		&label.Added,
 		&label.AddedBy.ID,
-		&label.AddedBy.Username)
+		&label.AddedBy.Username,
+		&parentID,
+		&parentName)
 	if err != nil {
 		return nil, err
 	}
+	label.Parent = parentLabel(parentID, parentName)
 
 	return &label, nil
 }
 
-func (db *DB) InsertRecordLabel(label *RecordLabel) error {
+func insertRecordLabelTx(ctx context.Context, label *RecordLabel, tx *sql.Tx) error {
+	var desc *string
+	if label.Description.String != "" {
+		desc = &label.Description.String
+	}
+	var founded *time.Time
+	var t time.Time
+	if label.Founded.Time != t {
+		founded = &label.Founded.Time
+	}
+
+	var parent *int
+	if label.Parent != nil {
+		parent = &label.Parent.ID
+	}
+
+	err := tx.QueryRowContext(ctx, "INSERT INTO record_labels (name,description,founded,added,added_by,parent) VALUES ($1,$2,$3,now(),$4,$5) RETURNING id,added",
+		label.Name, desc, founded, label.AddedBy.ID, parent).Scan(&label.ID, &label.Added)
+	if err != nil {
+		return err
+	}
+
+	return recordRevisionTx(ctx, RevisionRecordLabel, label.ID, Edit{Edited: label.Added, EditedBy: label.AddedBy}, tx)
+}
+
+// InsertRecordLabel inserts a record label and sets its ID and the time it
+// was added.
+func (db *DB) InsertRecordLabel(ctx context.Context, label *RecordLabel) error {
 	if label == nil {
 		return errors.New("missing label")
 	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = insertRecordLabelTx(ctx, label, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func updateRecordLabelTx(ctx context.Context, label RecordLabel, tx *sql.Tx) error {
 	var desc *string
 	if label.Description.String != "" {
 		desc = &label.Description.String
 	}
 	var founded *time.Time
-	var t time.Time
-	if label.Founded.Time != t {
+	if label.Founded.Valid {
 		founded = &label.Founded.Time
 	}
+	// Lock the label and the chain of its new ancestors, so that concurrent
+	// updates can not introduce a cycle between our check and the update.
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM record_labels WHERE id = $1 FOR UPDATE", label.ID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("record label not found")
+		}
+		return err
+	}
+
+	var parent *int
+	if label.Parent != nil {
+		parent = &label.Parent.ID
+
+		// The parent must not be the label itself or one of its sub-labels.
+		for id = label.Parent.ID; ; {
+			if id == label.ID {
+				return errors.New("record label can not be a sub-label of itself")
+			}
+			var next sql.NullInt64
+			err = tx.QueryRowContext(ctx, "SELECT parent FROM record_labels WHERE id = $1 FOR UPDATE", id).Scan(&next)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return errors.New("parent record label not found")
+				}
+				return err
+			}
+			if !next.Valid {
+				break
+			}
+			id = int(next.Int64)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE record_labels SET name = $2, description = $3, founded = $4, parent = $5 WHERE id = $1", label.ID, label.Name, desc, founded, parent)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("record label not found")
+	}
+
+	return nil
+}
+
+// UpdateRecordLabel updates the name, description, founding date and parent
+// of a record label.
+// It rejects parents that would make the label a sub-label of itself.
+func (db *DB) UpdateRecordLabel(ctx context.Context, label RecordLabel, e Edit) error {
+	return db.edit(ctx, RevisionRecordLabel, label.ID, e, func(tx *sql.Tx) error {
+		return updateRecordLabelTx(ctx, label, tx)
+	})
+}
+
+// GetSubLabels returns the labels the label with the given ID is the parent
+// of, ordered by name.
+func (db *DB) GetSubLabels(ctx context.Context, id int) ([]RecordLabel, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	rows, err := db.db.QueryContext(ctx, "SELECT l.id,l.name,l.description,l.founded,l.added,l.added_by,u.username,p.name FROM record_labels l, users u, record_labels p WHERE u.id = l.added_by AND p.id = l.parent AND l.parent = $1 ORDER BY l.name ASC, l.id ASC", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []RecordLabel
+	for rows.Next() {
+		tmp := RecordLabel{Parent: &RecordLabel{ID: id}}
+		err = rows.Scan(
+			&tmp.ID,
+			&tmp.Name,
+			&tmp.Description,
+			&tmp.Founded,
+			&tmp.Added,
+			&tmp.AddedBy.ID,
+			&tmp.AddedBy.Username,
+			&tmp.Parent.Name)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, tmp)
+	}
+
+	return labels, nil
+}
+
+// RecordLabelReleaseCursor is the position of a release in the catalog of a
+// record label, which is sorted by catalogue number, releases without one
+// last, then by release date and ID.
+type RecordLabelReleaseCursor struct {
+	CatalogueNumber sql.NullString
+	ReleaseDate     time.Time
+	ID              int
+}
+
+func (db *DB) queryRecordLabelReleases(ctx context.Context, query string, args ...interface{}) ([]Release, error) {
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var releases []Release
+	for rows.Next() {
+		var tmp Release
+		err = rows.Scan(
+			&tmp.ID,
+			&tmp.Edition,
+			&tmp.Medium,
+			&tmp.ReleaseDate,
+			&tmp.CatalogueNumber,
+			&tmp.RecordLabel.ID,
+			&tmp.RecordLabel.Name,
+			&tmp.Added,
+			&tmp.AddedBy.ID,
+			&tmp.AddedBy.Username,
+			&tmp.Original,
+			&tmp.ReleaseGroup.ID,
+			&tmp.ReleaseGroup.Name,
+			&tmp.ReleaseGroup.ReleaseDate,
+			&tmp.ReleaseGroup.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		err = db.populateReleaseTags(ctx, &tmp)
+		if err != nil {
+			return nil, err
+		}
+
+		err = db.populateReleaseProperties(ctx, &tmp)
+		if err != nil {
+			return nil, err
+		}
+
+		releases = append(releases, tmp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// GetRecordLabelReleases returns the catalog of a record label: the releases
+// on the label, ordered by catalogue number, then release date.
+// Releases without catalogue number come last.
+// The releases have their release group, tags and properties populated.
+func (db *DB) GetRecordLabelReleases(ctx context.Context, id, offset, limit int) ([]Release, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	return db.queryRecordLabelReleases(ctx, "SELECT r.id,r.edition,r.medium,r.release_date,r.catalogue_number,l.id,l.name,r.added,u.id,u.username,r.original,g.id,g.name,g.release_date,g.type FROM releases r, record_labels l, users u, release_groups g WHERE r.record_label = l.id AND r.added_by = u.id AND r.release_group = g.id AND r.record_label = $1 ORDER BY r.catalogue_number ASC NULLS LAST, r.release_date ASC, r.id ASC OFFSET $2 LIMIT $3", id, offset, limit)
+}
+
+// GetRecordLabelReleasesAfter returns up to limit releases of the catalog of
+// a record label following c, like GetRecordLabelReleases.
+func (db *DB) GetRecordLabelReleasesAfter(ctx context.Context, id int, c RecordLabelReleaseCursor, limit int) ([]Release, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
 
-	err := db.db.QueryRow("INSERT INTO record_labels (name,description,founded,added,added_by) VALUES ($1,$2,$3,now(),$4) RETURNING id",
-		label.Name, desc, founded, label.AddedBy.ID).Scan(&label.ID)
+	// Comparing (catalogue_number IS NULL, catalogue_number) sorts releases
+	// without catalogue number last, like NULLS LAST does.
+	return db.queryRecordLabelReleases(ctx, "SELECT r.id,r.edition,r.medium,r.release_date,r.catalogue_number,l.id,l.name,r.added,u.id,u.username,r.original,g.id,g.name,g.release_date,g.type FROM releases r, record_labels l, users u, release_groups g WHERE r.record_label = l.id AND r.added_by = u.id AND r.release_group = g.id AND r.record_label = $1 AND (r.catalogue_number IS NULL, COALESCE(r.catalogue_number, ''), r.release_date, r.id) > ($2, $3, $4, $5) ORDER BY r.catalogue_number ASC NULLS LAST, r.release_date ASC, r.id ASC LIMIT $6", id, !c.CatalogueNumber.Valid, c.CatalogueNumber.String, c.ReleaseDate, c.ID, limit)
+}
+
+// GetRecordLabelReleasesBefore returns up to limit releases of the catalog of
+// a record label preceding c, in catalog order.
+// These are the releases closest to c, not the first ones.
+func (db *DB) GetRecordLabelReleasesBefore(ctx context.Context, id int, c RecordLabelReleaseCursor, limit int) ([]Release, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	releases, err := db.queryRecordLabelReleases(ctx, "SELECT r.id,r.edition,r.medium,r.release_date,r.catalogue_number,l.id,l.name,r.added,u.id,u.username,r.original,g.id,g.name,g.release_date,g.type FROM releases r, record_labels l, users u, release_groups g WHERE r.record_label = l.id AND r.added_by = u.id AND r.release_group = g.id AND r.record_label = $1 AND (r.catalogue_number IS NULL, COALESCE(r.catalogue_number, ''), r.release_date, r.id) < ($2, $3, $4, $5) ORDER BY r.catalogue_number DESC NULLS FIRST, r.release_date DESC, r.id DESC LIMIT $6", id, !c.CatalogueNumber.Valid, c.CatalogueNumber.String, c.ReleaseDate, c.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(releases)-1; i < j; i, j = i+1, j-1 {
+		releases[i], releases[j] = releases[j], releases[i]
+	}
+
+	return releases, nil
+}
+
+// CountRecordLabelReleases returns the number of releases on a record label.
+func (db *DB) CountRecordLabelReleases(ctx context.Context, id int) (int, error) {
+	if id < 0 {
+		return 0, errors.New("invalid ID")
+	}
 
-	return err
+	var count int
+	err := db.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM releases WHERE record_label = $1", id).Scan(&count)
+	return count, err
 }
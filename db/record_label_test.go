@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 	"time"
@@ -10,6 +11,8 @@ import (
 )
 
 func TestInsertGetRecordLabel(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -25,20 +28,20 @@ func TestInsertGetRecordLabel(t *testing.T) {
 		Founded:     pq.NullTime{Time: time.Date(2007, 01, 01, 0, 0, 0, 0, time.FixedZone("", 0))},
 	}
 
-	err = db.InsertRecordLabel(&l1)
+	err = db.InsertRecordLabel(ctx, &l1)
 	require.Nil(t, err)
 
-	err = db.InsertRecordLabel(&l2)
+	err = db.InsertRecordLabel(ctx, &l2)
 	require.Nil(t, err)
 
-	l1r, err := db.GetRecordLabel(l1.ID)
+	l1r, err := db.GetRecordLabel(ctx, l1.ID)
 	require.Nil(t, err)
 	require.Equal(t, l1.Name, l1r.Name)
 	require.Equal(t, l1.AddedBy.ID, l1r.AddedBy.ID)
 	require.False(t, l1r.Founded.Valid)
 	require.False(t, l1r.Description.Valid)
 
-	l2r, err := db.GetRecordLabel(l2.ID)
+	l2r, err := db.GetRecordLabel(ctx, l2.ID)
 	require.Nil(t, err)
 	require.Equal(t, l2.Name, l2r.Name)
 	require.Equal(t, l2.AddedBy.ID, l2r.AddedBy.ID)
@@ -49,6 +52,8 @@ func TestInsertGetRecordLabel(t *testing.T) {
 }
 
 func TestAutocompleteRecordLabels(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -64,18 +69,18 @@ func TestAutocompleteRecordLabels(t *testing.T) {
 		Founded:     pq.NullTime{Time: time.Date(2007, 01, 01, 0, 0, 0, 0, time.FixedZone("", 0))},
 	}
 
-	err = db.InsertRecordLabel(&l1)
+	err = db.InsertRecordLabel(ctx, &l1)
 	require.Nil(t, err)
 
-	err = db.InsertRecordLabel(&l2)
+	err = db.InsertRecordLabel(ctx, &l2)
 	require.Nil(t, err)
 
-	labels, err := db.AutocompleteRecordLabels("such")
+	labels, err := db.AutocompleteRecordLabels(ctx, "such")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(labels))
 	require.Equal(t, l1.Name, labels[0].Name)
 
-	labels, err = db.AutocompleteRecordLabels("u")
+	labels, err = db.AutocompleteRecordLabels(ctx, "u")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(labels))
 }
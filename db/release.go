@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -28,12 +29,12 @@ type Release struct {
 	Properties map[string]string
 }
 
-func (db *DB) AutocompleteReleaseTags(s string) ([]string, error) {
+func (db *DB) AutocompleteReleaseTags(ctx context.Context, s string) ([]string, error) {
 	if len(s) == 0 {
 		return nil, errors.New("missing s")
 	}
 
-	rows, err := db.db.Query("SELECT tag FROM release_tags WHERE tag LIKE $1", fmt.Sprint("%", s, "%"))
+	rows, err := db.db.QueryContext(ctx, "SELECT tag FROM release_tags WHERE tag LIKE $1", fmt.Sprint("%", s, "%"))
 	if err != nil {
 		return nil, err
 	}
@@ -52,9 +53,9 @@ func (db *DB) AutocompleteReleaseTags(s string) ([]string, error) {
 	return tags, nil
 }
 
-func insertReleasePropertiesTx(release Release, tx *sql.Tx) error {
+func insertReleasePropertiesTx(ctx context.Context, release Release, tx *sql.Tx) error {
 	for k, v := range release.Properties {
-		_, err := tx.Exec("INSERT INTO release_properties_releases(release, property, value) VALUES ($1,(SELECT id from release_properties WHERE release_properties.property = $2 LIMIT 1),$3)", release.ID, k, v)
+		_, err := tx.ExecContext(ctx, "INSERT INTO release_properties_releases(release, property, value) VALUES ($1,(SELECT id from release_properties WHERE release_properties.property = $2 LIMIT 1),$3)", release.ID, k, v)
 		if err != nil {
 			return err
 		}
@@ -63,22 +64,22 @@ func insertReleasePropertiesTx(release Release, tx *sql.Tx) error {
 	return nil
 }
 
-func insertReleaseTagsTx(release Release, tx *sql.Tx) error {
+func insertReleaseTagsTx(ctx context.Context, release Release, tx *sql.Tx) error {
 	var res sql.Result
 	var err error
 	for _, t := range release.Tags {
 		var id int
-		err = tx.QueryRow("INSERT INTO release_tags(tag) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id", t).Scan(&id)
+		err = tx.QueryRowContext(ctx, "INSERT INTO release_tags(tag) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id", t).Scan(&id)
 		if err != nil && err != sql.ErrNoRows {
 			return err
 		}
 
 		if err == nil {
 			// inserted
-			res, err = tx.Exec("INSERT INTO release_tags_releases(release,tag) VALUES($1,$2)", release.ID, id)
+			res, err = tx.ExecContext(ctx, "INSERT INTO release_tags_releases(release,tag) VALUES($1,$2)", release.ID, id)
 		} else {
 			// already present
-			res, err = tx.Exec("INSERT INTO release_tags_releases(release,tag) VALUES($1,(SELECT id FROM release_tags WHERE tag=$2 LIMIT 1))", release.ID, t)
+			res, err = tx.ExecContext(ctx, "INSERT INTO release_tags_releases(release,tag) VALUES($1,(SELECT id FROM release_tags WHERE tag=$2 LIMIT 1))", release.ID, t)
 		}
 		if err != nil {
 			return err
@@ -96,7 +97,7 @@ func insertReleaseTagsTx(release Release, tx *sql.Tx) error {
 	return nil
 }
 
-func insertReleaseTx(release *Release, tx *sql.Tx) error {
+func insertReleaseTx(ctx context.Context, release *Release, tx *sql.Tx) error {
 	var (
 		edition, catalogueNum *string
 	)
@@ -106,7 +107,7 @@ func insertReleaseTx(release *Release, tx *sql.Tx) error {
 	if len(release.CatalogueNumber.String) != 0 {
 		catalogueNum = &release.CatalogueNumber.String
 	}
-	err := tx.QueryRow("INSERT INTO releases(edition,medium,release_group,record_label,added,added_by,release_date,catalogue_number,original) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id",
+	err := tx.QueryRowContext(ctx, "INSERT INTO releases(edition,medium,release_group,record_label,added,added_by,release_date,catalogue_number,original) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id",
 		edition,
 		release.Medium,
 		release.ReleaseGroup.ID,
@@ -120,23 +121,26 @@ func insertReleaseTx(release *Release, tx *sql.Tx) error {
 		return err
 	}
 
-	err = insertReleaseTagsTx(*release, tx)
+	err = insertReleaseTagsTx(ctx, *release, tx)
 	if err != nil {
 		return err
 	}
 
-	err = insertReleasePropertiesTx(*release, tx)
+	err = insertReleasePropertiesTx(ctx, *release, tx)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return recordRevisionTx(ctx, RevisionRelease, release.ID, Edit{Edited: release.Added, EditedBy: release.AddedBy}, tx)
 }
 
-func (db *DB) InsertRelease(release *Release) error {
-	tx, err := db.db.Begin()
+func (db *DB) InsertRelease(ctx context.Context, release *Release) error {
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	err = insertReleaseTx(release, tx)
+	err = insertReleaseTx(ctx, release, tx)
 	if err != nil {
 		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
 		tx.Rollback()
@@ -146,16 +150,58 @@ func (db *DB) InsertRelease(release *Release) error {
 	return tx.Commit()
 }
 
-func (db *DB) SetReleaseProperty(id int, k, v string) error {
-	if id < 0 {
-		return errors.New("invalid ID")
+func updateReleaseTx(ctx context.Context, release Release, tx *sql.Tx) error {
+	var (
+		edition, catalogueNum *string
+	)
+	if len(release.Edition.String) != 0 {
+		edition = &release.Edition.String
+	}
+	if len(release.CatalogueNumber.String) != 0 {
+		catalogueNum = &release.CatalogueNumber.String
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE releases SET edition = $2, medium = $3, record_label = $4, release_date = $5, catalogue_number = $6, original = $7 WHERE id = $1",
+		release.ID,
+		edition,
+		release.Medium,
+		release.RecordLabel.ID,
+		release.ReleaseDate,
+		catalogueNum,
+		release.Original)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("release not found")
 	}
+
+	err = deleteReleaseTagsTx(ctx, release.ID, tx)
+	if err != nil {
+		return err
+	}
+
+	return insertReleaseTagsTx(ctx, release, tx)
+}
+
+// UpdateRelease updates a release and replaces its tags.
+// The release group, properties and torrents of the release are kept.
+func (db *DB) UpdateRelease(ctx context.Context, release Release, e Edit) error {
+	return db.edit(ctx, RevisionRelease, release.ID, e, func(tx *sql.Tx) error {
+		return updateReleaseTx(ctx, release, tx)
+	})
+}
+
+func setReleasePropertyTx(ctx context.Context, id int, k, v string, tx *sql.Tx) error {
 	var value *string
 	if len(v) != 0 {
 		value = &v
 	}
 
-	res, err := db.db.Exec("INSERT INTO release_properties_releases(release, property, value) VALUES ($1,(SELECT id from release_properties WHERE release_properties.property = $2 LIMIT 1),$3) ON CONFLICT (release,property) DO UPDATE SET value = $3", id, k, value)
+	res, err := tx.ExecContext(ctx, "INSERT INTO release_properties_releases(release, property, value) VALUES ($1,(SELECT id from release_properties WHERE release_properties.property = $2 LIMIT 1),$3) ON CONFLICT (release,property) DO UPDATE SET value = $3", id, k, value)
 	if err != nil {
 		return err
 	}
@@ -170,12 +216,18 @@ func (db *DB) SetReleaseProperty(id int, k, v string) error {
 	return nil
 }
 
-func (db *DB) populateReleaseTags(r *Release) error {
+func (db *DB) SetReleaseProperty(ctx context.Context, id int, k, v string, e Edit) error {
+	return db.edit(ctx, RevisionRelease, id, e, func(tx *sql.Tx) error {
+		return setReleasePropertyTx(ctx, id, k, v, tx)
+	})
+}
+
+func (db *DB) populateReleaseTags(ctx context.Context, r *Release) error {
 	if r.ID < 0 {
 		return errors.New("invalid ID")
 	}
 
-	rows, err := db.db.Query("SELECT t.tag FROM release_tags t, release_tags_releases rtr WHERE rtr.tag = t.id AND rtr.release = $1", r.ID)
+	rows, err := db.db.QueryContext(ctx, "SELECT t.tag FROM release_tags t, release_tags_releases rtr WHERE rtr.tag = t.id AND rtr.release = $1", r.ID)
 	if err != nil {
 		return err
 	}
@@ -193,12 +245,12 @@ func (db *DB) populateReleaseTags(r *Release) error {
 	return nil
 }
 
-func (db *DB) populateReleaseProperties(r *Release) error {
+func (db *DB) populateReleaseProperties(ctx context.Context, r *Release) error {
 	if r.ID < 0 {
 		return errors.New("invalid ID")
 	}
 
-	rows, err := db.db.Query("SELECT p.property,rpr.value FROM release_properties p, release_properties_releases rpr WHERE rpr.property = p.ID AND rpr.release = $1", r.ID)
+	rows, err := db.db.QueryContext(ctx, "SELECT p.property,rpr.value FROM release_properties p, release_properties_releases rpr WHERE rpr.property = p.ID AND rpr.release = $1", r.ID)
 	if err != nil {
 		return err
 	}
@@ -225,12 +277,12 @@ func (db *DB) populateReleaseProperties(r *Release) error {
 	return nil
 }
 
-func (db *DB) GetRelease(id int) (*Release, error) {
+func (db *DB) GetRelease(ctx context.Context, id int) (*Release, error) {
 	if id < 0 {
 		return nil, errors.New("invalid ID")
 	}
 
-	row := db.db.QueryRow("SELECT r.edition,r.medium,g.id,g.name,l.id,l.name,r.added,u.id,u.username,r.release_date,r.catalogue_number,r.original FROM releases r, release_groups g, record_labels l, users u WHERE r.release_group = g.id AND r.record_label = l.id AND r.added_by = u.id AND r.id = $1", id)
+	row := db.db.QueryRowContext(ctx, "SELECT r.edition,r.medium,g.id,g.name,l.id,l.name,r.added,u.id,u.username,r.release_date,r.catalogue_number,r.original FROM releases r, release_groups g, record_labels l, users u WHERE r.release_group = g.id AND r.record_label = l.id AND r.added_by = u.id AND r.id = $1", id)
 	r := Release{ID: id}
 	err := row.Scan(
 		&r.Edition,
@@ -249,12 +301,12 @@ func (db *DB) GetRelease(id int) (*Release, error) {
 		return nil, err
 	}
 
-	err = db.populateReleaseTags(&r)
+	err = db.populateReleaseTags(ctx, &r)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.populateReleaseProperties(&r)
+	err = db.populateReleaseProperties(ctx, &r)
 	if err != nil {
 		return nil, err
 	}
@@ -262,13 +314,13 @@ func (db *DB) GetRelease(id int) (*Release, error) {
 	return &r, nil
 }
 
-func deleteReleasePropertiesTx(id int, tx *sql.Tx) error {
-	_, err := tx.Exec("DELETE FROM release_properties_releases WHERE release=$1", id)
+func deleteReleasePropertiesTx(ctx context.Context, id int, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM release_properties_releases WHERE release=$1", id)
 	return err
 }
 
-func deleteReleaseTagsTx(id int, tx *sql.Tx) error {
-	res, err := tx.Exec("DELETE FROM release_tags_releases WHERE release = $1", id)
+func deleteReleaseTagsTx(ctx context.Context, id int, tx *sql.Tx) error {
+	res, err := tx.ExecContext(ctx, "DELETE FROM release_tags_releases WHERE release = $1", id)
 	if err != nil {
 		return err
 	}
@@ -277,7 +329,7 @@ func deleteReleaseTagsTx(id int, tx *sql.Tx) error {
 		return err
 	}
 	if affected > 0 {
-		_, err := tx.Exec("DELETE FROM release_tags t WHERE (SELECT COUNT(*) FROM release_tags_releases rtr WHERE rtr.tag=t.id) = 0")
+		_, err := tx.ExecContext(ctx, "DELETE FROM release_tags t WHERE (SELECT COUNT(*) FROM release_tags_releases rtr WHERE rtr.tag=t.id) = 0")
 		if err != nil {
 			return err
 		}
@@ -286,18 +338,18 @@ func deleteReleaseTagsTx(id int, tx *sql.Tx) error {
 	return nil
 }
 
-func deleteReleaseTx(id int, tx *sql.Tx) error {
-	err := deleteReleaseTagsTx(id, tx)
+func deleteReleaseTx(ctx context.Context, id int, tx *sql.Tx) error {
+	err := deleteReleaseTagsTx(ctx, id, tx)
 	if err != nil {
 		return err
 	}
 
-	err = deleteReleasePropertiesTx(id, tx)
+	err = deleteReleasePropertiesTx(ctx, id, tx)
 	if err != nil {
 		return err
 	}
 
-	res, err := tx.Exec("DELETE FROM releases WHERE id=$1", id)
+	res, err := tx.ExecContext(ctx, "DELETE FROM releases WHERE id=$1", id)
 	if err != nil {
 		return err
 	}
@@ -312,13 +364,13 @@ func deleteReleaseTx(id int, tx *sql.Tx) error {
 	return nil
 }
 
-func (db *DB) DeleteRelease(id int) error {
-	tx, err := db.db.Begin()
+func (db *DB) DeleteRelease(ctx context.Context, id int) error {
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	err = deleteReleaseTx(id, tx)
+	err = deleteReleaseTx(ctx, id, tx)
 	if err != nil {
 		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
 		tx.Rollback()
@@ -328,6 +380,40 @@ func (db *DB) DeleteRelease(id int) error {
 	return tx.Commit()
 }
 
-func (db *DB) PopulateTorrents(release *Release) error {
-	return nil
+func ReleaseMediumSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "r",
+		col: "medium",
+	}
+}
+
+func ReleaseRecordLabelSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "r",
+		col: "record_label",
+	}
+}
+
+func ReleaseCatalogueNumberSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "r",
+		col: "catalogue_number",
+	}
+}
+
+func ReleaseReleaseDateSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "r",
+		col: "release_date",
+	}
+}
+
+// ReleaseTorrents matches releases with any torrent satisfying b.
+// b can use the torrent selectors.
+func ReleaseTorrents(b Boolean) Boolean {
+	return booleanExists{
+		column: "torrents",
+		prefix: "EXISTS (SELECT 1 FROM torrents t, torrent_trackerdata td WHERE t.id = td.torrent AND t.release = r.id AND",
+		cond:   b,
+	}
 }
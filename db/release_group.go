@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -26,12 +27,12 @@ type RoledArtist struct {
 	Artist Artist
 }
 
-func (db *DB) AutocompleteReleaseGroups(s string) ([]ReleaseGroup, error) {
+func (db *DB) AutocompleteReleaseGroups(ctx context.Context, s string) ([]ReleaseGroup, error) {
 	if len(s) == 0 {
 		return nil, errors.New("missing s")
 	}
 
-	rows, err := db.db.Query("SELECT rg.id,rg.name,rg.release_date,rg.added,u.id,u.username,rg.type FROM release_groups rg, users u WHERE rg.added_by = u.id AND rg.name ILIKE $1", fmt.Sprint("%", s, "%"))
+	rows, err := db.db.QueryContext(ctx, "SELECT rg.id,rg.name,rg.release_date,rg.added,u.id,u.username,rg.type FROM release_groups rg, users u WHERE rg.added_by = u.id AND rg.name ILIKE $1", fmt.Sprint("%", s, "%"))
 	if err != nil {
 		return nil, err
 	}
@@ -52,12 +53,12 @@ func (db *DB) AutocompleteReleaseGroups(s string) ([]ReleaseGroup, error) {
 			return nil, err
 		}
 
-		err = db.populateReleaseGroupTags(&group)
+		err = db.populateReleaseGroupTags(ctx, &group)
 		if err != nil {
 			return nil, err
 		}
 
-		err = db.populateReleaseGroupArtists(&group)
+		err = db.populateReleaseGroupArtists(ctx, &group)
 		if err != nil {
 			return nil, err
 		}
@@ -68,12 +69,12 @@ func (db *DB) AutocompleteReleaseGroups(s string) ([]ReleaseGroup, error) {
 	return groups, nil
 }
 
-func (db *DB) AutocompleteReleaseGroupTags(s string) ([]string, error) {
+func (db *DB) AutocompleteReleaseGroupTags(ctx context.Context, s string) ([]string, error) {
 	if len(s) == 0 {
 		return nil, errors.New("missing s")
 	}
 
-	rows, err := db.db.Query("SELECT tag FROM release_group_tags WHERE tag LIKE $1", fmt.Sprint("%", s, "%"))
+	rows, err := db.db.QueryContext(ctx, "SELECT tag FROM release_group_tags WHERE tag LIKE $1", fmt.Sprint("%", s, "%"))
 	if err != nil {
 		return nil, err
 	}
@@ -91,12 +92,12 @@ func (db *DB) AutocompleteReleaseGroupTags(s string) ([]string, error) {
 	return tags, nil
 }
 
-func (db *DB) populateReleaseGroupTags(group *ReleaseGroup) error {
+func (db *DB) populateReleaseGroupTags(ctx context.Context, group *ReleaseGroup) error {
 	if group.ID < 0 {
 		return errors.New("invalid group ID")
 	}
 
-	rows, err := db.db.Query("SELECT rgt.tag FROM release_group_tags rgt, release_group_tags_release_groups rgtrg WHERE rgt.id = rgtrg.tag AND rgtrg.release_group = $1", group.ID)
+	rows, err := db.db.QueryContext(ctx, "SELECT rgt.tag FROM release_group_tags rgt, release_group_tags_release_groups rgtrg WHERE rgt.id = rgtrg.tag AND rgtrg.release_group = $1", group.ID)
 	if err != nil {
 		return err
 	}
@@ -115,12 +116,12 @@ func (db *DB) populateReleaseGroupTags(group *ReleaseGroup) error {
 	return nil
 }
 
-func (db *DB) populateReleaseGroupArtists(group *ReleaseGroup) error {
+func (db *DB) populateReleaseGroupArtists(ctx context.Context, group *ReleaseGroup) error {
 	if group.ID < 0 {
 		return errors.New("invalid  group ID")
 	}
 
-	rows, err := db.db.Query("SELECT rga.role,a.id,a.name,a.bio FROM release_groups_artists rga, artists a WHERE rga.artist = a.id AND rga.release_group = $1", group.ID)
+	rows, err := db.db.QueryContext(ctx, "SELECT rga.role,a.id,a.name,a.bio FROM release_groups_artists rga, artists a WHERE rga.artist = a.id AND rga.release_group = $1", group.ID)
 	if err != nil {
 		return err
 	}
@@ -145,12 +146,12 @@ func (db *DB) populateReleaseGroupArtists(group *ReleaseGroup) error {
 	return nil
 }
 
-func (db *DB) GetReleaseGroup(id int) (*ReleaseGroup, error) {
+func (db *DB) GetReleaseGroup(ctx context.Context, id int) (*ReleaseGroup, error) {
 	if id < 0 {
 		return nil, errors.New("invalid ID")
 	}
 
-	res := db.db.QueryRow("SELECT rg.name,rg.release_date,rg.added,u.id,u.username,rg.type FROM release_groups rg, users u WHERE rg.added_by = u.id AND rg.id = $1", id)
+	res := db.db.QueryRowContext(ctx, "SELECT rg.name,rg.release_date,rg.added,u.id,u.username,rg.type FROM release_groups rg, users u WHERE rg.added_by = u.id AND rg.id = $1", id)
 	group := ReleaseGroup{ID: id}
 	err := res.Scan(
 		&group.Name,
@@ -163,12 +164,12 @@ func (db *DB) GetReleaseGroup(id int) (*ReleaseGroup, error) {
 		return nil, err
 	}
 
-	err = db.populateReleaseGroupTags(&group)
+	err = db.populateReleaseGroupTags(ctx, &group)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.populateReleaseGroupArtists(&group)
+	err = db.populateReleaseGroupArtists(ctx, &group)
 	if err != nil {
 		return nil, err
 	}
@@ -176,21 +177,21 @@ func (db *DB) GetReleaseGroup(id int) (*ReleaseGroup, error) {
 	return &group, nil
 }
 
-func insertReleaseGroupTagsTx(group ReleaseGroup, tx *sql.Tx) error {
+func insertReleaseGroupTagsTx(ctx context.Context, group ReleaseGroup, tx *sql.Tx) error {
 	var res sql.Result
 	for _, t := range group.Tags {
 		var id int
-		err := tx.QueryRow("INSERT INTO release_group_tags(tag) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id", t).Scan(&id)
+		err := tx.QueryRowContext(ctx, "INSERT INTO release_group_tags(tag) VALUES ($1) ON CONFLICT DO NOTHING RETURNING id", t).Scan(&id)
 		if err != nil && err != sql.ErrNoRows {
 			return err
 		}
 
 		if err == nil {
 			// inserted
-			res, err = tx.Exec("INSERT INTO release_group_tags_release_groups(release_group,tag) VALUES($1,$2)", group.ID, id)
+			res, err = tx.ExecContext(ctx, "INSERT INTO release_group_tags_release_groups(release_group,tag) VALUES($1,$2)", group.ID, id)
 		} else {
 			// already present
-			res, err = tx.Exec("INSERT INTO release_group_tags_release_groups(release_group,tag) VALUES($1,(SELECT id FROM release_group_tags WHERE tag=$2 LIMIT 1))", group.ID, t)
+			res, err = tx.ExecContext(ctx, "INSERT INTO release_group_tags_release_groups(release_group,tag) VALUES($1,(SELECT id FROM release_group_tags WHERE tag=$2 LIMIT 1))", group.ID, t)
 		}
 		if err != nil {
 			return err
@@ -208,9 +209,9 @@ func insertReleaseGroupTagsTx(group ReleaseGroup, tx *sql.Tx) error {
 	return nil
 }
 
-func insertReleaseGroupArtistsTx(group ReleaseGroup, tx *sql.Tx) error {
+func insertReleaseGroupArtistsTx(ctx context.Context, group ReleaseGroup, tx *sql.Tx) error {
 	for _, a := range group.Artists {
-		res, err := tx.Exec("INSERT INTO release_groups_artists(release_group,artist,role) VALUES($1,$2,$3)", group.ID, a.Artist.ID, a.Role)
+		res, err := tx.ExecContext(ctx, "INSERT INTO release_groups_artists(release_group,artist,role) VALUES($1,$2,$3)", group.ID, a.Artist.ID, a.Role)
 		if err != nil {
 			return err
 		}
@@ -226,37 +227,141 @@ func insertReleaseGroupArtistsTx(group ReleaseGroup, tx *sql.Tx) error {
 	return nil
 }
 
-func insertReleaseGroupTx(group *ReleaseGroup, tx *sql.Tx) error {
-	err := tx.QueryRow("INSERT INTO release_groups(name,release_date,type,added,added_by) VALUES ($1,$2,$3,$4,$5) RETURNING id", group.Name, group.ReleaseDate, group.Type, group.Added, group.AddedBy.ID).Scan(&group.ID)
+func insertReleaseGroupTx(ctx context.Context, group *ReleaseGroup, tx *sql.Tx) error {
+	err := tx.QueryRowContext(ctx, "INSERT INTO release_groups(name,release_date,type,added,added_by) VALUES ($1,$2,$3,$4,$5) RETURNING id", group.Name, group.ReleaseDate, group.Type, group.Added, group.AddedBy.ID).Scan(&group.ID)
 	if err != nil {
 		return err
 	}
 
-	err = insertReleaseGroupTagsTx(*group, tx)
+	err = insertReleaseGroupTagsTx(ctx, *group, tx)
 	if err != nil {
 		return err
 	}
 
-	err = insertReleaseGroupArtistsTx(*group, tx)
+	err = insertReleaseGroupArtistsTx(ctx, *group, tx)
 	if err != nil {
 		return err
 	}
 
 	// TODO insert releases?
-	return nil
+	return recordRevisionTx(ctx, RevisionReleaseGroup, group.ID, Edit{Edited: group.Added, EditedBy: group.AddedBy}, tx)
 }
 
-func (db *DB) InsertReleaseGroup(group *ReleaseGroup) error {
+func (db *DB) InsertReleaseGroup(ctx context.Context, group *ReleaseGroup) error {
 	if group.AddedBy.ID < 0 {
 		return errors.New("invalid user ID")
 	}
 
-	tx, err := db.db.Begin()
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = insertReleaseGroupTx(ctx, group, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteReleaseGroupTagsTx(ctx context.Context, id int, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM release_group_tags_release_groups WHERE release_group = $1", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM release_group_tags t WHERE NOT EXISTS (SELECT * FROM release_group_tags_release_groups rgtrg WHERE rgtrg.tag = t.id)")
+	return err
+}
+
+func updateReleaseGroupTx(ctx context.Context, group ReleaseGroup, tx *sql.Tx) error {
+	res, err := tx.ExecContext(ctx, "UPDATE release_groups SET name = $2, release_date = $3, type = $4 WHERE id = $1", group.ID, group.Name, group.ReleaseDate, group.Type)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("release group not found")
+	}
+
+	err = deleteReleaseGroupTagsTx(ctx, group.ID, tx)
+	if err != nil {
+		return err
+	}
+	err = insertReleaseGroupTagsTx(ctx, group, tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM release_groups_artists WHERE release_group = $1", group.ID)
+	if err != nil {
+		return err
+	}
+
+	return insertReleaseGroupArtistsTx(ctx, group, tx)
+}
+
+// UpdateReleaseGroup updates the name, release date and type of a release
+// group and replaces its tags and artists.
+func (db *DB) UpdateReleaseGroup(ctx context.Context, group ReleaseGroup, e Edit) error {
+	return db.edit(ctx, RevisionReleaseGroup, group.ID, e, func(tx *sql.Tx) error {
+		return updateReleaseGroupTx(ctx, group, tx)
+	})
+}
+
+func deleteReleaseGroupTx(ctx context.Context, id int, tx *sql.Tx) error {
+	var releases int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM releases WHERE release_group = $1", id).Scan(&releases)
+	if err != nil {
+		return err
+	}
+	if releases > 0 {
+		return errors.New("release group has releases")
+	}
+
+	err = deleteReleaseGroupTagsTx(ctx, id, tx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM release_groups_artists WHERE release_group = $1", id)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM release_groups WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("release group not found")
+	}
+
+	return nil
+}
+
+// DeleteReleaseGroup deletes a release group without releases.
+func (db *DB) DeleteReleaseGroup(ctx context.Context, id int) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	err = insertReleaseGroupTx(group, tx)
+	err = deleteReleaseGroupTx(ctx, id, tx)
 	if err != nil {
 		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
 		tx.Rollback()
@@ -266,12 +371,12 @@ func (db *DB) InsertReleaseGroup(group *ReleaseGroup) error {
 	return tx.Commit()
 }
 
-func (db *DB) PopulateReleases(group *ReleaseGroup) error {
+func (db *DB) PopulateReleases(ctx context.Context, group *ReleaseGroup) error {
 	if group.ID < 0 {
 		return errors.New("invalid ID")
 	}
 
-	rows, err := db.db.Query("SELECT r.id,r.edition,r.medium,r.release_date,r.catalogue_number,l.id,l.name,r.added,u.id,u.username,r.original FROM releases r, record_labels l, users u WHERE r.record_label = l.id AND r.added_by = u.id AND r.release_group = $1", group.ID)
+	rows, err := db.db.QueryContext(ctx, "SELECT r.id,r.edition,r.medium,r.release_date,r.catalogue_number,l.id,l.name,r.added,u.id,u.username,r.original FROM releases r, record_labels l, users u WHERE r.record_label = l.id AND r.added_by = u.id AND r.release_group = $1", group.ID)
 	if err != nil {
 		return err
 	}
@@ -295,12 +400,12 @@ func (db *DB) PopulateReleases(group *ReleaseGroup) error {
 			return err
 		}
 
-		err = db.populateReleaseTags(&tmp)
+		err = db.populateReleaseTags(ctx, &tmp)
 		if err != nil {
 			return err
 		}
 
-		err = db.populateReleaseProperties(&tmp)
+		err = db.populateReleaseProperties(ctx, &tmp)
 		if err != nil {
 			return err
 		}
@@ -311,33 +416,103 @@ func (db *DB) PopulateReleases(group *ReleaseGroup) error {
 	return nil
 }
 
-type simpleColumnSelector struct {
-	col string
+func ReleaseGroupIDSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "rg",
+		col: "id",
+	}
 }
 
-func (s simpleColumnSelector) column() string {
-	return s.col
+func ReleaseGroupNameSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "rg",
+		col: "name",
+	}
 }
 
 func ReleaseGroupAddedSelector() ColumnSelector {
 	return simpleColumnSelector{
+		tab: "rg",
 		col: "added",
 	}
 }
 
 func ReleaseGroupReleaseDateSelector() ColumnSelector {
 	return simpleColumnSelector{
+		tab: "rg",
 		col: "release_date",
 	}
 }
 
 func ReleaseGroupTypeSelector() ColumnSelector {
 	return simpleColumnSelector{
+		tab: "rg",
 		col: "type",
 	}
 }
 
-func (db *DB) SearchReleaseGroups(q *Query, offset, limit int) ([]ReleaseGroup, error) {
+// ReleaseGroupTagSelector selects the tag in ReleaseGroupTags.
+func ReleaseGroupTagSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "rgt",
+		col: "tag",
+	}
+}
+
+// ReleaseGroupArtistRoleSelector selects the role of the artist in
+// ReleaseGroupArtists.
+func ReleaseGroupArtistRoleSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "rga",
+		col: "role",
+	}
+}
+
+// ReleaseGroupTags matches release groups with any tag satisfying b.
+// b can use ReleaseGroupTagSelector.
+func ReleaseGroupTags(b Boolean) Boolean {
+	return booleanExists{
+		column: "tags",
+		prefix: "EXISTS (SELECT 1 FROM release_group_tags rgt, release_group_tags_release_groups rgtrg WHERE rgt.id = rgtrg.tag AND rgtrg.release_group = rg.id AND",
+		cond:   b,
+	}
+}
+
+// ReleaseGroupArtists matches release groups with any credited artist
+// satisfying b.
+// b can use ReleaseGroupArtistRoleSelector and the artist selectors.
+func ReleaseGroupArtists(b Boolean) Boolean {
+	return booleanExists{
+		column: "artists",
+		prefix: "EXISTS (SELECT 1 FROM release_groups_artists rga, artists a WHERE rga.artist = a.id AND rga.release_group = rg.id AND",
+		cond:   b,
+	}
+}
+
+// ReleaseGroupReleases matches release groups with any release satisfying b.
+// b can use the release selectors and ReleaseTorrents.
+func ReleaseGroupReleases(b Boolean) Boolean {
+	return booleanExists{
+		column: "releases",
+		prefix: "EXISTS (SELECT 1 FROM releases r WHERE r.release_group = rg.id AND",
+		cond:   b,
+	}
+}
+
+// ReleaseGroupHasTag matches release groups tagged with tag.
+func ReleaseGroupHasTag(tag string) Boolean {
+	return ReleaseGroupTags(Eq(ReleaseGroupTagSelector(), tag))
+}
+
+// ReleaseGroupHasArtist matches release groups the artist with the given ID
+// is credited on, in any role.
+func ReleaseGroupHasArtist(id int) Boolean {
+	return ReleaseGroupArtists(Eq(ArtistIDSelector(), id))
+}
+
+// SearchReleaseGroups returns the release groups matching q.
+// Release groups the sorter of q considers equal are ordered by ID.
+func (db *DB) SearchReleaseGroups(ctx context.Context, q *Query, offset, limit int) ([]ReleaseGroup, error) {
 	if q == nil {
 		return nil, errors.New("missing q")
 	}
@@ -348,11 +523,21 @@ func (db *DB) SearchReleaseGroups(q *Query, offset, limit int) ([]ReleaseGroup,
 		return nil, errors.New("invalid offset")
 	}
 
-	query, params := q.Build()
+	cond, params := q.buildCondition()
+	if cond != "" {
+		cond = " AND " + cond
+	}
+
+	order := q.buildOrder()
+	if order != "" {
+		order += ", rg.id ASC"
+	} else {
+		order = "ORDER BY rg.id ASC"
+	}
 
-	qq := fmt.Sprintf("SELECT rg.id,rg.name,rg.release_date,rg.added,u.id,u.username,rg.type FROM release_groups rg, users u WHERE rg.added_by = u.id AND %s OFFSET $%d LIMIT $%d;", query, len(params)+1, len(params)+2)
+	qq := fmt.Sprintf("SELECT rg.id,rg.name,rg.release_date,rg.added,u.id,u.username,rg.type FROM release_groups rg, users u WHERE rg.added_by = u.id%s %s OFFSET $%d LIMIT $%d;", cond, order, len(params)+1, len(params)+2)
 
-	rows, err := db.db.Query(qq, append(params, offset, limit)...)
+	rows, err := db.db.QueryContext(ctx, qq, append(params, offset, limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -373,12 +558,12 @@ func (db *DB) SearchReleaseGroups(q *Query, offset, limit int) ([]ReleaseGroup,
 			return nil, err
 		}
 
-		err = db.populateReleaseGroupTags(&group)
+		err = db.populateReleaseGroupTags(ctx, &group)
 		if err != nil {
 			return nil, err
 		}
 
-		err = db.populateReleaseGroupArtists(&group)
+		err = db.populateReleaseGroupArtists(ctx, &group)
 		if err != nil {
 			return nil, err
 		}
@@ -388,3 +573,108 @@ func (db *DB) SearchReleaseGroups(q *Query, offset, limit int) ([]ReleaseGroup,
 
 	return groups, nil
 }
+
+// CountReleaseGroups returns the number of release groups matching q.
+// The sorter of q is ignored.
+func (db *DB) CountReleaseGroups(ctx context.Context, q *Query) (int, error) {
+	if q == nil {
+		return 0, errors.New("missing q")
+	}
+
+	cond, params := q.buildCondition()
+	if cond != "" {
+		cond = " AND " + cond
+	}
+
+	var count int
+	err := db.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM release_groups rg, users u WHERE rg.added_by = u.id%s", cond), params...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// ReleaseGroupFacets counts the release groups matching a query by some of
+// their properties.
+// A release group is counted once for every format, medium and leech type of
+// its releases and torrents.
+type ReleaseGroupFacets struct {
+	Types      map[int]int
+	Decades    map[int]int // keyed by the first year of the decade
+	Media      map[int]int
+	Formats    map[int]int
+	LeechTypes map[int]int
+	Tags       map[string]int
+}
+
+// NewReleaseGroupFacets returns facets with all counts zero.
+func NewReleaseGroupFacets() *ReleaseGroupFacets {
+	return &ReleaseGroupFacets{
+		Types:      make(map[int]int),
+		Decades:    make(map[int]int),
+		Media:      make(map[int]int),
+		Formats:    make(map[int]int),
+		LeechTypes: make(map[int]int),
+		Tags:       make(map[string]int),
+	}
+}
+
+// GetReleaseGroupFacets computes the facets of the release groups matching q.
+// The sorter of q is ignored.
+func (db *DB) GetReleaseGroupFacets(ctx context.Context, q *Query) (*ReleaseGroupFacets, error) {
+	if q == nil {
+		return nil, errors.New("missing q")
+	}
+
+	cond, params := q.buildCondition()
+	if cond != "" {
+		cond = " AND " + cond
+	}
+
+	// All facets are computed from the matches in one pass.
+	qq := fmt.Sprintf("WITH matches AS (SELECT rg.id, rg.type, rg.release_date FROM release_groups rg, users u WHERE rg.added_by = u.id%s) "+
+		"SELECT 'type', m.type, NULL::TEXT, COUNT(*) FROM matches m GROUP BY m.type "+
+		"UNION ALL SELECT 'decade', (EXTRACT(YEAR FROM m.release_date)::INT / 10) * 10, NULL::TEXT, COUNT(*) FROM matches m GROUP BY 2 "+
+		"UNION ALL SELECT 'medium', r.medium, NULL::TEXT, COUNT(DISTINCT m.id) FROM matches m, releases r WHERE r.release_group = m.id GROUP BY r.medium "+
+		"UNION ALL SELECT 'format', t.format, NULL::TEXT, COUNT(DISTINCT m.id) FROM matches m, releases r, torrents t WHERE r.release_group = m.id AND t.release = r.id GROUP BY t.format "+
+		"UNION ALL SELECT 'leech_type', td.leech_type, NULL::TEXT, COUNT(DISTINCT m.id) FROM matches m, releases r, torrents t, torrent_trackerdata td WHERE r.release_group = m.id AND t.release = r.id AND td.torrent = t.id GROUP BY td.leech_type "+
+		"UNION ALL SELECT 'tag', NULL::INT, rgt.tag, COUNT(*) FROM matches m, release_group_tags_release_groups rgtrg, release_group_tags rgt WHERE rgtrg.release_group = m.id AND rgtrg.tag = rgt.id GROUP BY rgt.tag", cond)
+
+	rows, err := db.db.QueryContext(ctx, qq, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := NewReleaseGroupFacets()
+	for rows.Next() {
+		var (
+			facet string
+			key   sql.NullInt64
+			tag   sql.NullString
+			count int
+		)
+		err = rows.Scan(&facet, &key, &tag, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		switch facet {
+		case "type":
+			facets.Types[int(key.Int64)] = count
+		case "decade":
+			facets.Decades[int(key.Int64)] = count
+		case "medium":
+			facets.Media[int(key.Int64)] = count
+		case "format":
+			facets.Formats[int(key.Int64)] = count
+		case "leech_type":
+			facets.LeechTypes[int(key.Int64)] = count
+		case "tag":
+			facets.Tags[tag.String] = count
+		}
+	}
+
+	return facets, nil
+}
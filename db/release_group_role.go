@@ -1,7 +1,9 @@
 package db
 
-func (db *DB) GetAllReleaseGroupRoles() (map[int]string, error) {
-	rows, err := db.db.Query("SELECT id,role FROM release_roles")
+import "context"
+
+func (db *DB) GetAllReleaseGroupRoles(ctx context.Context) (map[int]string, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,role FROM release_roles")
 	if err != nil {
 		return nil, err
 	}
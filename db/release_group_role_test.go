@@ -1,16 +1,19 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetAllReleaseGroupRoles(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	roles, err := db.GetAllReleaseGroupRoles()
+	roles, err := db.GetAllReleaseGroupRoles(ctx)
 	require.Nil(t, err)
 	require.NotNil(t, roles)
 	require.NotEmpty(t, roles)
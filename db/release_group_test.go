@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -8,6 +9,8 @@ import (
 )
 
 func TestAutocompleteReleaseGroupTags(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -17,7 +20,7 @@ func TestAutocompleteReleaseGroupTags(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertArtist(&a)
+	err = db.InsertArtist(ctx, &a)
 	require.Nil(t, err)
 
 	g := ReleaseGroup{
@@ -35,15 +38,15 @@ func TestAutocompleteReleaseGroupTags(t *testing.T) {
 		Tags:        []string{"electronic", "canadian"},
 	}
 
-	err = db.InsertReleaseGroup(&g)
+	err = db.InsertReleaseGroup(ctx, &g)
 	require.Nil(t, err)
 
-	tags, err := db.AutocompleteReleaseGroupTags("elec")
+	tags, err := db.AutocompleteReleaseGroupTags(ctx, "elec")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(tags))
 	require.Equal(t, "electronic", tags[0])
 
-	tags, err = db.AutocompleteReleaseGroupTags("i")
+	tags, err = db.AutocompleteReleaseGroupTags(ctx, "i")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(tags))
 	require.Contains(t, tags, "electronic")
@@ -51,6 +54,8 @@ func TestAutocompleteReleaseGroupTags(t *testing.T) {
 }
 
 func TestAutocompleteReleaseGroups(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -60,7 +65,7 @@ func TestAutocompleteReleaseGroups(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertArtist(&a)
+	err = db.InsertArtist(ctx, &a)
 	require.Nil(t, err)
 
 	g1 := ReleaseGroup{
@@ -93,13 +98,13 @@ func TestAutocompleteReleaseGroups(t *testing.T) {
 		Tags:        []string{"electronic", "canadian", "house"},
 	}
 
-	err = db.InsertReleaseGroup(&g1)
+	err = db.InsertReleaseGroup(ctx, &g1)
 	require.Nil(t, err)
 
-	err = db.InsertReleaseGroup(&g2)
+	err = db.InsertReleaseGroup(ctx, &g2)
 	require.Nil(t, err)
 
-	groups, err := db.AutocompleteReleaseGroups("old")
+	groups, err := db.AutocompleteReleaseGroups(ctx, "old")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(groups))
 	require.Equal(t, g1.Name, groups[0].Name)
@@ -112,7 +117,7 @@ func TestAutocompleteReleaseGroups(t *testing.T) {
 	require.Equal(t, g1.Type, groups[0].Type)
 	require.Equal(t, g1.Tags, groups[0].Tags)
 
-	groups, err = db.AutocompleteReleaseGroups("new")
+	groups, err = db.AutocompleteReleaseGroups(ctx, "new")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(groups))
 	require.Equal(t, g2.Name, groups[0].Name)
@@ -125,12 +130,14 @@ func TestAutocompleteReleaseGroups(t *testing.T) {
 	require.Equal(t, g2.Type, groups[0].Type)
 	require.Equal(t, g2.Tags, groups[0].Tags)
 
-	groups, err = db.AutocompleteReleaseGroups("title")
+	groups, err = db.AutocompleteReleaseGroups(ctx, "title")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(groups))
 }
 
 func TestInsertGetReleaseGroup(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -140,7 +147,7 @@ func TestInsertGetReleaseGroup(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertArtist(&a)
+	err = db.InsertArtist(ctx, &a)
 	require.Nil(t, err)
 
 	g := ReleaseGroup{
@@ -158,10 +165,10 @@ func TestInsertGetReleaseGroup(t *testing.T) {
 		Tags:        []string{"electronic", "canadian"},
 	}
 
-	err = db.InsertReleaseGroup(&g)
+	err = db.InsertReleaseGroup(ctx, &g)
 	require.Nil(t, err)
 
-	got, err := db.GetReleaseGroup(g.ID)
+	got, err := db.GetReleaseGroup(ctx, g.ID)
 	require.Nil(t, err)
 	require.Equal(t, g.Name, got.Name)
 	require.Equal(t, len(g.Artists), len(got.Artists))
@@ -175,6 +182,8 @@ func TestInsertGetReleaseGroup(t *testing.T) {
 }
 
 func TestSearchReleaseGroups(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -184,7 +193,7 @@ func TestSearchReleaseGroups(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertArtist(&a)
+	err = db.InsertArtist(ctx, &a)
 	require.Nil(t, err)
 
 	g1 := ReleaseGroup{
@@ -217,13 +226,13 @@ func TestSearchReleaseGroups(t *testing.T) {
 		Tags:        []string{"electronic", "canadian", "house"},
 	}
 
-	err = db.InsertReleaseGroup(&g1)
+	err = db.InsertReleaseGroup(ctx, &g1)
 	require.Nil(t, err)
 
-	err = db.InsertReleaseGroup(&g2)
+	err = db.InsertReleaseGroup(ctx, &g2)
 	require.Nil(t, err)
 
-	groups, err := db.SearchReleaseGroups(NewQuery(
+	groups, err := db.SearchReleaseGroups(ctx, NewQuery(
 		Eq(ReleaseGroupReleaseDateSelector(), g2.ReleaseDate),
 	), 0, 100)
 	require.Nil(t, err)
@@ -235,7 +244,7 @@ func TestSearchReleaseGroups(t *testing.T) {
 		Neq(ReleaseGroupReleaseDateSelector(), g2.ReleaseDate),
 	)) // This is effectively WHERE TRUE
 	q.SetSorter(SortDescending(ReleaseGroupReleaseDateSelector()))
-	groups, err = db.SearchReleaseGroups(q, 0, 100)
+	groups, err = db.SearchReleaseGroups(ctx, q, 0, 100)
 	require.Nil(t, err)
 	require.Equal(t, 2, len(groups))
 	require.Equal(t, g2.ID, groups[0].ID)
@@ -1,7 +1,9 @@
 package db
 
-func (db *DB) GetAllReleaseGroupTypes() (map[int]string, error) {
-	rows, err := db.db.Query("SELECT id,type FROM release_group_types")
+import "context"
+
+func (db *DB) GetAllReleaseGroupTypes(ctx context.Context) (map[int]string, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,type FROM release_group_types")
 	if err != nil {
 		return nil, err
 	}
@@ -1,9 +1,12 @@
 package db
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
-func (db *DB) GetAllReleaseProperties() (map[int]string, error) {
-	rows, err := db.db.Query("SELECT id,property FROM release_properties")
+func (db *DB) GetAllReleaseProperties(ctx context.Context) (map[int]string, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,property FROM release_properties")
 	if err != nil {
 		return nil, err
 	}
@@ -26,12 +29,12 @@ func (db *DB) GetAllReleaseProperties() (map[int]string, error) {
 	return m, nil
 }
 
-func (db *DB) AddReleaseProperty(key string) error {
+func (db *DB) AddReleaseProperty(ctx context.Context, key string) error {
 	if len(key) == 0 {
 		return errors.New("missing key")
 	}
 
-	res, err := db.db.Exec("INSERT INTO release_properties(property) VALUES ($1)", key)
+	res, err := db.db.ExecContext(ctx, "INSERT INTO release_properties(property) VALUES ($1)", key)
 	if err != nil {
 		return err
 	}
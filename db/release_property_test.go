@@ -1,26 +1,29 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestAddGetAllReleaseProperties(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	p, err := db.GetAllReleaseProperties()
+	p, err := db.GetAllReleaseProperties(ctx)
 	require.Nil(t, err)
 	require.NotNil(t, p)
 	require.NotEmpty(t, p)
 
 	l := len(p)
 
-	err = db.AddReleaseProperty("SquareVinylApproved")
+	err = db.AddReleaseProperty(ctx, "SquareVinylApproved")
 	require.Nil(t, err)
 
-	p, err = db.GetAllReleaseProperties()
+	p, err = db.GetAllReleaseProperties(ctx)
 	require.Nil(t, err)
 	require.Equal(t, l+1, len(p))
 }
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 	"time"
@@ -9,6 +10,8 @@ import (
 )
 
 func TestInsertGetDeleteRelease(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -17,7 +20,7 @@ func TestInsertGetDeleteRelease(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertRecordLabel(&l)
+	err = db.InsertRecordLabel(ctx, &l)
 	require.Nil(t, err)
 
 	a := Artist{
@@ -26,7 +29,7 @@ func TestInsertGetDeleteRelease(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertArtist(&a)
+	err = db.InsertArtist(ctx, &a)
 	require.Nil(t, err)
 
 	g := ReleaseGroup{
@@ -44,7 +47,7 @@ func TestInsertGetDeleteRelease(t *testing.T) {
 		Tags:        []string{"electronic", "canadian"},
 	}
 
-	err = db.InsertReleaseGroup(&g)
+	err = db.InsertReleaseGroup(ctx, &g)
 	require.Nil(t, err)
 
 	r := Release{
@@ -60,10 +63,10 @@ func TestInsertGetDeleteRelease(t *testing.T) {
 		Properties:      map[string]string{"LossyWebApproved": "", "LossyMasterApproved": "true"},
 	}
 
-	err = db.InsertRelease(&r)
+	err = db.InsertRelease(ctx, &r)
 	require.Nil(t, err)
 
-	got, err := db.GetRelease(r.ID)
+	got, err := db.GetRelease(ctx, r.ID)
 	require.Nil(t, err)
 	require.False(t, got.Edition.Valid)
 	require.Equal(t, r.Medium, got.Medium)
@@ -77,14 +80,16 @@ func TestInsertGetDeleteRelease(t *testing.T) {
 	require.Equal(t, r.Tags, got.Tags)
 	require.Equal(t, r.Properties, got.Properties)
 
-	err = db.DeleteRelease(r.ID)
+	err = db.DeleteRelease(ctx, r.ID)
 	require.Nil(t, err)
 
-	_, err = db.GetRelease(r.ID)
+	_, err = db.GetRelease(ctx, r.ID)
 	require.Equal(t, sql.ErrNoRows, err)
 }
 
 func TestAutocompleteReleaseTags(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -93,7 +98,7 @@ func TestAutocompleteReleaseTags(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertRecordLabel(&l)
+	err = db.InsertRecordLabel(ctx, &l)
 	require.Nil(t, err)
 
 	a := Artist{
@@ -102,7 +107,7 @@ func TestAutocompleteReleaseTags(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertArtist(&a)
+	err = db.InsertArtist(ctx, &a)
 	require.Nil(t, err)
 
 	g := ReleaseGroup{
@@ -120,7 +125,7 @@ func TestAutocompleteReleaseTags(t *testing.T) {
 		Tags:        []string{"electronic", "canadian"},
 	}
 
-	err = db.InsertReleaseGroup(&g)
+	err = db.InsertReleaseGroup(ctx, &g)
 	require.Nil(t, err)
 
 	r1 := Release{
@@ -147,18 +152,18 @@ func TestAutocompleteReleaseTags(t *testing.T) {
 		Properties:   map[string]string{"LossyWebApproved": "", "LossyMasterApproved": "true"},
 	}
 
-	err = db.InsertRelease(&r1)
+	err = db.InsertRelease(ctx, &r1)
 	require.Nil(t, err)
 
-	err = db.InsertRelease(&r2)
+	err = db.InsertRelease(ctx, &r2)
 	require.Nil(t, err)
 
-	tags, err := db.AutocompleteReleaseTags("k")
+	tags, err := db.AutocompleteReleaseTags(ctx, "k")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(tags))
 	require.Equal(t, "special.k.edition", tags[0])
 
-	tags, err = db.AutocompleteReleaseTags("tag")
+	tags, err = db.AutocompleteReleaseTags(ctx, "tag")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(tags))
 	require.Contains(t, tags, "some.tag")
@@ -166,6 +171,8 @@ func TestAutocompleteReleaseTags(t *testing.T) {
 }
 
 func TestReleaseSetProperty(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
@@ -174,7 +181,7 @@ func TestReleaseSetProperty(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertRecordLabel(&l)
+	err = db.InsertRecordLabel(ctx, &l)
 	require.Nil(t, err)
 
 	a := Artist{
@@ -183,7 +190,7 @@ func TestReleaseSetProperty(t *testing.T) {
 		AddedBy: User{ID: 1},
 	}
 
-	err = db.InsertArtist(&a)
+	err = db.InsertArtist(ctx, &a)
 	require.Nil(t, err)
 
 	g := ReleaseGroup{
@@ -201,7 +208,7 @@ func TestReleaseSetProperty(t *testing.T) {
 		Tags:        []string{"electronic", "canadian"},
 	}
 
-	err = db.InsertReleaseGroup(&g)
+	err = db.InsertReleaseGroup(ctx, &g)
 	require.Nil(t, err)
 
 	r := Release{
@@ -216,20 +223,21 @@ func TestReleaseSetProperty(t *testing.T) {
 		Properties:   map[string]string{"LossyWebApproved": "", "LossyMasterApproved": "true"},
 	}
 
-	err = db.InsertRelease(&r)
+	err = db.InsertRelease(ctx, &r)
 	require.Nil(t, err)
 
-	got, err := db.GetRelease(r.ID)
+	got, err := db.GetRelease(ctx, r.ID)
 	require.Nil(t, err)
 	require.Equal(t, r.Properties, got.Properties)
 
-	err = db.SetReleaseProperty(r.ID, "CassetteApproved", "blah")
+	e := Edit{Edited: time.Date(2012, 3, 4, 0, 0, 0, 0, time.FixedZone("", 0)), EditedBy: User{ID: 1}}
+	err = db.SetReleaseProperty(ctx, r.ID, "CassetteApproved", "blah", e)
 	require.Nil(t, err)
 
-	err = db.SetReleaseProperty(r.ID, "LossyWebApproved", "true")
+	err = db.SetReleaseProperty(ctx, r.ID, "LossyWebApproved", "true", e)
 	require.Nil(t, err)
 
-	got, err = db.GetRelease(r.ID)
+	got, err = db.GetRelease(ctx, r.ID)
 	require.Nil(t, err)
 	require.Equal(t, "blah", got.Properties["CassetteApproved"])
 	require.Equal(t, "true", got.Properties["LossyWebApproved"])
@@ -0,0 +1,848 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+// Entity types that have revisions.
+const (
+	RevisionArtist       = "artist"
+	RevisionReleaseGroup = "release_group"
+	RevisionRelease      = "release"
+	RevisionRecordLabel  = "record_label"
+)
+
+// revisionTables maps the entity types that have revisions to their tables.
+var revisionTables = map[string]string{
+	RevisionArtist:       "artists",
+	RevisionReleaseGroup: "release_groups",
+	RevisionRelease:      "releases",
+	RevisionRecordLabel:  "record_labels",
+}
+
+func validRevisionEntityType(entityType string) bool {
+	_, ok := revisionTables[entityType]
+	return ok
+}
+
+// An Edit attributes a change of a catalog entity to a user.
+// Every edit is recorded as a revision of the entity, in the same transaction
+// as the change itself.
+type Edit struct {
+	Edited   time.Time
+	EditedBy User
+}
+
+// A Revision records an edit of a catalog entity.
+type Revision struct {
+	ID         int
+	EntityType string
+	Entity     int
+	Revision   int // counts from 1 per entity
+
+	// Snapshot is a JSON object holding the state of the entity after the
+	// edit.
+	Snapshot json.RawMessage
+
+	// Diff is a JSON object holding the fields that changed compared to the
+	// previous revision, see DiffSnapshots.
+	Diff json.RawMessage
+
+	Edited   time.Time
+	EditedBy User
+}
+
+// Snapshots hold the editable fields of an entity.
+// Lookup values are stored by name, not by ID, and lists are sorted, so that
+// diffs between snapshots only show actual changes.
+
+type ArtistSnapshot struct {
+	Name    string   `json:"name"`
+	Bio     *string  `json:"bio,omitempty"`
+	Aliases []string `json:"aliases,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// Normalize sorts the aliases and tags of the snapshot.
+func (s *ArtistSnapshot) Normalize() {
+	s.Aliases = sortedStrings(s.Aliases)
+	s.Tags = sortedStrings(s.Tags)
+}
+
+type SnapshotArtist struct {
+	Artist int    `json:"artist"`
+	Role   string `json:"role"`
+}
+
+type ReleaseGroupSnapshot struct {
+	Name        string           `json:"name"`
+	Type        string           `json:"type"`
+	ReleaseDate time.Time        `json:"release_date"`
+	Artists     []SnapshotArtist `json:"artists,omitempty"`
+	Tags        []string         `json:"tags,omitempty"`
+}
+
+// Normalize sorts the artists and tags of the snapshot.
+func (s *ReleaseGroupSnapshot) Normalize() {
+	if len(s.Artists) == 0 {
+		s.Artists = nil
+	}
+	sort.Slice(s.Artists, func(i, j int) bool {
+		if s.Artists[i].Artist != s.Artists[j].Artist {
+			return s.Artists[i].Artist < s.Artists[j].Artist
+		}
+		return s.Artists[i].Role < s.Artists[j].Role
+	})
+	s.Tags = sortedStrings(s.Tags)
+}
+
+type ReleaseSnapshot struct {
+	ReleaseGroup    int               `json:"release_group"` // can not be reverted
+	Edition         *string           `json:"edition,omitempty"`
+	Medium          string            `json:"medium"`
+	ReleaseDate     time.Time         `json:"release_date"`
+	CatalogueNumber *string           `json:"catalogue_number,omitempty"`
+	RecordLabel     int               `json:"record_label"`
+	Original        bool              `json:"original"`
+	Tags            []string          `json:"tags,omitempty"`
+	Properties      map[string]string `json:"properties,omitempty"`
+}
+
+// Normalize sorts the tags of the snapshot.
+func (s *ReleaseSnapshot) Normalize() {
+	s.Tags = sortedStrings(s.Tags)
+}
+
+type RecordLabelSnapshot struct {
+	Name        string     `json:"name"`
+	Description *string    `json:"description,omitempty"`
+	Founded     *time.Time `json:"founded,omitempty"`
+	Parent      *int       `json:"parent,omitempty"`
+}
+
+func sortedStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	sorted := append([]string(nil), s...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// A FieldChange is the change of a single field between two snapshots.
+// Old is not set for added fields, New is not set for removed fields.
+type FieldChange struct {
+	Old json.RawMessage `json:"old,omitempty"`
+	New json.RawMessage `json:"new,omitempty"`
+}
+
+// DiffSnapshots compares the top-level fields of two JSON objects and
+// returns a JSON object mapping the fields that differ to FieldChanges.
+// old may be empty, in which case all fields of new are added.
+func DiffSnapshots(old, new json.RawMessage) (json.RawMessage, error) {
+	var o, n map[string]json.RawMessage
+	if len(old) > 0 {
+		err := json.Unmarshal(old, &o)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := json.Unmarshal(new, &n)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]FieldChange)
+	for k, v := range o {
+		nv, ok := n[k]
+		if !ok {
+			diff[k] = FieldChange{Old: v}
+			continue
+		}
+
+		// compare the values, not their encodings, Postgres reformats JSON
+		var ov, nvv interface{}
+		err = json.Unmarshal(v, &ov)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(nv, &nvv)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(ov, nvv) {
+			diff[k] = FieldChange{Old: v, New: nv}
+		}
+	}
+	for k, v := range n {
+		if _, ok := o[k]; !ok {
+			diff[k] = FieldChange{New: v}
+		}
+	}
+
+	return json.Marshal(diff)
+}
+
+func insertRevisionTx(ctx context.Context, r *Revision, tx *sql.Tx) error {
+	var (
+		previous []byte
+		latest   int
+	)
+	err := tx.QueryRowContext(ctx, "SELECT revision,snapshot FROM revisions WHERE entity_type = $1 AND entity = $2 ORDER BY revision DESC LIMIT 1 FOR UPDATE", r.EntityType, r.Entity).Scan(&latest, &previous)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	diff, err := DiffSnapshots(previous, r.Snapshot)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	err = tx.QueryRowContext(ctx, "INSERT INTO revisions(entity_type,entity,revision,snapshot,diff,edited,edited_by) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id", r.EntityType, r.Entity, latest+1, string(r.Snapshot), string(diff), r.Edited, r.EditedBy.ID).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	r.ID = id
+	r.Revision = latest + 1
+	r.Diff = diff
+	return nil
+}
+
+func queryStringsTx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var s []string
+	for rows.Next() {
+		var tmp string
+		err = rows.Scan(&tmp)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, tmp)
+	}
+
+	return s, rows.Err()
+}
+
+func artistSnapshotTx(ctx context.Context, id int, tx *sql.Tx) (*ArtistSnapshot, error) {
+	var (
+		s   ArtistSnapshot
+		bio sql.NullString
+	)
+	err := tx.QueryRowContext(ctx, "SELECT name,bio FROM artists WHERE id = $1", id).Scan(&s.Name, &bio)
+	if err != nil {
+		return nil, err
+	}
+	if bio.Valid {
+		s.Bio = &bio.String
+	}
+
+	s.Aliases, err = queryStringsTx(ctx, tx, "SELECT alias FROM artist_aliases WHERE artist = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	s.Tags, err = queryStringsTx(ctx, tx, "SELECT t.tag FROM artist_tags t, artist_tags_artists a WHERE a.tag = t.id AND a.artist = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Normalize()
+	return &s, nil
+}
+
+func releaseGroupSnapshotTx(ctx context.Context, id int, tx *sql.Tx) (*ReleaseGroupSnapshot, error) {
+	var s ReleaseGroupSnapshot
+	err := tx.QueryRowContext(ctx, "SELECT rg.name,t.type,rg.release_date FROM release_groups rg, release_group_types t WHERE rg.type = t.id AND rg.id = $1", id).Scan(&s.Name, &s.Type, &s.ReleaseDate)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT rga.artist,r.role FROM release_groups_artists rga, release_roles r WHERE rga.role = r.id AND rga.release_group = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tmp SnapshotArtist
+		err = rows.Scan(&tmp.Artist, &tmp.Role)
+		if err != nil {
+			return nil, err
+		}
+		s.Artists = append(s.Artists, tmp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.Tags, err = queryStringsTx(ctx, tx, "SELECT t.tag FROM release_group_tags t, release_group_tags_release_groups rgtrg WHERE rgtrg.tag = t.id AND rgtrg.release_group = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Normalize()
+	return &s, nil
+}
+
+func releaseSnapshotTx(ctx context.Context, id int, tx *sql.Tx) (*ReleaseSnapshot, error) {
+	var (
+		s                        ReleaseSnapshot
+		edition, catalogueNumber sql.NullString
+	)
+	err := tx.QueryRowContext(ctx, "SELECT r.release_group,r.edition,m.medium,r.release_date,r.catalogue_number,r.record_label,r.original FROM releases r, media m WHERE r.medium = m.id AND r.id = $1", id).Scan(
+		&s.ReleaseGroup,
+		&edition,
+		&s.Medium,
+		&s.ReleaseDate,
+		&catalogueNumber,
+		&s.RecordLabel,
+		&s.Original)
+	if err != nil {
+		return nil, err
+	}
+	if edition.Valid {
+		s.Edition = &edition.String
+	}
+	if catalogueNumber.Valid {
+		s.CatalogueNumber = &catalogueNumber.String
+	}
+
+	s.Tags, err = queryStringsTx(ctx, tx, "SELECT t.tag FROM release_tags t, release_tags_releases rtr WHERE rtr.tag = t.id AND rtr.release = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT p.property,rpr.value FROM release_properties p, release_properties_releases rpr WHERE rpr.property = p.id AND rpr.release = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			k string
+			v sql.NullString
+		)
+		err = rows.Scan(&k, &v)
+		if err != nil {
+			return nil, err
+		}
+		if s.Properties == nil {
+			s.Properties = make(map[string]string)
+		}
+		s.Properties[k] = v.String
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.Normalize()
+	return &s, nil
+}
+
+func recordLabelSnapshotTx(ctx context.Context, id int, tx *sql.Tx) (*RecordLabelSnapshot, error) {
+	var (
+		s           RecordLabelSnapshot
+		description sql.NullString
+		founded     pq.NullTime
+		parent      sql.NullInt64
+	)
+	err := tx.QueryRowContext(ctx, "SELECT name,description,founded,parent FROM record_labels WHERE id = $1", id).Scan(&s.Name, &description, &founded, &parent)
+	if err != nil {
+		return nil, err
+	}
+	if description.Valid {
+		s.Description = &description.String
+	}
+	if founded.Valid {
+		s.Founded = &founded.Time
+	}
+	if parent.Valid {
+		p := int(parent.Int64)
+		s.Parent = &p
+	}
+
+	return &s, nil
+}
+
+// snapshotTx returns the current snapshot of an entity.
+// It returns sql.ErrNoRows if the entity does not exist.
+func snapshotTx(ctx context.Context, entityType string, id int, tx *sql.Tx) (interface{}, error) {
+	switch entityType {
+	case RevisionArtist:
+		return artistSnapshotTx(ctx, id, tx)
+	case RevisionReleaseGroup:
+		return releaseGroupSnapshotTx(ctx, id, tx)
+	case RevisionRelease:
+		return releaseSnapshotTx(ctx, id, tx)
+	case RevisionRecordLabel:
+		return recordLabelSnapshotTx(ctx, id, tx)
+	}
+
+	return nil, fmt.Errorf("unknown entity type %q", entityType)
+}
+
+// recordRevisionTx records the current state of an entity as a new revision.
+func recordRevisionTx(ctx context.Context, entityType string, id int, e Edit, tx *sql.Tx) error {
+	s, err := snapshotTx(ctx, entityType, id, tx)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return insertRevisionTx(ctx, &Revision{
+		EntityType: entityType,
+		Entity:     id,
+		Snapshot:   b,
+		Edited:     e.Edited,
+		EditedBy:   e.EditedBy,
+	}, tx)
+}
+
+// beginEditTx locks an entity for an edit.
+// If the entity has no revisions yet, for example because it predates them,
+// its current state is recorded first, attributed to the user who added it,
+// so that the edit can be reverted.
+// It returns sql.ErrNoRows if the entity does not exist.
+func beginEditTx(ctx context.Context, entityType string, id int, tx *sql.Tx) error {
+	table, ok := revisionTables[entityType]
+	if !ok {
+		return fmt.Errorf("unknown entity type %q", entityType)
+	}
+
+	var e Edit
+	err := tx.QueryRowContext(ctx, "SELECT added,added_by FROM "+table+" WHERE id = $1 FOR UPDATE", id).Scan(&e.Edited, &e.EditedBy.ID)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM revisions WHERE entity_type = $1 AND entity = $2", entityType, id).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return recordRevisionTx(ctx, entityType, id, e, tx)
+}
+
+func editTx(ctx context.Context, entityType string, id int, e Edit, f func(*sql.Tx) error, tx *sql.Tx) error {
+	err := beginEditTx(ctx, entityType, id, tx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		return err
+	}
+
+	return recordRevisionTx(ctx, entityType, id, e, tx)
+}
+
+// edit runs f in a transaction and records the resulting state of the
+// entity as a new revision in the same transaction.
+func (db *DB) edit(ctx context.Context, entityType string, id int, e Edit, f func(*sql.Tx) error) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+	if e.EditedBy.ID < 0 {
+		return errors.New("invalid user ID")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = editTx(ctx, entityType, id, e, f, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func containsString(s []string, v string) bool {
+	for _, tmp := range s {
+		if tmp == v {
+			return true
+		}
+	}
+	return false
+}
+
+// revertArtistTx restores the name, bio, aliases and tags of an artist.
+// Restored aliases are attributed to the reverting user.
+func revertArtistTx(ctx context.Context, id int, b json.RawMessage, e Edit, tx *sql.Tx) error {
+	var s ArtistSnapshot
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	current, err := artistSnapshotTx(ctx, id, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range current.Aliases {
+		if !containsString(s.Aliases, alias) {
+			err = removeArtistAliasTx(ctx, id, alias, tx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	artist := Artist{ID: id, Name: s.Name}
+	if s.Bio != nil {
+		artist.Bio = sql.NullString{String: *s.Bio, Valid: true}
+	}
+	err = updateArtistTx(ctx, artist, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range s.Aliases {
+		if !containsString(current.Aliases, alias) {
+			err = addArtistAliasTx(ctx, id, ArtistAlias{Alias: alias, Added: e.Edited, AddedBy: e.EditedBy}, tx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, tag := range current.Tags {
+		if !containsString(s.Tags, tag) {
+			err = removeArtistTagTx(ctx, id, tag, tx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	var added []string
+	for _, tag := range s.Tags {
+		if !containsString(current.Tags, tag) {
+			added = append(added, tag)
+		}
+	}
+
+	return addArtistTagsTx(ctx, id, added, tx)
+}
+
+// revertReleaseGroupTx restores a release group.
+// Artists that were merged into another artist since are replaced by that
+// artist.
+func revertReleaseGroupTx(ctx context.Context, id int, b json.RawMessage, tx *sql.Tx) error {
+	var s ReleaseGroupSnapshot
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	group := ReleaseGroup{
+		ID:          id,
+		Name:        s.Name,
+		ReleaseDate: s.ReleaseDate,
+		Tags:        s.Tags,
+	}
+	err = tx.QueryRowContext(ctx, "SELECT id FROM release_group_types WHERE type = $1", s.Type).Scan(&group.Type)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("unknown release group type %q", s.Type)
+		}
+		return err
+	}
+
+	type credit struct{ artist, role int }
+	seen := make(map[credit]bool)
+	for _, ra := range s.Artists {
+		artist := RoledArtist{Artist: Artist{ID: ra.Artist}}
+		err = tx.QueryRowContext(ctx, "SELECT id FROM release_roles WHERE role = $1", ra.Role).Scan(&artist.Role)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("unknown role %q", ra.Role)
+			}
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, "SELECT target FROM artist_redirects WHERE artist = $1", ra.Artist).Scan(&artist.Artist.ID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		// the artist might have been merged into another credited artist
+		key := credit{artist: artist.Artist.ID, role: artist.Role}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		group.Artists = append(group.Artists, artist)
+	}
+
+	return updateReleaseGroupTx(ctx, group, tx)
+}
+
+// revertReleaseTx restores a release.
+// Properties set after the revision are kept, as they can not be removed.
+func revertReleaseTx(ctx context.Context, id int, b json.RawMessage, tx *sql.Tx) error {
+	var s ReleaseSnapshot
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	current, err := releaseSnapshotTx(ctx, id, tx)
+	if err != nil {
+		return err
+	}
+
+	release := Release{
+		ID:          id,
+		ReleaseDate: s.ReleaseDate,
+		RecordLabel: RecordLabel{ID: s.RecordLabel},
+		Original:    s.Original,
+		Tags:        s.Tags,
+	}
+	if s.Edition != nil {
+		release.Edition = sql.NullString{String: *s.Edition, Valid: true}
+	}
+	if s.CatalogueNumber != nil {
+		release.CatalogueNumber = sql.NullString{String: *s.CatalogueNumber, Valid: true}
+	}
+	err = tx.QueryRowContext(ctx, "SELECT id FROM media WHERE medium = $1", s.Medium).Scan(&release.Medium)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("unknown medium %q", s.Medium)
+		}
+		return err
+	}
+
+	err = updateReleaseTx(ctx, release, tx)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range s.Properties {
+		if cv, ok := current.Properties[k]; ok && cv == v {
+			continue
+		}
+		err = setReleasePropertyTx(ctx, id, k, v, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func revertRecordLabelTx(ctx context.Context, id int, b json.RawMessage, tx *sql.Tx) error {
+	var s RecordLabelSnapshot
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	label := RecordLabel{ID: id, Name: s.Name}
+	if s.Description != nil {
+		label.Description = sql.NullString{String: *s.Description, Valid: true}
+	}
+	if s.Founded != nil {
+		label.Founded = pq.NullTime{Time: *s.Founded, Valid: true}
+	}
+	if s.Parent != nil {
+		label.Parent = &RecordLabel{ID: *s.Parent}
+	}
+
+	return updateRecordLabelTx(ctx, label, tx)
+}
+
+// RevertRevision restores an entity to the state of one of its revisions.
+// The revert is recorded as a new revision, in the same transaction.
+// It returns sql.ErrNoRows if the entity or the revision does not exist.
+func (db *DB) RevertRevision(ctx context.Context, entityType string, id, revision int, e Edit) error {
+	if !validRevisionEntityType(entityType) {
+		return errors.New("invalid entity type")
+	}
+
+	return db.edit(ctx, entityType, id, e, func(tx *sql.Tx) error {
+		var b []byte
+		err := tx.QueryRowContext(ctx, "SELECT snapshot FROM revisions WHERE entity_type = $1 AND entity = $2 AND revision = $3", entityType, id, revision).Scan(&b)
+		if err != nil {
+			return err
+		}
+
+		switch entityType {
+		case RevisionArtist:
+			return revertArtistTx(ctx, id, b, e, tx)
+		case RevisionReleaseGroup:
+			return revertReleaseGroupTx(ctx, id, b, tx)
+		case RevisionRelease:
+			return revertReleaseTx(ctx, id, b, tx)
+		}
+		return revertRecordLabelTx(ctx, id, b, tx)
+	})
+}
+
+// GetRevision returns a revision of an entity.
+func (db *DB) GetRevision(ctx context.Context, entityType string, id, revision int) (*Revision, error) {
+	if !validRevisionEntityType(entityType) {
+		return nil, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	var (
+		r              = Revision{EntityType: entityType, Entity: id, Revision: revision}
+		snapshot, diff []byte
+	)
+	err := db.db.QueryRowContext(ctx, "SELECT r.id,r.snapshot,r.diff,r.edited,r.edited_by,u.username FROM revisions r, users u WHERE r.edited_by = u.id AND r.entity_type = $1 AND r.entity = $2 AND r.revision = $3", entityType, id, revision).Scan(
+		&r.ID,
+		&snapshot,
+		&diff,
+		&r.Edited,
+		&r.EditedBy.ID,
+		&r.EditedBy.Username)
+	if err != nil {
+		return nil, err
+	}
+	r.Snapshot = snapshot
+	r.Diff = diff
+
+	return &r, nil
+}
+
+func (db *DB) queryRevisions(ctx context.Context, entityType string, id int, query string, args ...interface{}) ([]Revision, error) {
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		var (
+			r              = Revision{EntityType: entityType, Entity: id}
+			snapshot, diff []byte
+		)
+		err = rows.Scan(
+			&r.ID,
+			&r.Revision,
+			&snapshot,
+			&diff,
+			&r.Edited,
+			&r.EditedBy.ID,
+			&r.EditedBy.Username)
+		if err != nil {
+			return nil, err
+		}
+		r.Snapshot = snapshot
+		r.Diff = diff
+		revisions = append(revisions, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetRevisions returns the revisions of an entity, newest first.
+func (db *DB) GetRevisions(ctx context.Context, entityType string, id, offset, limit int) ([]Revision, error) {
+	if !validRevisionEntityType(entityType) {
+		return nil, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	return db.queryRevisions(ctx, entityType, id, "SELECT r.id,r.revision,r.snapshot,r.diff,r.edited,r.edited_by,u.username FROM revisions r, users u WHERE r.edited_by = u.id AND r.entity_type = $1 AND r.entity = $2 ORDER BY r.revision DESC LIMIT $3 OFFSET $4", entityType, id, limit, offset)
+}
+
+// GetRevisionsAfter returns up to limit revisions of an entity older than the
+// given revision, newest first.
+func (db *DB) GetRevisionsAfter(ctx context.Context, entityType string, id, revision, limit int) ([]Revision, error) {
+	if !validRevisionEntityType(entityType) {
+		return nil, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	return db.queryRevisions(ctx, entityType, id, "SELECT r.id,r.revision,r.snapshot,r.diff,r.edited,r.edited_by,u.username FROM revisions r, users u WHERE r.edited_by = u.id AND r.entity_type = $1 AND r.entity = $2 AND r.revision < $3 ORDER BY r.revision DESC LIMIT $4", entityType, id, revision, limit)
+}
+
+// GetRevisionsBefore returns up to limit revisions of an entity newer than the
+// given revision, newest first.
+// These are the revisions closest to the given one, not the newest ones.
+func (db *DB) GetRevisionsBefore(ctx context.Context, entityType string, id, revision, limit int) ([]Revision, error) {
+	if !validRevisionEntityType(entityType) {
+		return nil, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	revisions, err := db.queryRevisions(ctx, entityType, id, "SELECT r.id,r.revision,r.snapshot,r.diff,r.edited,r.edited_by,u.username FROM revisions r, users u WHERE r.edited_by = u.id AND r.entity_type = $1 AND r.entity = $2 AND r.revision > $3 ORDER BY r.revision ASC LIMIT $4", entityType, id, revision, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(revisions)-1; i < j; i, j = i+1, j-1 {
+		revisions[i], revisions[j] = revisions[j], revisions[i]
+	}
+
+	return revisions, nil
+}
+
+// CountRevisions returns the number of revisions of an entity.
+func (db *DB) CountRevisions(ctx context.Context, entityType string, id int) (int, error) {
+	if !validRevisionEntityType(entityType) {
+		return 0, errors.New("invalid entity type")
+	}
+	if id < 0 {
+		return 0, errors.New("invalid ID")
+	}
+
+	var count int
+	err := db.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM revisions WHERE entity_type = $1 AND entity = $2", entityType, id).Scan(&count)
+	return count, err
+}
@@ -1,7 +1,12 @@
 package db
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
+	"time"
 )
 
 type tok int
@@ -20,6 +25,10 @@ const (
 
 	and
 	or
+	not
+	in
+	ilike
+	isNull
 	where
 	orderBy
 	ascending
@@ -28,11 +37,12 @@ const (
 	table
 	column
 	placeholder
+	raw
 )
 
 func (t tok) isSpecial() bool {
 	switch t {
-	case table, column, placeholder:
+	case table, column, placeholder, raw:
 		return true
 	}
 	return false
@@ -64,6 +74,14 @@ func (t tok) string() string {
 		return "AND"
 	case or:
 		return "OR"
+	case not:
+		return "NOT"
+	case in:
+		return "IN"
+	case ilike:
+		return "ILIKE"
+	case isNull:
+		return "IS NULL"
 	case where:
 		return "WHERE"
 	case orderBy:
@@ -72,7 +90,7 @@ func (t tok) string() string {
 		return "ASC"
 	case descending:
 		return "DESC"
-	case placeholder, column, table:
+	case placeholder, column, table, raw:
 		return ""
 	default:
 		panic("unknown token")
@@ -119,6 +137,18 @@ func (t columnToken) Column() string {
 	return t.name
 }
 
+// rawToken is a fixed piece of SQL, written as is.
+// It must never contain user input.
+type rawToken struct {
+	sql string
+}
+
+func (t rawToken) Tok() tok { return raw }
+
+func (t rawToken) SQL() string {
+	return t.sql
+}
+
 type queryBuilder struct {
 	placeholderIndex int
 	tokens           []token
@@ -167,6 +197,13 @@ func (b *queryBuilder) Build() (string, []interface{}) {
 				break
 			}
 			panic("not a table-dot-column combo")
+		case raw:
+			r, ok := t.(rawToken)
+			if !ok {
+				panic("invalid raw token")
+			}
+
+			s += r.SQL() + " "
 		}
 	}
 
@@ -182,6 +219,8 @@ type Query struct {
 	sorter Sorter
 }
 
+// NewQuery returns a query with the condition b.
+// A nil condition matches everything.
 func NewQuery(b Boolean) *Query {
 	return &Query{b: b}
 }
@@ -191,8 +230,9 @@ func (q *Query) SetSorter(s Sorter) {
 }
 
 func (q *Query) Build() (string, []interface{}) {
-	b := queryBuilder{
-		tokens: q.b.tokens(),
+	var b queryBuilder
+	if q.b != nil {
+		b.tokens = q.b.tokens()
 	}
 	if q.sorter != nil {
 		b.tokens = append(b.tokens, q.sorter.tokens()...)
@@ -201,6 +241,186 @@ func (q *Query) Build() (string, []interface{}) {
 	return b.Build()
 }
 
+// buildCondition builds only the condition of the query, or an empty string
+// if there is none.
+func (q *Query) buildCondition() (string, []interface{}) {
+	if q.b == nil {
+		return "", nil
+	}
+	b := queryBuilder{
+		tokens: q.b.tokens(),
+	}
+	return b.Build()
+}
+
+// buildOrder builds only the ORDER BY clause of the query, or an empty string
+// if there is no sorter.
+func (q *Query) buildOrder() string {
+	if q.sorter == nil {
+		return ""
+	}
+	b := queryBuilder{
+		tokens: q.sorter.tokens(),
+	}
+	s, _ := b.Build()
+	return s
+}
+
+// Row provides the values of a row's columns to evaluate a Query in memory.
+// NULL values are returned as nil.
+// Columns reached through subqueries, like the tags of a release group, are
+// returned as a []Row.
+// It returns an error if the row does not have the column.
+type Row func(column string) (interface{}, error)
+
+// Match reports whether the row satisfies the condition of the query.
+// Like in SQL, a condition that evaluates to NULL does not match.
+func (q *Query) Match(r Row) (bool, error) {
+	if q.b == nil {
+		return true, nil
+	}
+	t, err := q.b.match(r)
+	if err != nil {
+		return false, err
+	}
+	return t == truthTrue, nil
+}
+
+// Less reports whether row a is sorted before row b.
+// If the query has no sorter, no row is sorted before another.
+func (q *Query) Less(a, b Row) (bool, error) {
+	if q.sorter == nil {
+		return false, nil
+	}
+	c, err := q.sorter.compare(a, b)
+	if err != nil {
+		return false, err
+	}
+	return c < 0, nil
+}
+
+// truth is the result of evaluating a condition in SQL's three-valued logic.
+type truth int
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// compareValues compares two column values the way postgres would compare
+// them. Integers of any size, floats, strings, bools and time.Time are
+// supported.
+// Times are compared by their wall clock, like TIMESTAMP columns are.
+func compareValues(a, b interface{}) (int, error) {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		if !ok {
+			return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+		}
+		ta, tb = wallClock(ta), wallClock(tb)
+		switch {
+		case ta.Before(tb):
+			return -1, nil
+		case ta.After(tb):
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return 0, errors.New("cannot compare NULL values")
+	}
+
+	switch {
+	case isNumber(va.Kind()) && isNumber(vb.Kind()):
+		fa, fb := toFloat(va), toFloat(vb)
+		switch {
+		case fa < fb:
+			return -1, nil
+		case fa > fb:
+			return 1, nil
+		}
+		return 0, nil
+	case va.Kind() == reflect.String && vb.Kind() == reflect.String:
+		switch {
+		case va.String() < vb.String():
+			return -1, nil
+		case va.String() > vb.String():
+			return 1, nil
+		}
+		return 0, nil
+	case va.Kind() == reflect.Bool && vb.Kind() == reflect.Bool:
+		switch {
+		case va.Bool() == vb.Bool():
+			return 0, nil
+		case vb.Bool():
+			return -1, nil
+		}
+		return 1, nil
+	}
+
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+// likePattern compiles an ILIKE pattern to a regular expression.
+func likePattern(pattern string) *regexp.Regexp {
+	var b bytes.Buffer
+	b.WriteString("(?is)^")
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
+}
+
 type tokenizer interface {
 	tokens() []token
 }
@@ -209,13 +429,57 @@ type ColumnSelector interface {
 	column() string
 }
 
+// tableSelector is implemented by column selectors that qualify the column
+// with a table (alias), to keep it unambiguous in subqueries.
+type tableSelector interface {
+	table() string
+}
+
+type simpleColumnSelector struct {
+	tab string
+	col string
+}
+
+func (s simpleColumnSelector) table() string {
+	return s.tab
+}
+
+func (s simpleColumnSelector) column() string {
+	return s.col
+}
+
+func columnTokens(c ColumnSelector) []token {
+	if t, ok := c.(tableSelector); ok && t.table() != "" {
+		return []token{
+			tableToken{
+				name: t.table(),
+			},
+			simpleToken{
+				tok: dot,
+			},
+			columnToken{
+				name: c.column(),
+			},
+		}
+	}
+
+	return []token{
+		columnToken{
+			name: c.column(),
+		},
+	}
+}
+
 type Sorter interface {
 	tokenizer
+	orderTokens() []token // the sort keys, without ORDER BY
+	compare(a, b Row) (int, error)
 	s()
 }
 
 type Boolean interface {
 	tokenizer
+	match(r Row) (truth, error)
 	b()
 }
 
@@ -225,31 +489,34 @@ type simpleSorter struct {
 }
 
 func (s simpleSorter) tokens() []token {
+	return append([]token{simpleToken{tok: orderBy}}, s.orderTokens()...)
+}
+func (s simpleSorter) orderTokens() []token {
+	t := columnTokens(s.column)
 	if s.desc {
-		return []token{
-			simpleToken{
-				tok: orderBy,
-			},
-			columnToken{
-				name: s.column.column(),
-			},
-			simpleToken{
-				tok: descending,
-			},
-		}
+		return append(t, simpleToken{tok: descending})
+	}
+	return append(t, simpleToken{tok: ascending})
+}
+func (s simpleSorter) compare(a, b Row) (int, error) {
+	va, err := a(s.column.column())
+	if err != nil {
+		return 0, err
+	}
+	vb, err := b(s.column.column())
+	if err != nil {
+		return 0, err
 	}
 
-	return []token{
-		simpleToken{
-			tok: orderBy,
-		},
-		columnToken{
-			name: s.column.column(),
-		},
-		simpleToken{
-			tok: ascending,
-		},
+	c, err := compareValues(va, vb)
+	if err != nil {
+		return 0, err
 	}
+
+	if s.desc {
+		return -c, nil
+	}
+	return c, nil
 }
 func (s simpleSorter) s() {}
 
@@ -266,6 +533,41 @@ func SortDescending(column ColumnSelector) Sorter {
 	}
 }
 
+type multiSorter struct {
+	sorters []Sorter
+}
+
+func (s multiSorter) tokens() []token {
+	return append([]token{simpleToken{tok: orderBy}}, s.orderTokens()...)
+}
+func (s multiSorter) orderTokens() []token {
+	var t []token
+	for i, sorter := range s.sorters {
+		if i > 0 {
+			t = append(t, simpleToken{tok: comma})
+		}
+		t = append(t, sorter.orderTokens()...)
+	}
+	return t
+}
+func (s multiSorter) compare(a, b Row) (int, error) {
+	for _, sorter := range s.sorters {
+		c, err := sorter.compare(a, b)
+		if err != nil || c != 0 {
+			return c, err
+		}
+	}
+	return 0, nil
+}
+func (s multiSorter) s() {}
+
+// SortBy sorts by the first sorter, then by the second one and so on.
+func SortBy(first Sorter, more ...Sorter) Sorter {
+	return multiSorter{
+		sorters: append([]Sorter{first}, more...),
+	}
+}
+
 type booleanComparator struct {
 	column ColumnSelector
 	val    interface{}
@@ -273,17 +575,55 @@ type booleanComparator struct {
 }
 
 func (b booleanComparator) tokens() []token {
-	return []token{
-		columnToken{
-			name: b.column.column(),
-		},
+	return append(columnTokens(b.column),
 		simpleToken{
 			tok: b.op,
 		},
 		placeholderToken{
 			value: b.val,
-		},
+		})
+}
+func (b booleanComparator) match(r Row) (truth, error) {
+	v, err := r(b.column.column())
+	if err != nil {
+		return truthFalse, err
+	}
+	if v == nil || b.val == nil {
+		return truthUnknown, nil
 	}
+
+	if b.op == ilike {
+		s, ok := v.(string)
+		if !ok {
+			return truthFalse, fmt.Errorf("cannot match %T with ILIKE", v)
+		}
+		pattern, ok := b.val.(string)
+		if !ok {
+			return truthFalse, fmt.Errorf("invalid ILIKE pattern %T", b.val)
+		}
+		return truthOf(likePattern(pattern).MatchString(s)), nil
+	}
+
+	c, err := compareValues(v, b.val)
+	if err != nil {
+		return truthFalse, err
+	}
+
+	switch b.op {
+	case eq:
+		return truthOf(c == 0), nil
+	case neq:
+		return truthOf(c != 0), nil
+	case lt:
+		return truthOf(c < 0), nil
+	case lte:
+		return truthOf(c <= 0), nil
+	case gt:
+		return truthOf(c > 0), nil
+	case gte:
+		return truthOf(c >= 0), nil
+	}
+	return truthFalse, fmt.Errorf("unknown operator %q", b.op.string())
 }
 func (b booleanComparator) b() {}
 
@@ -303,6 +643,225 @@ func Neq(column ColumnSelector, v interface{}) Boolean {
 	}
 }
 
+func Lt(column ColumnSelector, v interface{}) Boolean {
+	return booleanComparator{
+		column: column,
+		val:    v,
+		op:     lt,
+	}
+}
+
+func Lte(column ColumnSelector, v interface{}) Boolean {
+	return booleanComparator{
+		column: column,
+		val:    v,
+		op:     lte,
+	}
+}
+
+func Gt(column ColumnSelector, v interface{}) Boolean {
+	return booleanComparator{
+		column: column,
+		val:    v,
+		op:     gt,
+	}
+}
+
+func Gte(column ColumnSelector, v interface{}) Boolean {
+	return booleanComparator{
+		column: column,
+		val:    v,
+		op:     gte,
+	}
+}
+
+// ILike matches the column case insensitively against pattern, where % matches
+// any sequence of characters and _ any single character.
+// Use EscapeLike to match user input literally.
+func ILike(column ColumnSelector, pattern string) Boolean {
+	return booleanComparator{
+		column: column,
+		val:    pattern,
+		op:     ilike,
+	}
+}
+
+// EscapeLike escapes the wildcards of ILike patterns in s.
+func EscapeLike(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '\\', '%', '_':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type booleanIn struct {
+	column ColumnSelector
+	vals   []interface{}
+}
+
+func (b booleanIn) tokens() []token {
+	if len(b.vals) == 0 {
+		// IN () is not valid SQL.
+		return []token{
+			rawToken{
+				sql: "FALSE",
+			},
+		}
+	}
+
+	t := append(columnTokens(b.column),
+		simpleToken{
+			tok: in,
+		},
+		simpleToken{
+			tok: leftParen,
+		})
+	for i, v := range b.vals {
+		if i > 0 {
+			t = append(t, simpleToken{tok: comma})
+		}
+		t = append(t, placeholderToken{value: v})
+	}
+	return append(t, simpleToken{tok: rightParen})
+}
+func (b booleanIn) match(r Row) (truth, error) {
+	if len(b.vals) == 0 {
+		return truthFalse, nil
+	}
+
+	v, err := r(b.column.column())
+	if err != nil {
+		return truthFalse, err
+	}
+	if v == nil {
+		return truthUnknown, nil
+	}
+
+	res := truthFalse
+	for _, val := range b.vals {
+		if val == nil {
+			res = truthUnknown
+			continue
+		}
+		c, err := compareValues(v, val)
+		if err != nil {
+			return truthFalse, err
+		}
+		if c == 0 {
+			return truthTrue, nil
+		}
+	}
+	return res, nil
+}
+func (b booleanIn) b() {}
+
+// In matches if the column equals any of the values.
+// Without values, nothing matches.
+func In(column ColumnSelector, vs ...interface{}) Boolean {
+	return booleanIn{
+		column: column,
+		vals:   vs,
+	}
+}
+
+type booleanIsNull struct {
+	column ColumnSelector
+}
+
+func (b booleanIsNull) tokens() []token {
+	return append(columnTokens(b.column), simpleToken{tok: isNull})
+}
+func (b booleanIsNull) match(r Row) (truth, error) {
+	v, err := r(b.column.column())
+	if err != nil {
+		return truthFalse, err
+	}
+	return truthOf(v == nil), nil
+}
+func (b booleanIsNull) b() {}
+
+func IsNull(column ColumnSelector) Boolean {
+	return booleanIsNull{
+		column: column,
+	}
+}
+
+type booleanNot struct {
+	b1 Boolean
+}
+
+func (b booleanNot) tokens() []token {
+	t := []token{simpleToken{tok: not}, simpleToken{tok: leftParen}}
+	t = append(t, b.b1.tokens()...)
+	return append(t, simpleToken{tok: rightParen})
+}
+func (b booleanNot) match(r Row) (truth, error) {
+	t, err := b.b1.match(r)
+	if err != nil {
+		return truthFalse, err
+	}
+
+	switch t {
+	case truthTrue:
+		return truthFalse, nil
+	case truthFalse:
+		return truthTrue, nil
+	}
+	return truthUnknown, nil
+}
+func (b booleanNot) b() {}
+
+func Not(b Boolean) Boolean {
+	return booleanNot{
+		b1: b,
+	}
+}
+
+// booleanExists matches if any row of a related table satisfies a condition,
+// using a correlated EXISTS subquery.
+// prefix is the beginning of the subquery, up to and including the AND
+// before the condition. It must never contain user input.
+// In memory, the column provides the related rows as a []Row.
+type booleanExists struct {
+	column string
+	prefix string
+	cond   Boolean
+}
+
+func (b booleanExists) tokens() []token {
+	t := []token{rawToken{sql: b.prefix}}
+	t = append(t, b.cond.tokens()...)
+	return append(t, simpleToken{tok: rightParen})
+}
+func (b booleanExists) match(r Row) (truth, error) {
+	v, err := r(b.column)
+	if err != nil {
+		return truthFalse, err
+	}
+
+	rows, ok := v.([]Row)
+	if !ok {
+		return truthFalse, fmt.Errorf("column %q is not a subquery", b.column)
+	}
+
+	for _, row := range rows {
+		t, err := b.cond.match(row)
+		if err != nil {
+			return truthFalse, err
+		}
+		if t == truthTrue {
+			return truthTrue, nil
+		}
+	}
+	return truthFalse, nil
+}
+func (b booleanExists) b() {}
+
 type booleanBinary struct {
 	b1, b2      Boolean
 	conjunction tok
@@ -317,6 +876,31 @@ func (b booleanBinary) tokens() []token {
 
 	return t
 }
+func (b booleanBinary) match(r Row) (truth, error) {
+	t1, err := b.b1.match(r)
+	if err != nil {
+		return truthFalse, err
+	}
+	t2, err := b.b2.match(r)
+	if err != nil {
+		return truthFalse, err
+	}
+
+	// The value that decides the result on its own: FALSE for AND, TRUE
+	// for OR.
+	decisive := truthFalse
+	if b.conjunction == or {
+		decisive = truthTrue
+	}
+
+	switch {
+	case t1 == decisive || t2 == decisive:
+		return decisive, nil
+	case t1 == truthUnknown || t2 == truthUnknown:
+		return truthUnknown, nil
+	}
+	return t1, nil
+}
 func (b booleanBinary) b() {}
 
 func And(b1, b2 Boolean) Boolean {
@@ -334,3 +918,24 @@ func Or(b1, b2 Boolean) Boolean {
 		conjunction: or,
 	}
 }
+
+// After matches the rows that follow the row with the given key and ID in a
+// list sorted by column, descending if desc, and then by idColumn ascending.
+// It is used for keyset pagination, the column must not be NULL.
+func After(column ColumnSelector, desc bool, key interface{}, idColumn ColumnSelector, id int) Boolean {
+	beyond := Gt(column, key)
+	if desc {
+		beyond = Lt(column, key)
+	}
+	return Or(beyond, And(Eq(column, key), Gt(idColumn, id)))
+}
+
+// Before matches the rows that precede the row with the given key and ID in a
+// list sorted like for After.
+func Before(column ColumnSelector, desc bool, key interface{}, idColumn ColumnSelector, id int) Boolean {
+	beyond := Lt(column, key)
+	if desc {
+		beyond = Gt(column, key)
+	}
+	return Or(beyond, And(Eq(column, key), Lt(idColumn, id)))
+}
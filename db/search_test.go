@@ -1,6 +1,7 @@
 package db
 
 import (
+	"fmt"
 	"testing"
 
 	"time"
@@ -89,3 +90,279 @@ func TestQueryWithSorting(t *testing.T) {
 	require.Equal(t, []interface{}{p1, p2}, params)
 	require.Equal(t, "( \"a\" = $1 OR \"a\" = $2 ) ORDER BY \"c\" DESC", query)
 }
+
+func testRow(values map[string]interface{}) Row {
+	return func(column string) (interface{}, error) {
+		v, ok := values[column]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+		return v, nil
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	now := time.Now()
+	q := NewQuery(
+		And(
+			Eq(columnA{}, "test"),
+			Or(
+				Eq(columnB{}, 3),
+				Neq(columnC{}, now),
+			),
+		),
+	)
+
+	match, err := q.Match(testRow(map[string]interface{}{"a": "test", "b": int64(3), "c": now}))
+	require.Nil(t, err)
+	require.True(t, match)
+
+	match, err = q.Match(testRow(map[string]interface{}{"a": "test", "b": 4, "c": now.Add(time.Second)}))
+	require.Nil(t, err)
+	require.True(t, match)
+
+	match, err = q.Match(testRow(map[string]interface{}{"a": "test", "b": 4, "c": now}))
+	require.Nil(t, err)
+	require.False(t, match)
+
+	match, err = q.Match(testRow(map[string]interface{}{"a": "other", "b": 3, "c": now}))
+	require.Nil(t, err)
+	require.False(t, match)
+
+	_, err = q.Match(testRow(map[string]interface{}{"a": 1, "b": 3, "c": now}))
+	require.NotNil(t, err)
+
+	_, err = q.Match(testRow(map[string]interface{}{"b": 3, "c": now}))
+	require.NotNil(t, err)
+}
+
+func TestQueryLess(t *testing.T) {
+	q := NewQuery(Eq(columnA{}, 1))
+	r1 := testRow(map[string]interface{}{"a": 1, "b": 1})
+	r2 := testRow(map[string]interface{}{"a": 1, "b": 2})
+
+	less, err := q.Less(r1, r2)
+	require.Nil(t, err)
+	require.False(t, less)
+
+	q.SetSorter(SortAscending(columnB{}))
+	less, err = q.Less(r1, r2)
+	require.Nil(t, err)
+	require.True(t, less)
+	less, err = q.Less(r2, r1)
+	require.Nil(t, err)
+	require.False(t, less)
+
+	q.SetSorter(SortDescending(columnB{}))
+	less, err = q.Less(r1, r2)
+	require.Nil(t, err)
+	require.False(t, less)
+	less, err = q.Less(r2, r1)
+	require.Nil(t, err)
+	require.True(t, less)
+}
+
+func TestQueryRange(t *testing.T) {
+	q := NewQuery(And(Gte(columnA{}, 1), Lt(columnA{}, 3)))
+
+	query, params := q.Build()
+	require.Equal(t, []interface{}{1, 3}, params)
+	require.Equal(t, "( \"a\" >= $1 AND \"a\" < $2 )", query)
+
+	for v, expected := range map[int]bool{0: false, 1: true, 2: true, 3: false} {
+		match, err := q.Match(testRow(map[string]interface{}{"a": v}))
+		require.Nil(t, err)
+		require.Equal(t, expected, match, "a = %d", v)
+	}
+}
+
+func TestQueryEmpty(t *testing.T) {
+	q := NewQuery(nil)
+
+	query, params := q.Build()
+	require.Equal(t, 0, len(params))
+	require.Equal(t, "", query)
+
+	match, err := q.Match(testRow(nil))
+	require.Nil(t, err)
+	require.True(t, match)
+
+	q.SetSorter(SortDescending(columnA{}))
+	query, _ = q.Build()
+	require.Equal(t, "ORDER BY \"a\" DESC", query)
+
+	cond, params := q.buildCondition()
+	require.Equal(t, "", cond)
+	require.Equal(t, 0, len(params))
+	require.Equal(t, "ORDER BY \"a\" DESC", q.buildOrder())
+}
+
+func TestQueryExists(t *testing.T) {
+	q := NewQuery(And(
+		Eq(columnA{}, 1),
+		booleanExists{
+			column: "tags",
+			prefix: "EXISTS (SELECT 1 FROM tags WHERE tags.x = x AND",
+			cond:   Eq(columnB{}, "techno"),
+		},
+	))
+
+	query, params := q.Build()
+	require.Equal(t, []interface{}{1, "techno"}, params)
+	require.Equal(t, "( \"a\" = $1 AND EXISTS (SELECT 1 FROM tags WHERE tags.x = x AND \"b\" = $2 ) )", query)
+
+	tags := func(tags ...string) []Row {
+		var rows []Row
+		for _, tag := range tags {
+			rows = append(rows, testRow(map[string]interface{}{"b": tag}))
+		}
+		return rows
+	}
+
+	match, err := q.Match(testRow(map[string]interface{}{"a": 1, "tags": tags("edm", "techno")}))
+	require.Nil(t, err)
+	require.True(t, match)
+
+	match, err = q.Match(testRow(map[string]interface{}{"a": 1, "tags": tags()}))
+	require.Nil(t, err)
+	require.False(t, match)
+
+	_, err = q.Match(testRow(map[string]interface{}{"a": 1, "tags": "techno"}))
+	require.NotNil(t, err)
+}
+
+type qualifiedColumn struct{}
+
+func (qualifiedColumn) table() string {
+	return "t"
+}
+
+func (qualifiedColumn) column() string {
+	return "a"
+}
+
+func TestQueryOperators(t *testing.T) {
+	q := NewQuery(And(
+		Not(IsNull(qualifiedColumn{})),
+		Or(
+			In(columnB{}, 1, 2, 3),
+			ILike(columnC{}, "%tech\\_no%"),
+		),
+	))
+
+	query, params := q.Build()
+	require.Equal(t, []interface{}{1, 2, 3, "%tech\\_no%"}, params)
+	require.Equal(t, "( NOT ( \"t\".\"a\" IS NULL ) AND ( \"b\" IN ( $1 , $2 , $3 ) OR \"c\" ILIKE $4 ) )", query)
+
+	for _, c := range []struct {
+		a, b, c  interface{}
+		expected bool
+	}{
+		{"x", 2, "", true},
+		{"x", 4, "Some TECH_NO edit", true},
+		{"x", 4, "technology", false},
+		{nil, 2, "", false},
+		{"x", nil, "techno", false},
+		{"x", nil, nil, false},
+	} {
+		match, err := q.Match(testRow(map[string]interface{}{"a": c.a, "b": c.b, "c": c.c}))
+		require.Nil(t, err)
+		require.Equal(t, c.expected, match, "%v", c)
+	}
+
+	query, params = NewQuery(In(columnA{})).Build()
+	require.Equal(t, 0, len(params))
+	require.Equal(t, "FALSE", query)
+
+	match, err := NewQuery(In(columnA{})).Match(testRow(map[string]interface{}{"a": 1}))
+	require.Nil(t, err)
+	require.False(t, match)
+
+	require.Equal(t, "100\\% \\_\\\\", EscapeLike("100% _\\"))
+}
+
+func TestQueryNull(t *testing.T) {
+	row := testRow(map[string]interface{}{"a": nil, "b": 1})
+
+	for _, c := range []struct {
+		b        Boolean
+		expected bool
+	}{
+		// NULL = 1 is NULL, and so is its negation
+		{Eq(columnA{}, 1), false},
+		{Not(Eq(columnA{}, 1)), false},
+		{Neq(columnA{}, 1), false},
+		{IsNull(columnA{}), true},
+		{Not(IsNull(columnA{})), false},
+		// NULL AND FALSE is FALSE, NULL OR TRUE is TRUE
+		{Not(And(Eq(columnA{}, 1), Eq(columnB{}, 2))), true},
+		{Or(Eq(columnA{}, 1), Eq(columnB{}, 1)), true},
+		{Not(Or(Eq(columnA{}, 1), Eq(columnB{}, 2))), false},
+		{In(columnB{}, nil, 1), true},
+		{Not(In(columnB{}, nil, 2)), false},
+	} {
+		match, err := NewQuery(c.b).Match(row)
+		require.Nil(t, err)
+		require.Equal(t, c.expected, match, "%v", c.b)
+	}
+}
+
+func TestQueryMultiSort(t *testing.T) {
+	q := NewQuery(nil)
+	q.SetSorter(SortBy(SortAscending(columnA{}), SortDescending(qualifiedColumn{}), SortAscending(columnB{})))
+
+	query, _ := q.Build()
+	require.Equal(t, "ORDER BY \"a\" ASC , \"t\".\"a\" DESC , \"b\" ASC", query)
+
+	r1 := testRow(map[string]interface{}{"a": 1, "b": 2})
+	r2 := testRow(map[string]interface{}{"a": 1, "b": 3})
+	r3 := testRow(map[string]interface{}{"a": 0, "b": 4})
+
+	less, err := q.Less(r1, r2)
+	require.Nil(t, err)
+	require.True(t, less)
+
+	less, err = q.Less(r2, r1)
+	require.Nil(t, err)
+	require.False(t, less)
+
+	less, err = q.Less(r3, r1)
+	require.Nil(t, err)
+	require.True(t, less)
+
+	less, err = q.Less(r1, r1)
+	require.Nil(t, err)
+	require.False(t, less)
+}
+
+func TestQuerySeek(t *testing.T) {
+	q := NewQuery(After(columnA{}, true, 2, columnB{}, 5))
+	query, params := q.Build()
+	require.Equal(t, "( \"a\" < $1 OR ( \"a\" = $2 AND \"b\" > $3 ) )", query)
+	require.Equal(t, []interface{}{2, 2, 5}, params)
+
+	rows := []Row{
+		testRow(map[string]interface{}{"a": 3, "b": 1}),
+		testRow(map[string]interface{}{"a": 2, "b": 4}),
+		testRow(map[string]interface{}{"a": 2, "b": 5}),
+		testRow(map[string]interface{}{"a": 2, "b": 6}),
+		testRow(map[string]interface{}{"a": 1, "b": 2}),
+	}
+
+	for _, c := range []struct {
+		b        Boolean
+		expected []bool
+	}{
+		{After(columnA{}, true, 2, columnB{}, 5), []bool{false, false, false, true, true}},
+		{Before(columnA{}, true, 2, columnB{}, 5), []bool{true, true, false, false, false}},
+		{After(columnA{}, false, 2, columnB{}, 5), []bool{true, false, false, true, false}},
+		{Before(columnA{}, false, 2, columnB{}, 5), []bool{false, true, false, false, true}},
+	} {
+		q := NewQuery(c.b)
+		for i, r := range rows {
+			match, err := q.Match(r)
+			require.Nil(t, err)
+			require.Equal(t, c.expected[i], match, "%v row %d", c.b, i)
+		}
+	}
+}
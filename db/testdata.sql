@@ -0,0 +1,6 @@
+-- testdata.sql contains fixtures for tests and the test instance.
+-- It is applied on top of a freshly migrated schema.
+-- The initial schema creates the test user with the privileges of that time,
+-- it gets all of them through its class instead.
+-- The password of the test user is test.
+DELETE FROM users_privileges WHERE uid = 1;
@@ -1,35 +1,328 @@
 package db
 
-import "time"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
 
 type Torrent struct {
 	ID         int
+	Release    Release
 	Uploaded   time.Time
 	UploadedBy User
 	InfoHash   [20]byte
 
+	// Info is the bencoded info dictionary of the torrent.
+	// It is not populated by GetTorrent or PopulateTorrents, use
+	// GetTorrentInfo instead.
+	Info []byte
+
 	Format      int
 	Size        int64
-	Description string
+	Description sql.NullString
 
-	Leechers int
-	Seeders  int
-	Snatches int
+	LeechType       int
+	Leechers        int
+	Seeders         int
+	Snatches        int
+	TotalUploaded   int64
+	TotalDownloaded int64
 
-	FileList []string
+	FileList []TorrentFile
+}
 
-	Properties map[string]string
-	LeechType  string
+type TorrentFile struct {
+	Path string
+	Size int64
 }
 
-func (db *DB) InsertTorrent(torrent *Torrent) error {
+func insertTorrentFilesTx(ctx context.Context, torrent Torrent, tx *sql.Tx) error {
+	for i, f := range torrent.FileList {
+		res, err := tx.ExecContext(ctx, "INSERT INTO torrent_files(torrent,idx,path,size) VALUES ($1,$2,$3,$4)", torrent.ID, i, f.Path, f.Size)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected != 1 {
+			return errors.New("did not insert")
+		}
+	}
+
 	return nil
 }
 
-func (db *DB) GetTorrent(id int) (*Torrent, error) {
-	return &Torrent{}, nil
+func insertTorrentTx(ctx context.Context, torrent *Torrent, tx *sql.Tx) error {
+	var desc *string
+	if torrent.Description.String != "" {
+		desc = &torrent.Description.String
+	}
+
+	err := tx.QueryRowContext(ctx, "INSERT INTO torrents(release,uploaded,uploader,info_hash,info,format,size,description) VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id",
+		torrent.Release.ID,
+		torrent.Uploaded,
+		torrent.UploadedBy.ID,
+		torrent.InfoHash[:],
+		torrent.Info,
+		torrent.Format,
+		torrent.Size,
+		desc).Scan(&torrent.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO torrent_trackerdata(torrent,leech_type) VALUES ($1,$2)", torrent.ID, torrent.LeechType)
+	if err != nil {
+		return err
+	}
+
+	return insertTorrentFilesTx(ctx, *torrent, tx)
+}
+
+func (db *DB) InsertTorrent(ctx context.Context, torrent *Torrent) error {
+	if torrent == nil {
+		return errors.New("missing torrent")
+	}
+	if torrent.Release.ID < 0 {
+		return errors.New("invalid release ID")
+	}
+	if torrent.UploadedBy.ID < 0 {
+		return errors.New("invalid user ID")
+	}
+	if len(torrent.Info) == 0 {
+		return errors.New("missing info")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = insertTorrentTx(ctx, torrent, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) populateTorrentFiles(ctx context.Context, t *Torrent) error {
+	if t.ID < 0 {
+		return errors.New("invalid ID")
+	}
+
+	rows, err := db.db.QueryContext(ctx, "SELECT path,size FROM torrent_files WHERE torrent = $1 ORDER BY idx ASC", t.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tmp TorrentFile
+		err = rows.Scan(
+			&tmp.Path,
+			&tmp.Size)
+		if err != nil {
+			return err
+		}
+
+		t.FileList = append(t.FileList, tmp)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// scanTorrent scans a row produced by one of the torrent queries into t.
+// The row must contain the columns t.id, t.release, t.uploaded, u.id,
+// u.username, t.info_hash, t.format, t.size, t.description, td.leech_type,
+// td.seeders, td.leechers, td.snatches, td.total_uploaded and
+// td.total_downloaded, in that order.
+func scanTorrent(row interface {
+	Scan(...interface{}) error
+}, t *Torrent) error {
+	var infoHash []byte
+	err := row.Scan(
+		&t.ID,
+		&t.Release.ID,
+		&t.Uploaded,
+		&t.UploadedBy.ID,
+		&t.UploadedBy.Username,
+		&infoHash,
+		&t.Format,
+		&t.Size,
+		&t.Description,
+		&t.LeechType,
+		&t.Seeders,
+		&t.Leechers,
+		&t.Snatches,
+		&t.TotalUploaded,
+		&t.TotalDownloaded)
+	if err != nil {
+		return err
+	}
+
+	if len(infoHash) != len(t.InfoHash) {
+		return errors.New("invalid info hash length")
+	}
+	copy(t.InfoHash[:], infoHash)
+
+	return nil
+}
+
+const selectTorrent = "SELECT t.id,t.release,t.uploaded,u.id,u.username,t.info_hash,t.format,t.size,t.description,td.leech_type,td.seeders,td.leechers,td.snatches,td.total_uploaded,td.total_downloaded FROM torrents t, torrent_trackerdata td, users u WHERE t.id = td.torrent AND t.uploader = u.id"
+
+func (db *DB) GetTorrent(ctx context.Context, id int) (*Torrent, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	var t Torrent
+	err := scanTorrent(db.db.QueryRowContext(ctx, selectTorrent+" AND t.id = $1", id), &t)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.populateTorrentFiles(ctx, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// GetTorrentByInfoHash returns the torrent with the given info hash.
+// The file list is not populated.
+func (db *DB) GetTorrentByInfoHash(ctx context.Context, infoHash [20]byte) (*Torrent, error) {
+	var t Torrent
+	err := scanTorrent(db.db.QueryRowContext(ctx, selectTorrent+" AND t.info_hash = $1", infoHash[:]), &t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
 }
 
-func (db *DB) DeleteTorrent(id int) error {
+// GetTorrentInfo returns the bencoded info dictionary of the torrent with the
+// given ID.
+func (db *DB) GetTorrentInfo(ctx context.Context, id int) ([]byte, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	var info []byte
+	err := db.db.QueryRowContext(ctx, "SELECT info FROM torrents WHERE id = $1", id).Scan(&info)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (db *DB) PopulateTorrents(ctx context.Context, release *Release) error {
+	if release.ID < 0 {
+		return errors.New("invalid ID")
+	}
+
+	rows, err := db.db.QueryContext(ctx, selectTorrent+" AND t.release = $1 ORDER BY t.id ASC", release.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var torrents []Torrent
+	for rows.Next() {
+		var tmp Torrent
+		err = scanTorrent(rows, &tmp)
+		if err != nil {
+			return err
+		}
+
+		torrents = append(torrents, tmp)
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	for i := range torrents {
+		err = db.populateTorrentFiles(ctx, &torrents[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	release.Torrents = append(release.Torrents, torrents...)
 	return nil
 }
+
+func deleteTorrentTx(ctx context.Context, id int, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM torrent_files WHERE torrent = $1", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM torrent_trackerdata WHERE torrent = $1", id)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM torrents WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("torrent not found")
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteTorrent(ctx context.Context, id int) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = deleteTorrentTx(ctx, id, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func TorrentFormatSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "t",
+		col: "format",
+	}
+}
+
+func TorrentLeechTypeSelector() ColumnSelector {
+	return simpleColumnSelector{
+		tab: "td",
+		col: "leech_type",
+	}
+}
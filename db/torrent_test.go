@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func insertTestRelease(t *testing.T, db BoilingDB) Release {
+	ctx := context.Background()
+
+	l := RecordLabel{
+		Name:    "NONESUCH",
+		AddedBy: User{ID: 1},
+	}
+
+	err := db.InsertRecordLabel(ctx, &l)
+	require.Nil(t, err)
+
+	a := Artist{
+		Name:    "deadmau5",
+		Added:   time.Date(2001, 1, 1, 0, 0, 0, 0, time.FixedZone("", 0)),
+		AddedBy: User{ID: 1},
+	}
+
+	err = db.InsertArtist(ctx, &a)
+	require.Nil(t, err)
+
+	g := ReleaseGroup{
+		Name: "4x4=12",
+		Artists: []RoledArtist{
+			{
+				Role:   0,
+				Artist: Artist{ID: a.ID},
+			},
+		},
+		ReleaseDate: time.Date(2010, 12, 13, 13, 14, 15, 0, time.FixedZone("", 0)),
+		Added:       time.Date(2012, 2, 2, 2, 2, 2, 0, time.FixedZone("", 0)),
+		AddedBy:     User{ID: 1},
+		Type:        0,
+		Tags:        []string{"electronic", "canadian"},
+	}
+
+	err = db.InsertReleaseGroup(ctx, &g)
+	require.Nil(t, err)
+
+	r := Release{
+		ReleaseGroup: ReleaseGroup{ID: g.ID},
+		Medium:       0,
+		ReleaseDate:  time.Date(2012, 3, 2, 0, 0, 0, 0, time.FixedZone("", 0)),
+		RecordLabel:  RecordLabel{ID: l.ID},
+		Added:        time.Date(2012, 3, 3, 0, 0, 2, 0, time.FixedZone("", 0)),
+		AddedBy:      User{ID: 1},
+		Original:     true,
+	}
+
+	err = db.InsertRelease(ctx, &r)
+	require.Nil(t, err)
+
+	return r
+}
+
+func TestInsertGetDeleteTorrent(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := cleanDB()
+	require.Nil(t, err)
+
+	r := insertTestRelease(t, db)
+
+	tor := Torrent{
+		Release:     Release{ID: r.ID},
+		Uploaded:    time.Date(2012, 3, 4, 0, 0, 0, 0, time.FixedZone("", 0)),
+		UploadedBy:  User{ID: 1},
+		InfoHash:    [20]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20},
+		Info:        []byte("d4:name1:ae"),
+		Format:      0,
+		Size:        3000,
+		Description: sql.NullString{String: "Some rip log"},
+		LeechType:   1,
+		FileList: []TorrentFile{
+			{Path: "01 - Some Chords.flac", Size: 1000},
+			{Path: "02 - Sofi Needs a Ladder.flac", Size: 2000},
+		},
+	}
+
+	err = db.InsertTorrent(ctx, &tor)
+	require.Nil(t, err)
+
+	got, err := db.GetTorrent(ctx, tor.ID)
+	require.Nil(t, err)
+	require.Equal(t, r.ID, got.Release.ID)
+	require.Equal(t, tor.Uploaded, got.Uploaded)
+	require.Equal(t, tor.UploadedBy.ID, got.UploadedBy.ID)
+	require.Equal(t, tor.InfoHash, got.InfoHash)
+	require.Equal(t, tor.Format, got.Format)
+	require.Equal(t, tor.Size, got.Size)
+	require.True(t, got.Description.Valid)
+	require.Equal(t, tor.Description.String, got.Description.String)
+	require.Equal(t, tor.LeechType, got.LeechType)
+	require.Equal(t, tor.FileList, got.FileList)
+	require.Equal(t, 0, got.Seeders)
+	require.Equal(t, 0, got.Leechers)
+	require.Equal(t, 0, got.Snatches)
+	require.Nil(t, got.Info)
+
+	info, err := db.GetTorrentInfo(ctx, tor.ID)
+	require.Nil(t, err)
+	require.Equal(t, tor.Info, info)
+
+	got, err = db.GetTorrentByInfoHash(ctx, tor.InfoHash)
+	require.Nil(t, err)
+	require.Equal(t, tor.ID, got.ID)
+	require.Equal(t, tor.LeechType, got.LeechType)
+
+	_, err = db.GetTorrentByInfoHash(ctx, [20]byte{})
+	require.Equal(t, sql.ErrNoRows, err)
+
+	err = db.PopulateTorrents(ctx, &r)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(r.Torrents))
+	require.Equal(t, tor.ID, r.Torrents[0].ID)
+	require.Equal(t, tor.FileList, r.Torrents[0].FileList)
+
+	err = db.DeleteTorrent(ctx, tor.ID)
+	require.Nil(t, err)
+
+	_, err = db.GetTorrent(ctx, tor.ID)
+	require.Equal(t, sql.ErrNoRows, err)
+
+	err = db.DeleteTorrent(ctx, tor.ID)
+	require.NotNil(t, err)
+}
+
+func TestInsertTorrentDuplicateInfoHash(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := cleanDB()
+	require.Nil(t, err)
+
+	r := insertTestRelease(t, db)
+
+	tor := Torrent{
+		Release:    Release{ID: r.ID},
+		Uploaded:   time.Date(2012, 3, 4, 0, 0, 0, 0, time.FixedZone("", 0)),
+		UploadedBy: User{ID: 1},
+		InfoHash:   [20]byte{1, 2, 3},
+		Info:       []byte("d4:name1:ae"),
+		Size:       1000,
+		FileList:   []TorrentFile{{Path: "a.flac", Size: 1000}},
+	}
+
+	err = db.InsertTorrent(ctx, &tor)
+	require.Nil(t, err)
+
+	dup := tor
+	err = db.InsertTorrent(ctx, &dup)
+	require.NotNil(t, err)
+}
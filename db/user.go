@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
@@ -23,15 +24,26 @@ type User struct {
 	LastAccess   pq.NullTime
 	Uploaded     int64
 	Downloaded   int64
-	Privileges   []int
+	Class        int
+
+	// RatioWatchUntil is the deadline of the ratio watch of the user, it is
+	// not valid if the user is not on ratio watch.
+	RatioWatchUntil pq.NullTime
+
+	// Invites is the number of invites the user can still issue.
+	Invites int
+
+	// Privileges are the effective privileges of the user, see
+	// PopulateUserPrivileges.
+	Privileges []int
 }
 
-func (db *DB) UpdateUserSetLastLogin(id int, lastLogin time.Time) error {
+func (db *DB) UpdateUserSetLastLogin(ctx context.Context, id int, lastLogin time.Time) error {
 	if id < 0 {
 		return errors.New("invalid id")
 	}
 
-	res, err := db.db.Exec("UPDATE users SET last_login = $1, last_access=$1 WHERE id=$2", lastLogin, id)
+	res, err := db.db.ExecContext(ctx, "UPDATE users SET last_login = $1, last_access=$1 WHERE id=$2", lastLogin, id)
 	if err != nil {
 		return err
 	}
@@ -46,12 +58,12 @@ func (db *DB) UpdateUserSetLastLogin(id int, lastLogin time.Time) error {
 	return nil
 }
 
-func (db *DB) UpdateUserSetLastAccess(id int, lastAccess time.Time) error {
+func (db *DB) UpdateUserSetLastAccess(ctx context.Context, id int, lastAccess time.Time) error {
 	if id < 0 {
 		return errors.New("invalid id")
 	}
 
-	res, err := db.db.Exec("UPDATE users SET last_access=$1 WHERE id=$2", lastAccess, id)
+	res, err := db.db.ExecContext(ctx, "UPDATE users SET last_access=$1 WHERE id=$2", lastAccess, id)
 	if err != nil {
 		return err
 	}
@@ -66,9 +78,11 @@ func (db *DB) UpdateUserSetLastAccess(id int, lastAccess time.Time) error {
 	return nil
 }
 
-func updateUserAddPrivilegesTx(id int, privileges []int, tx *sql.Tx) error {
+// updateUserPrivilegesTx grants or denies privileges to a user, replacing
+// earlier grants or denials of the same privileges.
+func updateUserPrivilegesTx(ctx context.Context, id int, privileges []int, granted bool, tx *sql.Tx) error {
 	for _, p := range privileges {
-		res, err := tx.Exec("INSERT INTO users_privileges(uid,privilege) VALUES($1,$2) ON CONFLICT (uid,privilege) DO UPDATE SET privilege=$2", id, p)
+		res, err := tx.ExecContext(ctx, "INSERT INTO users_privileges(uid,privilege,granted) VALUES($1,$2,$3) ON CONFLICT (uid,privilege) DO UPDATE SET granted=$3", id, p, granted)
 		if err != nil {
 			return err
 		}
@@ -85,17 +99,29 @@ func updateUserAddPrivilegesTx(id int, privileges []int, tx *sql.Tx) error {
 	return nil
 }
 
-func (db *DB) UpdateUserAddPrivileges(id int, privileges []int) error {
+// UpdateUserAddPrivileges grants privileges to a user, in addition to the
+// privileges of their class.
+func (db *DB) UpdateUserAddPrivileges(ctx context.Context, id int, privileges []int) error {
+	return db.updateUserPrivileges(ctx, id, privileges, true)
+}
+
+// UpdateUserDenyPrivileges denies privileges to a user, even if their class
+// has them.
+func (db *DB) UpdateUserDenyPrivileges(ctx context.Context, id int, privileges []int) error {
+	return db.updateUserPrivileges(ctx, id, privileges, false)
+}
+
+func (db *DB) updateUserPrivileges(ctx context.Context, id int, privileges []int, granted bool) error {
 	if id < 0 {
 		return errors.New("invalid ID")
 	}
 
-	tx, err := db.db.Begin()
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	err = updateUserAddPrivilegesTx(id, privileges, tx)
+	err = updateUserPrivilegesTx(ctx, id, privileges, granted, tx)
 	if err != nil {
 		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
 		tx.Rollback()
@@ -105,12 +131,77 @@ func (db *DB) UpdateUserAddPrivileges(id int, privileges []int) error {
 	return tx.Commit()
 }
 
-func (db *DB) UpdateUserDeltaUpDown(id, deltaUp, deltaDown int) error {
+// UpdateUserRemovePrivileges removes the privileges granted or denied to a
+// user individually, the user falls back to the privileges of their class.
+func (db *DB) UpdateUserRemovePrivileges(ctx context.Context, id int, privileges []int) error {
+	if id < 0 {
+		return errors.New("invalid ID")
+	}
+
+	_, err := db.db.ExecContext(ctx, "DELETE FROM users_privileges WHERE uid = $1 AND privilege = ANY($2)", id, pq.Array(privileges))
+	return err
+}
+
+// GetUserPrivilegeGrants returns the privileges granted or denied to a user
+// individually, mapped to true for grants and false for denials.
+func (db *DB) GetUserPrivilegeGrants(ctx context.Context, id int) (map[int]bool, error) {
+	if id < 0 {
+		return nil, errors.New("invalid ID")
+	}
+
+	rows, err := db.db.QueryContext(ctx, "SELECT privilege,granted FROM users_privileges WHERE uid = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[int]bool)
+	for rows.Next() {
+		var (
+			p       int
+			granted bool
+		)
+		err = rows.Scan(&p, &granted)
+		if err != nil {
+			return nil, err
+		}
+		m[p] = granted
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (db *DB) UpdateUserDeltaUpDown(ctx context.Context, id, deltaUp, deltaDown int) error {
+	if id < 0 {
+		return errors.New("invalid id")
+	}
+
+	res, err := db.db.ExecContext(ctx, "UPDATE users SET uploaded = uploaded + $1, downloaded = downloaded + $2 WHERE id = $3", deltaUp, deltaDown, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// UpdateUserSetRatioWatch puts a user on ratio watch until the given
+// deadline, or takes them off ratio watch if until is not valid.
+func (db *DB) UpdateUserSetRatioWatch(ctx context.Context, id int, until pq.NullTime) error {
 	if id < 0 {
 		return errors.New("invalid id")
 	}
 
-	res, err := db.db.Exec("UPDATE users SET uploaded = uploaded + $1, downloaded = downloaded + $2 WHERE id = $3", deltaUp, deltaDown, id)
+	res, err := db.db.ExecContext(ctx, "UPDATE users SET ratio_watch_until = $1 WHERE id = $2", until, id)
 	if err != nil {
 		return err
 	}
@@ -125,21 +216,63 @@ func (db *DB) UpdateUserDeltaUpDown(id, deltaUp, deltaDown int) error {
 	return nil
 }
 
-func (db *DB) SignUpUser(username, password, email string) error {
+// UserRatioStats are the numbers the ratio watch evaluates users by.
+type UserRatioStats struct {
+	User            int
+	Uploaded        int64
+	Downloaded      int64
+	RatioWatchUntil pq.NullTime
+}
+
+// GetUserRatioStats returns the ratio stats of all enabled users, ordered by
+// their ID.
+func (db *DB) GetUserRatioStats(ctx context.Context) ([]UserRatioStats, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,uploaded,downloaded,ratio_watch_until FROM users WHERE enabled ORDER BY id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []UserRatioStats
+	for rows.Next() {
+		var s UserRatioStats
+		err = rows.Scan(
+			&s.User,
+			&s.Uploaded,
+			&s.Downloaded,
+			&s.RatioWatchUntil)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// hashSignUpPassword checks the fields of a signup and hashes the password.
+func hashSignUpPassword(username, password, email string) ([]byte, error) {
 	if len(username) == 0 || len(password) == 0 || len(email) == 0 {
-		return errors.New("missing username/password/email")
+		return nil, errors.New("missing username/password/email")
 	}
 
 	if !checkPasswordRequirements(password) {
-		return errors.New("password does not meet the requirements")
+		return nil, errors.New("password does not meet the requirements")
 	}
 
-	pwHash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+func (db *DB) SignUpUser(ctx context.Context, username, password, email string) error {
+	pwHash, err := hashSignUpPassword(username, password, email)
 	if err != nil {
 		return err
 	}
 
-	res, err := db.db.Exec("INSERT INTO users(username, email, password, enabled, can_login, joined_at) VALUES ($1,$2,$3,TRUE,TRUE,NOW())", username, email, pwHash)
+	res, err := db.db.ExecContext(ctx, "INSERT INTO users(username, email, password, enabled, can_login, joined_at) VALUES ($1,$2,$3,TRUE,TRUE,NOW())", username, email, pwHash)
 	if err != nil {
 		return err
 	}
@@ -154,12 +287,15 @@ func (db *DB) SignUpUser(username, password, email string) error {
 	return nil
 }
 
-func (db *DB) PopulateUserPrivileges(u *User) error {
+// PopulateUserPrivileges populates the effective privileges of a user: the
+// privileges of their class and the ones granted to them, without the ones
+// denied to them, in ascending order.
+func (db *DB) PopulateUserPrivileges(ctx context.Context, u *User) error {
 	if u.ID < 0 {
 		return errors.New("invalid ID")
 	}
 
-	rows, err := db.db.Query("SELECT privilege FROM users_privileges WHERE uid =$1 ORDER BY privilege ASC", u.ID)
+	rows, err := db.db.QueryContext(ctx, "SELECT cp.privilege FROM user_classes_privileges cp, users u WHERE cp.class = u.class AND u.id = $1 UNION SELECT privilege FROM users_privileges WHERE uid = $1 AND granted EXCEPT SELECT privilege FROM users_privileges WHERE uid = $1 AND NOT granted ORDER BY 1 ASC", u.ID)
 	if err != nil {
 		return err
 	}
@@ -178,12 +314,12 @@ func (db *DB) PopulateUserPrivileges(u *User) error {
 	return nil
 }
 
-func (db *DB) GetUser(id int) (*User, error) {
+func (db *DB) GetUser(ctx context.Context, id int) (*User, error) {
 	if id < 0 {
 		return nil, errors.New("invalid ID")
 	}
 
-	row := db.db.QueryRow("SELECT email,username,password,bio,enabled,can_login,joined_at,last_login,last_access,uploaded,downloaded FROM users WHERE id=$1", id)
+	row := db.db.QueryRowContext(ctx, "SELECT email,username,password,bio,enabled,can_login,joined_at,last_login,last_access,uploaded,downloaded,class,ratio_watch_until,invites FROM users WHERE id=$1", id)
 
 	user := User{ID: id}
 	err := row.Scan(
@@ -198,6 +334,9 @@ func (db *DB) GetUser(id int) (*User, error) {
 		&user.LastAccess,
 		&user.Uploaded,
 		&user.Downloaded,
+		&user.Class,
+		&user.RatioWatchUntil,
+		&user.Invites,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -214,12 +353,12 @@ func checkPasswordRequirements(password string) bool {
 	return len(password) >= 12
 }
 
-func (db *DB) LoginAndGetUser(username, password string) (*User, error) {
+func (db *DB) LoginAndGetUser(ctx context.Context, username, password string) (*User, error) {
 	if len(username) == 0 || len(password) == 0 {
 		return nil, errors.New("missing username/password")
 	}
 
-	row := db.db.QueryRow("SELECT id,email,password,bio,enabled,can_login,joined_at,last_access,last_login,uploaded,downloaded FROM users WHERE username = $1", username)
+	row := db.db.QueryRowContext(ctx, "SELECT id,email,password,bio,enabled,can_login,joined_at,last_access,last_login,uploaded,downloaded,class,ratio_watch_until,invites FROM users WHERE username = $1", username)
 
 	user := User{Username: username}
 	err := row.Scan(
@@ -233,7 +372,10 @@ func (db *DB) LoginAndGetUser(username, password string) (*User, error) {
 		&user.LastAccess,
 		&user.LastLogin,
 		&user.Uploaded,
-		&user.Downloaded)
+		&user.Downloaded,
+		&user.Class,
+		&user.RatioWatchUntil,
+		&user.Invites)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
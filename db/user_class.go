@@ -0,0 +1,291 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GetAllUserClasses returns the user classes, keyed by their ID, which is
+// also their rank.
+func (db *DB) GetAllUserClasses(ctx context.Context) (map[int]string, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT id,class FROM user_classes")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[int]string)
+	for rows.Next() {
+		var (
+			tmpI int
+			tmpS string
+		)
+		err = rows.Scan(&tmpI, &tmpS)
+		if err != nil {
+			return nil, err
+		}
+
+		m[tmpI] = tmpS
+	}
+
+	return m, nil
+}
+
+// GetUserClassPrivileges returns the privileges of a user class, in
+// ascending order.
+func (db *DB) GetUserClassPrivileges(ctx context.Context, class int) ([]int, error) {
+	if class < 0 {
+		return nil, errors.New("invalid class")
+	}
+
+	rows, err := db.db.QueryContext(ctx, "SELECT privilege FROM user_classes_privileges WHERE class = $1 ORDER BY privilege ASC", class)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var privileges []int
+	for rows.Next() {
+		var tmp int
+		err = rows.Scan(&tmp)
+		if err != nil {
+			return nil, err
+		}
+
+		privileges = append(privileges, tmp)
+	}
+
+	return privileges, nil
+}
+
+// A UserClassChange records a user being moved to another class.
+type UserClassChange struct {
+	ID      int
+	User    int
+	From    int
+	To      int
+	Changed time.Time
+	Reason  string
+
+	// ChangedBy is the user who made the change, nil for automatic changes.
+	ChangedBy *User
+
+	// Conditional makes the change depend on the user still being in the
+	// class From, so that changes made meanwhile are not overwritten.
+	Conditional bool
+}
+
+func setUserClassTx(ctx context.Context, c *UserClassChange, tx *sql.Tx) error {
+	var from int
+	err := tx.QueryRowContext(ctx, "SELECT class FROM users WHERE id = $1 FOR UPDATE", c.User).Scan(&from)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("user not found")
+		}
+		return err
+	}
+	if c.Conditional && from != c.From {
+		return nil
+	}
+	c.From = from
+	if c.From == c.To {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET class = $1 WHERE id = $2", c.To, c.User)
+	if err != nil {
+		return err
+	}
+
+	var changedBy *int
+	if c.ChangedBy != nil {
+		changedBy = &c.ChangedBy.ID
+	}
+
+	return tx.QueryRowContext(ctx, "INSERT INTO user_class_history(uid,changed,from_class,to_class,reason,changed_by) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id", c.User, c.Changed, c.From, c.To, c.Reason, changedBy).Scan(&c.ID)
+}
+
+// SetUserClass moves a user to the class c.To and records the change in the
+// class history of the user.
+// It sets the ID of c and the class the user was moved from.
+// Nothing is recorded if the user is in the class already, or for a
+// conditional change if the user is not in the class c.From anymore, the ID
+// is left zero then.
+// Privileges granted or denied to the user individually are kept.
+func (db *DB) SetUserClass(ctx context.Context, c *UserClassChange) error {
+	if c.User < 0 {
+		return errors.New("invalid ID")
+	}
+	if c.To < 0 {
+		return errors.New("invalid class")
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = setUserClassTx(ctx, c, tx)
+	if err != nil {
+		log.Warnln("Rolling back transaction due to error", log.Fields{"err": err})
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UserClassChangeCursor is the position of a change in the class history of
+// a user, which is sorted by the time of the change and then by ID, newest
+// first.
+type UserClassChangeCursor struct {
+	Changed time.Time
+	ID      int
+}
+
+func (db *DB) queryUserClassHistory(ctx context.Context, uid int, query string, args ...interface{}) ([]UserClassChange, error) {
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []UserClassChange
+	for rows.Next() {
+		var (
+			c                 = UserClassChange{User: uid}
+			changedBy         sql.NullInt64
+			changedByUsername sql.NullString
+		)
+		err = rows.Scan(
+			&c.ID,
+			&c.Changed,
+			&c.From,
+			&c.To,
+			&c.Reason,
+			&changedBy,
+			&changedByUsername)
+		if err != nil {
+			return nil, err
+		}
+		if changedBy.Valid {
+			c.ChangedBy = &User{ID: int(changedBy.Int64), Username: changedByUsername.String}
+		}
+		changes = append(changes, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// GetUserClassHistory returns the class changes of a user, newest first.
+func (db *DB) GetUserClassHistory(ctx context.Context, uid, offset, limit int) ([]UserClassChange, error) {
+	if uid < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+
+	return db.queryUserClassHistory(ctx, uid, "SELECT h.id,h.changed,h.from_class,h.to_class,h.reason,h.changed_by,u.username FROM user_class_history h LEFT JOIN users u ON h.changed_by = u.id WHERE h.uid = $1 ORDER BY h.changed DESC, h.id DESC LIMIT $2 OFFSET $3", uid, limit, offset)
+}
+
+// GetUserClassHistoryAfter returns up to limit class changes of a user
+// following c, newest first.
+func (db *DB) GetUserClassHistoryAfter(ctx context.Context, uid int, c UserClassChangeCursor, limit int) ([]UserClassChange, error) {
+	if uid < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	return db.queryUserClassHistory(ctx, uid, "SELECT h.id,h.changed,h.from_class,h.to_class,h.reason,h.changed_by,u.username FROM user_class_history h LEFT JOIN users u ON h.changed_by = u.id WHERE h.uid = $1 AND (h.changed, h.id) < ($2, $3) ORDER BY h.changed DESC, h.id DESC LIMIT $4", uid, c.Changed, c.ID, limit)
+}
+
+// GetUserClassHistoryBefore returns up to limit class changes of a user
+// preceding c, newest first.
+// These are the changes closest to c, not the newest ones.
+func (db *DB) GetUserClassHistoryBefore(ctx context.Context, uid int, c UserClassChangeCursor, limit int) ([]UserClassChange, error) {
+	if uid < 0 {
+		return nil, errors.New("invalid ID")
+	}
+	if limit < 1 {
+		return nil, errors.New("invalid limit")
+	}
+
+	changes, err := db.queryUserClassHistory(ctx, uid, "SELECT h.id,h.changed,h.from_class,h.to_class,h.reason,h.changed_by,u.username FROM user_class_history h LEFT JOIN users u ON h.changed_by = u.id WHERE h.uid = $1 AND (h.changed, h.id) > ($2, $3) ORDER BY h.changed ASC, h.id ASC LIMIT $4", uid, c.Changed, c.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+		changes[i], changes[j] = changes[j], changes[i]
+	}
+
+	return changes, nil
+}
+
+// CountUserClassHistory returns the number of class changes of a user.
+func (db *DB) CountUserClassHistory(ctx context.Context, uid int) (int, error) {
+	if uid < 0 {
+		return 0, errors.New("invalid ID")
+	}
+
+	var count int
+	err := db.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_class_history WHERE uid = $1", uid).Scan(&count)
+	return count, err
+}
+
+// UserPromotionStats are the numbers the automatic promotion evaluates users
+// by.
+type UserPromotionStats struct {
+	User       int
+	Class      int
+	Uploaded   int64
+	Downloaded int64
+	JoinedAt   time.Time
+
+	// Uploads is the number of torrents the user uploaded.
+	Uploads int
+}
+
+// GetUserPromotionStats returns the promotion stats of all enabled users,
+// ordered by their ID.
+func (db *DB) GetUserPromotionStats(ctx context.Context) ([]UserPromotionStats, error) {
+	rows, err := db.db.QueryContext(ctx, "SELECT u.id,u.class,u.uploaded,u.downloaded,u.joined_at,COUNT(t.id) FROM users u LEFT JOIN torrents t ON t.uploader = u.id WHERE u.enabled GROUP BY u.id ORDER BY u.id ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []UserPromotionStats
+	for rows.Next() {
+		var s UserPromotionStats
+		err = rows.Scan(
+			&s.User,
+			&s.Class,
+			&s.Uploaded,
+			&s.Downloaded,
+			&s.JoinedAt,
+			&s.Uploads)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// StatBucket is the granularity of aggregated stat histories.
+type StatBucket string
+
+const (
+	StatBucketHour  StatBucket = "hour"
+	StatBucketDay   StatBucket = "day"
+	StatBucketWeek  StatBucket = "week"
+	StatBucketMonth StatBucket = "month"
+)
+
+func (b StatBucket) Valid() bool {
+	switch b {
+	case StatBucketHour, StatBucketDay, StatBucketWeek, StatBucketMonth:
+		return true
+	}
+	return false
+}
+
+// UserStatPoint is the sum of a user's stat changes within one bucket.
+// Uploaded and Downloaded are the credited amounts, RawUploaded and
+// RawDownloaded the amounts before leech type multipliers were applied.
+type UserStatPoint struct {
+	Bucket        time.Time
+	Uploaded      int64
+	Downloaded    int64
+	RawUploaded   int64
+	RawDownloaded int64
+}
+
+// UserTorrentStats is the sum of a user's stat changes on one torrent.
+// Torrent is nil for the sum of the changes on deleted torrents.
+type UserTorrentStats struct {
+	Torrent       *int
+	Uploaded      int64
+	Downloaded    int64
+	RawUploaded   int64
+	RawDownloaded int64
+	Announces     int
+	FirstAnnounce time.Time
+	LastAnnounce  time.Time
+}
+
+func validateStatRange(uid int, from, to time.Time) error {
+	if uid < 0 {
+		return errors.New("invalid ID")
+	}
+	if !from.Before(to) {
+		return errors.New("invalid time range")
+	}
+	return nil
+}
+
+// GetUserStatHistory returns the stat changes of the user with the given ID
+// reported in [from, to), aggregated into buckets.
+// Buckets without any stat changes are omitted, the result is ordered by
+// bucket.
+func (db *DB) GetUserStatHistory(ctx context.Context, uid int, from, to time.Time, bucket StatBucket) ([]UserStatPoint, error) {
+	err := validateStatRange(uid, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if !bucket.Valid() {
+		return nil, errors.New("invalid bucket")
+	}
+
+	rows, err := db.db.QueryContext(ctx, "SELECT date_trunc($1, reported_at) AS bucket,SUM(uploaded_delta)::BIGINT,SUM(downloaded_delta)::BIGINT,SUM(raw_uploaded)::BIGINT,SUM(raw_downloaded)::BIGINT FROM user_stat_changes WHERE uid = $2 AND reported_at >= $3 AND reported_at < $4 GROUP BY bucket ORDER BY bucket ASC",
+		string(bucket),
+		uid,
+		from,
+		to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []UserStatPoint
+	for rows.Next() {
+		var tmp UserStatPoint
+		err = rows.Scan(
+			&tmp.Bucket,
+			&tmp.Uploaded,
+			&tmp.Downloaded,
+			&tmp.RawUploaded,
+			&tmp.RawDownloaded)
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, tmp)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// GetUserTorrentStats returns the stat changes of the user with the given ID
+// reported in [from, to), aggregated per torrent.
+// The changes on deleted torrents are aggregated into one entry without a
+// torrent.
+// The result is ordered by the time of the last announce, most recent first.
+func (db *DB) GetUserTorrentStats(ctx context.Context, uid int, from, to time.Time) ([]UserTorrentStats, error) {
+	err := validateStatRange(uid, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.db.QueryContext(ctx, "SELECT torrent,SUM(uploaded_delta)::BIGINT,SUM(downloaded_delta)::BIGINT,SUM(raw_uploaded)::BIGINT,SUM(raw_downloaded)::BIGINT,COUNT(*),MIN(reported_at),MAX(reported_at) FROM user_stat_changes WHERE uid = $1 AND reported_at >= $2 AND reported_at < $3 GROUP BY torrent ORDER BY MAX(reported_at) DESC, torrent ASC",
+		uid,
+		from,
+		to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []UserTorrentStats
+	for rows.Next() {
+		var (
+			tmp     UserTorrentStats
+			torrent sql.NullInt64
+		)
+		err = rows.Scan(
+			&torrent,
+			&tmp.Uploaded,
+			&tmp.Downloaded,
+			&tmp.RawUploaded,
+			&tmp.RawDownloaded,
+			&tmp.Announces,
+			&tmp.FirstAnnounce,
+			&tmp.LastAnnounce)
+		if err != nil {
+			return nil, err
+		}
+		if torrent.Valid {
+			id := int(torrent.Int64)
+			tmp.Torrent = &id
+		}
+
+		stats = append(stats, tmp)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
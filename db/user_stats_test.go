@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserStatHistory(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := cleanDB()
+	require.Nil(t, err)
+
+	r := insertTestRelease(t, db)
+
+	tor := Torrent{
+		Release:    Release{ID: r.ID},
+		Uploaded:   time.Now(),
+		UploadedBy: User{ID: 1},
+		InfoHash:   [20]byte{1},
+		Info:       []byte("d4:name1:ae"),
+		LeechType:  3, // DoubleUp
+	}
+	err = db.InsertTorrent(ctx, &tor)
+	require.Nil(t, err)
+
+	pk, err := db.GenerateNewPasskeyForUser(ctx, 1)
+	require.Nil(t, err)
+
+	day := time.Date(2017, 10, 14, 0, 0, 0, 0, time.UTC)
+	_, err = db.RecordAnnounces(ctx, []Announce{
+		{Passkey: pk, InfoHash: tor.InfoHash, Uploaded: 100, Downloaded: 10, Left: 10, Event: AnnounceEventStarted, ReportedAt: day.Add(1 * time.Hour)},
+		{Passkey: pk, InfoHash: tor.InfoHash, Uploaded: 200, Downloaded: 20, Left: 10, ReportedAt: day.Add(2 * time.Hour)},
+		{Passkey: pk, InfoHash: tor.InfoHash, Uploaded: 400, Downloaded: 40, Left: 10, ReportedAt: day.Add(25 * time.Hour)},
+		// repeated announces must be recorded separately
+		{Passkey: pk, InfoHash: tor.InfoHash, Uploaded: 400, Downloaded: 40, Left: 10, ReportedAt: day.Add(25 * time.Hour)},
+	})
+	require.Nil(t, err)
+
+	points, err := db.GetUserStatHistory(ctx, 1, day, day.Add(72*time.Hour), StatBucketDay)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(points))
+	require.True(t, day.Equal(points[0].Bucket))
+	require.Equal(t, int64(600), points[0].Uploaded)
+	require.Equal(t, int64(300), points[0].RawUploaded)
+	require.Equal(t, int64(30), points[0].Downloaded)
+	require.Equal(t, int64(30), points[0].RawDownloaded)
+	require.True(t, day.Add(24*time.Hour).Equal(points[1].Bucket))
+	require.Equal(t, int64(1600), points[1].Uploaded)
+	require.Equal(t, int64(80), points[1].Downloaded)
+
+	// to is exclusive
+	points, err = db.GetUserStatHistory(ctx, 1, day, day.Add(2*time.Hour), StatBucketHour)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(points))
+	require.Equal(t, int64(200), points[0].Uploaded)
+
+	stats, err := db.GetUserTorrentStats(ctx, 1, day, day.Add(72*time.Hour))
+	require.Nil(t, err)
+	require.Equal(t, 1, len(stats))
+	require.Equal(t, tor.ID, stats[0].Torrent)
+	require.Equal(t, int64(2200), stats[0].Uploaded)
+	require.Equal(t, int64(1100), stats[0].RawUploaded)
+	require.Equal(t, 4, stats[0].Announces)
+	require.True(t, day.Add(1*time.Hour).Equal(stats[0].FirstAnnounce))
+	require.True(t, day.Add(25*time.Hour).Equal(stats[0].LastAnnounce))
+
+	_, err = db.GetUserStatHistory(ctx, 1, day, day.Add(72*time.Hour), StatBucket("year"))
+	require.NotNil(t, err)
+
+	_, err = db.GetUserStatHistory(ctx, 1, day, day, StatBucketDay)
+	require.NotNil(t, err)
+}
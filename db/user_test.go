@@ -1,19 +1,22 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestSignUpUser(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	err = db.SignUpUser("testuser", "testtest12345", "test@example.com")
+	err = db.SignUpUser(ctx, "testuser", "testtest12345", "test@example.com")
 	require.Nil(t, err)
 
-	u, err := db.LoginAndGetUser("testuser", "testtest12345")
+	u, err := db.LoginAndGetUser(ctx, "testuser", "testtest12345")
 	require.Nil(t, err)
 	require.NotNil(t, u)
 
@@ -23,26 +26,28 @@ func TestSignUpUser(t *testing.T) {
 	require.False(t, u.LastAccess.Valid)
 	require.False(t, u.LastLogin.Valid)
 
-	u2, err := db.GetUser(u.ID)
+	u2, err := db.GetUser(ctx, u.ID)
 	require.Nil(t, err)
 	require.Equal(t, u, u2)
 }
 
 func TestUpdateUserDeltaUpDown(t *testing.T) {
+	ctx := context.Background()
+
 	db, err := cleanDB()
 	require.Nil(t, err)
 
-	err = db.SignUpUser("testuser", "testpwtest1234", "test@example.com")
+	err = db.SignUpUser(ctx, "testuser", "testpwtest1234", "test@example.com")
 	require.Nil(t, err)
 
-	u, err := db.LoginAndGetUser("testuser", "testpwtest1234")
+	u, err := db.LoginAndGetUser(ctx, "testuser", "testpwtest1234")
 	require.Nil(t, err)
 	require.NotNil(t, u)
 
-	err = db.UpdateUserDeltaUpDown(u.ID, 513, -234)
+	err = db.UpdateUserDeltaUpDown(ctx, u.ID, 513, -234)
 	require.Nil(t, err)
 
-	u, err = db.GetUser(u.ID)
+	u, err = db.GetUser(ctx, u.ID)
 	require.Nil(t, err)
 	require.Equal(t, int64(513), u.Uploaded)
 	require.Equal(t, int64(-234), u.Downloaded)
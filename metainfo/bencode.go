@@ -0,0 +1,218 @@
+package metainfo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// maxDepth limits the nesting of lists and dictionaries when decoding, to
+// avoid exhausting the stack on malicious input.
+const maxDepth = 64
+
+var ErrInvalidBencode = errors.New("invalid bencode")
+
+// Decode decodes a single bencoded value from data.
+// Integers are decoded as int64, strings as string, lists as []interface{}
+// and dictionaries as map[string]interface{}.
+// Trailing data after the value is an error.
+func Decode(data []byte) (interface{}, error) {
+	d := decoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%s: trailing data at offset %d", ErrInvalidBencode, d.pos)
+	}
+
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s at offset %d", ErrInvalidBencode, fmt.Sprintf(format, args...), d.pos)
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, d.errorf("nested too deeply")
+	}
+	if d.pos >= len(d.data) {
+		return nil, d.errorf("unexpected end of input")
+	}
+
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		return d.integer()
+	case c == 'l':
+		return d.list(depth)
+	case c == 'd':
+		return d.dict(depth)
+	case c >= '0' && c <= '9':
+		return d.string()
+	default:
+		return nil, d.errorf("unexpected character %q", c)
+	}
+}
+
+func (d *decoder) integer() (int64, error) {
+	d.pos++ // skip the i
+	end := bytes.IndexByte(d.data[d.pos:], 'e')
+	if end < 0 {
+		return 0, d.errorf("unterminated integer")
+	}
+
+	s := string(d.data[d.pos : d.pos+end])
+	if len(s) == 0 || s == "-0" ||
+		(len(s) > 1 && s[0] == '0') ||
+		(len(s) > 2 && s[0] == '-' && s[1] == '0') {
+		return 0, d.errorf("malformed integer %q", s)
+	}
+
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, d.errorf("malformed integer %q", s)
+	}
+
+	d.pos += end + 1
+	return i, nil
+}
+
+func (d *decoder) string() (string, error) {
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon < 0 {
+		return "", d.errorf("unterminated string length")
+	}
+
+	l := string(d.data[d.pos : d.pos+colon])
+	if len(l) > 1 && l[0] == '0' {
+		return "", d.errorf("malformed string length %q", l)
+	}
+	n, err := strconv.Atoi(l)
+	if err != nil || n < 0 {
+		return "", d.errorf("malformed string length %q", l)
+	}
+
+	start := d.pos + colon + 1
+	if n > len(d.data)-start {
+		return "", d.errorf("string exceeds input")
+	}
+
+	d.pos = start + n
+	return string(d.data[start:d.pos]), nil
+}
+
+func (d *decoder) list(depth int) ([]interface{}, error) {
+	d.pos++ // skip the l
+	l := make([]interface{}, 0)
+	for {
+		if d.pos >= len(d.data) {
+			return nil, d.errorf("unterminated list")
+		}
+		if d.data[d.pos] == 'e' {
+			d.pos++
+			return l, nil
+		}
+
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, v)
+	}
+}
+
+func (d *decoder) dict(depth int) (map[string]interface{}, error) {
+	d.pos++ // skip the d
+	m := make(map[string]interface{})
+	for {
+		if d.pos >= len(d.data) {
+			return nil, d.errorf("unterminated dictionary")
+		}
+		if d.data[d.pos] == 'e' {
+			d.pos++
+			return m, nil
+		}
+		if c := d.data[d.pos]; c < '0' || c > '9' {
+			return nil, d.errorf("dictionary key is not a string")
+		}
+
+		k, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m[k]; ok {
+			return nil, d.errorf("duplicate dictionary key %q", k)
+		}
+
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+}
+
+// Encode encodes v to bencode.
+// Supported types are int, int64, string, []byte, []interface{} and
+// map[string]interface{}, as well as any nesting of them.
+// Dictionary keys are sorted, so the output is canonical.
+func Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := encode(&buf, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch t := v.(type) {
+	case int:
+		fmt.Fprintf(buf, "i%de", t)
+	case int64:
+		fmt.Fprintf(buf, "i%de", t)
+	case string:
+		fmt.Fprintf(buf, "%d:%s", len(t), t)
+	case []byte:
+		fmt.Fprintf(buf, "%d:", len(t))
+		buf.Write(t)
+	case []interface{}:
+		buf.WriteByte('l')
+		for _, e := range t {
+			err := encode(buf, e)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('d')
+		for _, k := range keys {
+			fmt.Fprintf(buf, "%d:%s", len(k), k)
+			err := encode(buf, t[k])
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("unable to bencode value of type %T", v)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package metainfo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	v, err := Decode([]byte("d3:bar4:spam3:fooi42e4:listli-3e0:ee"))
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{
+		"bar":  "spam",
+		"foo":  int64(42),
+		"list": []interface{}{int64(-3), ""},
+	}, v)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"i42",
+		"i-0e",
+		"i03e",
+		"ie",
+		"5:abc",
+		"05:abcde",
+		"l",
+		"d3:foo",
+		"di1ei2ee",
+		"d3:fooi1e3:fooi2ee",
+		"i1ei2e",
+		"x",
+	}
+
+	for _, s := range invalid {
+		_, err := Decode([]byte(s))
+		require.NotNil(t, err, "expected error decoding %q", s)
+	}
+}
+
+func TestDecodeTooDeep(t *testing.T) {
+	var s string
+	for i := 0; i < maxDepth+2; i++ {
+		s += "l"
+	}
+	for i := 0; i < maxDepth+2; i++ {
+		s += "e"
+	}
+
+	_, err := Decode([]byte(s))
+	require.NotNil(t, err)
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	in := "d1:ai1e1:bl3:abci-7ee1:cd1:xdeee"
+
+	v, err := Decode([]byte(in))
+	require.Nil(t, err)
+
+	out, err := Encode(v)
+	require.Nil(t, err)
+	require.Equal(t, in, string(out))
+}
+
+func TestEncodeSortsKeys(t *testing.T) {
+	out, err := Encode(map[string]interface{}{
+		"z": 1,
+		"a": []byte("x"),
+	})
+	require.Nil(t, err)
+	require.Equal(t, "d1:a1:x1:zi1ee", string(out))
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	_, err := Encode(1.5)
+	require.NotNil(t, err)
+}
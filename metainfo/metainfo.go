@@ -0,0 +1,177 @@
+// Package metainfo implements parsing and building of BitTorrent metainfo
+// (.torrent) files, as well as the bencode encoding they use.
+package metainfo
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MetaInfo is a parsed and sanitized .torrent file.
+// Only the info dictionary is retained - any announce URLs and other top-level
+// keys of the original file are dropped.
+type MetaInfo struct {
+	// Info is the bencoded info dictionary, with the private flag set.
+	Info []byte
+
+	// InfoHash is the v1 (SHA-1) info hash, computed over Info.
+	InfoHash [20]byte
+
+	Name  string
+	Files []File
+}
+
+type File struct {
+	Path   string
+	Length int64
+}
+
+// TotalSize returns the sum of the lengths of all files.
+func (m *MetaInfo) TotalSize() int64 {
+	var size int64
+	for _, f := range m.Files {
+		size += f.Length
+	}
+	return size
+}
+
+// Parse parses a .torrent file.
+// The info dictionary is forced to be private and re-encoded canonically,
+// after which the info hash is computed.
+// Announce URLs contained in the file are stripped, they are added back per
+// user by Build.
+func Parse(data []byte) (*MetaInfo, error) {
+	v, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	root, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("metainfo is not a dictionary")
+	}
+
+	info, ok := root["info"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("missing info dictionary")
+	}
+
+	m := &MetaInfo{}
+
+	m.Name, ok = info["name"].(string)
+	if !ok || len(m.Name) == 0 {
+		return nil, errors.New("missing name")
+	}
+	if !validPathElement(m.Name) {
+		return nil, errors.New("invalid name")
+	}
+
+	pieceLength, ok := info["piece length"].(int64)
+	if !ok || pieceLength <= 0 {
+		return nil, errors.New("missing or invalid piece length")
+	}
+
+	pieces, ok := info["pieces"].(string)
+	if !ok || len(pieces) == 0 || len(pieces)%sha1.Size != 0 {
+		// we only support v1 and hybrid torrents
+		return nil, errors.New("missing or invalid pieces")
+	}
+
+	m.Files, err = parseFiles(m.Name, info)
+	if err != nil {
+		return nil, err
+	}
+
+	numPieces := (m.TotalSize() + pieceLength - 1) / pieceLength
+	if numPieces != int64(len(pieces)/sha1.Size) {
+		return nil, fmt.Errorf("expected %d pieces, got %d", numPieces, len(pieces)/sha1.Size)
+	}
+
+	info["private"] = int64(1)
+
+	m.Info, err = Encode(info)
+	if err != nil {
+		return nil, err
+	}
+	m.InfoHash = sha1.Sum(m.Info)
+
+	return m, nil
+}
+
+func parseFiles(name string, info map[string]interface{}) ([]File, error) {
+	if _, ok := info["length"]; ok {
+		if _, ok := info["files"]; ok {
+			return nil, errors.New("both length and files present")
+		}
+
+		length, ok := info["length"].(int64)
+		if !ok || length < 0 {
+			return nil, errors.New("invalid length")
+		}
+		return []File{{Path: name, Length: length}}, nil
+	}
+
+	rawFiles, ok := info["files"].([]interface{})
+	if !ok || len(rawFiles) == 0 {
+		return nil, errors.New("missing length or files")
+	}
+
+	files := make([]File, 0, len(rawFiles))
+	for i, rawFile := range rawFiles {
+		f, ok := rawFile.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("file %d is not a dictionary", i)
+		}
+
+		length, ok := f["length"].(int64)
+		if !ok || length < 0 {
+			return nil, fmt.Errorf("file %d has an invalid length", i)
+		}
+
+		rawPath, ok := f["path"].([]interface{})
+		if !ok || len(rawPath) == 0 {
+			return nil, fmt.Errorf("file %d has an invalid path", i)
+		}
+
+		elements := []string{name}
+		for _, rawElement := range rawPath {
+			e, ok := rawElement.(string)
+			if !ok || !validPathElement(e) {
+				return nil, fmt.Errorf("file %d has an invalid path", i)
+			}
+			elements = append(elements, e)
+		}
+
+		files = append(files, File{
+			Path:   strings.Join(elements, "/"),
+			Length: length,
+		})
+	}
+
+	return files, nil
+}
+
+func validPathElement(e string) bool {
+	return len(e) != 0 && e != "." && e != ".." && !strings.ContainsAny(e, "/\\\x00")
+}
+
+// Build builds a .torrent file from a bencoded info dictionary, as returned
+// by Parse, and an announce URL.
+func Build(info []byte, announce string) ([]byte, error) {
+	v, err := Decode(info)
+	if err != nil {
+		return nil, err
+	}
+
+	infoDict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("info is not a dictionary")
+	}
+
+	return Encode(map[string]interface{}{
+		"announce": announce,
+		"info":     infoDict,
+	})
+}
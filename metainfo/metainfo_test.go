@@ -0,0 +1,143 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func multiFileTorrent(t *testing.T) []byte {
+	b, err := Encode(map[string]interface{}{
+		"announce":      "http://tracker.example.com/announce",
+		"announce-list": []interface{}{[]interface{}{"http://tracker.example.com/announce"}},
+		"comment":       "some comment",
+		"info": map[string]interface{}{
+			"name":         "deadmau5 - 4x4=12",
+			"piece length": int64(16384),
+			"pieces":       strings.Repeat("a", 2*sha1.Size),
+			"files": []interface{}{
+				map[string]interface{}{
+					"length": int64(20000),
+					"path":   []interface{}{"01 - Some Chords.flac"},
+				},
+				map[string]interface{}{
+					"length": int64(100),
+					"path":   []interface{}{"scans", "cover.jpg"},
+				},
+			},
+		},
+	})
+	require.Nil(t, err)
+	return b
+}
+
+func TestParseMultiFile(t *testing.T) {
+	m, err := Parse(multiFileTorrent(t))
+	require.Nil(t, err)
+
+	require.Equal(t, "deadmau5 - 4x4=12", m.Name)
+	require.Equal(t, []File{
+		{Path: "deadmau5 - 4x4=12/01 - Some Chords.flac", Length: 20000},
+		{Path: "deadmau5 - 4x4=12/scans/cover.jpg", Length: 100},
+	}, m.Files)
+	require.Equal(t, int64(20100), m.TotalSize())
+	require.Equal(t, sha1.Sum(m.Info), m.InfoHash)
+
+	v, err := Decode(m.Info)
+	require.Nil(t, err)
+	info := v.(map[string]interface{})
+	require.Equal(t, int64(1), info["private"])
+	_, ok := info["announce"]
+	require.False(t, ok)
+}
+
+func TestParseSingleFile(t *testing.T) {
+	b, err := Encode(map[string]interface{}{
+		"info": map[string]interface{}{
+			"name":         "track.flac",
+			"piece length": int64(16384),
+			"pieces":       strings.Repeat("a", sha1.Size),
+			"length":       int64(100),
+			"private":      int64(1),
+		},
+	})
+	require.Nil(t, err)
+
+	m, err := Parse(b)
+	require.Nil(t, err)
+	require.Equal(t, []File{{Path: "track.flac", Length: 100}}, m.Files)
+
+	// already private and canonical, so the info hash must not change
+	v, err := Decode(b)
+	require.Nil(t, err)
+	info, err := Encode(v.(map[string]interface{})["info"])
+	require.Nil(t, err)
+	require.Equal(t, sha1.Sum(info), m.InfoHash)
+}
+
+func TestParseInvalid(t *testing.T) {
+	invalid := []map[string]interface{}{
+		{},
+		{"info": "garbage"},
+		{"info": map[string]interface{}{
+			"name":         "x",
+			"piece length": int64(16384),
+			"pieces":       "short",
+			"length":       int64(1),
+		}},
+		{"info": map[string]interface{}{
+			"name":         "..",
+			"piece length": int64(16384),
+			"pieces":       strings.Repeat("a", sha1.Size),
+			"length":       int64(1),
+		}},
+		{"info": map[string]interface{}{
+			"name":         "x",
+			"piece length": int64(16384),
+			"pieces":       strings.Repeat("a", sha1.Size),
+			"files": []interface{}{
+				map[string]interface{}{
+					"length": int64(1),
+					"path":   []interface{}{"..", "etc", "passwd"},
+				},
+			},
+		}},
+		{"info": map[string]interface{}{
+			"name":         "x",
+			"piece length": int64(16384),
+			"pieces":       strings.Repeat("a", 3*sha1.Size),
+			"length":       int64(1),
+		}},
+	}
+
+	for i, m := range invalid {
+		b, err := Encode(m)
+		require.Nil(t, err)
+
+		_, err = Parse(b)
+		require.NotNil(t, err, "expected error for case %d", i)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	m, err := Parse(multiFileTorrent(t))
+	require.Nil(t, err)
+
+	b, err := Build(m.Info, "http://tracker.boiling.rip/somepasskey/announce")
+	require.Nil(t, err)
+
+	v, err := Decode(b)
+	require.Nil(t, err)
+	root := v.(map[string]interface{})
+	require.Equal(t, "http://tracker.boiling.rip/somepasskey/announce", root["announce"])
+
+	info, err := Encode(root["info"])
+	require.Nil(t, err)
+	require.Equal(t, m.Info, info)
+
+	m2, err := Parse(b)
+	require.Nil(t, err)
+	require.Equal(t, m.InfoHash, m2.InfoHash)
+}
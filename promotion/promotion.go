@@ -0,0 +1,257 @@
+// Package promotion implements the automatic promotion and demotion of users
+// between user classes.
+//
+// Each user class can have a rule with requirements on the traffic, ratio,
+// account age and number of uploads of its members.
+// Users are moved to the highest class whose requirements they meet, or to
+// the lowest class if they meet none.
+// Classes without rules, usually staff classes, are left alone: their
+// members are never moved and users are never moved to them.
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+// DefaultInterval is the interval users are evaluated in if none is
+// configured.
+const DefaultInterval = time.Hour
+
+// A Rule holds the requirements of a user class.
+// Zero values are not checked.
+type Rule struct {
+	Class string `yaml:"class"`
+
+	// MinUploaded is the minimum upload in bytes.
+	MinUploaded int64 `yaml:"min_uploaded"`
+
+	// MinRatio is the minimum ratio of upload to download.
+	// Users who never downloaded anything meet any ratio.
+	MinRatio float64 `yaml:"min_ratio"`
+
+	// MinAge is the minimum time since the user joined.
+	MinAge time.Duration `yaml:"min_age"`
+
+	// MinUploads is the minimum number of torrents uploaded.
+	MinUploads int `yaml:"min_uploads"`
+}
+
+type Config struct {
+	// Interval is the time between two evaluations of all users.
+	// If it is zero, DefaultInterval is used.
+	Interval time.Duration
+
+	Rules []Rule
+}
+
+type rule struct {
+	Rule
+	class int
+}
+
+// An Engine periodically promotes and demotes users.
+type Engine struct {
+	db       db.BoilingDB
+	interval time.Duration
+
+	// rules are ordered from the lowest to the highest class.
+	rules   []rule
+	lowest  int
+	classes map[int]string
+	managed map[int]bool
+
+	now func() time.Time
+}
+
+// New creates an Engine, resolving the classes of the rules.
+func New(ctx context.Context, d db.BoilingDB, cfg Config) (*Engine, error) {
+	if cfg.Interval < 0 {
+		return nil, errors.New("interval must not be negative")
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultInterval
+	}
+
+	classes, err := d.GetAllUserClasses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(classes) == 0 {
+		return nil, errors.New("no user classes")
+	}
+
+	ids := make(map[string]int)
+	lowest := math.MaxInt32
+	for id, class := range classes {
+		ids[class] = id
+		if id < lowest {
+			lowest = id
+		}
+	}
+
+	e := &Engine{
+		db:       d,
+		interval: cfg.Interval,
+		lowest:   lowest,
+		classes:  classes,
+		managed:  map[int]bool{lowest: true},
+		now:      time.Now,
+	}
+
+	for _, r := range cfg.Rules {
+		id, ok := ids[r.Class]
+		if !ok {
+			return nil, fmt.Errorf("unknown user class %s", r.Class)
+		}
+		if id == lowest {
+			return nil, fmt.Errorf("the lowest user class %s can not have requirements", r.Class)
+		}
+		if e.managed[id] {
+			return nil, fmt.Errorf("duplicate rule for user class %s", r.Class)
+		}
+		if r.MinUploaded < 0 || r.MinRatio < 0 || r.MinAge < 0 || r.MinUploads < 0 {
+			return nil, fmt.Errorf("requirements of user class %s must not be negative", r.Class)
+		}
+
+		e.managed[id] = true
+
+		i := len(e.rules)
+		e.rules = append(e.rules, rule{Rule: r, class: id})
+		for ; i > 0 && e.rules[i-1].class > id; i-- {
+			e.rules[i], e.rules[i-1] = e.rules[i-1], e.rules[i]
+		}
+	}
+
+	return e, nil
+}
+
+func ratio(s db.UserPromotionStats) (float64, bool) {
+	if s.Downloaded == 0 {
+		return 0, false
+	}
+	return float64(s.Uploaded) / float64(s.Downloaded), true
+}
+
+func days(d time.Duration) string {
+	return fmt.Sprintf("%d days", int(d.Hours()/24))
+}
+
+// unmet returns the first requirement of r that s does not meet, or an empty
+// string if s meets all of them.
+func (r rule) unmet(s db.UserPromotionStats, now time.Time) string {
+	if s.Uploaded < r.MinUploaded {
+		return fmt.Sprintf("uploaded %d bytes, below %d", s.Uploaded, r.MinUploaded)
+	}
+	if ratio, ok := ratio(s); ok && ratio < r.MinRatio {
+		return fmt.Sprintf("ratio %.2f, below %.2f", ratio, r.MinRatio)
+	}
+	if age := now.Sub(s.JoinedAt); age < r.MinAge {
+		return fmt.Sprintf("account age %s, below %s", days(age), days(r.MinAge))
+	}
+	if s.Uploads < r.MinUploads {
+		return fmt.Sprintf("%d uploads, below %d", s.Uploads, r.MinUploads)
+	}
+	return ""
+}
+
+func describe(s db.UserPromotionStats, now time.Time) string {
+	r := "no downloads"
+	if ratio, ok := ratio(s); ok {
+		r = fmt.Sprintf("ratio %.2f", ratio)
+	}
+	return fmt.Sprintf("uploaded %d bytes, %s, account age %s, %d uploads", s.Uploaded, r, days(now.Sub(s.JoinedAt)), s.Uploads)
+}
+
+// evaluate returns the class a user belongs in and the reason for it.
+func (e *Engine) evaluate(s db.UserPromotionStats, now time.Time) (int, string) {
+	target := e.lowest
+	for _, r := range e.rules {
+		if r.unmet(s, now) == "" {
+			target = r.class
+		}
+	}
+
+	switch {
+	case target > s.Class:
+		return target, fmt.Sprintf("promoted: meets the requirements of %s: %s", e.classes[target], describe(s, now))
+	case target < s.Class:
+		for _, r := range e.rules {
+			if r.class == s.Class {
+				return target, fmt.Sprintf("demoted: no longer meets the requirements of %s: %s", e.classes[s.Class], r.unmet(s, now))
+			}
+		}
+	}
+	return target, ""
+}
+
+// RunOnce evaluates all users once, moves the ones who meet the requirements
+// of another class and returns the number of users moved.
+func (e *Engine) RunOnce(ctx context.Context) (int, error) {
+	stats, err := e.db.GetUserPromotionStats(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := e.now()
+	moved := 0
+	for _, s := range stats {
+		if !e.managed[s.Class] {
+			continue
+		}
+
+		target, reason := e.evaluate(s, now)
+		if target == s.Class {
+			continue
+		}
+
+		// Staff may have moved the user since the stats were read.
+		c := db.UserClassChange{
+			User:        s.User,
+			From:        s.Class,
+			To:          target,
+			Changed:     now,
+			Reason:      reason,
+			Conditional: true,
+		}
+		err = e.db.SetUserClass(ctx, &c)
+		if err != nil {
+			return moved, err
+		}
+		if c.ID == 0 {
+			log.Infoln("user changed class meanwhile, not moving them", log.Fields{"user": s.User})
+			continue
+		}
+
+		log.Infoln("moved user to another class", log.Fields{"user": s.User, "from": e.classes[c.From], "to": e.classes[target], "reason": reason})
+		moved++
+	}
+
+	return moved, nil
+}
+
+// Run evaluates all users once per interval, until ctx is done.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := e.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warnln("unable to promote and demote users", log.Fields{"err": err})
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/db/memdb"
+)
+
+const gib = 1 << 30
+
+var (
+	now = time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+
+	testRules = []Rule{
+		{Class: "Elite", MinUploaded: 100 * gib, MinRatio: 1.05, MinAge: 28 * 24 * time.Hour, MinUploads: 5},
+		{Class: "Power User", MinUploaded: 25 * gib, MinRatio: 1.05, MinAge: 14 * 24 * time.Hour},
+	}
+)
+
+func insertUser(t *testing.T, d *memdb.DB, id, class int, uploaded, downloaded int64, age time.Duration) {
+	err := d.InsertUser(db.User{
+		ID:         id,
+		Username:   fmt.Sprintf("user%d", id),
+		Email:      fmt.Sprintf("user%d@boiling.rip", id),
+		Enabled:    true,
+		CanLogin:   true,
+		JoinedAt:   now.Add(-age),
+		Uploaded:   uploaded,
+		Downloaded: downloaded,
+		Class:      class,
+	})
+	require.Nil(t, err)
+}
+
+func newEngine(t *testing.T, d db.BoilingDB, rules []Rule) *Engine {
+	e, err := New(context.Background(), d, Config{Rules: rules})
+	require.Nil(t, err)
+	e.now = func() time.Time { return now }
+	return e
+}
+
+func class(t *testing.T, d db.BoilingDB, id int) int {
+	u, err := d.GetUser(context.Background(), id)
+	require.Nil(t, err)
+	return u.Class
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	d := memdb.New()
+
+	e, err := New(ctx, d, Config{Rules: testRules})
+	require.Nil(t, err)
+	require.Equal(t, DefaultInterval, e.interval)
+
+	// rules are ordered by class
+	require.Len(t, e.rules, 2)
+	require.Equal(t, "Power User", e.rules[0].Class)
+	require.Equal(t, "Elite", e.rules[1].Class)
+
+	_, err = New(ctx, d, Config{Rules: []Rule{{Class: "Overlord"}}})
+	require.NotNil(t, err)
+
+	_, err = New(ctx, d, Config{Rules: []Rule{{Class: "User"}}})
+	require.NotNil(t, err)
+
+	_, err = New(ctx, d, Config{Rules: []Rule{{Class: "Elite"}, {Class: "Elite"}}})
+	require.NotNil(t, err)
+
+	_, err = New(ctx, d, Config{Rules: []Rule{{Class: "Elite", MinRatio: -1}}})
+	require.NotNil(t, err)
+
+	_, err = New(ctx, d, Config{Interval: -time.Hour})
+	require.NotNil(t, err)
+}
+
+func TestRunOnce(t *testing.T) {
+	ctx := context.Background()
+	d := memdb.New()
+
+	day := 24 * time.Hour
+	insertUser(t, d, 1, 0, 30*gib, 10*gib, 20*day)   // meets Power User
+	insertUser(t, d, 2, 0, 30*gib, 10*gib, 10*day)   // too young
+	insertUser(t, d, 3, 0, 200*gib, 0, 60*day)       // meets Elite, but no uploads
+	insertUser(t, d, 4, 2, 200*gib, 300*gib, 60*day) // Elite with a bad ratio
+	insertUser(t, d, 5, 3, 0, 300*gib, 60*day)       // Moderators are left alone
+
+	e := newEngine(t, d, testRules)
+
+	moved, err := e.RunOnce(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 3, moved)
+
+	require.Equal(t, 1, class(t, d, 1))
+	require.Equal(t, 0, class(t, d, 2))
+	require.Equal(t, 1, class(t, d, 3))
+	require.Equal(t, 0, class(t, d, 4))
+	require.Equal(t, 3, class(t, d, 5))
+
+	history, err := d.GetUserClassHistory(ctx, 1, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, 0, history[0].From)
+	require.Equal(t, 1, history[0].To)
+	require.Nil(t, history[0].ChangedBy)
+	require.Equal(t, "promoted: meets the requirements of Power User: uploaded 32212254720 bytes, ratio 3.00, account age 20 days, 0 uploads", history[0].Reason)
+
+	history, err = d.GetUserClassHistory(ctx, 4, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, "demoted: no longer meets the requirements of Elite: ratio 0.67, below 1.05", history[0].Reason)
+
+	// nothing changes on the next run
+	moved, err = e.RunOnce(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 0, moved)
+
+	// without rules, only members of the lowest class are evaluated
+	e = newEngine(t, d, nil)
+	moved, err = e.RunOnce(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 0, moved)
+}
+
+// racingDB moves a user to another class right after the stats are read.
+type racingDB struct {
+	*memdb.DB
+	user, class int
+}
+
+func (d racingDB) GetUserPromotionStats(ctx context.Context) ([]db.UserPromotionStats, error) {
+	stats, err := d.DB.GetUserPromotionStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.DB.SetUserClass(ctx, &db.UserClassChange{User: d.user, To: d.class, Changed: now, ChangedBy: &db.User{ID: d.user}})
+	return stats, err
+}
+
+func TestRunOnceConcurrentChange(t *testing.T) {
+	ctx := context.Background()
+	d := memdb.New()
+
+	day := 24 * time.Hour
+	insertUser(t, d, 1, 2, 200*gib, 300*gib, 60*day) // Elite with a bad ratio
+
+	// staff promote the user to Moderator while the engine runs
+	e := newEngine(t, racingDB{DB: d, user: 1, class: 3}, testRules)
+
+	moved, err := e.RunOnce(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 0, moved)
+	require.Equal(t, 3, class(t, d, 1))
+
+	count, err := d.CountUserClassHistory(ctx, 1)
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,204 @@
+// Package ratiowatch implements required ratios and the ratio watch.
+//
+// The ratio a user is required to keep depends on the amount they
+// downloaded, in tiers like Gazelle's.
+// Users under their required ratio are put on ratio watch for a grace
+// period.
+// If they are still under it after the grace period, they can't download
+// anymore until their ratio recovers.
+package ratiowatch
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/boilingrip/boiling-api/db"
+)
+
+const (
+	// DefaultInterval is the interval users are evaluated in if none is
+	// configured.
+	DefaultInterval = time.Hour
+
+	// DefaultGrace is the grace period if none is configured.
+	DefaultGrace = 14 * 24 * time.Hour
+)
+
+const gib = 1 << 30
+
+// A Tier is the required ratio for users who downloaded at least
+// MinDownloaded bytes.
+type Tier struct {
+	MinDownloaded int64
+	Required      float64
+}
+
+// Tiers are the required ratio tiers, ordered by MinDownloaded.
+// They are Gazelle's required ratios for users who seed none of their
+// snatches.
+var Tiers = []Tier{
+	{MinDownloaded: 0, Required: 0},
+	{MinDownloaded: 5 * gib, Required: 0.15},
+	{MinDownloaded: 10 * gib, Required: 0.20},
+	{MinDownloaded: 20 * gib, Required: 0.30},
+	{MinDownloaded: 30 * gib, Required: 0.40},
+	{MinDownloaded: 40 * gib, Required: 0.50},
+	{MinDownloaded: 50 * gib, Required: 0.60},
+}
+
+// Required returns the required ratio for a user who downloaded the given
+// amount of bytes.
+func Required(downloaded int64) float64 {
+	required := 0.0
+	for _, t := range Tiers {
+		if downloaded < t.MinDownloaded {
+			break
+		}
+		required = t.Required
+	}
+	return required
+}
+
+// Under reports whether a user is under their required ratio.
+// Users who never downloaded anything never are.
+func Under(uploaded, downloaded int64) bool {
+	if downloaded == 0 {
+		return false
+	}
+	return float64(uploaded)/float64(downloaded) < Required(downloaded)
+}
+
+// A Status is the ratio watch status of a user.
+type Status string
+
+const (
+	// StatusOK is the status of users who are not under their required
+	// ratio.
+	StatusOK Status = "ok"
+
+	// StatusWatch is the status of users who are under their required ratio
+	// and within their grace period, or not yet on ratio watch.
+	StatusWatch Status = "watch"
+
+	// StatusDisabled is the status of users who stayed under their required
+	// ratio beyond their grace period. They can't download.
+	StatusDisabled Status = "disabled"
+)
+
+// StatusOf returns the ratio watch status of a user at the given time.
+// Only Uploaded, Downloaded and RatioWatchUntil of u are used.
+func StatusOf(u *db.User, now time.Time) Status {
+	if !Under(u.Uploaded, u.Downloaded) {
+		return StatusOK
+	}
+	if !u.RatioWatchUntil.Valid || now.Before(u.RatioWatchUntil.Time) {
+		return StatusWatch
+	}
+	return StatusDisabled
+}
+
+// DownloadsDisabled reports whether a user lost their download rights.
+func DownloadsDisabled(u *db.User, now time.Time) bool {
+	return StatusOf(u, now) == StatusDisabled
+}
+
+type Config struct {
+	// Interval is the time between two evaluations of all users.
+	// If it is zero, DefaultInterval is used.
+	Interval time.Duration
+
+	// Grace is the time users on ratio watch have to get back to their
+	// required ratio.
+	// If it is zero, DefaultGrace is used.
+	Grace time.Duration
+}
+
+// An Engine periodically puts users on ratio watch and takes them off it.
+type Engine struct {
+	db       db.BoilingDB
+	interval time.Duration
+	grace    time.Duration
+
+	now func() time.Time
+}
+
+func New(d db.BoilingDB, cfg Config) (*Engine, error) {
+	if cfg.Interval < 0 {
+		return nil, errors.New("interval must not be negative")
+	}
+	if cfg.Grace < 0 {
+		return nil, errors.New("grace period must not be negative")
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Grace == 0 {
+		cfg.Grace = DefaultGrace
+	}
+
+	return &Engine{
+		db:       d,
+		interval: cfg.Interval,
+		grace:    cfg.Grace,
+		now:      time.Now,
+	}, nil
+}
+
+// RunOnce evaluates all users once.
+// Users under their required ratio are put on ratio watch, users who are
+// back to it are taken off it.
+// It returns the number of users whose ratio watch changed.
+func (e *Engine) RunOnce(ctx context.Context) (int, error) {
+	stats, err := e.db.GetUserRatioStats(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := e.now()
+	changed := 0
+	for _, s := range stats {
+		under := Under(s.Uploaded, s.Downloaded)
+
+		var until pq.NullTime
+		switch {
+		case under && !s.RatioWatchUntil.Valid:
+			until = pq.NullTime{Time: now.Add(e.grace), Valid: true}
+			log.Infoln("putting user on ratio watch", log.Fields{"user": s.User, "until": until.Time})
+		case !under && s.RatioWatchUntil.Valid:
+			log.Infoln("taking user off ratio watch", log.Fields{"user": s.User})
+		default:
+			continue
+		}
+
+		err = e.db.UpdateUserSetRatioWatch(ctx, s.User, until)
+		if err != nil {
+			return changed, err
+		}
+		changed++
+	}
+
+	return changed, nil
+}
+
+// Run evaluates all users once per interval, until ctx is done.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := e.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warnln("unable to update ratio watches", log.Fields{"err": err})
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package ratiowatch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	"github.com/boilingrip/boiling-api/db"
+	"github.com/boilingrip/boiling-api/db/memdb"
+)
+
+var now = time.Date(2017, 10, 2, 12, 0, 0, 0, time.UTC)
+
+func TestRequired(t *testing.T) {
+	require.Equal(t, 0.0, Required(0))
+	require.Equal(t, 0.0, Required(5*gib-1))
+	require.Equal(t, 0.15, Required(5*gib))
+	require.Equal(t, 0.30, Required(25*gib))
+	require.Equal(t, 0.60, Required(50*gib))
+	require.Equal(t, 0.60, Required(5000*gib))
+
+	require.False(t, Under(0, 0))
+	require.False(t, Under(0, 1*gib))
+	require.True(t, Under(1*gib, 10*gib))
+	require.False(t, Under(2*gib, 10*gib))
+}
+
+func TestStatusOf(t *testing.T) {
+	u := db.User{Uploaded: 10 * gib, Downloaded: 10 * gib}
+	require.Equal(t, StatusOK, StatusOf(&u, now))
+
+	// not on ratio watch yet
+	u.Uploaded = 1 * gib
+	require.Equal(t, StatusWatch, StatusOf(&u, now))
+
+	u.RatioWatchUntil = pq.NullTime{Time: now.Add(time.Hour), Valid: true}
+	require.Equal(t, StatusWatch, StatusOf(&u, now))
+	require.False(t, DownloadsDisabled(&u, now))
+
+	u.RatioWatchUntil.Time = now
+	require.Equal(t, StatusDisabled, StatusOf(&u, now))
+	require.True(t, DownloadsDisabled(&u, now))
+
+	// recovered, but still on ratio watch until the next run
+	u.Uploaded = 10 * gib
+	require.Equal(t, StatusOK, StatusOf(&u, now))
+}
+
+func insertUser(t *testing.T, d *memdb.DB, id int, uploaded, downloaded int64, until pq.NullTime) {
+	err := d.InsertUser(db.User{
+		ID:              id,
+		Username:        fmt.Sprintf("user%d", id),
+		Email:           fmt.Sprintf("user%d@boiling.rip", id),
+		Enabled:         true,
+		CanLogin:        true,
+		JoinedAt:        now,
+		Uploaded:        uploaded,
+		Downloaded:      downloaded,
+		RatioWatchUntil: until,
+	})
+	require.Nil(t, err)
+}
+
+func TestRunOnce(t *testing.T) {
+	ctx := context.Background()
+	d := memdb.New()
+
+	watched := pq.NullTime{Time: now.Add(-time.Hour), Valid: true}
+	insertUser(t, d, 1, 10*gib, 10*gib, pq.NullTime{}) // fine
+	insertUser(t, d, 2, 1*gib, 10*gib, pq.NullTime{})  // under
+	insertUser(t, d, 3, 1*gib, 10*gib, watched)        // still under
+	insertUser(t, d, 4, 10*gib, 10*gib, watched)       // recovered
+
+	e, err := New(d, Config{})
+	require.Nil(t, err)
+	e.now = func() time.Time { return now }
+
+	changed, err := e.RunOnce(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 2, changed)
+
+	u, err := d.GetUser(ctx, 1)
+	require.Nil(t, err)
+	require.False(t, u.RatioWatchUntil.Valid)
+
+	u, err = d.GetUser(ctx, 2)
+	require.Nil(t, err)
+	require.True(t, u.RatioWatchUntil.Valid)
+	require.True(t, now.Add(DefaultGrace).Equal(u.RatioWatchUntil.Time))
+	require.Equal(t, StatusWatch, StatusOf(u, now))
+
+	// the deadline is not extended
+	u, err = d.GetUser(ctx, 3)
+	require.Nil(t, err)
+	require.True(t, watched.Time.Equal(u.RatioWatchUntil.Time))
+	require.Equal(t, StatusDisabled, StatusOf(u, now))
+
+	u, err = d.GetUser(ctx, 4)
+	require.Nil(t, err)
+	require.False(t, u.RatioWatchUntil.Valid)
+
+	changed, err = e.RunOnce(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 0, changed)
+
+	_, err = New(d, Config{Grace: -time.Hour})
+	require.NotNil(t, err)
+}
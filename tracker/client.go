@@ -0,0 +1,185 @@
+// Package tracker implements a client for the internal tracker API of boiling.
+//
+// It is meant to be imported by a tracker middleware, for example a chihaya
+// PreHook, to authorize announces against the boiling database.
+// The package deliberately has no dependencies on the rest of boiling.
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SecretHeader is the HTTP header carrying the shared secret.
+const SecretHeader = "X-Tracker-Secret"
+
+// AuthorizationResponse is the response of the authorize endpoint.
+type AuthorizationResponse struct {
+	// Allowed indicates whether the announce is allowed.
+	Allowed bool `json:"allowed"`
+
+	// Reason describes why an announce is not allowed.
+	// It is suitable to be sent to the client as a failure reason.
+	Reason string `json:"reason,omitempty"`
+
+	// UserID is the ID of the user owning the passkey.
+	// It is only set if the passkey is valid.
+	UserID int `json:"user_id,omitempty"`
+
+	// LeechType is the leech type of the torrent, for example "Freeleech".
+	// It is only set if the torrent is known.
+	LeechType string `json:"leech_type,omitempty"`
+}
+
+// Announce is a single announce reported to the API.
+// Uploaded and Downloaded are the amounts transferred since the peer's last
+// announce, not the totals reported by the client.
+type Announce struct {
+	Passkey    string    `json:"passkey"`
+	InfoHash   string    `json:"info_hash"` // hex encoded
+	Uploaded   int64     `json:"uploaded"`
+	Downloaded int64     `json:"downloaded"`
+	Left       int64     `json:"left"`
+	Event      string    `json:"event,omitempty"` // started, completed, stopped or empty
+	ReportedAt time.Time `json:"reported_at"`
+}
+
+// ReportRequest is the request body of the announces endpoint.
+type ReportRequest struct {
+	Announces []Announce `json:"announces"`
+}
+
+// ReportResponse is the response of the announces endpoint.
+type ReportResponse struct {
+	// Recorded is the number of announces recorded.
+	// Announces with unknown passkeys or info hashes are not recorded.
+	Recorded int `json:"recorded"`
+}
+
+// MaxAnnouncesPerReport is the maximum number of announces in one report.
+const MaxAnnouncesPerReport = 1000
+
+type response struct {
+	Status  string          `json:"status"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// Client is a client for the internal tracker API.
+// It is safe for concurrent use.
+type Client struct {
+	base   string
+	secret string
+	client *http.Client
+}
+
+// NewClient creates a new client.
+// base is the base URL of the API, for example https://api.boiling.rip:8443.
+// If c is nil, http.DefaultClient is used.
+func NewClient(base, secret string, c *http.Client) *Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	return &Client{
+		base:   strings.TrimSuffix(base, "/"),
+		secret: secret,
+		client: c,
+	}
+}
+
+func (c *Client) do(req *http.Request, data interface{}) error {
+	req.Header.Set(SecretHeader, c.secret)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r response
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return fmt.Errorf("unable to decode response (HTTP %d): %s", resp.StatusCode, err)
+	}
+
+	if r.Status != "success" {
+		if len(r.Message) == 0 {
+			r.Message = "unknown error"
+		}
+		return fmt.Errorf("%s (HTTP %d): %s", r.Status, resp.StatusCode, r.Message)
+	}
+
+	if data == nil {
+		return nil
+	}
+	if len(r.Data) == 0 {
+		return errors.New("missing data in response")
+	}
+
+	return json.Unmarshal(r.Data, data)
+}
+
+// Authorize asks the API whether an announce with the given passkey for the
+// given info hash is allowed.
+// left is the number of bytes the peer still has to download, peers with
+// nothing left are seeding.
+// An error is only returned if the API could not be queried - a denied
+// announce is indicated by Allowed being false.
+func (c *Client) Authorize(ctx context.Context, passkey string, infoHash [20]byte, left int64) (*AuthorizationResponse, error) {
+	q := url.Values{}
+	q.Set("passkey", passkey)
+	q.Set("info_hash", hex.EncodeToString(infoHash[:]))
+	q.Set("left", strconv.FormatInt(left, 10))
+
+	req, err := http.NewRequest(http.MethodGet, c.base+"/internal/tracker/authorize?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var a AuthorizationResponse
+	err = c.do(req.WithContext(ctx), &a)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// Report reports a batch of announces to the API.
+// The batch is recorded atomically, either all valid announces are recorded or
+// none.
+// At most MaxAnnouncesPerReport announces can be reported at once.
+func (c *Client) Report(ctx context.Context, announces []Announce) (*ReportResponse, error) {
+	if len(announces) > MaxAnnouncesPerReport {
+		return nil, errors.New("too many announces")
+	}
+
+	body, err := json.Marshal(ReportRequest{Announces: announces})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.base+"/internal/tracker/announces", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var r ReportResponse
+	err = c.do(req.WithContext(ctx), &r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
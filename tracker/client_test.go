@@ -0,0 +1,90 @@
+package tracker
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	infoHash := [20]byte{1, 2, 3}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/internal/tracker/authorize", r.URL.Path)
+
+		if r.Header.Get(SecretHeader) != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":  "fail",
+				"message": "invalid secret",
+			})
+			return
+		}
+
+		require.Equal(t, hex.EncodeToString(infoHash[:]), r.URL.Query().Get("info_hash"))
+		require.Equal(t, "42", r.URL.Query().Get("left"))
+
+		resp := AuthorizationResponse{Allowed: false, Reason: "unknown passkey"}
+		if r.URL.Query().Get("passkey") == "good" {
+			resp = AuthorizationResponse{Allowed: true, UserID: 3, LeechType: "Freeleech"}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   resp,
+		})
+	}))
+	defer s.Close()
+
+	c := NewClient(s.URL+"/", "secret", nil)
+
+	a, err := c.Authorize(context.Background(), "good", infoHash, 42)
+	require.Nil(t, err)
+	require.True(t, a.Allowed)
+	require.Equal(t, 3, a.UserID)
+	require.Equal(t, "Freeleech", a.LeechType)
+
+	a, err = c.Authorize(context.Background(), "bad", infoHash, 42)
+	require.Nil(t, err)
+	require.False(t, a.Allowed)
+	require.Equal(t, "unknown passkey", a.Reason)
+
+	c = NewClient(s.URL, "wrong", nil)
+	_, err = c.Authorize(context.Background(), "good", infoHash, 42)
+	require.NotNil(t, err)
+}
+
+func TestReport(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/internal/tracker/announces", r.URL.Path)
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "secret", r.Header.Get(SecretHeader))
+
+		var req ReportRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		require.Nil(t, err)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   ReportResponse{Recorded: len(req.Announces)},
+		})
+	}))
+	defer s.Close()
+
+	c := NewClient(s.URL, "secret", nil)
+
+	resp, err := c.Report(context.Background(), []Announce{
+		{Passkey: "a", InfoHash: "00", Uploaded: 1},
+		{Passkey: "b", InfoHash: "00", Downloaded: 1, Event: "completed"},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, resp.Recorded)
+
+	_, err = c.Report(context.Background(), make([]Announce, MaxAnnouncesPerReport+1))
+	require.NotNil(t, err)
+}